@@ -0,0 +1,67 @@
+// Package cmd contains the openapi command.
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hewen/mastiff-go/cmd/mastiffgen/internal/scaffold"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.AddCommand(OpenAPICmd())
+}
+
+// errNoOpenAPICommand is returned when the project has no openapi command, such as projects
+// generated before it was added to the scaffold.
+var errNoOpenAPICommand = errors.New(`project has no openapi command, add cmd/openapi.go as generated by "mastiffgen init"`)
+
+// runCommand runs the command, replaced in tests.
+var runCommand = func(c *exec.Cmd) error {
+	return c.Run()
+}
+
+// OpenAPICmd returns the openapi command, exporting the OpenAPI document of the HTTP routes of a
+// generated project with its own openapi command.
+func OpenAPICmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "openapi",
+		Short: "Export the OpenAPI document of a project",
+		RunE:  runOpenAPICmd,
+	}
+	cmd.Flags().StringP("dir", "d", ".", "Project directory")
+	cmd.Flags().StringP("output", "o", "openapi.json", `Output file, "-" for stdout`)
+	return cmd
+}
+
+func runOpenAPICmd(cmd *cobra.Command, _ []string) error {
+	projectDir, _ := cmd.Flags().GetString("dir")
+	output, _ := cmd.Flags().GetString("output")
+
+	if output != "-" {
+		abs, err := filepath.Abs(scaffold.ExpandPath(output))
+		if err != nil {
+			return fmt.Errorf("failed to resolve output file: %v", err)
+		}
+		output = abs
+	}
+
+	c := exec.Command("go", "run", ".", "openapi", "--output", output) // #nosec G204 -- runs the project's own command
+	c.Dir = scaffold.ExpandPath(projectDir)
+	c.Stdout = cmd.OutOrStdout()
+	var stderr bytes.Buffer
+	c.Stderr = io.MultiWriter(cmd.OutOrStderr(), &stderr)
+	if err := runCommand(c); err != nil {
+		if strings.Contains(stderr.String(), `unknown command "openapi"`) {
+			return errNoOpenAPICommand
+		}
+		return fmt.Errorf("failed to export the OpenAPI document: %v", err)
+	}
+	return nil
+}
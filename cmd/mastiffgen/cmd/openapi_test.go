@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubRunCommand replaces runCommand with fn for the test.
+func stubRunCommand(t *testing.T, fn func(c *exec.Cmd) error) {
+	t.Helper()
+	run := runCommand
+	runCommand = fn
+	t.Cleanup(func() {
+		runCommand = run
+	})
+}
+
+func TestRunOpenAPICmd(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	var ran *exec.Cmd
+	stubRunCommand(t, func(c *exec.Cmd) error {
+		ran = c
+		return nil
+	})
+
+	cmd := OpenAPICmd()
+	assert.NoError(t, cmd.Flags().Set("dir", tmpDir))
+	assert.NoError(t, cmd.Flags().Set("output", "api.json"))
+	assert.NoError(t, runOpenAPICmd(cmd, []string{}))
+
+	output, err := filepath.Abs("api.json")
+	assert.NoError(t, err)
+	assert.Equal(t, tmpDir, ran.Dir)
+	assert.Equal(t, []string{"go", "run", ".", "openapi", "--output", output}, ran.Args)
+}
+
+func TestRunOpenAPICmd_Stdout(t *testing.T) {
+	var ran *exec.Cmd
+	stubRunCommand(t, func(c *exec.Cmd) error {
+		ran = c
+		return nil
+	})
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("dir", ".", "")
+	cmd.Flags().String("output", "-", "")
+	assert.NoError(t, runOpenAPICmd(cmd, []string{}))
+	assert.Equal(t, []string{"go", "run", ".", "openapi", "--output", "-"}, ran.Args)
+}
+
+func TestRunOpenAPICmd_RunFail(t *testing.T) {
+	stubRunCommand(t, func(*exec.Cmd) error {
+		return errors.New("exit status 1")
+	})
+
+	err := runOpenAPICmd(OpenAPICmd(), []string{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to export the OpenAPI document")
+}
+
+func TestRunOpenAPICmd_NoCommand(t *testing.T) {
+	stubRunCommand(t, func(c *exec.Cmd) error {
+		_, _ = fmt.Fprintln(c.Stderr, `Error: unknown command "openapi" for "demo"`)
+		return errors.New("exit status 1")
+	})
+
+	cmd := OpenAPICmd()
+	cmd.SetOutput(io.Discard)
+	assert.ErrorIs(t, runOpenAPICmd(cmd, []string{}), errNoOpenAPICommand)
+}
@@ -20,16 +20,21 @@ type Config struct {
 	RPC    *serverconf.RPCConfig
 	Socket *serverconf.SocketConfig
 	Queue  *serverconf.QueueConfig
+	Cron   *serverconf.CronConfig
 	Mysql  *storeconf.MysqlConfig
 	Redis  *storeconf.RedisConfig
 	Custom any
 }
 
 var (
-	cfg   *Config      // Global config instance
-	mutex sync.RWMutex // Protects cfg
+	cfg    *Config      // Global config instance
+	reload func() error // Re-reads the config file of the last LoadConfig
+	mutex  sync.RWMutex // Protects cfg and reload
 )
 
+// ErrNotLoaded is returned by Reload before LoadConfig succeeded.
+var ErrNotLoaded = errors.New("config not loaded")
+
 // LoadConfig loads the application configuration from file, unmarshals it into Config,
 // binds custom config, and watches for live updates. The onChange callback will be called
 // whenever the config file changes: - If changeErr is nil, newConfig contains the updated
@@ -54,15 +59,27 @@ func LoadConfig(configPath string, custom any, onUpdate func(newConfig *Config,
 
 	setConfig(&c)
 
-	v.OnConfigChange(func(_ fsnotify.Event) {
+	// the watcher and Reload read the file with a viper of their own, as v is read by the
+	// watcher goroutine of viper, which is not safe for concurrent use and keeps the last
+	// config when the file fails to parse. One lock serialises their reads and updates.
+	var reloadMu sync.Mutex
+	reloadConfig := func() error {
+		reloadMu.Lock()
+		defer reloadMu.Unlock()
+
 		var newC Config
 		newC.Custom = custom
 
-		if err := UnmarshalAll(v, &newC, custom); err != nil {
+		rv, err := newViper(configPath)
+		if err == nil {
+			err = UnmarshalAll(rv, &newC, custom)
+		}
+		if err != nil {
+			err = fmt.Errorf("config reload error: %w", err)
 			if onUpdate != nil {
-				onUpdate(nil, fmt.Errorf("config reload error: %w", err))
+				onUpdate(nil, err)
 			}
-			return
+			return err
 		}
 
 		setConfig(&newC)
@@ -70,7 +87,13 @@ func LoadConfig(configPath string, custom any, onUpdate func(newConfig *Config,
 		if onUpdate != nil {
 			onUpdate(&newC, nil)
 		}
+		return nil
+	}
+
+	v.OnConfigChange(func(_ fsnotify.Event) {
+		_ = reloadConfig()
 	})
+	setReload(reloadConfig)
 
 	v.WatchConfig()
 	if onUpdate != nil {
@@ -89,6 +112,20 @@ func MustLoad(configPath string, custom any) *Config {
 	return c
 }
 
+// Reload re-reads the config file of the last LoadConfig, such as on SIGHUP, and calls its
+// onUpdate callback like a change of the file does. It is thread-safe: reloads and changes
+// of the file are applied one at a time.
+func Reload() error {
+	mutex.RLock()
+	fn := reload
+	mutex.RUnlock()
+
+	if fn == nil {
+		return ErrNotLoaded
+	}
+	return fn()
+}
+
 // GetConfig safely returns the current configuration. It is thread-safe.
 func GetConfig() *Config {
 	mutex.RLock()
@@ -107,6 +144,12 @@ func setConfig(c *Config) {
 	cfg = c
 }
 
+func setReload(fn func() error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	reload = fn
+}
+
 func newViper(path string) (*viper.Viper, error) {
 	v := viper.New()
 	v.SetConfigFile(path)
@@ -234,3 +234,45 @@ func TestUnmarshalAll_BaseConfigError(t *testing.T) {
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "error unmarshaling base config")
 }
+
+// replaceFile atomically replaces the content of the file at path.
+func replaceFile(t *testing.T, path, content string) {
+	t.Helper()
+	tmp := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, []byte(content), 0600))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+func TestReload(t *testing.T) {
+	setReload(nil)
+	assert.ErrorIs(t, Reload(), ErrNotLoaded)
+
+	path := createTempConfigFile(t, `
+http:
+  addr: ":8080"
+`)
+	var mu sync.Mutex
+	var updates int
+	_, err := LoadConfig(path, nil, func(_ *Config, _ error) {
+		mu.Lock()
+		defer mu.Unlock()
+		updates++
+	})
+	require.NoError(t, err)
+
+	// the file is replaced at once, so the watcher never reads it half written.
+	replaceFile(t, path, `
+http:
+  addr: ":9090"
+`)
+	require.NoError(t, Reload())
+	assert.Equal(t, ":9090", GetConfig().HTTP.Addr)
+
+	replaceFile(t, path, "http: [")
+	assert.Error(t, Reload())
+	assert.Equal(t, ":9090", GetConfig().HTTP.Addr)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.GreaterOrEqual(t, updates, 2)
+}
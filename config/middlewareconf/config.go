@@ -23,6 +23,10 @@ type Config struct {
 	EnableRecovery *bool
 	// Enable logging middleware, default enabled
 	EnableLogging *bool
+	// Enable errorx interceptor converting handler errors to gRPC statuses, default disabled.
+	// Once enabled, errors that are not an errorx.Error or a status reach clients as Internal
+	// "internal error" instead of Unknown with their message.
+	EnableErrorx *bool
 }
 
 // SetDefaults sets default values for the configuration.
@@ -39,6 +43,10 @@ func (c *Config) SetDefaults() {
 		b := false
 		c.EnableMetrics = &b
 	}
+	if c.EnableErrorx == nil {
+		b := false
+		c.EnableErrorx = &b
+	}
 	if c.TimeoutSeconds == nil {
 		d := 30
 		c.TimeoutSeconds = &d
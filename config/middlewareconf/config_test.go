@@ -10,5 +10,6 @@ func TestSetDefaults(t *testing.T) {
 	var conf Config
 	conf.SetDefaults()
 	assert.Equal(t, true, *conf.EnableRecovery)
+	assert.Equal(t, false, *conf.EnableErrorx)
 	assert.Equal(t, 30, *conf.TimeoutSeconds)
 }
@@ -22,6 +22,9 @@ type (
 	// SocketFrameworkType represents the type of framework used for the RPC server.
 	SocketFrameworkType string
 
+	// CronOverlapPolicy represents what a cron server does when a job is due while its previous run is still running.
+	CronOverlapPolicy string
+
 	// HTTPConfig holds the configuration for an HTTP server.
 	HTTPConfig struct {
 		// Middlewares represents the configuration for middlewares.
@@ -30,7 +33,7 @@ type (
 		Addr string
 		// Mode represents the server mode, either "debug", "release", or "test".
 		Mode string
-		// FrameworkType either "gin", "fiber".
+		// FrameworkType either "gin", "fiber", "std".
 		FrameworkType HTTPFrameworkType
 		// TimeoutRead represents the timeout for reading requests in milliseconds.
 		ReadTimeout int64
@@ -62,10 +65,51 @@ type (
 	QueueConfig struct {
 		// QueueName represents the queue name.
 		QueueName string
+		// ConsumerGroup represents the consumer group of a stream queue.
+		ConsumerGroup string
+		// ConsumerName represents the consumer name, unique per consumer process.
+		ConsumerName string
 		// PoolSize represents the size of the goroutine pool for processing queue messages.
 		PoolSize int
 		// EmptySleepInterval represents the duration to sleep when the queue is empty.
 		EmptySleepInterval time.Duration
+		// StreamMaxLen represents the approximate number of entries a stream queue is trimmed to on every push, unbounded if 0.
+		// Entries trimmed before they are acknowledged are lost, so it must stay well above the backlog.
+		StreamMaxLen int64
+		// VisibilityTimeout represents how long a popped message may stay unacknowledged before it is re-queued.
+		VisibilityTimeout time.Duration
+		// MaxAttempts represents the maximum number of deliveries of a message before it is given up.
+		MaxAttempts int
+		// RetryBackoff represents the delay before the first retry of a failed message, doubled on every attempt.
+		RetryBackoff time.Duration
+		// RetryMaxBackoff represents the upper bound of the retry delay.
+		RetryMaxBackoff time.Duration
+		// RecoverInterval represents the interval for re-queueing expired in-flight messages and promoting due delayed messages.
+		RecoverInterval time.Duration
+		// BatchSize represents the maximum number of messages handled in one batch by a batch handler.
+		BatchSize int
+		// BatchMaxWait represents the maximum duration spent filling a batch before it is handled.
+		BatchMaxWait time.Duration
+		// Capacity represents the maximum number of messages held by an in-memory queue.
+		Capacity int
+		// PersistPath represents the file an in-memory queue is saved to when closed and restored from when created, disabled if empty.
+		PersistPath string
+		// DrainTimeout represents how long Stop waits for in-flight messages before pushing them back to the queue.
+		DrainTimeout time.Duration
+		// MetricsInterval represents the interval for polling the queue depth and pool gauges.
+		MetricsInterval time.Duration
+		// PriorityLevels represents the number of priority levels of a priority queue, 0 being the highest.
+		PriorityLevels int
+		// PriorityWeights represents the relative share of pops each priority level is tried first, strict priority order if empty.
+		PriorityWeights []int
+		// IdempotencyTTL represents how long the keys of handled messages are remembered for skipping duplicates.
+		IdempotencyTTL time.Duration
+		// RateLimit represents the maximum number of messages handled per second, unlimited if 0.
+		RateLimit float64
+		// RateBurst represents the maximum number of messages handled at once above RateLimit.
+		RateBurst int
+		// MaxConcurrency represents the maximum number of messages handled concurrently, bounded by PoolSize only if 0.
+		MaxConcurrency int
 	}
 
 	// SocketConfig holds the configuration for a socket server.
@@ -79,6 +123,32 @@ type (
 		// TickInterval represents the interval for the tick function.
 		TickInterval time.Duration
 	}
+
+	// CronConfig holds the configuration for a cron server.
+	CronConfig struct {
+		// Jobs represents the schedules of the jobs, matched to job functions by name.
+		Jobs []CronJobConfig
+		// Location represents the time zone of the schedules, such as "Asia/Shanghai", the local time zone if empty.
+		Location string
+		// LeaderKey represents the Redis key of the leader lock, used when the leader lock is enabled.
+		LeaderKey string
+		// LeaderTTL represents how long the leader lock is held without being renewed.
+		LeaderTTL time.Duration
+	}
+
+	// CronJobConfig holds the schedule of a cron job.
+	CronJobConfig struct {
+		// Name represents the name the job function is registered with.
+		Name string
+		// Spec represents the cron expression with an optional leading seconds field, or a descriptor such as "@every 1m".
+		Spec string
+		// Overlap represents the overlap policy, either "skip", "queue" or "allow", default "skip".
+		Overlap CronOverlapPolicy
+		// Timeout represents the maximum duration of a run, unlimited if 0.
+		Timeout time.Duration
+		// Disabled represents whether the job is not scheduled.
+		Disabled bool
+	}
 )
 
 const (
@@ -86,6 +156,8 @@ const (
 	FrameworkGin HTTPFrameworkType = "gin"
 	// FrameworkFiber represents the type of framework used for the HTTP server, which is Fiber.
 	FrameworkFiber HTTPFrameworkType = "fiber"
+	// FrameworkStd represents the type of framework used for the HTTP server, which is net/http ServeMux.
+	FrameworkStd HTTPFrameworkType = "std"
 
 	// FrameworkGrpc represents the type of framework used for the rpc server, which is gRPC.
 	FrameworkGrpc RPCFrameworkType = "grpc"
@@ -94,6 +166,13 @@ const (
 
 	// FrameworkGnet represents the type of framework used for the socket server, which is gnet.
 	FrameworkGnet SocketFrameworkType = "gnet"
+
+	// OverlapSkip skips a run that is due while the previous one is still running.
+	OverlapSkip CronOverlapPolicy = "skip"
+	// OverlapQueue delays a run that is due until the previous one has finished.
+	OverlapQueue CronOverlapPolicy = "queue"
+	// OverlapAllow starts a run that is due even if the previous one is still running.
+	OverlapAllow CronOverlapPolicy = "allow"
 )
 
 // SetDefault sets default values for the configuration.
@@ -0,0 +1,29 @@
+// Package handler provides a context interface for HTTP handlers.
+package handler
+
+import (
+	"reflect"
+	"slices"
+
+	"github.com/hewen/mastiff-go/server/httpx/handler"
+	"github.com/hewen/mastiff-go/server/httpx/openapi"
+)
+
+// Handle adds a route with the given method and path to the group, running the middlewares then
+// the handler function wrapped by WrapHandler. Unlike the routes added with the methods of the
+// group, the route is recorded with the types of its request and response, and described in the
+// OpenAPI document served by handler.WithOpenAPI.
+func Handle[T any, R any](group handler.RouterGroup, method, path string, handle WrapHandlerFunc[T, R],
+	middlewares ...handler.HTTPHandlerFunc) {
+	group.Handle(method, path, append(slices.Clip(middlewares), WrapHandler(handle))...)
+
+	if r, ok := group.(handler.RouteRecorder); ok {
+		r.RecordRoute(openapi.Route{
+			Method:   method,
+			Path:     path,
+			Request:  reflect.TypeFor[T](),
+			Response: reflect.TypeFor[RespWithData[R]](),
+			Error:    reflect.TypeFor[ErrResp](),
+		})
+	}
+}
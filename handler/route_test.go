@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	httpxhandler "github.com/hewen/mastiff-go/server/httpx/handler"
+	"github.com/hewen/mastiff-go/server/httpx/openapi"
+	"github.com/hewen/mastiff-go/server/httpx/unicontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandle(t *testing.T) {
+	for _, framework := range []serverconf.HTTPFrameworkType{
+		serverconf.FrameworkGin,
+		serverconf.FrameworkFiber,
+		serverconf.FrameworkStd,
+	} {
+		t.Run(string(framework), func(t *testing.T) {
+			h, err := httpxhandler.NewHandler(&serverconf.HTTPConfig{FrameworkType: framework, Mode: "test"},
+				httpxhandler.WithOpenAPI(openapi.Info{Title: "search", Version: "1.0.0"}))
+			require.NoError(t, err)
+
+			var called int
+			middlewares := make([]httpxhandler.HTTPHandlerFunc, 1, 2)
+			middlewares[0] = func(c unicontext.UniversalContext) error {
+				called++
+				return c.Next()
+			}
+			Handle(h.Group("/api"), http.MethodGet, "/search/:name",
+				func(_ unicontext.UniversalContext, req SearchRequest) (SearchRequest, error) {
+					return req, nil
+				}, middlewares...)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/search/mastiff?page=2", nil)
+			req.Header.Set("X-Token", "secret")
+			resp, err := h.Test(req)
+			require.NoError(t, err)
+			var ok RespWithData[SearchRequest]
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&ok))
+			_ = resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, 1, called)
+			// the spare capacity of the middlewares of the caller is left untouched.
+			assert.Nil(t, middlewares[:2][1])
+			assert.Equal(t, SearchRequest{Token: "secret", Name: "mastiff", Page: 2}, ok.Data)
+
+			r, isRecorder := h.(httpxhandler.RouteRecorder)
+			require.True(t, isRecorder)
+			assert.Equal(t, []openapi.Route{{
+				Method:   http.MethodGet,
+				Path:     "/api/search/:name",
+				Request:  reflect.TypeFor[SearchRequest](),
+				Response: reflect.TypeFor[RespWithData[SearchRequest]](),
+				Error:    reflect.TypeFor[ErrResp](),
+			}}, r.Routes().Routes())
+
+			resp, err = h.Test(httptest.NewRequest(http.MethodGet, openapi.DocumentPath, nil))
+			require.NoError(t, err)
+			var doc openapi.Document
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+			_ = resp.Body.Close()
+			op := doc.Paths["/api/search/{name}"]["get"]
+			require.NotNil(t, op)
+			assert.Len(t, op.Parameters, 3)
+			assert.Contains(t, op.Responses, "200")
+			assert.Contains(t, op.Responses, "400")
+			assert.Contains(t, doc.Components.Schemas, "RespWithData_SearchRequest")
+			assert.Contains(t, doc.Components.Schemas, "ErrResp")
+		})
+	}
+}
+
+// plainGroup is a RouterGroup not recording its routes.
+type plainGroup struct {
+	httpxhandler.RouterGroup
+}
+
+func TestHandle_NotRecorded(t *testing.T) {
+	h, err := httpxhandler.NewHandler(&serverconf.HTTPConfig{FrameworkType: serverconf.FrameworkStd})
+	require.NoError(t, err)
+
+	Handle(plainGroup{h}, http.MethodPost, "/foo", FooHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/foo", nil)
+	resp, err := h.Test(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, h.(httpxhandler.RouteRecorder).Routes().Routes())
+}
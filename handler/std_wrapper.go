@@ -0,0 +1,16 @@
+// Package handler provides a context interface for HTTP handlers.
+package handler
+
+import (
+	"net/http"
+
+	"github.com/hewen/mastiff-go/server/httpx/unicontext"
+)
+
+// WrapHandlerStd wraps a handler function into a net/http handler.
+func WrapHandlerStd[T any, R any](handle WrapHandlerFunc[T, R]) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := unicontext.NewStdContext(w, r, WrapHandler(handle))
+		_ = ctx.Next()
+	}
+}
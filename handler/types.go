@@ -1,6 +1,8 @@
 // Package handler defines handler types.
 package handler
 
+import "github.com/hewen/mastiff-go/pkg/errorx"
+
 // BaseResp is a base response.
 type BaseResp struct {
 	Trace string `json:"trace"`
@@ -12,3 +14,12 @@ type RespWithData[T any] struct {
 	Data T `json:"data,omitempty"`
 	BaseResp
 }
+
+// ErrResp is an error response, listing the invalid fields of the request if any. Its code is
+// the business code of the error, or the HTTP status without one.
+type ErrResp struct {
+	Details map[string]string   `json:"details,omitempty"`
+	Message string              `json:"message,omitempty"`
+	Errors  []errorx.FieldError `json:"errors,omitempty"`
+	BaseResp
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -11,9 +12,12 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gofiber/fiber/v2"
 	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/pkg/errorx"
 	"github.com/hewen/mastiff-go/server/httpx"
+	httpxhandler "github.com/hewen/mastiff-go/server/httpx/handler"
 	"github.com/hewen/mastiff-go/server/httpx/unicontext"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
 )
 
 type FooRequest struct {
@@ -79,6 +83,28 @@ func TestWrapHandlerFiber(t *testing.T) {
 	assert.NotEmpty(t, respBody.Trace)
 }
 
+func TestWrapHandlerStd(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("POST /foo", WrapHandlerStd(FooHandler))
+
+	reqBody, _ := json.Marshal(FooRequest{Name: "Std"})
+
+	req := httptest.NewRequest(http.MethodPost, "/foo", bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp RespWithData[FooResponse]
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, Std", resp.Data.Message)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.NotEmpty(t, resp.Trace)
+}
+
 func TestWrapHandlerHttpx(t *testing.T) {
 	app, err := httpx.NewHTTPServer(&serverconf.HTTPConfig{
 		FrameworkType: serverconf.FrameworkGin,
@@ -110,12 +136,15 @@ func TestWrapHandlerHttpx(t *testing.T) {
 }
 
 func TestWrapHandler_Success(t *testing.T) {
+	var called bool
 	handlerFn := WrapHandler(func(_ unicontext.UniversalContext, req TestReq) (TestResp, error) {
-		assert.Equal(t, "Wen", req.Name)
+		called = true
+		assert.Empty(t, req.Name)
 		return TestResp{Greet: "Hello " + req.Name}, nil
 	})
 
-	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	w := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(w)
 	ginCtx.Request, _ = http.NewRequest("GET", "/", bytes.NewReader([]byte("")))
 	ctx := &unicontext.GinContext{
 		Ctx: ginCtx,
@@ -123,6 +152,51 @@ func TestWrapHandler_Success(t *testing.T) {
 
 	err := handlerFn(ctx)
 	assert.Nil(t, err)
+	// a request without body binds the zero value.
+	assert.True(t, called)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestWrapHandler_SuccessJSON(t *testing.T) {
+	handlerFn := WrapHandler(func(_ unicontext.UniversalContext, req TestReq) (TestResp, error) {
+		assert.Equal(t, "Wen", req.Name)
+		return TestResp{Greet: "Hello " + req.Name}, nil
+	})
+
+	w := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(w)
+	ginCtx.Request, _ = http.NewRequest("POST", "/", bytes.NewReader([]byte(`{"name":"Wen"}`)))
+	ctx := &unicontext.GinContext{
+		Ctx: ginCtx,
+	}
+
+	err := handlerFn(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestWrapHandler_UnsupportedMediaType(t *testing.T) {
+	handlerFn := WrapHandler(func(_ unicontext.UniversalContext, _ TestReq) (TestResp, error) {
+		t.Fatal("should not be called")
+		return TestResp{}, nil
+	})
+
+	w := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(w)
+	ginCtx.Request, _ = http.NewRequest("POST", "/", bytes.NewReader([]byte("Wen")))
+	ginCtx.Request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	ctx := &unicontext.GinContext{
+		Ctx: ginCtx,
+	}
+
+	err := handlerFn(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	var resp ErrResp
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.Code)
+	assert.Equal(t, `unsupported media type "text/plain"`, resp.Message)
 }
 
 func TestWrapHandler_BindError(t *testing.T) {
@@ -131,14 +205,23 @@ func TestWrapHandler_BindError(t *testing.T) {
 		return TestResp{}, nil
 	})
 
-	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
-	ginCtx.Request, _ = http.NewRequest("GET", "/", bytes.NewReader([]byte("")))
+	w := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(w)
+	ginCtx.Request, _ = http.NewRequest("POST", "/", bytes.NewReader([]byte(`{"name":`)))
+	ginCtx.Request.Header.Set("Content-Type", "application/json")
 	ctx := &unicontext.GinContext{
 		Ctx: ginCtx,
 	}
 
 	err := handlerFn(ctx)
 	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var resp ErrResp
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, "invalid request", resp.Message)
+	assert.Empty(t, resp.Errors)
 }
 
 func TestWrapHandler_HandlerError(t *testing.T) {
@@ -155,3 +238,111 @@ func TestWrapHandler_HandlerError(t *testing.T) {
 	err := handlerFn(ctx)
 	assert.Nil(t, err)
 }
+
+type SearchRequest struct {
+	Token string `header:"X-Token" validate:"required"`
+	Name  string `uri:"name"`
+	Page  int    `query:"page" validate:"min=1"`
+}
+
+func TestWrapHandler_Bind(t *testing.T) {
+	for _, framework := range []serverconf.HTTPFrameworkType{
+		serverconf.FrameworkGin,
+		serverconf.FrameworkFiber,
+		serverconf.FrameworkStd,
+	} {
+		t.Run(string(framework), func(t *testing.T) {
+			h, err := httpxhandler.NewHandler(&serverconf.HTTPConfig{FrameworkType: framework, Mode: "test"})
+			assert.NoError(t, err)
+			h.Get("/search/:name", WrapHandler(func(_ unicontext.UniversalContext, req SearchRequest) (SearchRequest, error) {
+				return req, nil
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/search/mastiff?page=2", nil)
+			req.Header.Set("X-Token", "secret")
+			resp, err := h.Test(req)
+			assert.NoError(t, err)
+			var ok RespWithData[SearchRequest]
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&ok))
+			_ = resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, SearchRequest{Token: "secret", Name: "mastiff", Page: 2}, ok.Data)
+
+			resp, err = h.Test(httptest.NewRequest(http.MethodGet, "/search/mastiff?page=0", nil))
+			assert.NoError(t, err)
+			var invalid ErrResp
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&invalid))
+			_ = resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			assert.Equal(t, "invalid request", invalid.Message)
+			assert.Equal(t, []errorx.FieldError{
+				{Field: "X-Token", Rule: "required", Message: "X-Token failed on the required rule"},
+				{Field: "page", Rule: "min", Param: "1", Message: "page failed on the min=1 rule"},
+			}, invalid.Errors)
+
+			resp, err = h.Test(httptest.NewRequest(http.MethodGet, "/search/mastiff?page=x", nil))
+			assert.NoError(t, err)
+			invalid = ErrResp{}
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&invalid))
+			_ = resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			assert.Equal(t, []errorx.FieldError{
+				{Field: "page", Rule: "type", Message: "page is not a valid int"},
+			}, invalid.Errors)
+		})
+	}
+}
+
+func TestWrapHandler_Errors(t *testing.T) {
+	errUserNotFound := errorx.New(codes.NotFound, "user not found").WithCode(40401)
+
+	for _, framework := range []serverconf.HTTPFrameworkType{
+		serverconf.FrameworkGin,
+		serverconf.FrameworkFiber,
+		serverconf.FrameworkStd,
+	} {
+		t.Run(string(framework), func(t *testing.T) {
+			h, err := httpxhandler.NewHandler(&serverconf.HTTPConfig{FrameworkType: framework, Mode: "test"})
+			assert.NoError(t, err)
+			h.Get("/users/:id", WrapHandler(func(_ unicontext.UniversalContext, req struct {
+				ID string `uri:"id"`
+			}) (FooResponse, error) {
+				switch req.ID {
+				case "conflict":
+					return FooResponse{}, errorx.New(codes.AlreadyExists, "user exists")
+				case "internal":
+					return FooResponse{}, errors.New("secret")
+				default:
+					return FooResponse{}, fmt.Errorf("get user: %w", errUserNotFound.WithDetail("id", req.ID))
+				}
+			}))
+
+			get := func(target string) (int, ErrResp) {
+				resp, err := h.Test(httptest.NewRequest(http.MethodGet, target, nil))
+				assert.NoError(t, err)
+				defer func() { _ = resp.Body.Close() }()
+				var body ErrResp
+				assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.NotEmpty(t, body.Trace)
+				body.Trace = ""
+				return resp.StatusCode, body
+			}
+
+			code, body := get("/users/1")
+			assert.Equal(t, http.StatusNotFound, code)
+			assert.Equal(t, ErrResp{
+				Message:  "user not found",
+				Details:  map[string]string{"id": "1"},
+				BaseResp: BaseResp{Code: 40401},
+			}, body)
+
+			code, body = get("/users/conflict")
+			assert.Equal(t, http.StatusConflict, code)
+			assert.Equal(t, ErrResp{Message: "user exists", BaseResp: BaseResp{Code: http.StatusConflict}}, body)
+
+			code, body = get("/users/internal")
+			assert.Equal(t, http.StatusInternalServerError, code)
+			assert.Equal(t, ErrResp{Message: "internal error", BaseResp: BaseResp{Code: http.StatusInternalServerError}}, body)
+		})
+	}
+}
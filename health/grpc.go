@@ -0,0 +1,112 @@
+package health
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultWatchInterval is the default interval at which Watch re-runs the checks.
+	DefaultWatchInterval = 5 * time.Second
+)
+
+// GRPCServer implements the grpc.health.v1 service over a Registry. The empty service name
+// is the readiness of the whole service, and other names are the registered checks.
+type GRPCServer struct {
+	healthpb.UnimplementedHealthServer
+	registry      *Registry
+	watchInterval time.Duration
+}
+
+// NewGRPCServer creates a new GRPCServer instance.
+func NewGRPCServer(r *Registry) *GRPCServer {
+	return &GRPCServer{registry: r, watchInterval: DefaultWatchInterval}
+}
+
+// RegisterGRPC registers the grpc.health.v1 service of the registry on the gRPC server.
+func RegisterGRPC(s *grpc.Server, r *Registry) {
+	healthpb.RegisterHealthServer(s, NewGRPCServer(r))
+}
+
+// WithWatchInterval sets the interval at which Watch re-runs the checks.
+func (s *GRPCServer) WithWatchInterval(d time.Duration) {
+	if d > 0 {
+		s.watchInterval = d
+	}
+}
+
+// Check returns the serving status of the service, or NOT_FOUND if it is unknown.
+func (s *GRPCServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	st, ok := s.status(ctx, req.GetService())
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.GetService())
+	}
+	return &healthpb.HealthCheckResponse{Status: st}, nil
+}
+
+// List returns the serving status of the whole service and of every registered check.
+func (s *GRPCServer) List(ctx context.Context, _ *healthpb.HealthListRequest) (*healthpb.HealthListResponse, error) {
+	services := append([]string{""}, s.registry.Names()...)
+
+	statuses := make(map[string]*healthpb.HealthCheckResponse, len(services))
+	for _, service := range services {
+		if st, ok := s.status(ctx, service); ok {
+			statuses[service] = &healthpb.HealthCheckResponse{Status: st}
+		}
+	}
+	return &healthpb.HealthListResponse{Statuses: statuses}, nil
+}
+
+// Watch sends the serving status of the service, then every change of it until the client
+// cancels. Unknown services are reported as SERVICE_UNKNOWN.
+func (s *GRPCServer) Watch(req *healthpb.HealthCheckRequest, stream grpc.ServerStreamingServer[healthpb.HealthCheckResponse]) error {
+	ctx := stream.Context()
+	ticker := time.NewTicker(s.watchInterval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+	for {
+		st, ok := s.status(ctx, req.GetService())
+		if !ok {
+			st = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		}
+		if st != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+			last = st
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// status returns the serving status of the service, and false if it is unknown.
+func (s *GRPCServer) status(ctx context.Context, service string) (healthpb.HealthCheckResponse_ServingStatus, bool) {
+	if service == "" {
+		return servingStatus(s.registry.Ready(ctx).Up()), true
+	}
+
+	res, ok := s.registry.CheckOne(ctx, service)
+	if !ok {
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, false
+	}
+	return servingStatus(res.Status == StatusUp && !s.registry.shuttingDown.Load()), true
+}
+
+// servingStatus converts whether a check is up to a serving status.
+func servingStatus(up bool) healthpb.HealthCheckResponse_ServingStatus {
+	if up {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
@@ -0,0 +1,116 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestHealthClient(t *testing.T, r *Registry, watchInterval time.Duration) healthpb.HealthClient {
+	ln := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	hs := NewGRPCServer(r)
+	hs.WithWatchInterval(watchInterval)
+	healthpb.RegisterHealthServer(s, hs)
+	go func() {
+		_ = s.Serve(ln)
+	}()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return ln.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestGRPCServer_Check(t *testing.T) {
+	r := NewRegistry()
+	r.Register("redis", CheckerFunc(func(context.Context) error {
+		return nil
+	}))
+	r.Register("db", CheckerFunc(func(context.Context) error {
+		return errors.New("down")
+	}))
+	client := newTestHealthClient(t, r, time.Second)
+	ctx := context.Background()
+
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+	resp, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "redis"})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	list, err := client.List(ctx, &healthpb.HealthListRequest{})
+	require.NoError(t, err)
+	assert.Len(t, list.Statuses, 3)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, list.Statuses["redis"].Status)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, list.Statuses["db"].Status)
+
+	// every service stops serving during shutdown.
+	r.Shutdown()
+	resp, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "redis"})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+}
+
+func TestGRPCServer_Watch(t *testing.T) {
+	r := NewRegistry()
+	var up atomic.Bool
+	r.Register("db", CheckerFunc(func(context.Context) error {
+		if !up.Load() {
+			return errors.New("down")
+		}
+		return nil
+	}))
+	client := newTestHealthClient(t, r, 5*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+	up.Store(true)
+	resp, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	unknown, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+	require.NoError(t, err)
+	resp, err = unknown.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVICE_UNKNOWN, resp.Status)
+}
+
+func TestRegisterGRPC(t *testing.T) {
+	s := grpc.NewServer()
+	RegisterGRPC(s, NewRegistry())
+	assert.Contains(t, s.GetServiceInfo(), healthpb.Health_ServiceDesc.ServiceName)
+}
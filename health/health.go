@@ -0,0 +1,269 @@
+// Package health provides health checks of the components of a service, exposed as
+// liveness and readiness endpoints over HTTP and as the gRPC health service.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/hewen/mastiff-go/store"
+)
+
+const (
+	// DefaultCheckTimeout is the default duration a checker has to complete.
+	DefaultCheckTimeout = 3 * time.Second
+)
+
+// ErrShuttingDown is reported by readiness checks once the service is shutting down.
+var ErrShuttingDown = errors.New("shutting down")
+
+// Status is the health of a check or of the whole service.
+type Status string
+
+const (
+	// StatusUp means the check passed.
+	StatusUp Status = "up"
+	// StatusDown means the check failed.
+	StatusDown Status = "down"
+)
+
+// Checker checks the health of a component.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc is a function implementing Checker.
+type CheckerFunc func(ctx context.Context) error
+
+// Check implements Checker.
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name     string `json:"name"`
+	Status   Status `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the outcome of the checks of a probe.
+type Report struct {
+	Status Status   `json:"status"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+// Up reports whether all checks passed.
+func (r Report) Up() bool {
+	return r.Status == StatusUp
+}
+
+// check is a registered checker.
+type check struct {
+	checker  Checker
+	name     string
+	liveness bool
+}
+
+// Registry holds the checkers of the components of a service. Liveness checks tell whether
+// the process should be restarted, readiness checks whether it should receive traffic.
+// Readiness also runs the liveness checks, and fails once Shutdown is called.
+type Registry struct {
+	checks       []check
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+	mu           sync.RWMutex
+}
+
+// NewRegistry creates a new Registry instance.
+func NewRegistry() *Registry {
+	return &Registry{timeout: DefaultCheckTimeout}
+}
+
+// WithTimeout sets the duration each checker has to complete.
+func (r *Registry) WithTimeout(d time.Duration) {
+	if d > 0 {
+		r.timeout = d
+	}
+}
+
+// Register adds a readiness checker, such as the connection of a store.
+func (r *Registry) Register(name string, c Checker) {
+	r.add(check{name: name, checker: c})
+}
+
+// RegisterLiveness adds a liveness checker, which should only fail when restarting the
+// process helps, such as a deadlock.
+func (r *Registry) RegisterLiveness(name string, c Checker) {
+	r.add(check{name: name, checker: c, liveness: true})
+}
+
+// Shutdown makes readiness fail, so load balancers stop sending traffic while the service
+// drains.
+func (r *Registry) Shutdown() {
+	r.shuttingDown.Store(true)
+}
+
+// Live runs the liveness checks.
+func (r *Registry) Live(ctx context.Context) Report {
+	return r.run(ctx, func(c check) bool {
+		return c.liveness
+	})
+}
+
+// Ready runs the liveness and readiness checks.
+func (r *Registry) Ready(ctx context.Context) Report {
+	if r.shuttingDown.Load() {
+		return Report{
+			Status: StatusDown,
+			Checks: []Result{{Name: "shutdown", Status: StatusDown, Error: ErrShuttingDown.Error()}},
+		}
+	}
+	return r.run(ctx, func(check) bool {
+		return true
+	})
+}
+
+// CheckOne runs the check of the given name, returning false if there is none.
+func (r *Registry) CheckOne(ctx context.Context, name string) (Result, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, c := range r.checks {
+		if c.name == name {
+			return r.runCheck(ctx, c), true
+		}
+	}
+	return Result{}, false
+}
+
+// Names returns the names of the registered checks.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, len(r.checks))
+	for i, c := range r.checks {
+		names[i] = c.name
+	}
+	return names
+}
+
+// LivenessHandler returns an HTTP handler responding with the liveness report, with status
+// 200 if it is up and 503 otherwise.
+func (r *Registry) LivenessHandler() http.Handler {
+	return reportHandler(r.Live)
+}
+
+// ReadinessHandler returns an HTTP handler responding with the readiness report, with status
+// 200 if it is up and 503 otherwise.
+func (r *Registry) ReadinessHandler() http.Handler {
+	return reportHandler(r.Ready)
+}
+
+// add adds a check, replacing the one of the same name.
+func (r *Registry) add(c check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.checks {
+		if r.checks[i].name == c.name {
+			r.checks[i] = c
+			return
+		}
+	}
+	r.checks = append(r.checks, c)
+}
+
+// run runs the selected checks concurrently.
+func (r *Registry) run(ctx context.Context, selected func(c check) bool) Report {
+	r.mu.RLock()
+	var checks []check
+	for _, c := range r.checks {
+		if selected(c) {
+			checks = append(checks, c)
+		}
+	}
+	r.mu.RUnlock()
+
+	report := Report{Status: StatusUp, Checks: make([]Result, len(checks))}
+	var group sync.WaitGroup
+	group.Add(len(checks))
+	for i, c := range checks {
+		go func() {
+			defer group.Done()
+			report.Checks[i] = r.runCheck(ctx, c)
+		}()
+	}
+	group.Wait()
+
+	for _, res := range report.Checks {
+		if res.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// runCheck runs one check within the timeout.
+func (r *Registry) runCheck(ctx context.Context, c check) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := Result{Name: c.name, Status: StatusUp, Duration: time.Since(start).String()}
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// reportHandler returns an HTTP handler writing the report as JSON.
+func reportHandler(probe func(ctx context.Context) Report) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := probe(req.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if report.Up() {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
+
+// DB returns a checker pinging the database.
+func DB(db *store.DB) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	})
+}
+
+// Redis returns a checker pinging the Redis server.
+func Redis(client *redis.Client) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		return client.WithContext(ctx).Ping().Err()
+	})
+}
@@ -0,0 +1,120 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hewen/mastiff-go/storemock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	errDown := errors.New("connection refused")
+	r.RegisterLiveness("loop", CheckerFunc(func(context.Context) error {
+		return nil
+	}))
+	r.Register("db", CheckerFunc(func(context.Context) error {
+		return errDown
+	}))
+	assert.Equal(t, []string{"loop", "db"}, r.Names())
+
+	ctx := context.Background()
+	live := r.Live(ctx)
+	assert.True(t, live.Up())
+	require.Len(t, live.Checks, 1)
+	assert.Equal(t, "loop", live.Checks[0].Name)
+
+	ready := r.Ready(ctx)
+	assert.False(t, ready.Up())
+	require.Len(t, ready.Checks, 2)
+	assert.Equal(t, StatusUp, ready.Checks[0].Status)
+	assert.Equal(t, Result{Name: "db", Status: StatusDown, Error: errDown.Error(), Duration: ready.Checks[1].Duration}, ready.Checks[1])
+
+	// a check of the same name is replaced.
+	r.Register("db", CheckerFunc(func(context.Context) error {
+		return nil
+	}))
+	assert.True(t, r.Ready(ctx).Up())
+	res, ok := r.CheckOne(ctx, "db")
+	assert.True(t, ok)
+	assert.Equal(t, StatusUp, res.Status)
+	_, ok = r.CheckOne(ctx, "unknown")
+	assert.False(t, ok)
+
+	r.Shutdown()
+	ready = r.Ready(ctx)
+	assert.False(t, ready.Up())
+	assert.Equal(t, ErrShuttingDown.Error(), ready.Checks[0].Error)
+	// liveness does not change during shutdown.
+	assert.True(t, r.Live(ctx).Up())
+}
+
+func TestRegistry_Timeout(t *testing.T) {
+	r := NewRegistry()
+	r.WithTimeout(10 * time.Millisecond)
+	block := make(chan struct{})
+	defer close(block)
+	r.Register("hung", CheckerFunc(func(context.Context) error {
+		<-block
+		return nil
+	}))
+
+	report := r.Ready(context.Background())
+	assert.False(t, report.Up())
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+}
+
+func TestRegistry_Handlers(t *testing.T) {
+	r := NewRegistry()
+	var ready bool
+	r.Register("servers", CheckerFunc(func(context.Context) error {
+		if !ready {
+			return errors.New("not ready")
+		}
+		return nil
+	}))
+
+	get := func(h http.Handler) (int, Report) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var report Report
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		return w.Code, report
+	}
+
+	code, report := get(r.LivenessHandler())
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusUp, report.Status)
+
+	code, report = get(r.ReadinessHandler())
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not ready", report.Checks[0].Error)
+
+	ready = true
+	code, _ = get(r.ReadinessHandler())
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestCheckers(t *testing.T) {
+	ctx := context.Background()
+
+	client := storemock.InitMockRedis()
+	assert.NoError(t, Redis(client).Check(ctx))
+	require.NoError(t, client.Close())
+	assert.Error(t, Redis(client).Check(ctx))
+
+	db, err := storemock.InitMockMysql("../storemock/test/")
+	require.NoError(t, err)
+	assert.NoError(t, DB(db).Check(ctx))
+	require.NoError(t, db.Close())
+	assert.Error(t, DB(db).Check(ctx))
+}
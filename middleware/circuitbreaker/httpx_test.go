@@ -68,7 +68,7 @@ func TestHttpxMiddleware_ConsecutiveFailures(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 
-	mgr.Break("/fail/1", 1)
+	mgr.Break("/fail/:id", 1)
 	req, _ = http.NewRequest("GET", "/fail/1", nil)
 	resp, err = r.Test(req)
 	defer func() {
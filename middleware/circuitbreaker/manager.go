@@ -1,7 +1,11 @@
 package circuitbreaker
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -38,6 +42,23 @@ func (m *Manager) Get(name string) *gobreaker.CircuitBreaker {
 	return cb
 }
 
+// Check implements health.Checker. It fails while any circuit breaker is open.
+func (m *Manager) Check(_ context.Context) error {
+	var open []string
+	m.breakers.Range(func(key, value any) bool {
+		if value.(*gobreaker.CircuitBreaker).State() == gobreaker.StateOpen {
+			open = append(open, key.(string))
+		}
+		return true
+	})
+	if len(open) == 0 {
+		return nil
+	}
+
+	sort.Strings(open)
+	return fmt.Errorf("%w: %s", gobreaker.ErrOpenState, strings.Join(open, ","))
+}
+
 // Break breaks a circuit breaker.
 func (m *Manager) Break(name string, times int) {
 	cb := m.Get(name)
@@ -1,9 +1,11 @@
 package circuitbreaker
 
 import (
+	"context"
 	"testing"
 
 	"github.com/hewen/mastiff-go/config/middlewareconf/circuitbreakerconf"
+	"github.com/sony/gobreaker"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -34,3 +36,24 @@ func TestGetReuseBreaker(t *testing.T) {
 
 	assert.Same(t, cb1, cb2)
 }
+
+func TestManagerCheck(t *testing.T) {
+	cfg := &circuitbreakerconf.Config{
+		MaxRequests: 1,
+		Interval:    1,
+		Timeout:     60,
+		Policy: &circuitbreakerconf.PolicyConfig{
+			Type:                "consecutive_failures",
+			ConsecutiveFailures: 1,
+		},
+	}
+	mgr := NewManager(cfg)
+	mgr.Get("/ok")
+	assert.NoError(t, mgr.Check(context.Background()))
+
+	mgr.Break("/b", 1)
+	mgr.Break("/a", 1)
+	err := mgr.Check(context.Background())
+	assert.ErrorIs(t, err, gobreaker.ErrOpenState)
+	assert.ErrorContains(t, err, "/a,/b")
+}
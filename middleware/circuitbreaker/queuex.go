@@ -0,0 +1,21 @@
+package circuitbreaker
+
+import (
+	"context"
+
+	"github.com/hewen/mastiff-go/server/queuex"
+)
+
+// QueueMiddleware returns a queue handler middleware with circuit breaker. While the breaker
+// of the queue is open, messages fail with gobreaker.ErrOpenState and are retried later.
+func QueueMiddleware[T any](mgr *Manager, queueName string) queuex.Middleware[T] {
+	return func(next queuex.Handler[T]) queuex.Handler[T] {
+		return queuex.HandlerFunc[T](func(ctx context.Context, msg T) error {
+			breaker := mgr.Get(queueName)
+			_, err := breaker.Execute(func() (any, error) {
+				return nil, next.Handle(ctx, msg)
+			})
+			return err
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package circuitbreaker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hewen/mastiff-go/config/middlewareconf/circuitbreakerconf"
+	"github.com/hewen/mastiff-go/server/queuex"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueMiddleware(t *testing.T) {
+	mgr := NewManager(&circuitbreakerconf.Config{
+		MaxRequests: 1,
+		Interval:    60,
+		Timeout:     60,
+		Policy: &circuitbreakerconf.PolicyConfig{
+			Type:                "consecutive_failures",
+			ConsecutiveFailures: 1,
+		},
+	})
+
+	h := QueueMiddleware[string](mgr, "test")(queuex.HandlerFunc[string](func(_ context.Context, _ string) error {
+		return nil
+	}))
+	assert.Nil(t, h.Handle(context.TODO(), "msg"))
+
+	mgr.Break("test", 1)
+	assert.Equal(t, gobreaker.ErrOpenState, h.Handle(context.TODO(), "msg"))
+}
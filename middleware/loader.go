@@ -12,7 +12,9 @@ import (
 	"github.com/hewen/mastiff-go/middleware/ratelimit"
 	"github.com/hewen/mastiff-go/middleware/recovery"
 	"github.com/hewen/mastiff-go/middleware/timeout"
+	"github.com/hewen/mastiff-go/pkg/errorx"
 	"github.com/hewen/mastiff-go/server/httpx/unicontext"
+	"github.com/hewen/mastiff-go/server/queuex"
 	"google.golang.org/grpc"
 )
 
@@ -23,6 +25,10 @@ func LoadGRPCMiddlewares(conf middlewareconf.Config) []grpc.UnaryServerIntercept
 
 	var result []grpc.UnaryServerInterceptor
 
+	// the errors of the handlers and of the other interceptors are converted to gRPC statuses.
+	if IsEnabled(conf.EnableErrorx) {
+		result = append(result, errorx.UnaryServerInterceptor())
+	}
 	if IsEnabled(conf.EnableLogging) {
 		result = append(result, logging.UnaryServerInterceptor())
 	}
@@ -80,6 +86,31 @@ func LoadHttpxMiddlewares(conf middlewareconf.Config) []func(unicontext.Universa
 	return result
 }
 
+// LoadQueueMiddlewares loads queue handler middlewares based on the provided configuration.
+// The middlewares of batch handlers are loaded with a slice type, such as
+// LoadQueueMiddlewares[[]T], and added with QueueServer.UseBatch.
+func LoadQueueMiddlewares[T any](conf middlewareconf.Config, queueName string) []queuex.Middleware[T] {
+	conf.SetDefaults()
+
+	var result []queuex.Middleware[T]
+
+	if IsEnabled(conf.EnableLogging) {
+		result = append(result, logging.QueueMiddleware[T](queueName))
+	}
+	if IsEnabled(conf.EnableRecovery) {
+		result = append(result, recovery.QueueMiddleware[T]())
+	}
+	if conf.TimeoutSeconds != nil && *conf.TimeoutSeconds > 0 {
+		result = append(result, timeout.QueueMiddleware[T](time.Duration(*conf.TimeoutSeconds)*time.Second))
+	}
+	if conf.CircuitBreaker != nil {
+		mgr := circuitbreaker.NewManager(conf.CircuitBreaker)
+		result = append(result, circuitbreaker.QueueMiddleware[T](mgr, queueName))
+	}
+
+	return result
+}
+
 // IsEnabled returns true if the flag is nil or true.
 func IsEnabled(flag *bool) bool {
 	return flag == nil || *flag
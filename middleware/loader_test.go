@@ -1,14 +1,21 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/hewen/mastiff-go/config/middlewareconf"
 	"github.com/hewen/mastiff-go/config/middlewareconf/authconf"
 	"github.com/hewen/mastiff-go/config/middlewareconf/circuitbreakerconf"
 	"github.com/hewen/mastiff-go/config/middlewareconf/ratelimitconf"
+	"github.com/hewen/mastiff-go/server/queuex"
 )
 
 func TestLoadGRPCMiddlewares(t *testing.T) {
@@ -52,6 +59,25 @@ func TestLoadGRPCMiddlewares(t *testing.T) {
 		mws := LoadGRPCMiddlewares(conf)
 		assert.NotEmpty(t, mws)
 	})
+
+	t.Run("Errorx", func(t *testing.T) {
+		disable, enable, noTimeout := false, true, 0
+		conf := middlewareconf.Config{
+			EnableLogging:  &disable,
+			EnableRecovery: &disable,
+			TimeoutSeconds: &noTimeout,
+		}
+		// plain errors keep reaching clients as Unknown unless errorx is enabled.
+		assert.Empty(t, LoadGRPCMiddlewares(conf))
+
+		conf.EnableErrorx = &enable
+		mws := LoadGRPCMiddlewares(conf)
+		require.Len(t, mws, 1)
+		_, err := mws[0](context.Background(), nil, &grpc.UnaryServerInfo{}, func(context.Context, any) (any, error) {
+			return nil, errors.New("boom")
+		})
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
 }
 
 func TestLoadHttpxMiddlewares(t *testing.T) {
@@ -94,3 +120,40 @@ func TestLoadHttpxMiddlewares(t *testing.T) {
 		assert.NotEmpty(t, mws)
 	})
 }
+
+func TestLoadQueueMiddlewares(t *testing.T) {
+	t.Run("All features enabled", func(t *testing.T) {
+		timeoutSec := 5
+		enable := true
+
+		conf := middlewareconf.Config{
+			CircuitBreaker: &circuitbreakerconf.Config{
+				MaxRequests: 5,
+				Interval:    60,
+				Timeout:     10,
+			},
+			EnableRecovery: &enable,
+			TimeoutSeconds: &timeoutSec,
+		}
+
+		mws := LoadQueueMiddlewares[string](conf, "test")
+		assert.Len(t, mws, 4)
+		for _, mw := range mws {
+			assert.NotNil(t, mw)
+		}
+	})
+
+	t.Run("Minimal config", func(t *testing.T) {
+		conf := middlewareconf.Config{}
+		mws := LoadQueueMiddlewares[string](conf, "test")
+		assert.NotEmpty(t, mws)
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		mws := LoadQueueMiddlewares[[]string](middlewareconf.Config{}, "test")
+		h := queuex.Chain[[]string](queuex.HandlerFunc[[]string](func(_ context.Context, _ []string) error {
+			panic("batch")
+		}), mws...)
+		assert.EqualError(t, h.Handle(context.Background(), []string{"a", "b"}), "panic: batch")
+	})
+}
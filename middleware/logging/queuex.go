@@ -0,0 +1,32 @@
+package logging
+
+import (
+	"context"
+	"time"
+
+	"github.com/hewen/mastiff-go/logger"
+	"github.com/hewen/mastiff-go/server/queuex"
+)
+
+// QueueMiddleware is a queue handler middleware that logs the handled message, including execution time and any errors.
+func QueueMiddleware[T any](queueName string) queuex.Middleware[T] {
+	return func(next queuex.Handler[T]) queuex.Handler[T] {
+		return queuex.HandlerFunc[T](func(ctx context.Context, msg T) error {
+			start := time.Now()
+			err := next.Handle(ctx, msg)
+
+			logger.LogRequest(
+				logger.NewLoggerWithContext(ctx),
+				0,
+				time.Since(start),
+				"",
+				queueName,
+				"QUEUE",
+				msg,
+				nil,
+				err,
+			)
+			return err
+		})
+	}
+}
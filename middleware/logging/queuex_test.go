@@ -0,0 +1,23 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hewen/mastiff-go/server/queuex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueMiddleware(t *testing.T) {
+	errHandle := errors.New("handle failed")
+	h := QueueMiddleware[string]("test")(queuex.HandlerFunc[string](func(_ context.Context, msg string) error {
+		if msg == "fail" {
+			return errHandle
+		}
+		return nil
+	}))
+
+	assert.Nil(t, h.Handle(context.TODO(), "msg"))
+	assert.Equal(t, errHandle, h.Handle(context.TODO(), "fail"))
+}
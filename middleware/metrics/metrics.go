@@ -24,9 +24,112 @@ var (
 		},
 		[]string{"service", "method", "code"},
 	)
+
+	// QueuePopped counts messages popped from queues.
+	QueuePopped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "queue_messages_popped_total",
+			Help: "Number of messages popped from queues",
+		},
+		[]string{"queue"},
+	)
+
+	// QueueHandled counts messages handled successfully.
+	QueueHandled = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "queue_messages_handled_total",
+			Help: "Number of queue messages handled successfully",
+		},
+		[]string{"queue"},
+	)
+
+	// QueueFailed counts messages whose handler returned an error.
+	QueueFailed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "queue_messages_failed_total",
+			Help: "Number of queue messages whose handling failed",
+		},
+		[]string{"queue"},
+	)
+
+	// QueueDecodeErrors counts messages that could not be decoded.
+	QueueDecodeErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "queue_decode_errors_total",
+			Help: "Number of queue messages that could not be decoded",
+		},
+		[]string{"queue"},
+	)
+
+	// QueueHandleDuration records the duration of queue handlers, per message or per batch.
+	QueueHandleDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "queue_handle_duration_seconds",
+			Help:    "Duration of queue message handling",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"queue"},
+	)
+
+	// QueuePoolRunning records the number of running goroutines of queue server pools.
+	QueuePoolRunning = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "queue_pool_running",
+			Help: "Number of running goroutines in the queue server pool",
+		},
+		[]string{"queue"},
+	)
+
+	// QueuePoolFree records the number of free goroutines of queue server pools.
+	QueuePoolFree = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "queue_pool_free",
+			Help: "Number of free goroutines in the queue server pool",
+		},
+		[]string{"queue"},
+	)
+
+	// QueueDepth records the number of messages waiting in queues.
+	QueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "queue_depth",
+			Help: "Number of messages waiting in the queue",
+		},
+		[]string{"queue"},
+	)
+
+	// CronRuns counts cron job runs by status, either "success", "failure" or "skipped".
+	CronRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cron_job_runs_total",
+			Help: "Number of cron job runs",
+		},
+		[]string{"job", "status"},
+	)
+
+	// CronDuration records the duration of cron job runs.
+	CronDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cron_job_duration_seconds",
+			Help:    "Duration of cron job runs",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"job"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(HTTPDuration)
 	prometheus.MustRegister(GRPCDuration)
+	prometheus.MustRegister(
+		QueuePopped,
+		QueueHandled,
+		QueueFailed,
+		QueueDecodeErrors,
+		QueueHandleDuration,
+		QueuePoolRunning,
+		QueuePoolFree,
+		QueueDepth,
+	)
+	prometheus.MustRegister(CronRuns, CronDuration)
 }
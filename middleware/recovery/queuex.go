@@ -0,0 +1,29 @@
+// Package recovery provides a queue handler middleware that recovers from panics.
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	"github.com/hewen/mastiff-go/logger"
+	"github.com/hewen/mastiff-go/server/queuex"
+)
+
+// QueueMiddleware recovers from panics in queue handlers, logs them and returns them as
+// errors, so the message is retried instead of being acknowledged.
+func QueueMiddleware[T any]() queuex.Middleware[T] {
+	return func(next queuex.Handler[T]) queuex.Handler[T] {
+		return queuex.HandlerFunc[T](func(ctx context.Context, msg T) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					l := logger.NewLoggerWithContext(ctx)
+					l.Errorf("panic: %v $%s", r, strings.ReplaceAll(string(debug.Stack()), "\n", "$"))
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			return next.Handle(ctx, msg)
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package recovery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hewen/mastiff-go/server/queuex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueMiddleware(t *testing.T) {
+	h := QueueMiddleware[string]()(queuex.HandlerFunc[string](func(_ context.Context, _ string) error {
+		panic("test")
+	}))
+	assert.EqualError(t, h.Handle(context.TODO(), "msg"), "panic: test")
+
+	h = QueueMiddleware[string]()(queuex.HandlerFunc[string](func(_ context.Context, _ string) error {
+		return nil
+	}))
+	assert.Nil(t, h.Handle(context.TODO(), "msg"))
+}
@@ -0,0 +1,19 @@
+package timeout
+
+import (
+	"context"
+	"time"
+
+	"github.com/hewen/mastiff-go/server/queuex"
+)
+
+// QueueMiddleware creates a queue handler middleware that sets a timeout for each message.
+func QueueMiddleware[T any](timeout time.Duration) queuex.Middleware[T] {
+	return func(next queuex.Handler[T]) queuex.Handler[T] {
+		return queuex.HandlerFunc[T](func(ctx context.Context, msg T) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return next.Handle(ctx, msg)
+		})
+	}
+}
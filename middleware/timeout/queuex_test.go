@@ -0,0 +1,18 @@
+package timeout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hewen/mastiff-go/server/queuex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueMiddleware(t *testing.T) {
+	h := QueueMiddleware[string](10 * time.Millisecond)(queuex.HandlerFunc[string](func(ctx context.Context, _ string) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	assert.Equal(t, context.DeadlineExceeded, h.Handle(context.TODO(), "msg"))
+}
@@ -0,0 +1,246 @@
+// Package errorx provides typed errors carrying a business code, an HTTP status, a gRPC code, a
+// message and details, understood by both handler.WrapHandler and the gRPC interceptors.
+package errorx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// Predefined errors for the failures of the framework.
+var (
+	// ErrInvalidRequest is returned for requests failing to bind or validate.
+	ErrInvalidRequest = New(codes.InvalidArgument, "invalid request")
+	// ErrInternal is returned for errors of an unknown type, keeping their message out of responses.
+	ErrInternal = New(codes.Internal, "internal error")
+	// ErrDeadlineExceeded is returned for requests whose deadline passed.
+	ErrDeadlineExceeded = New(codes.DeadlineExceeded, "deadline exceeded")
+	// ErrCanceled is returned for requests canceled by the client.
+	ErrCanceled = New(codes.Canceled, "canceled")
+)
+
+// FieldError describes why the value of a field of a request is invalid.
+type FieldError struct {
+	// Field is the name of the field in the request, or its path for nested fields.
+	Field string `json:"field"`
+	// Rule is the rule the value broke, "type" for values not parsing into the field.
+	Rule string `json:"rule"`
+	// Param is the parameter of the rule, if any.
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// FieldErrors lists the invalid fields of a request, as returned by request binding.
+type FieldErrors []FieldError
+
+// Error returns the messages of the field errors.
+func (e FieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Error is an error carrying how to answer it over HTTP and gRPC. Errors are immutable: the With
+// methods return copies, which stay equal to the original for errors.Is.
+type Error struct {
+	// Details holds additional information about the error.
+	Details map[string]string
+	cause   error
+	// Message is the message of the error, returned to clients.
+	Message string
+	// Fields lists the invalid fields of the request, if any.
+	Fields []FieldError
+	// Code is the business code of the error, 0 if none.
+	Code int
+	// HTTPStatus is the status of HTTP responses.
+	HTTPStatus int
+	// GRPCCode is the code of gRPC statuses.
+	GRPCCode codes.Code
+}
+
+// New creates an Error with the gRPC code, the HTTP status it maps to, and the message.
+func New(code codes.Code, message string) *Error {
+	return &Error{
+		GRPCCode:   code,
+		HTTPStatus: runtime.HTTPStatusFromCode(code),
+		Message:    message,
+	}
+}
+
+// Newf creates an Error with the gRPC code, the HTTP status it maps to, and the formatted message.
+func Newf(code codes.Code, format string, args ...any) *Error {
+	return New(code, fmt.Sprintf(format, args...))
+}
+
+// Error returns the message of the error, followed by its cause if any.
+func (e *Error) Error() string {
+	if e.cause == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.cause.Error()
+}
+
+// Unwrap returns the cause of the error.
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is reports whether target is an Error with the same code, HTTP status, gRPC code and message.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return e.Code == t.Code && e.HTTPStatus == t.HTTPStatus && e.GRPCCode == t.GRPCCode && e.Message == t.Message
+}
+
+// WithCode returns a copy of the error with the business code.
+func (e *Error) WithCode(code int) *Error {
+	c := e.clone()
+	c.Code = code
+	return c
+}
+
+// WithHTTPStatus returns a copy of the error answered over HTTP with the status.
+func (e *Error) WithHTTPStatus(status int) *Error {
+	c := e.clone()
+	c.HTTPStatus = status
+	return c
+}
+
+// WithDetail returns a copy of the error with the detail added.
+func (e *Error) WithDetail(key, value string) *Error {
+	c := e.clone()
+	if c.Details == nil {
+		c.Details = make(map[string]string, 1)
+	}
+	c.Details[key] = value
+	return c
+}
+
+// WithFields returns a copy of the error with the invalid fields added.
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	c := e.clone()
+	c.Fields = slices.Concat(c.Fields, fields)
+	return c
+}
+
+// Wrap returns a copy of the error caused by err. The cause is logged but not returned to clients.
+func (e *Error) Wrap(err error) *Error {
+	c := e.clone()
+	c.cause = err
+	return c
+}
+
+// ResponseCode returns the code of HTTP response bodies: the business code, or the HTTP status
+// without one.
+func (e *Error) ResponseCode() int {
+	if e.Code != 0 {
+		return e.Code
+	}
+	return e.HTTPStatus
+}
+
+// GRPCStatus returns the gRPC status of the error, with the business code and details as an
+// ErrorInfo and the invalid fields as a BadRequest. It lets status.FromError convert the error.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(e.GRPCCode, e.Message)
+
+	var details []protoadapt.MessageV1
+	if e.Code != 0 || len(e.Details) > 0 {
+		info := &errdetails.ErrorInfo{Metadata: e.Details}
+		if e.Code != 0 {
+			info.Reason = strconv.Itoa(e.Code)
+		}
+		details = append(details, info)
+	}
+	if len(e.Fields) > 0 {
+		violations := make([]*errdetails.BadRequest_FieldViolation, len(e.Fields))
+		for i, f := range e.Fields {
+			violations[i] = &errdetails.BadRequest_FieldViolation{
+				Field:       f.Field,
+				Reason:      f.Rule,
+				Description: f.Message,
+			}
+		}
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+	if len(details) == 0 {
+		return st
+	}
+
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st
+	}
+	return withDetails
+}
+
+// clone returns a copy of the error.
+func (e *Error) clone() *Error {
+	c := *e
+	c.Details = maps.Clone(e.Details)
+	c.Fields = slices.Clone(e.Fields)
+	return &c
+}
+
+// FromStatus converts a gRPC status to an Error, reading back the details GRPCStatus adds.
+func FromStatus(st *status.Status) *Error {
+	e := New(st.Code(), st.Message())
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			e.Code, _ = strconv.Atoi(d.GetReason())
+			e.Details = maps.Clone(d.GetMetadata())
+		case *errdetails.BadRequest:
+			for _, v := range d.GetFieldViolations() {
+				e.Fields = append(e.Fields, FieldError{
+					Field:   v.GetField(),
+					Rule:    v.GetReason(),
+					Message: v.GetDescription(),
+				})
+			}
+		}
+	}
+	return e
+}
+
+// FromError converts err to an Error. Errors wrapping an Error return it, context errors,
+// gRPC statuses and binding errors are converted, and other errors become ErrInternal caused by
+// err. FromError returns nil if err is nil.
+func FromError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	var fields FieldErrors
+	switch {
+	case errors.As(err, &fields):
+		return ErrInvalidRequest.WithFields(fields...).Wrap(err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrDeadlineExceeded.Wrap(err)
+	case errors.Is(err, context.Canceled):
+		return ErrCanceled.Wrap(err)
+	}
+	if st, ok := status.FromError(err); ok && st.Code() != codes.Unknown {
+		return FromStatus(st).Wrap(err)
+	}
+	return ErrInternal.Wrap(err)
+}
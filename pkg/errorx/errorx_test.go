@@ -0,0 +1,92 @@
+package errorx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errUserNotFound = New(codes.NotFound, "user not found").WithCode(40401)
+
+func TestError(t *testing.T) {
+	assert.Equal(t, http.StatusNotFound, errUserNotFound.HTTPStatus)
+	assert.Equal(t, codes.NotFound, errUserNotFound.GRPCCode)
+	assert.Equal(t, 40401, errUserNotFound.ResponseCode())
+	assert.Equal(t, "user not found", errUserNotFound.Error())
+	assert.Equal(t, http.StatusConflict, New(codes.AlreadyExists, "exists").ResponseCode())
+	assert.Equal(t, "user 1 not found", Newf(codes.NotFound, "user %d not found", 1).Message)
+
+	cause := errors.New("no rows")
+	err := errUserNotFound.WithDetail("id", "1").WithDetail("source", "db").Wrap(cause)
+	assert.Equal(t, map[string]string{"id": "1", "source": "db"}, err.Details)
+	assert.Equal(t, "user not found: no rows", err.Error())
+	assert.ErrorIs(t, err, errUserNotFound)
+	assert.ErrorIs(t, err, cause)
+	assert.ErrorIs(t, fmt.Errorf("get user: %w", err), errUserNotFound)
+	assert.NotErrorIs(t, err, errUserNotFound.WithCode(40402))
+	assert.NotErrorIs(t, err, errUserNotFound.WithHTTPStatus(http.StatusGone))
+
+	// the original error is not changed.
+	assert.Nil(t, errUserNotFound.Details)
+	assert.NoError(t, errUserNotFound.Unwrap())
+
+	fields := ErrInvalidRequest.WithFields(FieldError{Field: "name", Rule: "required"})
+	assert.Len(t, fields.WithFields(FieldError{Field: "page", Rule: "min"}).Fields, 2)
+	assert.Len(t, fields.Fields, 1)
+}
+
+func TestError_GRPCStatus(t *testing.T) {
+	err := errUserNotFound.WithDetail("id", "1").WithFields(FieldError{
+		Field: "id", Rule: "gte", Message: "id failed on the gte=1 rule",
+	})
+
+	st, ok := status.FromError(fmt.Errorf("get user: %w", err))
+	require.True(t, ok)
+	assert.Equal(t, codes.NotFound, st.Code())
+	require.Len(t, st.Details(), 2)
+	assert.Equal(t, "40401", st.Details()[0].(*errdetails.ErrorInfo).GetReason())
+
+	back := FromStatus(err.GRPCStatus())
+	assert.Equal(t, err, back)
+	assert.ErrorIs(t, back, errUserNotFound)
+
+	st = New(codes.Unavailable, "unavailable").GRPCStatus()
+	assert.Empty(t, st.Details())
+	assert.Equal(t, http.StatusServiceUnavailable, FromStatus(st).HTTPStatus)
+}
+
+func TestFromError(t *testing.T) {
+	assert.Nil(t, FromError(nil))
+	assert.Same(t, errUserNotFound, FromError(fmt.Errorf("wrapped: %w", errUserNotFound)))
+
+	fields := FieldErrors{{Field: "name", Rule: "required", Message: "name failed on the required rule"}}
+	e := FromError(fields)
+	assert.ErrorIs(t, e, ErrInvalidRequest)
+	assert.Equal(t, []FieldError(fields), e.Fields)
+	assert.Equal(t, http.StatusBadRequest, e.HTTPStatus)
+
+	e = FromError(fmt.Errorf("query: %w", context.DeadlineExceeded))
+	assert.ErrorIs(t, e, ErrDeadlineExceeded)
+	assert.Equal(t, http.StatusGatewayTimeout, e.HTTPStatus)
+	assert.ErrorIs(t, FromError(context.Canceled), ErrCanceled)
+
+	e = FromError(status.Error(codes.PermissionDenied, "denied"))
+	assert.Equal(t, codes.PermissionDenied, e.GRPCCode)
+	assert.Equal(t, http.StatusForbidden, e.HTTPStatus)
+	assert.Equal(t, "denied", e.Message)
+
+	cause := errors.New("secret")
+	e = FromError(cause)
+	assert.ErrorIs(t, e, ErrInternal)
+	assert.ErrorIs(t, e, cause)
+	assert.Equal(t, "internal error", e.Message)
+	assert.ErrorIs(t, FromError(status.Error(codes.Unknown, "unknown")), ErrInternal)
+}
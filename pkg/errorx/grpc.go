@@ -0,0 +1,56 @@
+package errorx
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor converts the errors of unary handlers with FromError, so clients
+// receive the status of the Error with its details. The gRPC handlers install it first when
+// their middleware configuration sets EnableErrorx.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		return resp, toStatusError(err)
+	}
+}
+
+// StreamServerInterceptor converts the errors of stream handlers with FromError, so clients
+// receive the status of the Error with its details.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return toStatusError(handler(srv, ss))
+	}
+}
+
+// UnaryClientInterceptor converts the statuses of failed calls to Errors, so errors returned by
+// other services keep their code, details and HTTP status.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err == nil {
+			return nil
+		}
+		if st, ok := status.FromError(err); ok {
+			return FromStatus(st)
+		}
+		return err
+	}
+}
+
+// toStatusError converts err to the error of its gRPC status.
+func toStatusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return FromError(err).GRPCStatus().Err()
+}
@@ -0,0 +1,73 @@
+package errorx
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	fn := UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "test"}
+
+	resp, err := fn(context.TODO(), nil, info, func(context.Context, any) (any, error) {
+		return "ok", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+
+	_, err = fn(context.TODO(), nil, info, func(context.Context, any) (any, error) {
+		return nil, errUserNotFound.WithDetail("id", "1")
+	})
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, codes.NotFound, st.Code())
+	assert.Equal(t, "user not found", st.Message())
+	assert.Equal(t, errUserNotFound.WithDetail("id", "1"), FromStatus(st))
+
+	_, err = fn(context.TODO(), nil, info, func(context.Context, any) (any, error) {
+		return nil, errors.New("secret")
+	})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, "internal error", status.Convert(err).Message())
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	fn := StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "test"}
+
+	assert.NoError(t, fn(nil, nil, info, func(any, grpc.ServerStream) error {
+		return nil
+	}))
+	err := fn(nil, nil, info, func(any, grpc.ServerStream) error {
+		return context.DeadlineExceeded
+	})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	fn := UnaryClientInterceptor()
+	invoke := func(err error) error {
+		return fn(context.TODO(), "test", nil, nil, nil,
+			func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+				return err
+			})
+	}
+
+	assert.NoError(t, invoke(nil))
+
+	err := invoke(errUserNotFound.GRPCStatus().Err())
+	assert.ErrorIs(t, err, errUserNotFound)
+	var e *Error
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, 40401, e.Code)
+
+	plain := errors.New("plain")
+	assert.Equal(t, plain, invoke(plain))
+}
@@ -0,0 +1,252 @@
+// Package lock provides a distributed lock on top of Redis.
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+const (
+	// DefaultRetryInterval is the default interval between attempts of a blocking Acquire.
+	DefaultRetryInterval = 50 * time.Millisecond
+)
+
+var (
+	// ErrNotAcquired is returned by TryAcquire when the lock is held by someone else.
+	ErrNotAcquired = errors.New("lock not acquired")
+	// ErrNotHeld is returned when extending or releasing a lock that expired or was taken over.
+	ErrNotHeld = errors.New("lock not held")
+)
+
+// acquireScript sets the lock key unless it exists, and returns the next fencing token of the
+// key, or 0 if the lock is held by someone else.
+var acquireScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('INCR', KEYS[2])
+end
+return 0
+`)
+
+// extendScript resets the TTL of the lock key if it holds the token.
+var extendScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseScript deletes the lock key if it holds the token.
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Locker acquires distributed locks stored in Redis. A lock is a key holding a random token
+// with a TTL, so a crashed holder releases it once the TTL expires.
+type Locker struct {
+	client        *redis.Client
+	retryInterval time.Duration
+}
+
+// NewLocker creates a new Locker instance using a client such as the one returned by store.InitRedis.
+func NewLocker(client *redis.Client) *Locker {
+	return &Locker{
+		client:        client,
+		retryInterval: DefaultRetryInterval,
+	}
+}
+
+// WithRetryInterval sets the interval between attempts of a blocking Acquire.
+func (lk *Locker) WithRetryInterval(d time.Duration) {
+	if d > 0 {
+		lk.retryInterval = d
+	}
+}
+
+// TryAcquire acquires the lock of the key for the TTL, returning ErrNotAcquired if it is held.
+// The lock is extended every third of the TTL until it is released.
+func (lk *Locker) TryAcquire(key string, ttl time.Duration) (*Lock, error) {
+	token, err := gonanoid.New()
+	if err != nil {
+		return nil, err
+	}
+
+	fence, err := acquireScript.Run(lk.client, []string{key, fenceKey(key)}, token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if fence == 0 {
+		return nil, ErrNotAcquired
+	}
+
+	l := &Lock{
+		client:  lk.client,
+		key:     key,
+		token:   token,
+		fence:   fence,
+		ttl:     ttl,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		lost:    make(chan struct{}),
+	}
+	go l.keepAlive()
+	return l, nil
+}
+
+// Acquire blocks until the lock of the key is acquired or ctx is done.
+func (lk *Locker) Acquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	ticker := time.NewTicker(lk.retryInterval)
+	defer ticker.Stop()
+
+	for {
+		l, err := lk.TryAcquire(key, ttl)
+		if !errors.Is(err, ErrNotAcquired) {
+			return l, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// WithLock runs fn while holding the lock of the key, blocking until it is acquired. The
+// context of fn is cancelled if the lock is lost.
+func (lk *Locker) WithLock(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) error) error {
+	l, err := lk.Acquire(ctx, key, ttl)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-l.Lost():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err = fn(ctx)
+	if releaseErr := l.Release(); releaseErr != nil {
+		return errors.Join(err, releaseErr)
+	}
+	return err
+}
+
+// Lock is a held distributed lock.
+type Lock struct {
+	client   *redis.Client
+	stop     chan struct{}
+	stopped  chan struct{}
+	lost     chan struct{}
+	key      string
+	token    string
+	fence    int64
+	ttl      time.Duration
+	lostOnce sync.Once
+	stopOnce sync.Once
+}
+
+// Key returns the key of the lock.
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Token returns the random token identifying the holder of the lock.
+func (l *Lock) Token() string {
+	return l.token
+}
+
+// Fence returns the fencing token of the lock, which increases with every acquisition of the
+// key. Storage written under the lock can reject writes with a lower token than the last
+// one seen, so a holder that paused past its TTL cannot overwrite the next holder.
+func (l *Lock) Fence() int64 {
+	return l.fence
+}
+
+// Lost returns a channel closed when the lock expired or was taken over while held.
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Extend resets the TTL of the lock.
+func (l *Lock) Extend(ttl time.Duration) error {
+	n, err := extendScript.Run(l.client, []string{l.key}, l.token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		l.markLost()
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// Release stops extending the lock and deletes it, returning ErrNotHeld if it was lost.
+func (l *Lock) Release() error {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+	<-l.stopped
+
+	n, err := releaseScript.Run(l.client, []string{l.key}, l.token).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// keepAlive extends the lock every third of its TTL until it is released or lost. The lock
+// is considered lost once it could not be extended for a whole TTL.
+func (l *Lock) keepAlive() {
+	defer close(l.stopped)
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	extended := time.Now()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		err := l.Extend(l.ttl)
+		switch {
+		case err == nil:
+			extended = time.Now()
+		case errors.Is(err, ErrNotHeld):
+			return
+		case time.Since(extended) >= l.ttl:
+			l.markLost()
+			return
+		}
+	}
+}
+
+// markLost closes the lost channel once.
+func (l *Lock) markLost() {
+	l.lostOnce.Do(func() {
+		close(l.lost)
+	})
+}
+
+// fenceKey returns the key of the counter of fencing tokens of a lock key.
+func fenceKey(key string) string {
+	return key + ":fence"
+}
@@ -0,0 +1,153 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/hewen/mastiff-go/storemock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocker_TryAcquire(t *testing.T) {
+	client := storemock.InitMockRedis()
+	lk := NewLocker(client)
+
+	l, err := lk.TryAcquire("job", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "job", l.Key())
+	assert.Equal(t, int64(1), l.Fence())
+	assert.Equal(t, l.Token(), client.Get("job").Val())
+	assert.Greater(t, client.PTTL("job").Val(), time.Duration(0))
+
+	_, err = lk.TryAcquire("job", time.Minute)
+	assert.ErrorIs(t, err, ErrNotAcquired)
+
+	require.NoError(t, l.Release())
+	assert.Equal(t, int64(0), client.Exists("job").Val())
+	assert.ErrorIs(t, l.Release(), ErrNotHeld)
+
+	// fencing tokens increase with every acquisition.
+	l2, err := lk.TryAcquire("job", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), l2.Fence())
+	assert.NotEqual(t, l.Token(), l2.Token())
+	require.NoError(t, l2.Release())
+}
+
+func TestLock_ReleaseTakenOver(t *testing.T) {
+	client := storemock.InitMockRedis()
+	lk := NewLocker(client)
+
+	l, err := lk.TryAcquire("job", time.Minute)
+	require.NoError(t, err)
+
+	// the lock expired and was acquired by another holder, whose key must not be deleted.
+	require.NoError(t, client.Set("job", "other", time.Minute).Err())
+	assert.ErrorIs(t, l.Extend(time.Minute), ErrNotHeld)
+	assert.ErrorIs(t, l.Release(), ErrNotHeld)
+	assert.Equal(t, "other", client.Get("job").Val())
+
+	select {
+	case <-l.Lost():
+	default:
+		t.Fatal("lock is not lost")
+	}
+}
+
+func TestLock_KeepAlive(t *testing.T) {
+	client := storemock.InitMockRedis()
+	lk := NewLocker(client)
+
+	l, err := lk.TryAcquire("job", 60*time.Millisecond)
+	require.NoError(t, err)
+
+	// the lock outlives its TTL while held.
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, l.Token(), client.Get("job").Val())
+	require.NoError(t, l.Release())
+
+	l, err = lk.TryAcquire("job", 60*time.Millisecond)
+	require.NoError(t, err)
+	require.NoError(t, client.Set("job", "other", time.Minute).Err())
+
+	select {
+	case <-l.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lock is not lost")
+	}
+}
+
+func TestLock_KeepAliveUnreachable(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+
+	l, err := NewLocker(client).TryAcquire("job", 60*time.Millisecond)
+	require.NoError(t, err)
+	mr.Close()
+
+	// the lock is lost once it could not be extended for a whole TTL.
+	select {
+	case <-l.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lock is not lost")
+	}
+	assert.Error(t, l.Release())
+}
+
+func TestLocker_Acquire(t *testing.T) {
+	client := storemock.InitMockRedis()
+	lk := NewLocker(client)
+	lk.WithRetryInterval(5 * time.Millisecond)
+
+	l, err := lk.TryAcquire("job", time.Minute)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = lk.Acquire(ctx, "job", time.Minute)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = l.Release()
+	}()
+	l2, err := lk.Acquire(context.Background(), "job", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), l2.Fence())
+	require.NoError(t, l2.Release())
+}
+
+func TestLocker_WithLock(t *testing.T) {
+	client := storemock.InitMockRedis()
+	lk := NewLocker(client)
+
+	errFn := errors.New("fn failed")
+	err := lk.WithLock(context.Background(), "job", time.Minute, func(_ context.Context) error {
+		assert.Equal(t, int64(1), client.Exists("job").Val())
+		return errFn
+	})
+	assert.ErrorIs(t, err, errFn)
+	assert.Equal(t, int64(0), client.Exists("job").Val())
+
+	// the context of fn is cancelled when the lock is lost.
+	err = lk.WithLock(context.Background(), "job", 30*time.Millisecond, func(ctx context.Context) error {
+		require.NoError(t, client.Set("job", "other", time.Minute).Err())
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, err, ErrNotHeld)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = lk.WithLock(ctx, "job", time.Minute, func(_ context.Context) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,240 @@
+// Package cronx provides a server running jobs on cron schedules.
+package cronx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/logger"
+	"github.com/hewen/mastiff-go/middleware/metrics"
+	"github.com/hewen/mastiff-go/pkg/contextkeys"
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	// ErrJobNotConfigured is returned when registering a job without a schedule in the configuration.
+	ErrJobNotConfigured = errors.New("job is not configured")
+	// ErrJobRegistered is returned when registering a job twice.
+	ErrJobRegistered = errors.New("job is already registered")
+	// ErrInvalidOverlapPolicy is returned when a job has an unknown overlap policy.
+	ErrInvalidOverlapPolicy = errors.New("invalid overlap policy")
+)
+
+// parser parses cron expressions with an optional leading seconds field and descriptors.
+var parser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// Job is a function run on a cron schedule. Its context carries a trace ID, is cancelled
+// after the Timeout of the job and when the server stops.
+type Job func(ctx context.Context) error
+
+// CronServer runs registered jobs on the schedules of their configuration. Runs are logged
+// with logger.LogRequest, recorded in the cron metrics, and recover from panics.
+type CronServer struct {
+	cron       *cron.Cron
+	leader     *leaderLock
+	logger     logger.Logger
+	ctx        context.Context
+	cancel     context.CancelFunc
+	done       chan struct{}
+	schedules  map[string]serverconf.CronJobConfig
+	registered map[string]bool
+	conf       serverconf.CronConfig
+	mu         sync.Mutex
+}
+
+// NewCronServer creates a new CronServer, validating the schedules of the configuration.
+func NewCronServer(conf *serverconf.CronConfig) (*CronServer, error) {
+	loc := time.Local
+	if conf.Location != "" {
+		var err error
+		if loc, err = time.LoadLocation(conf.Location); err != nil {
+			return nil, err
+		}
+	}
+
+	schedules := make(map[string]serverconf.CronJobConfig, len(conf.Jobs))
+	for _, job := range conf.Jobs {
+		if _, ok := schedules[job.Name]; ok {
+			return nil, fmt.Errorf("duplicate job %q", job.Name)
+		}
+		if _, err := parser.Parse(job.Spec); err != nil {
+			return nil, fmt.Errorf("job %q: %w", job.Name, err)
+		}
+		switch job.Overlap {
+		case "", serverconf.OverlapSkip, serverconf.OverlapQueue, serverconf.OverlapAllow:
+		default:
+			return nil, fmt.Errorf("job %q: %w: %s", job.Name, ErrInvalidOverlapPolicy, job.Overlap)
+		}
+		schedules[job.Name] = job
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &CronServer{
+		cron:       cron.New(cron.WithParser(parser), cron.WithLocation(loc)),
+		logger:     logger.NewLogger(),
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+		schedules:  schedules,
+		registered: make(map[string]bool),
+		conf:       *conf,
+	}, nil
+}
+
+// Register schedules the job with the configuration of the same name. Disabled jobs are not scheduled.
+func (s *CronServer) Register(name string, job Job) error {
+	conf, ok := s.schedules[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrJobNotConfigured, name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.registered[name] {
+		return fmt.Errorf("%w: %s", ErrJobRegistered, name)
+	}
+	s.registered[name] = true
+
+	if conf.Disabled {
+		s.logger.Infof("[cron] job %s is disabled", name)
+		return nil
+	}
+
+	_, err := s.cron.AddFunc(conf.Spec, s.wrap(conf, job))
+	return err
+}
+
+// WithLeaderLock makes replicas elect a leader with a Redis lock, so only one of them runs
+// the jobs. It must be called before Start.
+func (s *CronServer) WithLeaderLock(client *redis.Client) {
+	s.leader = newLeaderLock(client, s.conf.LeaderKey, s.conf.LeaderTTL)
+}
+
+// Start runs the scheduler until Stop is called.
+func (s *CronServer) Start() {
+	s.mu.Lock()
+	select {
+	case <-s.done:
+		s.mu.Unlock()
+		return
+	default:
+	}
+	s.mu.Unlock()
+
+	if s.leader != nil {
+		go s.leader.campaign(s.done, s.logger)
+	}
+
+	s.cron.Start()
+	<-s.done
+}
+
+// Stop stops scheduling jobs, cancels the context of running jobs and waits for them to return.
+func (s *CronServer) Stop() {
+	s.mu.Lock()
+	select {
+	case <-s.done:
+		s.mu.Unlock()
+		return
+	default:
+		close(s.done)
+	}
+	s.mu.Unlock()
+
+	stopped := s.cron.Stop()
+	s.cancel()
+	<-stopped.Done()
+
+	if s.leader != nil {
+		s.leader.resign(s.logger)
+	}
+}
+
+// Name returns the name of the cron server.
+func (s *CronServer) Name() string {
+	return fmt.Sprintf("cron server (%d jobs)", len(s.cron.Entries()))
+}
+
+// WithLogger sets the logger for the cron server.
+func (s *CronServer) WithLogger(l logger.Logger) {
+	if l != nil {
+		s.logger = l
+	}
+}
+
+// wrap returns the function run by the scheduler, which applies the overlap policy and the leader lock.
+func (s *CronServer) wrap(conf serverconf.CronJobConfig, job Job) func() {
+	run := func() {
+		if s.leader != nil && !s.leader.isLeader() {
+			return
+		}
+		s.run(conf, job)
+	}
+
+	switch conf.Overlap {
+	case serverconf.OverlapAllow:
+		return run
+	case serverconf.OverlapQueue:
+		var mu sync.Mutex
+		return func() {
+			mu.Lock()
+			defer mu.Unlock()
+			run()
+		}
+	default:
+		running := make(chan struct{}, 1)
+		return func() {
+			select {
+			case running <- struct{}{}:
+				defer func() { <-running }()
+				run()
+			default:
+				metrics.CronRuns.WithLabelValues(conf.Name, "skipped").Inc()
+				s.logger.Infof("[cron] job %s skipped, previous run is still running", conf.Name)
+			}
+		}
+	}
+}
+
+// run runs the job once with a new trace ID, then logs and records the run.
+func (s *CronServer) run(conf serverconf.CronJobConfig, job Job) {
+	ctx := contextkeys.SetTraceID(s.ctx, logger.NewTraceID())
+	if conf.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conf.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := safeRun(ctx, job)
+	duration := time.Since(start)
+
+	status := "success"
+	if err != nil {
+		status = "failure"
+	}
+	metrics.CronRuns.WithLabelValues(conf.Name, status).Inc()
+	metrics.CronDuration.WithLabelValues(conf.Name).Observe(duration.Seconds())
+	logger.LogRequest(logger.NewLoggerWithContext(ctx), 0, duration, "", conf.Name, "CRON", nil, nil, err)
+}
+
+// safeRun runs the job, returning a panic as an error.
+func safeRun(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			l := logger.NewLoggerWithContext(ctx)
+			l.Errorf("panic: %v $%s", r, strings.ReplaceAll(string(debug.Stack()), "\n", "$"))
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job(ctx)
+}
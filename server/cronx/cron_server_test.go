@@ -0,0 +1,172 @@
+package cronx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/logger"
+	"github.com/hewen/mastiff-go/pkg/contextkeys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCronServer(t *testing.T) {
+	s, err := NewCronServer(&serverconf.CronConfig{
+		Location: "UTC",
+		Jobs: []serverconf.CronJobConfig{
+			{Name: "minutely", Spec: "* * * * *"},
+			{Name: "secondly", Spec: "* * * * * *", Overlap: serverconf.OverlapQueue},
+			{Name: "every", Spec: "@every 1h", Overlap: serverconf.OverlapAllow},
+		},
+	})
+	require.NoError(t, err)
+	s.WithLogger(logger.NewLogger())
+
+	for _, conf := range []serverconf.CronConfig{
+		{Location: "Nowhere/Nowhere"},
+		{Jobs: []serverconf.CronJobConfig{{Name: "a", Spec: "* * * * *"}, {Name: "a", Spec: "* * * * *"}}},
+		{Jobs: []serverconf.CronJobConfig{{Name: "a", Spec: "not a spec"}}},
+	} {
+		_, err = NewCronServer(&conf)
+		assert.Error(t, err)
+	}
+
+	_, err = NewCronServer(&serverconf.CronConfig{Jobs: []serverconf.CronJobConfig{{Name: "a", Spec: "@daily", Overlap: "sometimes"}}})
+	assert.ErrorIs(t, err, ErrInvalidOverlapPolicy)
+}
+
+func TestCronServer_Register(t *testing.T) {
+	s, err := NewCronServer(&serverconf.CronConfig{
+		Jobs: []serverconf.CronJobConfig{
+			{Name: "enabled", Spec: "@daily"},
+			{Name: "disabled", Spec: "@daily", Disabled: true},
+		},
+	})
+	require.NoError(t, err)
+
+	job := func(context.Context) error { return nil }
+	require.NoError(t, s.Register("enabled", job))
+	require.NoError(t, s.Register("disabled", job))
+	assert.ErrorIs(t, s.Register("enabled", job), ErrJobRegistered)
+	assert.ErrorIs(t, s.Register("unknown", job), ErrJobNotConfigured)
+
+	assert.Equal(t, "cron server (1 jobs)", s.Name())
+}
+
+func TestCronServer_StartStop(t *testing.T) {
+	s, err := NewCronServer(&serverconf.CronConfig{
+		Jobs: []serverconf.CronJobConfig{{Name: "secondly", Spec: "* * * * * *"}},
+	})
+	require.NoError(t, err)
+
+	var runs atomic.Int32
+	var traceID atomic.Value
+	require.NoError(t, s.Register("secondly", func(ctx context.Context) error {
+		id, _ := contextkeys.GetTraceID(ctx)
+		traceID.Store(id)
+		runs.Add(1)
+		return nil
+	}))
+
+	go s.Start()
+	require.Eventually(t, func() bool {
+		return runs.Load() > 0
+	}, 3*time.Second, 10*time.Millisecond)
+	s.Stop()
+	s.Stop()
+
+	assert.NotEmpty(t, traceID.Load())
+
+	// a stopped server does not start again.
+	s.Start()
+}
+
+func TestCronServer_Run(t *testing.T) {
+	s, err := NewCronServer(&serverconf.CronConfig{})
+	require.NoError(t, err)
+
+	err = safeRun(context.Background(), func(context.Context) error {
+		panic("test")
+	})
+	assert.EqualError(t, err, "panic: test")
+
+	var deadline bool
+	s.run(serverconf.CronJobConfig{Name: "timeout", Timeout: time.Minute}, func(ctx context.Context) error {
+		_, deadline = ctx.Deadline()
+		return errors.New("failed")
+	})
+	assert.True(t, deadline)
+
+	// jobs see their context cancelled when the server stops.
+	started := make(chan struct{})
+	finished := make(chan error)
+	go s.run(serverconf.CronJobConfig{Name: "hung"}, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		finished <- ctx.Err()
+		return ctx.Err()
+	})
+	<-started
+	s.Stop()
+	assert.Equal(t, context.Canceled, <-finished)
+}
+
+func TestCronServer_Overlap(t *testing.T) {
+	s, err := NewCronServer(&serverconf.CronConfig{})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		policy     serverconf.CronOverlapPolicy
+		concurrent int32
+		runs       int32
+	}{
+		{policy: "", concurrent: 1, runs: 1},
+		{policy: serverconf.OverlapSkip, concurrent: 1, runs: 1},
+		{policy: serverconf.OverlapQueue, concurrent: 1, runs: 2},
+		{policy: serverconf.OverlapAllow, concurrent: 2, runs: 2},
+	} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			var running, maxRunning, runs atomic.Int32
+			release := make(chan struct{})
+			fn := s.wrap(serverconf.CronJobConfig{Name: "overlap", Overlap: tc.policy}, func(context.Context) error {
+				n := running.Add(1)
+				for {
+					m := maxRunning.Load()
+					if n <= m || maxRunning.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				<-release
+				running.Add(-1)
+				runs.Add(1)
+				return nil
+			})
+
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				fn()
+			}()
+			require.Eventually(t, func() bool {
+				return running.Load() == 1
+			}, time.Second, time.Millisecond)
+			go func() {
+				defer wg.Done()
+				fn()
+			}()
+
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			assert.Equal(t, tc.concurrent, maxRunning.Load())
+			assert.Equal(t, tc.runs, runs.Load())
+		})
+	}
+}
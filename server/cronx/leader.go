@@ -0,0 +1,113 @@
+package cronx
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/hewen/mastiff-go/logger"
+	"github.com/hewen/mastiff-go/pkg/lock"
+)
+
+const (
+	// DefaultLeaderKey is the default Redis key of the leader lock.
+	DefaultLeaderKey = "cron:leader"
+	// DefaultLeaderTTL is the default duration the leader lock is held without being renewed.
+	DefaultLeaderTTL = 15 * time.Second
+)
+
+// leaderLock elects one replica as leader by holding a lock of pkg/lock, checked every third
+// of the TTL. A replica that fails to extend the lock stops being leader at once, so another
+// one takes over once the lock expires.
+type leaderLock struct {
+	locker   *lock.Locker
+	held     *lock.Lock
+	key      string
+	ttl      time.Duration
+	leader   atomic.Bool
+	resigned bool
+	mu       sync.Mutex
+}
+
+// newLeaderLock creates a new leaderLock instance.
+func newLeaderLock(client *redis.Client, key string, ttl time.Duration) *leaderLock {
+	if key == "" {
+		key = DefaultLeaderKey
+	}
+	if ttl <= 0 {
+		ttl = DefaultLeaderTTL
+	}
+
+	return &leaderLock{locker: lock.NewLocker(client), key: key, ttl: ttl}
+}
+
+// isLeader reports whether the replica holds the leader lock.
+func (l *leaderLock) isLeader() bool {
+	return l.leader.Load()
+}
+
+// campaign acquires or checks the leader lock until done is closed.
+func (l *leaderLock) campaign(done <-chan struct{}, log logger.Logger) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		l.tryAcquire(log)
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tryAcquire acquires the leader lock if it is free, or extends it if it is held. The held
+// lock extends itself too, but leadership is given up at the first failed extension rather
+// than once the lock is lost.
+func (l *leaderLock) tryAcquire(log logger.Logger) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.resigned {
+		return
+	}
+
+	var err error
+	if l.held != nil {
+		if err = l.held.Extend(l.ttl); err != nil {
+			_ = l.held.Release()
+			l.held = nil
+		}
+	} else {
+		l.held, err = l.locker.TryAcquire(l.key, l.ttl)
+		if errors.Is(err, lock.ErrNotAcquired) {
+			err = nil
+		}
+	}
+	if err != nil {
+		log.Errorf("[cron] leader lock failed: %v", err)
+	}
+
+	acquired := l.held != nil
+	if was := l.leader.Swap(acquired); was != acquired {
+		log.Infof("[cron] leader changed, is leader: %v", acquired)
+	}
+}
+
+// resign releases the leader lock if it is held, and stops acquiring it.
+func (l *leaderLock) resign(log logger.Logger) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.resigned = true
+	l.leader.Store(false)
+
+	if l.held == nil {
+		return
+	}
+	if err := l.held.Release(); err != nil {
+		log.Errorf("[cron] resign leader lock failed: %v", err)
+	}
+	l.held = nil
+}
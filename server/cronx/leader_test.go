@@ -0,0 +1,103 @@
+package cronx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+
+	return mr, redis.NewClient(&redis.Options{Addr: mr.Addr()})
+}
+
+func TestLeaderLock(t *testing.T) {
+	mr, client := newTestRedis(t)
+	log := logger.NewLogger()
+	a := newLeaderLock(client, "", 0)
+	b := newLeaderLock(client, "", 0)
+
+	a.tryAcquire(log)
+	b.tryAcquire(log)
+	assert.True(t, a.isLeader())
+	assert.False(t, b.isLeader())
+
+	// the leader renews the lock.
+	mr.FastForward(DefaultLeaderTTL / 2)
+	a.tryAcquire(log)
+	assert.True(t, a.isLeader())
+	assert.Equal(t, DefaultLeaderTTL, mr.TTL(DefaultLeaderKey))
+
+	// another replica takes over once the leader resigns.
+	a.resign(log)
+	a.resign(log)
+	b.tryAcquire(log)
+	a.tryAcquire(log)
+	assert.False(t, a.isLeader())
+	assert.True(t, b.isLeader())
+
+	// a replica that cannot reach Redis stops being leader.
+	mr.Close()
+	b.tryAcquire(log)
+	assert.False(t, b.isLeader())
+}
+
+func TestLeaderLock_TakenOver(t *testing.T) {
+	mr, client := newTestRedis(t)
+	log := logger.NewLogger()
+	l := newLeaderLock(client, "", 0)
+
+	l.tryAcquire(log)
+	require.True(t, l.isLeader())
+
+	// a leader whose lock expired and was taken over stops being leader.
+	require.NoError(t, mr.Set(DefaultLeaderKey, "other"))
+	l.tryAcquire(log)
+	assert.False(t, l.isLeader())
+
+	mr.Del(DefaultLeaderKey)
+	l.tryAcquire(log)
+	assert.True(t, l.isLeader())
+	l.resign(log)
+	assert.False(t, mr.Exists(DefaultLeaderKey))
+}
+
+func TestCronServer_LeaderLock(t *testing.T) {
+	_, client := newTestRedis(t)
+	conf := &serverconf.CronConfig{LeaderKey: "jobs:leader", LeaderTTL: 30 * time.Millisecond}
+
+	leader, err := NewCronServer(conf)
+	require.NoError(t, err)
+	leader.WithLeaderLock(client)
+	follower, err := NewCronServer(conf)
+	require.NoError(t, err)
+	follower.WithLeaderLock(client)
+
+	go leader.Start()
+	require.Eventually(t, leader.leader.isLeader, time.Second, time.Millisecond)
+	go follower.Start()
+
+	var runs int
+	job := func(context.Context) error {
+		runs++
+		return nil
+	}
+	leader.wrap(serverconf.CronJobConfig{Name: "job"}, job)()
+	follower.wrap(serverconf.CronJobConfig{Name: "job"}, job)()
+	assert.Equal(t, 1, runs)
+
+	leader.Stop()
+	require.Eventually(t, follower.leader.isLeader, time.Second, time.Millisecond)
+	follower.Stop()
+	assert.Zero(t, client.Exists("jobs:leader").Val())
+}
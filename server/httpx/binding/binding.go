@@ -0,0 +1,208 @@
+// Package binding decodes the parts of HTTP requests into structs by their tags, and validates
+// them.
+package binding
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hewen/mastiff-go/pkg/errorx"
+)
+
+const (
+	// TagQuery is the tag of the fields bound from the query of the request.
+	TagQuery = "query"
+	// TagURI is the tag of the fields bound from the parameters of the route.
+	TagURI = "uri"
+	// TagHeader is the tag of the fields bound from the headers of the request.
+	TagHeader = "header"
+	// TagForm is the tag of the fields bound from the form of the request body.
+	TagForm = "form"
+)
+
+const (
+	// MIMEJSON is the media type of JSON bodies.
+	MIMEJSON = "application/json"
+	// MIMEPOSTForm is the media type of URL encoded form bodies.
+	MIMEPOSTForm = "application/x-www-form-urlencoded"
+	// MIMEMultipartPOSTForm is the media type of multipart form bodies.
+	MIMEMultipartPOSTForm = "multipart/form-data"
+)
+
+var (
+	// ErrInvalidTarget is returned when binding into a value that is not a non-nil pointer.
+	ErrInvalidTarget = errors.New("binding: target must be a non-nil pointer")
+	// ErrUnsupportedMediaType is returned when binding a body of an unsupported media type.
+	ErrUnsupportedMediaType = errors.New("binding: unsupported media type")
+)
+
+// Getter returns the values of the key in a part of a request, and whether the key is present.
+type Getter func(key string) ([]string, bool)
+
+// Errors lists the invalid fields of a request. errorx.FromError answers them as
+// errorx.ErrInvalidRequest with the fields.
+type Errors = errorx.FieldErrors
+
+// Decode sets the fields of the struct obj points to with the values get returns for the name in
+// their tag. Fields without the tag are skipped, except structs whose fields are decoded in turn.
+// Empty values leave the field unset, and values not parsing into the field are returned as
+// Errors. Decode does nothing if obj does not point to a struct.
+func Decode(obj any, tag string, get Getter) error {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return ErrInvalidTarget
+	}
+	v = v.Elem()
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs Errors
+	decodeStruct(v, tag, get, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// JSON decodes the JSON body into obj, returning values of the wrong type as Errors.
+func JSON(body []byte, obj any) error {
+	err := json.Unmarshal(body, obj)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return Errors{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: fmt.Sprintf("%s is not a valid %s", typeErr.Field, typeErr.Type),
+		}}
+	}
+	return err
+}
+
+// decodeStruct decodes the fields of the struct v, appending the values not parsing to errs.
+func decodeStruct(v reflect.Value, tag string, get Getter, errs *Errors) {
+	t := v.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		fv := v.Field(i)
+		switch {
+		case name == "-":
+		case name == "":
+			if fv.Kind() == reflect.Struct && !isScalar(fv) {
+				decodeStruct(fv, tag, get, errs)
+			}
+		default:
+			values, ok := get(name)
+			if !ok || len(values) == 0 {
+				continue
+			}
+			if err := setField(fv, values); err != nil {
+				*errs = append(*errs, errorx.FieldError{
+					Field:   name,
+					Rule:    "type",
+					Message: fmt.Sprintf("%s is not a valid %s", name, f.Type),
+				})
+			}
+		}
+	}
+}
+
+// setField sets the field v to the values, all of them for slices and the first one otherwise.
+func setField(v reflect.Value, values []string) error {
+	switch {
+	case v.Kind() == reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := setField(elem.Elem(), values); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case v.Kind() == reflect.Slice && !isScalar(v):
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, s := range values {
+			if err := setValue(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	default:
+		return setValue(v, values[0])
+	}
+}
+
+// durationType is the type of time.Duration, parsed from its string form.
+var durationType = reflect.TypeFor[time.Duration]()
+
+// isScalar reports whether v is set from a single value even if it is a struct or a slice.
+func isScalar(v reflect.Value) bool {
+	if _, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return true
+	}
+	return v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8
+}
+
+// setValue parses s into v.
+func setValue(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	if v.Kind() == reflect.String {
+		v.SetString(s)
+		return nil
+	}
+	if s == "" {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == durationType {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		v.SetBytes([]byte(s))
+	default:
+		return fmt.Errorf("binding: unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package binding
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// values returns a getter of the values.
+func values(v map[string][]string) Getter {
+	return func(key string) ([]string, bool) {
+		values, ok := v[key]
+		return values, ok
+	}
+}
+
+type Page struct {
+	Size int `query:"size"`
+}
+
+type decodeTarget struct {
+	Page
+	Time     time.Time `query:"time"`
+	Name     string    `query:"name,omitempty"`
+	Optional *int      `query:"optional"`
+	Bytes    []byte    `query:"bytes"`
+	Tags     []string  `query:"tag"`
+	IDs      []uint16  `query:"id"`
+	Nested   struct {
+		Flag bool `query:"flag"`
+	}
+	Skipped  string `query:"-"`
+	Untagged string
+	Timeout  time.Duration `query:"timeout"`
+	Ratio    float32       `query:"ratio"`
+	Count    int8          `query:"count"`
+	private  string        `query:"private"`
+}
+
+func TestDecode(t *testing.T) {
+	var target decodeTarget
+	require.NoError(t, Decode(&target, TagQuery, values(map[string][]string{
+		"size":     {"10"},
+		"time":     {"2025-01-02T03:04:05Z"},
+		"name":     {"mastiff", "ignored"},
+		"optional": {"3"},
+		"bytes":    {"raw"},
+		"tag":      {"a", "b"},
+		"id":       {"1", "2"},
+		"flag":     {"true"},
+		"Skipped":  {"x"},
+		"-":        {"x"},
+		"Untagged": {"x"},
+		"timeout":  {"1m"},
+		"ratio":    {"0.5"},
+		"count":    {""},
+		"private":  {"x"},
+	})))
+
+	three := 3
+	assert.Equal(t, decodeTarget{
+		Page:     Page{Size: 10},
+		Time:     time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Name:     "mastiff",
+		Optional: &three,
+		Bytes:    []byte("raw"),
+		Tags:     []string{"a", "b"},
+		IDs:      []uint16{1, 2},
+		Nested: struct {
+			Flag bool `query:"flag"`
+		}{Flag: true},
+		Timeout: time.Minute,
+		Ratio:   0.5,
+	}, target)
+}
+
+func TestDecode_Errors(t *testing.T) {
+	var target decodeTarget
+	err := Decode(&target, TagQuery, values(map[string][]string{
+		"size":    {"x"},
+		"id":      {"1", "-1"},
+		"count":   {"128"},
+		"timeout": {"1"},
+		"flag":    {"maybe"},
+		"ratio":   {"half"},
+		"time":    {"today"},
+	}))
+
+	var errs Errors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, Errors{
+		{Field: "size", Rule: "type", Message: "size is not a valid int"},
+		{Field: "time", Rule: "type", Message: "time is not a valid time.Time"},
+		{Field: "id", Rule: "type", Message: "id is not a valid []uint16"},
+		{Field: "flag", Rule: "type", Message: "flag is not a valid bool"},
+		{Field: "timeout", Rule: "type", Message: "timeout is not a valid time.Duration"},
+		{Field: "ratio", Rule: "type", Message: "ratio is not a valid float32"},
+		{Field: "count", Rule: "type", Message: "count is not a valid int8"},
+	}, errs)
+	assert.Equal(t, "size is not a valid int; time is not a valid time.Time; id is not a valid []uint16; "+
+		"flag is not a valid bool; timeout is not a valid time.Duration; ratio is not a valid float32; "+
+		"count is not a valid int8", err.Error())
+
+	var unsupported struct {
+		Values map[string]string `query:"values"`
+	}
+	assert.Error(t, Decode(&unsupported, TagQuery, values(map[string][]string{"values": {"x"}})))
+}
+
+func TestDecode_Targets(t *testing.T) {
+	get := values(map[string][]string{"size": {"1"}})
+
+	assert.ErrorIs(t, Decode(Page{}, TagQuery, get), ErrInvalidTarget)
+	assert.ErrorIs(t, Decode((*Page)(nil), TagQuery, get), ErrInvalidTarget)
+
+	m := map[string]any{}
+	assert.NoError(t, Decode(&m, TagQuery, get))
+	assert.Empty(t, m)
+}
+
+func TestJSON(t *testing.T) {
+	var target struct {
+		Name string `json:"name"`
+		Page int    `json:"page"`
+	}
+	require.NoError(t, JSON([]byte(`{"name":"mastiff","page":1}`), &target))
+	assert.Equal(t, "mastiff", target.Name)
+	assert.Equal(t, 1, target.Page)
+
+	var errs Errors
+	require.ErrorAs(t, JSON([]byte(`{"page":"x"}`), &target), &errs)
+	assert.Equal(t, Errors{{Field: "page", Rule: "type", Message: "page is not a valid int"}}, errs)
+
+	err := JSON([]byte(`{"name":`), &target)
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &errs))
+}
@@ -0,0 +1,98 @@
+package binding
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/hewen/mastiff-go/pkg/errorx"
+)
+
+// StructValidator validates the structs requests are bound into.
+type StructValidator interface {
+	// ValidateStruct validates obj, returning its invalid fields as Errors.
+	ValidateStruct(obj any) error
+}
+
+// Validator is the StructValidator bound requests are validated with. It defaults to
+// go-playground/validator checking the rules of the "validate" tags, and may be replaced, or set
+// to nil to skip validation.
+var Validator StructValidator = &defaultValidator{}
+
+// Validate validates obj with the Validator.
+func Validate(obj any) error {
+	if Validator == nil {
+		return nil
+	}
+	return Validator.ValidateStruct(obj)
+}
+
+// defaultValidator validates structs with go-playground/validator, naming the fields as in the
+// request.
+type defaultValidator struct {
+	validate *validator.Validate
+	once     sync.Once
+}
+
+// ValidateStruct validates obj if it is a struct or points to one.
+func (v *defaultValidator) ValidateStruct(obj any) error {
+	rv := reflect.ValueOf(obj)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	v.once.Do(func() {
+		v.validate = validator.New(validator.WithRequiredStructEnabled())
+		v.validate.RegisterTagNameFunc(fieldName)
+	})
+
+	err := v.validate.Struct(rv.Interface())
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+
+	errs := make(Errors, len(fieldErrs))
+	for i, fe := range fieldErrs {
+		// the namespace starts with the name of the struct.
+		_, field, _ := strings.Cut(fe.Namespace(), ".")
+		errs[i] = errorx.FieldError{
+			Field:   field,
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: message(field, fe.Tag(), fe.Param()),
+		}
+	}
+	return errs
+}
+
+// nameTags are the tags a field is named by in errors, in order of preference.
+var nameTags = []string{"json", TagForm, TagQuery, TagURI, TagHeader}
+
+// fieldName returns the name of the field in the request.
+func fieldName(f reflect.StructField) string {
+	for _, tag := range nameTags {
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// message returns the message of a field breaking a rule.
+func message(field, rule, param string) string {
+	if param == "" {
+		return fmt.Sprintf("%s failed on the %s rule", field, rule)
+	}
+	return fmt.Sprintf("%s failed on the %s=%s rule", field, rule, param)
+}
@@ -0,0 +1,67 @@
+package binding
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type validateTarget struct {
+	Address struct {
+		City string `json:"city" validate:"required"`
+	} `json:"address"`
+	Name   string `json:"name" validate:"required,min=3"`
+	Page   int    `query:"page" validate:"gte=1"`
+	Token  string `json:"-" header:"X-Token" validate:"required"`
+	Status string `validate:"oneof=on off"`
+}
+
+func TestValidate(t *testing.T) {
+	err := Validate(&validateTarget{Name: "ab", Page: 1, Token: "t", Status: "on", Address: struct {
+		City string `json:"city" validate:"required"`
+	}{City: "x"}})
+
+	var errs Errors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, Errors{
+		{Field: "name", Rule: "min", Param: "3", Message: "name failed on the min=3 rule"},
+	}, errs)
+
+	require.ErrorAs(t, Validate(validateTarget{}), &errs)
+	assert.Equal(t, Errors{
+		{Field: "address.city", Rule: "required", Message: "address.city failed on the required rule"},
+		{Field: "name", Rule: "required", Message: "name failed on the required rule"},
+		{Field: "page", Rule: "gte", Param: "1", Message: "page failed on the gte=1 rule"},
+		{Field: "X-Token", Rule: "required", Message: "X-Token failed on the required rule"},
+		{Field: "Status", Rule: "oneof", Param: "on off", Message: "Status failed on the oneof=on off rule"},
+	}, errs)
+
+	// values other than structs are not validated.
+	assert.NoError(t, Validate(&map[string]string{}))
+	assert.NoError(t, Validate([]int{1}))
+	assert.NoError(t, Validate((*validateTarget)(nil)))
+}
+
+// validatorFunc is a StructValidator calling the function.
+type validatorFunc func(obj any) error
+
+func (f validatorFunc) ValidateStruct(obj any) error {
+	return f(obj)
+}
+
+func TestValidate_Validator(t *testing.T) {
+	defer func(v StructValidator) {
+		Validator = v
+	}(Validator)
+
+	errInvalid := errors.New("invalid")
+	Validator = validatorFunc(func(any) error {
+		return errInvalid
+	})
+	assert.ErrorIs(t, Validate(&validateTarget{}), errInvalid)
+
+	Validator = nil
+	assert.NoError(t, Validate(&validateTarget{}))
+}
@@ -0,0 +1,53 @@
+package httpx
+
+import (
+	"testing"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/server/httpx/handler"
+	"github.com/hewen/mastiff-go/server/httpx/httpxtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerConformance(t *testing.T) {
+	for _, framework := range []serverconf.HTTPFrameworkType{
+		serverconf.FrameworkGin,
+		serverconf.FrameworkFiber,
+		serverconf.FrameworkStd,
+	} {
+		t.Run(string(framework), func(t *testing.T) {
+			for _, name := range httpxtest.Behaviours() {
+				t.Run(name, func(t *testing.T) {
+					h, err := handler.NewHandler(&serverconf.HTTPConfig{FrameworkType: framework, Mode: "test"})
+					require.NoError(t, err)
+					httpxtest.Check(t, name, h)
+				})
+			}
+		})
+	}
+}
+
+func TestHandlerConformance_NetHTTP(t *testing.T) {
+	for _, framework := range []serverconf.HTTPFrameworkType{
+		serverconf.FrameworkGin,
+		serverconf.FrameworkStd,
+	} {
+		t.Run(string(framework), func(t *testing.T) {
+			for _, name := range httpxtest.NetHTTPBehaviours() {
+				t.Run(name, func(t *testing.T) {
+					h, err := handler.NewHandler(&serverconf.HTTPConfig{FrameworkType: framework, Mode: "test"})
+					require.NoError(t, err)
+					httpxtest.Check(t, name, h)
+				})
+			}
+		})
+	}
+}
+
+func TestHandlerConformance_Run(t *testing.T) {
+	httpxtest.Run(t, func() handler.HTTPHandler {
+		h, err := handler.NewHandler(&serverconf.HTTPConfig{FrameworkType: serverconf.FrameworkStd})
+		require.NoError(t, err)
+		return h
+	})
+}
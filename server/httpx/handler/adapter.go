@@ -21,14 +21,14 @@ func AsFiberHandler(handlers ...HTTPHandlerFunc) []fiber.Handler {
 	return out
 }
 
-// AsGinHandler converts a list of HTTPHandlerFunc to a list of gin.HandlerFunc.
+// AsGinHandler converts a list of HTTPHandlerFunc to a list of gin.HandlerFunc, run by
+// unicontext.HandleGin.
 func AsGinHandler(handlers ...HTTPHandlerFunc) []gin.HandlerFunc {
 	out := make([]gin.HandlerFunc, len(handlers))
 	for i, h := range handlers {
 		handler := h
 		out[i] = func(c *gin.Context) {
-			ctx := &unicontext.GinContext{Ctx: c}
-			_ = handler(ctx)
+			unicontext.HandleGin(c, handler)
 		}
 	}
 	return out
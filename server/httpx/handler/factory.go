@@ -1,4 +1,4 @@
-// Package handler provides a unified HTTP abstraction over Gin and Fiber.
+// Package handler provides a unified HTTP abstraction over Gin, Fiber and net/http.
 package handler
 
 import (
@@ -6,6 +6,9 @@ import (
 	"net/http/pprof"
 
 	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/health"
+	"github.com/hewen/mastiff-go/server/httpx/openapi"
+	"github.com/hewen/mastiff-go/server/listener"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -22,6 +25,8 @@ func NewHandler(conf *serverconf.HTTPConfig, opts ...ServerOption) (HTTPHandler,
 		h, err = NewGinHandler(conf)
 	case serverconf.FrameworkFiber:
 		h, err = NewFiberHandler(conf)
+	case serverconf.FrameworkStd:
+		h, err = NewStdHandler(conf)
 	default:
 		return nil, fmt.Errorf("unsupported http type: %s", conf.FrameworkType)
 	}
@@ -47,6 +52,22 @@ func NewHandler(conf *serverconf.HTTPConfig, opts ...ServerOption) (HTTPHandler,
 // ServerOption is a function that configures a HTTP server.
 type ServerOption func(HTTPHandler)
 
+// listenerSetter is implemented by the handlers listening with a listener factory.
+type listenerSetter interface {
+	setListeners(f *listener.Factory)
+}
+
+// WithListeners makes the server listen with the listener factory, so it inherits its listener
+// from the process that started this one by an upgrade and hands it over on the next upgrade.
+// Without it, the server listens on its own.
+func WithListeners(f *listener.Factory) ServerOption {
+	return func(h HTTPHandler) {
+		if s, ok := h.(listenerSetter); ok {
+			s.setListeners(f)
+		}
+	}
+}
+
 // WithMetrics adds a metrics handler to the server.
 func WithMetrics() ServerOption {
 	return func(h HTTPHandler) {
@@ -54,6 +75,15 @@ func WithMetrics() ServerOption {
 	}
 }
 
+// WithHealth adds the liveness and readiness handlers of the health registry to the server,
+// at /healthz and /readyz.
+func WithHealth(r *health.Registry) ServerOption {
+	return func(h HTTPHandler) {
+		h.Get("/healthz", FromHTTPHandler(r.LivenessHandler()))
+		h.Get("/readyz", FromHTTPHandler(r.ReadinessHandler()))
+	}
+}
+
 // WithPprof adds a pprof handler to the server.
 func WithPprof() ServerOption {
 	return func(h HTTPHandler) {
@@ -64,3 +94,22 @@ func WithPprof() ServerOption {
 		h.Get("/debug/pprof/trace", FromHTTPHandlerFunc(pprof.Trace))
 	}
 }
+
+// WithOpenAPI adds a handler serving the OpenAPI document of the routes the server records to
+// the server, at /openapi.json. Only the routes registered with handler.Handle are recorded;
+// routes added with the methods of the server or its groups are left out of the document.
+func WithOpenAPI(info openapi.Info) ServerOption {
+	return func(h HTTPHandler) {
+		if r, ok := h.(RouteRecorder); ok {
+			h.Get(openapi.DocumentPath, FromHTTPHandler(openapi.Handler(r.Routes(), info)))
+		}
+	}
+}
+
+// WithOpenAPIViewer adds a page viewing the OpenAPI document served by WithOpenAPI to the
+// server, at /docs.
+func WithOpenAPIViewer(title string) ServerOption {
+	return func(h HTTPHandler) {
+		h.Get(openapi.ViewerPath, FromHTTPHandler(openapi.ViewerHandler(title, openapi.DocumentPath)))
+	}
+}
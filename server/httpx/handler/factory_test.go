@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,7 +11,10 @@ import (
 	"testing"
 
 	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/health"
+	"github.com/hewen/mastiff-go/server/httpx/openapi"
 	"github.com/hewen/mastiff-go/server/httpx/unicontext"
+	"github.com/hewen/mastiff-go/server/listener"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -105,6 +110,18 @@ func TestNewHandler(t *testing.T) {
 		assert.Contains(t, handler.Name(), "fiber")
 	})
 
+	t.Run("std handler", func(t *testing.T) {
+		conf := &serverconf.HTTPConfig{
+			Addr:          ":8081",
+			FrameworkType: serverconf.FrameworkStd,
+		}
+
+		handler, err := NewHandler(conf)
+		assert.NoError(t, err)
+		assert.NotNil(t, handler)
+		assert.Contains(t, handler.Name(), "std")
+	})
+
 	t.Run("unknown framework type", func(t *testing.T) {
 		conf := &serverconf.HTTPConfig{
 			Addr:          ":8082",
@@ -217,6 +234,105 @@ func TestWithMetrics(t *testing.T) {
 	})
 }
 
+func TestWithHealth(t *testing.T) {
+	for _, framework := range []serverconf.HTTPFrameworkType{serverconf.FrameworkGin, serverconf.FrameworkFiber} {
+		t.Run(string(framework), func(t *testing.T) {
+			r := health.NewRegistry()
+			r.Register("db", health.CheckerFunc(func(context.Context) error {
+				return nil
+			}))
+
+			handler, err := NewHandler(&serverconf.HTTPConfig{FrameworkType: framework}, WithHealth(r))
+			require.NoError(t, err)
+
+			get := func(path string) int {
+				resp, err := handler.Test(httptest.NewRequest("GET", path, nil))
+				require.NoError(t, err)
+				defer func() {
+					_ = resp.Body.Close()
+				}()
+				return resp.StatusCode
+			}
+
+			assert.Equal(t, http.StatusOK, get("/healthz"))
+			assert.Equal(t, http.StatusOK, get("/readyz"))
+
+			// readiness fails once the service is shutting down.
+			r.Shutdown()
+			assert.Equal(t, http.StatusOK, get("/healthz"))
+			assert.Equal(t, http.StatusServiceUnavailable, get("/readyz"))
+		})
+	}
+}
+
+func TestWithOpenAPI(t *testing.T) {
+	frameworks := []serverconf.HTTPFrameworkType{serverconf.FrameworkGin, serverconf.FrameworkFiber, serverconf.FrameworkStd}
+	for _, framework := range frameworks {
+		t.Run(string(framework), func(t *testing.T) {
+			handler, err := NewHandler(&serverconf.HTTPConfig{FrameworkType: framework},
+				WithOpenAPI(openapi.Info{Title: "test", Version: "1.0.0"}), WithOpenAPIViewer("test"))
+			require.NoError(t, err)
+
+			// routes are recorded with their path relative to the root of the handler.
+			root, ok := handler.(RouteRecorder)
+			require.True(t, ok)
+			root.RecordRoute(openapi.Route{Method: http.MethodGet, Path: "ping"})
+			group, ok := handler.Group("/api").Group("/v1").(RouteRecorder)
+			require.True(t, ok)
+			group.RecordRoute(openapi.Route{Method: http.MethodPost, Path: "/users/:id"})
+			assert.Same(t, root.Routes(), group.Routes())
+			assert.Equal(t, []openapi.Route{
+				{Method: http.MethodGet, Path: "/ping"},
+				{Method: http.MethodPost, Path: "/api/v1/users/:id"},
+			}, root.Routes().Routes())
+
+			resp, err := handler.Test(httptest.NewRequest(http.MethodGet, openapi.DocumentPath, nil))
+			require.NoError(t, err)
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			var doc openapi.Document
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+			assert.Equal(t, openapi.Info{Title: "test", Version: "1.0.0"}, doc.Info)
+			assert.Contains(t, doc.Paths, "/ping")
+			assert.Contains(t, doc.Paths, "/api/v1/users/{id}")
+
+			resp, err = handler.Test(httptest.NewRequest(http.MethodGet, openapi.ViewerPath, nil))
+			require.NoError(t, err)
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Contains(t, string(body), "<title>test</title>")
+		})
+	}
+}
+
+func TestWithListeners(t *testing.T) {
+	listeners, err := listener.New()
+	require.NoError(t, err)
+
+	frameworks := []serverconf.HTTPFrameworkType{serverconf.FrameworkGin, serverconf.FrameworkFiber, serverconf.FrameworkStd}
+	for _, framework := range frameworks {
+		t.Run(string(framework), func(t *testing.T) {
+			handler, err := NewHandler(&serverconf.HTTPConfig{FrameworkType: framework}, WithListeners(listeners))
+			require.NoError(t, err)
+
+			switch h := handler.(type) {
+			case *GinHandler:
+				assert.Same(t, listeners, h.listeners)
+			case *FiberHandler:
+				assert.Same(t, listeners, h.listeners)
+			case *StdHandler:
+				assert.Same(t, listeners, h.listeners)
+			}
+		})
+	}
+}
+
 func TestWithPprof(t *testing.T) {
 	t.Run("gin handler with pprof", func(t *testing.T) {
 		conf := &serverconf.HTTPConfig{
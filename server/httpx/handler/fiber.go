@@ -4,22 +4,47 @@ package handler
 import (
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/server/httpx/openapi"
+	"github.com/hewen/mastiff-go/server/httpx/unicontext"
+	"github.com/hewen/mastiff-go/server/listener"
 )
 
 // FiberHandler is a handler that provides a unified HTTP abstraction over Fiber.
 type FiberHandler struct {
 	RouterGroup
-	app  *fiber.App
-	addr string
-	name string
+	app       *fiber.App
+	routes    *openapi.Registry
+	listeners *listener.Factory
+	ready     chan struct{}
+	addr      string
+	name      string
 }
 
-// Start starts the FiberHandler.
+// Start starts the FiberHandler. Prefork binds its own sockets with SO_REUSEPORT, so only
+// without it the listener comes from the listener factory.
 func (f *FiberHandler) Start() error {
-	return f.app.Listen(f.addr)
+	if f.app.Config().Prefork {
+		return f.app.Listen(f.addr)
+	}
+	ln, err := f.listeners.Listen("tcp", f.addr)
+	if err != nil {
+		return err
+	}
+	return f.app.Listener(ln)
+}
+
+// setListeners sets the factory the FiberHandler listens with.
+func (f *FiberHandler) setListeners(l *listener.Factory) {
+	f.listeners = l
+}
+
+// Ready returns a channel closed once the FiberHandler listens.
+func (f *FiberHandler) Ready() <-chan struct{} {
+	return f.ready
 }
 
 // Stop stops the FiberHandler.
@@ -44,7 +69,18 @@ func (f *FiberHandler) Use(handlers ...HTTPHandlerFunc) Router {
 	for i := range h {
 		args[i] = h[i]
 	}
-	return newFiberRouterGroup(f.app.Use(args...))
+	return newFiberRouterGroup(f.app.Use(args...), f.routes)
+}
+
+// RecordRoute records the route, whose path is relative to the root of the FiberHandler.
+func (f *FiberHandler) RecordRoute(route openapi.Route) {
+	route.Path = joinPaths("/", route.Path)
+	f.routes.Add(route)
+}
+
+// Routes returns the registry the routes of the FiberHandler are recorded in.
+func (f *FiberHandler) Routes() *openapi.Registry {
+	return f.routes
 }
 
 // getFiberConfig returns the Fiber configuration.
@@ -68,6 +104,15 @@ func getFiberConfig(mode string) fiber.Config {
 	}
 }
 
+// writeFiberError writes the error returned by the chain of handlers as a 500 response, or with
+// the code of a *fiber.Error, unless one was written already.
+func writeFiberError(c *fiber.Ctx, err error) error {
+	if (&unicontext.FiberContext{Ctx: c}).Written() {
+		return nil
+	}
+	return fiber.DefaultErrorHandler(c, err)
+}
+
 // NewFiberHandler creates a new FiberHandler.
 func NewFiberHandler(conf *serverconf.HTTPConfig) (HTTPHandler, error) {
 	if conf == nil {
@@ -78,12 +123,25 @@ func NewFiberHandler(conf *serverconf.HTTPConfig) (HTTPHandler, error) {
 	fiberConfig.ReadTimeout = toDuration(conf.ReadTimeout)
 	fiberConfig.WriteTimeout = toDuration(conf.WriteTimeout)
 	fiberConfig.IdleTimeout = toDuration(conf.IdleTimeout)
+	fiberConfig.ErrorHandler = writeFiberError
 
 	app := fiber.New(fiberConfig)
 
+	ready := make(chan struct{})
+	var readyOnce sync.Once
+	app.Hooks().OnListen(func(fiber.ListenData) error {
+		readyOnce.Do(func() {
+			close(ready)
+		})
+		return nil
+	})
+
+	routes := openapi.NewRegistry()
 	return &FiberHandler{
-		RouterGroup: newFiberRouterGroup(app),
+		RouterGroup: newFiberRouterGroup(app, routes),
 		app:         app,
+		routes:      routes,
+		ready:       ready,
 		addr:        conf.Addr,
 		name:        "fiber",
 	}, nil
@@ -92,18 +150,35 @@ func NewFiberHandler(conf *serverconf.HTTPConfig) (HTTPHandler, error) {
 // FiberRouterGroup implements the RouterGroup interface for Fiber.
 type FiberRouterGroup struct {
 	Router
-	r fiber.Router
+	r      fiber.Router
+	routes *openapi.Registry
 }
 
 // Group creates a new router group with the given relative path and handlers.
 func (group *FiberRouterGroup) Group(relativePath string, handlers ...HTTPHandlerFunc) RouterGroup {
-	return newFiberRouterGroup(group.r.Group(relativePath, AsFiberHandler(handlers...)...))
+	return newFiberRouterGroup(group.r.Group(relativePath, AsFiberHandler(handlers...)...), group.routes)
+}
+
+// RecordRoute records the route, whose path is relative to the group.
+func (group *FiberRouterGroup) RecordRoute(route openapi.Route) {
+	prefix := "/"
+	if g, ok := group.r.(*fiber.Group); ok {
+		prefix = g.Prefix
+	}
+	route.Path = joinPaths(prefix, route.Path)
+	group.routes.Add(route)
+}
+
+// Routes returns the registry the routes of the group are recorded in.
+func (group *FiberRouterGroup) Routes() *openapi.Registry {
+	return group.routes
 }
 
 // FiberRouter implements the Router interface for Fiber.
 type FiberRouter struct {
 	Router
-	r fiber.Router
+	r      fiber.Router
+	routes *openapi.Registry
 }
 
 // Use adds middleware to the router.
@@ -113,7 +188,7 @@ func (f *FiberRouter) Use(handlers ...HTTPHandlerFunc) Router {
 	for i := range h {
 		args[i] = h[i]
 	}
-	return newFiberRouterGroup(f.r.Use(args...))
+	return newFiberRouterGroup(f.r.Use(args...), f.routes)
 }
 
 // Handle adds a route with the given method and path.
@@ -179,14 +254,15 @@ func (f *FiberRouter) Match(methods []string, path string, handlers ...HTTPHandl
 }
 
 // newFiberRouterGroup creates a new RouterGroup by fiber.Router.
-func newFiberRouterGroup(r fiber.Router) RouterGroup {
+func newFiberRouterGroup(r fiber.Router, routes *openapi.Registry) RouterGroup {
 	return &FiberRouterGroup{
-		Router: newFiberRouter(r),
+		Router: newFiberRouter(r, routes),
 		r:      r,
+		routes: routes,
 	}
 }
 
 // newFiberRouter creates a new Router by fiber.Router.
-func newFiberRouter(r fiber.Router) Router {
-	return &FiberRouter{r: r}
+func newFiberRouter(r fiber.Router, routes *openapi.Registry) Router {
+	return &FiberRouter{r: r, routes: routes}
 }
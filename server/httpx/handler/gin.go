@@ -8,10 +8,14 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/server/httpx/openapi"
+	"github.com/hewen/mastiff-go/server/httpx/unicontext"
+	"github.com/hewen/mastiff-go/server/listener"
 )
 
 // GinHandler is a handler that provides a unified HTTP abstraction over Gin.
@@ -20,12 +24,38 @@ type GinHandler struct {
 	name      string
 	addr      string
 	ginEngine *gin.Engine
+	routes    *openapi.Registry
+	listeners *listener.Factory
+	ready     chan struct{}
 	server    http.Server
+	readyOnce sync.Once
 }
 
 // Start starts the GinHandler.
 func (g *GinHandler) Start() error {
-	return g.server.ListenAndServe()
+	addr := g.server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	ln, err := g.listeners.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	g.readyOnce.Do(func() {
+		close(g.ready)
+	})
+	return g.server.Serve(ln)
+}
+
+// setListeners sets the factory the GinHandler listens with.
+func (g *GinHandler) setListeners(f *listener.Factory) {
+	g.listeners = f
+}
+
+// Ready returns a channel closed once the GinHandler listens.
+func (g *GinHandler) Ready() <-chan struct{} {
+	return g.ready
 }
 
 // Stop stops the GinHandler.
@@ -46,6 +76,17 @@ func (g *GinHandler) Use(handler ...HTTPHandlerFunc) Router {
 	return newGinRouter(g.ginEngine.Use(AsGinHandler(handler...)...))
 }
 
+// RecordRoute records the route, whose path is relative to the root of the GinHandler.
+func (g *GinHandler) RecordRoute(route openapi.Route) {
+	route.Path = joinPaths("/", route.Path)
+	g.routes.Add(route)
+}
+
+// Routes returns the registry the routes of the GinHandler are recorded in.
+func (g *GinHandler) Routes() *openapi.Registry {
+	return g.routes
+}
+
 // Test sends a request to the GinHandler and returns the response.
 func (g *GinHandler) Test(req *http.Request, msTimeout ...int) (*http.Response, error) {
 	w := httptest.NewRecorder()
@@ -63,6 +104,15 @@ func (g *GinHandler) Test(req *http.Request, msTimeout ...int) (*http.Response,
 	return resp, nil
 }
 
+// writeGinError writes the error returned by the chain of handlers as a 500 response, unless
+// one was written already.
+func writeGinError(c *gin.Context) {
+	c.Next()
+	if err := unicontext.GinError(c); err != nil && !c.Writer.Written() {
+		c.String(http.StatusInternalServerError, err.Error())
+	}
+}
+
 // NewGinHandler creates a new GinHandler.
 func NewGinHandler(conf *serverconf.HTTPConfig) (HTTPHandler, error) {
 	if conf == nil {
@@ -71,10 +121,15 @@ func NewGinHandler(conf *serverconf.HTTPConfig) (HTTPHandler, error) {
 
 	gin.SetMode(conf.Mode)
 	r := gin.New()
+	r.HandleMethodNotAllowed = true
+	r.Use(writeGinError)
+	routes := openapi.NewRegistry()
 
 	return &GinHandler{
-		RouterGroup: newGinRouterGroup(&r.RouterGroup),
+		RouterGroup: newGinRouterGroup(&r.RouterGroup, routes),
 		ginEngine:   r,
+		routes:      routes,
+		ready:       make(chan struct{}),
 		name:        "gin",
 		addr:        conf.Addr,
 		server: http.Server{
@@ -90,12 +145,24 @@ func NewGinHandler(conf *serverconf.HTTPConfig) (HTTPHandler, error) {
 // GinRouterGroup implements the RouterGroup interface for Gin.
 type GinRouterGroup struct {
 	Router
-	r *gin.RouterGroup
+	r      *gin.RouterGroup
+	routes *openapi.Registry
 }
 
 // Group creates a new router group with the given relative path and handlers.
 func (group *GinRouterGroup) Group(relativePath string, handlers ...HTTPHandlerFunc) RouterGroup {
-	return newGinRouterGroup(group.r.Group(relativePath, AsGinHandler(handlers...)...))
+	return newGinRouterGroup(group.r.Group(relativePath, AsGinHandler(handlers...)...), group.routes)
+}
+
+// RecordRoute records the route, whose path is relative to the group.
+func (group *GinRouterGroup) RecordRoute(route openapi.Route) {
+	route.Path = joinPaths(group.r.BasePath(), route.Path)
+	group.routes.Add(route)
+}
+
+// Routes returns the registry the routes of the group are recorded in.
+func (group *GinRouterGroup) Routes() *openapi.Registry {
+	return group.routes
 }
 
 // GinRouter implements the Router interface for Gin.
@@ -170,10 +237,11 @@ func (g *GinRouter) Match(methods []string, path string, handlers ...HTTPHandler
 }
 
 // newGinRouterGroup creates a new RouterGroup by gin.RouterGroup.
-func newGinRouterGroup(r *gin.RouterGroup) RouterGroup {
+func newGinRouterGroup(r *gin.RouterGroup, routes *openapi.Registry) RouterGroup {
 	return &GinRouterGroup{
 		Router: newGinRouter(r),
 		r:      r,
+		routes: routes,
 	}
 }
 
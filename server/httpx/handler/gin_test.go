@@ -390,13 +390,13 @@ func TestGinRouter_Match(t *testing.T) {
 		assert.Contains(t, string(body), "matched")
 	}
 
-	// Test with non-allowed method (Gin returns 404 for unmatched routes)
+	// Test with non-allowed method
 	req := httptest.NewRequest("DELETE", "/match-endpoint", nil)
 	resp, err := handler.Test(req)
 	assert.NoError(t, err)
 	defer func() { _ = resp.Body.Close() }()
 
-	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
 }
 
 func TestNewGinHandler_EdgeCases(t *testing.T) {
@@ -0,0 +1,289 @@
+// Package handler provides a unified HTTP abstraction over net/http ServeMux.
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/server/httpx/openapi"
+	"github.com/hewen/mastiff-go/server/httpx/unicontext"
+	"github.com/hewen/mastiff-go/server/listener"
+)
+
+// StdHandler is a handler that provides a unified HTTP abstraction over the pattern routing of
+// net/http ServeMux, following the contract of HTTPHandler. Routes take ":name" parameters, and
+// "*name" and "*" ones matching the rest of the path.
+type StdHandler struct {
+	*StdRouterGroup
+	mux       *http.ServeMux
+	routes    *openapi.Registry
+	listeners *listener.Factory
+	templates *template.Template
+	ready     chan struct{}
+	name      string
+	addr      string
+	server    http.Server
+	readyOnce sync.Once
+}
+
+// Start starts the StdHandler.
+func (h *StdHandler) Start() error {
+	addr := h.server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	ln, err := h.listeners.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	h.readyOnce.Do(func() {
+		close(h.ready)
+	})
+	return h.server.Serve(ln)
+}
+
+// setListeners sets the factory the StdHandler listens with.
+func (h *StdHandler) setListeners(f *listener.Factory) {
+	h.listeners = f
+}
+
+// Ready returns a channel closed once the StdHandler listens.
+func (h *StdHandler) Ready() <-chan struct{} {
+	return h.ready
+}
+
+// Stop stops the StdHandler.
+func (h *StdHandler) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return h.server.Shutdown(ctx)
+}
+
+// Name returns the name of the StdHandler.
+func (h *StdHandler) Name() string {
+	return fmt.Sprintf("http %s server(%s)", h.name, h.addr)
+}
+
+// SetHTMLTemplate sets the templates HTML responses are rendered with.
+func (h *StdHandler) SetHTMLTemplate(t *template.Template) {
+	h.templates = t
+}
+
+// ServeHTTP dispatches the request to the handlers of the matching route. Requests matching no
+// route run the middlewares of the root group before the not found or method not allowed
+// response.
+func (h *StdHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if notFound, pattern := h.mux.Handler(r); pattern == "" {
+		h.serve(w, r, "", slices.Concat(h.handlers, []HTTPHandlerFunc{FromHTTPHandler(notFound)}))
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// Test sends a request to the StdHandler and returns the response.
+func (h *StdHandler) Test(req *http.Request, msTimeout ...int) (*http.Response, error) {
+	w := httptest.NewRecorder()
+
+	if len(msTimeout) > 0 && msTimeout[0] > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), time.Duration(msTimeout[0])*time.Millisecond)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	h.ServeHTTP(w, req)
+
+	resp := w.Result()
+	resp.Body = io.NopCloser(bytes.NewBuffer(w.Body.Bytes()))
+	return resp, nil
+}
+
+// serve runs the handlers of the route on the request.
+func (h *StdHandler) serve(w http.ResponseWriter, r *http.Request, route string, handlers []HTTPHandlerFunc) {
+	chain := make([]func(unicontext.UniversalContext) error, len(handlers))
+	for i, handler := range handlers {
+		chain[i] = handler
+	}
+
+	c := unicontext.NewStdContext(w, r, chain...)
+	c.Route = route
+	c.Templates = h.templates
+	if err := c.Next(); err != nil && !c.Written() {
+		_ = c.Text(http.StatusInternalServerError, err.Error())
+	}
+}
+
+// NewStdHandler creates a new StdHandler.
+func NewStdHandler(conf *serverconf.HTTPConfig) (HTTPHandler, error) {
+	if conf == nil {
+		return nil, ErrEmptyHTTPConf
+	}
+
+	h := &StdHandler{
+		mux:    http.NewServeMux(),
+		routes: openapi.NewRegistry(),
+		ready:  make(chan struct{}),
+		name:   "std",
+		addr:   conf.Addr,
+	}
+	h.StdRouterGroup = &StdRouterGroup{h: h, prefix: "/"}
+	h.server = http.Server{
+		Addr:         conf.Addr,
+		Handler:      h,
+		ReadTimeout:  toDuration(conf.ReadTimeout),
+		WriteTimeout: toDuration(conf.WriteTimeout),
+		IdleTimeout:  toDuration(conf.IdleTimeout),
+	}
+	return h, nil
+}
+
+// StdRouterGroup implements the RouterGroup interface for net/http ServeMux. Its middlewares
+// apply to the routes added after them.
+type StdRouterGroup struct {
+	h        *StdHandler
+	prefix   string
+	handlers []HTTPHandlerFunc
+}
+
+// Group creates a new router group with the given relative path and handlers.
+func (g *StdRouterGroup) Group(relativePath string, handlers ...HTTPHandlerFunc) RouterGroup {
+	return &StdRouterGroup{
+		h:        g.h,
+		prefix:   joinPaths(g.prefix, relativePath),
+		handlers: slices.Concat(g.handlers, handlers),
+	}
+}
+
+// Use adds middleware to the router.
+func (g *StdRouterGroup) Use(handlers ...HTTPHandlerFunc) Router {
+	g.handlers = slices.Concat(g.handlers, handlers)
+	return g
+}
+
+// RecordRoute records the route, whose path is relative to the group.
+func (g *StdRouterGroup) RecordRoute(route openapi.Route) {
+	route.Path = joinPaths(g.prefix, route.Path)
+	g.h.routes.Add(route)
+}
+
+// Routes returns the registry the routes of the group are recorded in.
+func (g *StdRouterGroup) Routes() *openapi.Registry {
+	return g.h.routes
+}
+
+// Handle adds a route with the given method and path.
+func (g *StdRouterGroup) Handle(method, path string, handlers ...HTTPHandlerFunc) Router {
+	return g.handle(method, path, handlers)
+}
+
+// Any adds a route that matches all HTTP methods.
+func (g *StdRouterGroup) Any(path string, handlers ...HTTPHandlerFunc) Router {
+	return g.handle("", path, handlers)
+}
+
+// Get adds a route that matches GET requests.
+func (g *StdRouterGroup) Get(path string, handlers ...HTTPHandlerFunc) Router {
+	return g.handle(http.MethodGet, path, handlers)
+}
+
+// Post adds a route that matches POST requests.
+func (g *StdRouterGroup) Post(path string, handlers ...HTTPHandlerFunc) Router {
+	return g.handle(http.MethodPost, path, handlers)
+}
+
+// Delete adds a route that matches DELETE requests.
+func (g *StdRouterGroup) Delete(path string, handlers ...HTTPHandlerFunc) Router {
+	return g.handle(http.MethodDelete, path, handlers)
+}
+
+// Patch adds a route that matches PATCH requests.
+func (g *StdRouterGroup) Patch(path string, handlers ...HTTPHandlerFunc) Router {
+	return g.handle(http.MethodPatch, path, handlers)
+}
+
+// Put adds a route that matches PUT requests.
+func (g *StdRouterGroup) Put(path string, handlers ...HTTPHandlerFunc) Router {
+	return g.handle(http.MethodPut, path, handlers)
+}
+
+// Options adds a route that matches OPTIONS requests.
+func (g *StdRouterGroup) Options(path string, handlers ...HTTPHandlerFunc) Router {
+	return g.handle(http.MethodOptions, path, handlers)
+}
+
+// Head adds a route that matches HEAD requests.
+func (g *StdRouterGroup) Head(path string, handlers ...HTTPHandlerFunc) Router {
+	return g.handle(http.MethodHead, path, handlers)
+}
+
+// Match adds a route that matches the given HTTP methods.
+func (g *StdRouterGroup) Match(methods []string, path string, handlers ...HTTPHandlerFunc) Router {
+	for _, method := range methods {
+		g.handle(method, path, handlers)
+	}
+	return g
+}
+
+// handle registers the route on the ServeMux of the handler, matching any method if method
+// is empty.
+func (g *StdRouterGroup) handle(method, relativePath string, handlers []HTTPHandlerFunc) Router {
+	route := joinPaths(g.prefix, relativePath)
+	pattern := toPattern(route)
+	if method != "" {
+		pattern = method + " " + pattern
+	}
+
+	chain := slices.Concat(g.handlers, handlers)
+	g.h.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		g.h.serve(w, r, route, chain)
+	})
+	return g
+}
+
+// toPattern converts a route with ":name", "*name" and "*" parameters to a ServeMux pattern
+// matching it exactly.
+func toPattern(route string) string {
+	segments := strings.Split(route, "/")
+	for i, s := range segments {
+		switch {
+		case strings.HasPrefix(s, ":"):
+			segments[i] = "{" + s[1:] + "}"
+		case s == "*":
+			segments[i] = "{" + unicontext.WildcardParam + "...}"
+		case strings.HasPrefix(s, "*"):
+			segments[i] = "{" + s[1:] + "...}"
+		}
+	}
+
+	pattern := strings.Join(segments, "/")
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "{$}"
+	}
+	return pattern
+}
+
+// joinPaths joins a relative path to an absolute one, keeping the trailing slash of the
+// relative path.
+func joinPaths(absolutePath, relativePath string) string {
+	if relativePath == "" {
+		return absolutePath
+	}
+
+	finalPath := path.Join(absolutePath, relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(finalPath, "/") {
+		return finalPath + "/"
+	}
+	return finalPath
+}
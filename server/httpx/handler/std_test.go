@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/pkg/util"
+	"github.com/hewen/mastiff-go/server/httpx/unicontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStdHandler(t *testing.T) *StdHandler {
+	h, err := NewHandler(&serverconf.HTTPConfig{
+		Addr:          "localhost:8080",
+		FrameworkType: serverconf.FrameworkStd,
+	})
+	require.NoError(t, err)
+	return h.(*StdHandler)
+}
+
+// get sends a request to the handler and returns the status code and body of the response.
+func get(t *testing.T, h HTTPHandler, method, target string) (int, string) {
+	resp, err := h.Test(httptest.NewRequest(method, target, nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestStdHandler(t *testing.T) {
+	port, err := util.GetFreePort()
+	require.NoError(t, err)
+
+	h, err := NewStdHandler(&serverconf.HTTPConfig{
+		Addr:          fmt.Sprintf("localhost:%d", port),
+		FrameworkType: serverconf.FrameworkStd,
+	})
+	require.NoError(t, err)
+	h.Get("/test", func(c unicontext.UniversalContext) error {
+		return c.JSON(http.StatusOK, map[string]string{"message": "ok"})
+	})
+	assert.Equal(t, fmt.Sprintf("http std server(localhost:%d)", port), h.Name())
+
+	served := make(chan error, 1)
+	go func() {
+		served <- h.Start()
+	}()
+	<-h.(*StdHandler).Ready()
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/test", port))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"message":"ok"}`, string(body))
+
+	require.NoError(t, h.Stop())
+	assert.ErrorIs(t, <-served, http.ErrServerClosed)
+}
+
+func TestStdHandler_Test(t *testing.T) {
+	testBasicHandlerFunctionality(t, newTestStdHandler(t))
+}
+
+func TestStdRouterGroup_Group(t *testing.T) {
+	testRouterGroupFunctionality(t, newTestStdHandler(t))
+}
+
+func TestStdRouter_Use(t *testing.T) {
+	testRouterUseFunctionality(t, newTestStdHandler(t))
+}
+
+func TestStdRouter_HTTPMethods(t *testing.T) {
+	testHTTPMethods(t, newTestStdHandler(t))
+}
+
+func TestStdRouter_AnyAndMatch(t *testing.T) {
+	h := newTestStdHandler(t)
+	h.Any("/any", func(c unicontext.UniversalContext) error {
+		return c.Text(http.StatusOK, c.Method())
+	})
+	h.Match([]string{http.MethodGet, http.MethodPost}, "/match", func(c unicontext.UniversalContext) error {
+		return c.Text(http.StatusOK, "matched")
+	})
+	h.Handle("TRACE", "/trace", func(c unicontext.UniversalContext) error {
+		return c.Text(http.StatusAccepted, "trace")
+	})
+
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		code, body := get(t, h, method, "/any")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, method, body)
+	}
+	code, _ := get(t, h, http.MethodPost, "/match")
+	assert.Equal(t, http.StatusOK, code)
+	// a method not allowed on an existing route is rejected with 405.
+	code, _ = get(t, h, http.MethodDelete, "/match")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	code, _ = get(t, h, "TRACE", "/trace")
+	assert.Equal(t, http.StatusAccepted, code)
+}
+
+func TestStdRouter_Params(t *testing.T) {
+	h := newTestStdHandler(t)
+	users := h.Group("/users/:id")
+	users.Get("/posts/:post", func(c unicontext.UniversalContext) error {
+		return c.Text(http.StatusOK, c.Param("id")+" "+c.Param("post")+" "+c.FullPath())
+	})
+	h.Get("/files/*path", func(c unicontext.UniversalContext) error {
+		return c.Text(http.StatusOK, c.Param("path"))
+	})
+	h.Get("/static/*", func(c unicontext.UniversalContext) error {
+		return c.Text(http.StatusOK, c.Param("*"))
+	})
+	h.Get("/", func(c unicontext.UniversalContext) error {
+		return c.Text(http.StatusOK, "root")
+	})
+
+	code, body := get(t, h, http.MethodGet, "/users/1/posts/2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "1 2 /users/:id/posts/:post", body)
+
+	_, body = get(t, h, http.MethodGet, "/files/a/b.txt")
+	assert.Equal(t, "a/b.txt", body)
+	_, body = get(t, h, http.MethodGet, "/static/css/app.css")
+	assert.Equal(t, "css/app.css", body)
+
+	// "/" matches the root only, not every path.
+	_, body = get(t, h, http.MethodGet, "/")
+	assert.Equal(t, "root", body)
+	code, _ = get(t, h, http.MethodGet, "/unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestStdRouter_Middleware(t *testing.T) {
+	h := newTestStdHandler(t)
+	var order []string
+	record := func(name string) HTTPHandlerFunc {
+		return func(c unicontext.UniversalContext) error {
+			order = append(order, name+" before")
+			err := c.Next()
+			order = append(order, name+" after")
+			return err
+		}
+	}
+
+	h.Get("/early", func(c unicontext.UniversalContext) error {
+		return c.Text(http.StatusOK, "early")
+	})
+	h.Use(record("root"))
+	api := h.Group("/api", record("group"))
+	api.Use(record("use"))
+	api.Get("/test", func(c unicontext.UniversalContext) error {
+		order = append(order, "handler")
+		return c.Text(http.StatusOK, "ok")
+	})
+
+	_, body := get(t, h, http.MethodGet, "/api/test")
+	assert.Equal(t, "ok", body)
+	assert.Equal(t, []string{
+		"root before", "group before", "use before", "handler", "use after", "group after", "root after",
+	}, order)
+
+	// middlewares apply to the routes added after them.
+	order = nil
+	get(t, h, http.MethodGet, "/early")
+	assert.Empty(t, order)
+
+	// unmatched requests run the middlewares of the root group.
+	order = nil
+	code, _ := get(t, h, http.MethodGet, "/missing")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, []string{"root before", "root after"}, order)
+}
+
+func TestStdRouter_Abort(t *testing.T) {
+	h := newTestStdHandler(t)
+	var called bool
+	h.Get("/abort", func(c unicontext.UniversalContext) error {
+		_ = c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return c.Next()
+	}, func(unicontext.UniversalContext) error {
+		called = true
+		return nil
+	})
+	h.Get("/stop", func(c unicontext.UniversalContext) error {
+		return c.Text(http.StatusOK, "stopped")
+	}, func(unicontext.UniversalContext) error {
+		called = true
+		return nil
+	})
+	h.Get("/error", func(unicontext.UniversalContext) error {
+		return errors.New("boom")
+	})
+
+	code, body := get(t, h, http.MethodGet, "/abort")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.JSONEq(t, `{"error":"unauthorized"}`, body)
+	assert.False(t, called)
+
+	// like fiber, the chain stops at a handler not calling Next.
+	_, body = get(t, h, http.MethodGet, "/stop")
+	assert.Equal(t, "stopped", body)
+	assert.False(t, called)
+
+	code, body = get(t, h, http.MethodGet, "/error")
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, "boom", body)
+}
+
+func TestStdHandler_HTML(t *testing.T) {
+	h := newTestStdHandler(t)
+	h.Get("/page", func(c unicontext.UniversalContext) error {
+		return c.HTML(http.StatusOK, "page", map[string]string{"Name": "mastiff"})
+	})
+
+	code, body := get(t, h, http.MethodGet, "/page")
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, unicontext.ErrNoTemplates.Error(), body)
+
+	h.SetHTMLTemplate(template.Must(template.New("page").Parse(`<p>{{.Name}}</p>`)))
+	code, body = get(t, h, http.MethodGet, "/page")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "<p>mastiff</p>", body)
+}
+
+func TestToPattern(t *testing.T) {
+	assert.Equal(t, "/{$}", toPattern("/"))
+	assert.Equal(t, "/users/{id}", toPattern("/users/:id"))
+	assert.Equal(t, "/users/{$}", toPattern("/users/"))
+	assert.Equal(t, "/files/{path...}", toPattern("/files/*path"))
+	assert.Equal(t, "/static/{wildcard...}", toPattern("/static/*"))
+
+	assert.Equal(t, "/api", joinPaths("/api", ""))
+	assert.Equal(t, "/api/users/", joinPaths("/api", "/users/"))
+	assert.Equal(t, "/test", joinPaths("/", "test"))
+}
+
+func TestNewStdHandler_EdgeCases(t *testing.T) {
+	h, err := NewStdHandler(nil)
+	assert.Nil(t, h)
+	assert.Equal(t, ErrEmptyHTTPConf, err)
+}
@@ -1,4 +1,4 @@
-// Package handler provides a unified HTTP abstraction over Gin and Fiber.
+// Package handler provides a unified HTTP abstraction over Gin, Fiber and net/http.
 package handler
 
 import (
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/hewen/mastiff-go/server/httpx/openapi"
 	"github.com/hewen/mastiff-go/server/httpx/unicontext"
 )
 
@@ -22,7 +23,20 @@ const (
 // HTTPHandlerFunc is the function signature for HTTP handlers.
 type HTTPHandlerFunc func(unicontext.UniversalContext) error
 
-// HTTPHandler represents a unified HTTP server.
+// HTTPHandler represents a unified HTTP server. Its backends follow the same contract, checked by
+// the conformance suite of httpxtest:
+//   - middlewares apply to the routes added after them, and run before the handlers of the route
+//     in the order they were added. Requests matching no route run the middlewares of the root
+//     group before a 404 response, or a 405 one when the path is routed for other methods.
+//   - the chain of handlers stops at a handler not calling Next, or once aborted. Next returns
+//     the error of the handlers it ran.
+//   - an error returned by the chain is written as a 500 response, unless one was written already.
+//   - FullPath returns the pattern of the matched route, in the middlewares of groups too.
+//   - BindJSON binds the body as JSON whatever its content type, and the body can be read again.
+//   - a "*name" parameter holds the rest of the path, without its leading slash.
+//
+// The backends serving net/http requests also keep the context of the incoming request; fiber
+// serves fasthttp requests, which carry none.
 type HTTPHandler interface {
 	RouterGroup
 
@@ -53,6 +67,15 @@ type Router interface {
 	Match(methods []string, path string, handlers ...HTTPHandlerFunc) Router
 }
 
+// RouteRecorder is implemented by the handlers and their router groups, recording the routes
+// described in their OpenAPI document.
+type RouteRecorder interface {
+	// RecordRoute records the route, whose path is relative to the group.
+	RecordRoute(route openapi.Route)
+	// Routes returns the registry the routes of the handler are recorded in.
+	Routes() *openapi.Registry
+}
+
 // toDuration converts a timeout in seconds to a time.Duration.
 func toDuration(sec int64) time.Duration {
 	if sec == 0 {
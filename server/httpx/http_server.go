@@ -2,11 +2,15 @@
 package httpx
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
 	"sync"
 
 	"github.com/hewen/mastiff-go/config/serverconf"
 	"github.com/hewen/mastiff-go/logger"
 	"github.com/hewen/mastiff-go/server/httpx/handler"
+	"github.com/hewen/mastiff-go/server/httpx/openapi"
 )
 
 // HTTPServer is a server that provides a unified HTTP abstraction over Gin.
@@ -30,12 +34,27 @@ func NewHTTPServer(conf *serverconf.HTTPConfig, opts ...handler.ServerOption) (*
 
 }
 
-// Start starts the HTTPServer.
+// Start starts the HTTPServer, logging the error if it fails.
 func (s *HTTPServer) Start() {
-	if err := s.HTTPHandler.Start(); err != nil {
-		s.logger.Panicf("http server start failed: %v", err)
+	if err := s.Serve(); err != nil {
+		s.logger.Errorf("%v", err)
 	}
+}
+
+// Serve starts the HTTPServer and blocks until it is stopped, returning the error if it fails.
+func (s *HTTPServer) Serve() error {
+	if err := s.HTTPHandler.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("http server start failed: %w", err)
+	}
+	return nil
+}
 
+// Ready returns a channel closed once the HTTPServer listens, or nil if the handler does not report it.
+func (s *HTTPServer) Ready() <-chan struct{} {
+	if r, ok := s.HTTPHandler.(interface{ Ready() <-chan struct{} }); ok {
+		return r.Ready()
+	}
+	return nil
 }
 
 // Stop stops the HTTPServer.
@@ -53,6 +72,15 @@ func (s *HTTPServer) Name() string {
 	return s.HTTPHandler.Name()
 }
 
+// OpenAPI returns the OpenAPI document of the routes the HTTPServer records.
+func (s *HTTPServer) OpenAPI(info openapi.Info) *openapi.Document {
+	var routes []openapi.Route
+	if r, ok := s.HTTPHandler.(handler.RouteRecorder); ok {
+		routes = r.Routes().Routes()
+	}
+	return openapi.NewDocument(info, routes)
+}
+
 // WithLogger sets the logger for the HTTPServer.
 func (s *HTTPServer) WithLogger(l logger.Logger) {
 	if l != nil {
@@ -3,11 +3,13 @@ package httpx
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/hewen/mastiff-go/config/serverconf"
 	"github.com/hewen/mastiff-go/logger"
 	"github.com/hewen/mastiff-go/pkg/util"
 	"github.com/hewen/mastiff-go/server/httpx/handler"
+	"github.com/hewen/mastiff-go/server/httpx/openapi"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -57,12 +59,57 @@ func TestHTTPServerStartError(t *testing.T) {
 	s, err := NewHTTPServer(conf)
 	assert.Nil(t, err)
 
-	assert.Panics(t, func() {
+	assert.NotPanics(t, func() {
 		s.Start()
 	})
+	assert.ErrorContains(t, s.Serve(), "http server start failed")
+}
+
+func TestHTTPServerReady(t *testing.T) {
+	for _, framework := range []serverconf.HTTPFrameworkType{serverconf.FrameworkGin, serverconf.FrameworkFiber} {
+		t.Run(string(framework), func(t *testing.T) {
+			port, err := util.GetFreePort()
+			assert.Nil(t, err)
+
+			s, err := NewHTTPServer(&serverconf.HTTPConfig{
+				Addr:          fmt.Sprintf("localhost:%d", port),
+				FrameworkType: framework,
+			})
+			assert.Nil(t, err)
+
+			served := make(chan error, 1)
+			go func() {
+				served <- s.Serve()
+			}()
+
+			select {
+			case <-s.Ready():
+			case <-time.After(time.Second):
+				t.Fatal("server is not ready")
+			}
+
+			// a stopped server is not an error.
+			s.Stop()
+			assert.NoError(t, <-served)
+		})
+	}
 }
 
 func TestHTTPServerEmptyConfig(t *testing.T) {
 	_, err := NewHTTPServer(nil)
 	assert.EqualValues(t, err, handler.ErrEmptyHTTPConf)
 }
+
+func TestHTTPServerOpenAPI(t *testing.T) {
+	s, err := NewHTTPServer(&serverconf.HTTPConfig{FrameworkType: serverconf.FrameworkStd})
+	assert.Nil(t, err)
+
+	s.Group("/api").(handler.RouteRecorder).RecordRoute(openapi.Route{Method: "GET", Path: "/users/:id"})
+	doc := s.OpenAPI(openapi.Info{Title: "users", Version: "1.0.0"})
+	assert.Equal(t, "users", doc.Info.Title)
+	assert.Contains(t, doc.Paths, "/api/users/{id}")
+
+	// handlers not recording their routes have an empty document.
+	s.HTTPHandler = struct{ handler.HTTPHandler }{s.HTTPHandler}
+	assert.Empty(t, s.OpenAPI(openapi.Info{}).Paths)
+}
@@ -0,0 +1,711 @@
+// Package httpxtest provides a conformance suite for the backends of handler.HTTPHandler.
+//
+// The suite checks the behaviour shared by all backends; a new backend must pass every check.
+// The backends serving net/http requests must also pass the checks of NetHTTPBehaviours.
+// It depends on no test framework, reporting failures through TB.
+package httpxtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/hewen/mastiff-go/server/httpx/handler"
+	"github.com/hewen/mastiff-go/server/httpx/unicontext"
+)
+
+// TB is the part of testing.TB the suite reports through.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+	Fatalf(format string, args ...any)
+	TempDir() string
+}
+
+// behaviour is a behaviour of the suite, checked against a new handler.
+type behaviour struct {
+	test func(t TB, h handler.HTTPHandler)
+	name string
+}
+
+var behaviours = []behaviour{
+	{name: "Routing", test: testRouting},
+	{name: "MethodNotAllowed", test: testMethodNotAllowed},
+	{name: "Params", test: testParams},
+	{name: "Groups", test: testGroups},
+	{name: "GroupMiddlewareFullPath", test: testGroupMiddlewareFullPath},
+	{name: "MiddlewareOrder", test: testMiddlewareOrder},
+	{name: "Abort", test: testAbort},
+	{name: "NextAfterAbort", test: testNextAfterAbort},
+	{name: "StopWithoutNext", test: testStopWithoutNext},
+	{name: "Errors", test: testErrors},
+	{name: "ErrorAfterResponse", test: testErrorAfterResponse},
+	{name: "Responses", test: testResponses},
+	{name: "BindJSON", test: testBindJSON},
+	{name: "BindJSONWithoutContentType", test: testBindJSONWithoutContentType},
+	{name: "BodyReread", test: testBodyReread},
+	{name: "Form", test: testForm},
+	{name: "Bind", test: testBind},
+	{name: "Files", test: testFiles},
+	{name: "Context", test: testContext},
+}
+
+// netHTTPBehaviours are the behaviours of the backends serving net/http requests. fiber serves
+// fasthttp requests, which carry no context from the caller.
+var netHTTPBehaviours = []behaviour{
+	{name: "IncomingRequestContext", test: testIncomingRequestContext},
+}
+
+// Behaviours returns the names of the behaviours of the suite, in the order Run checks them.
+func Behaviours() []string {
+	names := make([]string, len(behaviours))
+	for i, b := range behaviours {
+		names[i] = b.name
+	}
+	return names
+}
+
+// NetHTTPBehaviours returns the names of the behaviours of the suite the backends serving
+// net/http requests follow besides Behaviours, so the context of the incoming request.
+func NetHTTPBehaviours() []string {
+	names := make([]string, len(netHTTPBehaviours))
+	for i, b := range netHTTPBehaviours {
+		names[i] = b.name
+	}
+	return names
+}
+
+// Run checks every behaviour of Behaviours, each against a handler created by newHandler.
+// Failures are prefixed with the name of their behaviour.
+func Run(t TB, newHandler func() handler.HTTPHandler) {
+	t.Helper()
+	for _, b := range behaviours {
+		b.test(prefixed{TB: t, name: b.name}, newHandler())
+	}
+}
+
+// Check checks the named behaviour of the suite against the handler, so callers can run each
+// behaviour as a subtest.
+func Check(t TB, name string, h handler.HTTPHandler) {
+	t.Helper()
+	for _, b := range slices.Concat(behaviours, netHTTPBehaviours) {
+		if b.name == name {
+			b.test(t, h)
+			return
+		}
+	}
+	t.Fatalf("unknown behaviour %q", name)
+}
+
+// prefixed prefixes the failures reported through TB with the name of a behaviour.
+type prefixed struct {
+	TB
+	name string
+}
+
+// Errorf reports a failure prefixed with the name of the behaviour.
+func (p prefixed) Errorf(format string, args ...any) {
+	p.TB.Helper()
+	p.TB.Errorf("%s: %s", p.name, fmt.Sprintf(format, args...))
+}
+
+// Fatalf reports a failure prefixed with the name of the behaviour and stops the test.
+func (p prefixed) Fatalf(format string, args ...any) {
+	p.TB.Helper()
+	p.TB.Fatalf("%s: %s", p.name, fmt.Sprintf(format, args...))
+}
+
+// equal reports a failure of the check named what when got is not want.
+func equal(t TB, what string, want, got any) {
+	t.Helper()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s: got %#v, want %#v", what, got, want)
+	}
+}
+
+// equalJSON reports a failure of the check named what when the JSON documents differ.
+func equalJSON(t TB, what, want, got string) {
+	t.Helper()
+	var w, g any
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("%s: invalid expected JSON: %v", what, err)
+	}
+	if err := json.Unmarshal([]byte(got), &g); err != nil {
+		t.Errorf("%s: invalid JSON %q: %v", what, got, err)
+		return
+	}
+	if !reflect.DeepEqual(w, g) {
+		t.Errorf("%s: got %s, want %s", what, got, want)
+	}
+}
+
+// must stops the test on an error of the step named what.
+func must(t TB, what string, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %v", what, err)
+	}
+}
+
+// response is a response received from a handler.
+type response struct {
+	header http.Header
+	body   string
+	code   int
+}
+
+// do sends the request to the handler and returns its response.
+func do(t TB, h handler.HTTPHandler, req *http.Request) response {
+	t.Helper()
+	resp, err := h.Test(req)
+	must(t, req.Method+" "+req.URL.String(), err)
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	must(t, "read body", err)
+	return response{code: resp.StatusCode, header: resp.Header, body: string(body)}
+}
+
+// send sends a request without body to the handler and returns its response.
+func send(t TB, h handler.HTTPHandler, method, target string) response {
+	t.Helper()
+	return do(t, h, httptest.NewRequest(method, target, nil))
+}
+
+// post sends a POST request with the body and content type to the handler.
+func post(t TB, h handler.HTTPHandler, target, contentType, body string) response {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return do(t, h, req)
+}
+
+// text returns a handler answering the given text with 200.
+func text(s string) handler.HTTPHandlerFunc {
+	return func(c unicontext.UniversalContext) error {
+		return c.Text(http.StatusOK, s)
+	}
+}
+
+// mediaType returns the media type of the Content-Type header, without its parameters.
+func mediaType(header http.Header) string {
+	mt, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return mt
+}
+
+func testRouting(t TB, h handler.HTTPHandler) {
+	h.Get("/", text("root"))
+	h.Get("/get", text("get"))
+	h.Post("/post", text("post"))
+	h.Put("/put", text("put"))
+	h.Patch("/patch", text("patch"))
+	h.Delete("/delete", text("delete"))
+	h.Options("/options", text("options"))
+	h.Head("/head", text("head"))
+	h.Handle(http.MethodPost, "/handle", text("handle"))
+	h.Match([]string{http.MethodGet, http.MethodPut}, "/match", text("match"))
+	h.Any("/any", func(c unicontext.UniversalContext) error {
+		return c.Text(http.StatusOK, c.Method())
+	})
+
+	for _, tc := range []struct{ method, target, body string }{
+		{http.MethodGet, "/", "root"},
+		{http.MethodGet, "/get", "get"},
+		{http.MethodPost, "/post", "post"},
+		{http.MethodPut, "/put", "put"},
+		{http.MethodPatch, "/patch", "patch"},
+		{http.MethodDelete, "/delete", "delete"},
+		{http.MethodOptions, "/options", "options"},
+		{http.MethodPost, "/handle", "handle"},
+		{http.MethodGet, "/match", "match"},
+		{http.MethodPut, "/match", "match"},
+		{http.MethodGet, "/any", http.MethodGet},
+		{http.MethodDelete, "/any", http.MethodDelete},
+	} {
+		resp := send(t, h, tc.method, tc.target)
+		equal(t, tc.method+" "+tc.target+" status", http.StatusOK, resp.code)
+		equal(t, tc.method+" "+tc.target+" body", tc.body, resp.body)
+	}
+	equal(t, "HEAD /head status", http.StatusOK, send(t, h, http.MethodHead, "/head").code)
+	equal(t, "unrouted path status", http.StatusNotFound, send(t, h, http.MethodGet, "/missing").code)
+}
+
+func testMethodNotAllowed(t TB, h handler.HTTPHandler) {
+	h.Get("/get", text("get"))
+	h.Match([]string{http.MethodGet, http.MethodPut}, "/match", text("match"))
+
+	equal(t, "POST /get status", http.StatusMethodNotAllowed, send(t, h, http.MethodPost, "/get").code)
+	equal(t, "DELETE /match status", http.StatusMethodNotAllowed, send(t, h, http.MethodDelete, "/match").code)
+}
+
+func testParams(t TB, h handler.HTTPHandler) {
+	h.Get("/users/:id/posts/:post", func(c unicontext.UniversalContext) error {
+		return c.Text(http.StatusOK, c.Param("id")+" "+c.Param("post")+" "+c.Param("missing")+"|"+c.FullPath())
+	})
+	h.Get("/search", func(c unicontext.UniversalContext) error {
+		return c.Text(http.StatusOK, c.Query("q")+" "+c.Query("missing")+"|"+c.Path())
+	})
+	h.Get("/headers", func(c unicontext.UniversalContext) error {
+		return c.Text(http.StatusOK, c.Header("X-Token")+" "+c.Cookie("session")+" "+c.Cookie("missing"))
+	})
+
+	resp := send(t, h, http.MethodGet, "/users/1/posts/2")
+	equal(t, "path params status", http.StatusOK, resp.code)
+	equal(t, "path params", "1 2 |/users/:id/posts/:post", resp.body)
+
+	resp = send(t, h, http.MethodGet, "/search?q=mastiff%20go")
+	equal(t, "query", "mastiff go |/search", resp.body)
+
+	req := httptest.NewRequest(http.MethodGet, "/headers", nil)
+	req.Header.Set("X-Token", "secret")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	equal(t, "headers and cookies", "secret abc ", do(t, h, req).body)
+}
+
+func testGroups(t TB, h handler.HTTPHandler) {
+	var fullPath string
+	api := h.Group("/api", func(c unicontext.UniversalContext) error {
+		return c.Next()
+	})
+	api.Get("/status", text("status"))
+	users := api.Group("/users/:id")
+	users.Get("/posts/:post", func(c unicontext.UniversalContext) error {
+		fullPath = c.FullPath()
+		return c.Text(http.StatusOK, c.Param("id")+" "+c.Param("post"))
+	})
+
+	equal(t, "group route", "status", send(t, h, http.MethodGet, "/api/status").body)
+	resp := send(t, h, http.MethodGet, "/api/users/1/posts/2")
+	equal(t, "nested group status", http.StatusOK, resp.code)
+	equal(t, "nested group params", "1 2", resp.body)
+	equal(t, "nested group full path", "/api/users/:id/posts/:post", fullPath)
+
+	equal(t, "path outside group status", http.StatusNotFound, send(t, h, http.MethodGet, "/status").code)
+}
+
+func testGroupMiddlewareFullPath(t TB, h handler.HTTPHandler) {
+	var fullPaths []string
+	api := h.Group("/api", func(c unicontext.UniversalContext) error {
+		fullPaths = append(fullPaths, c.FullPath())
+		return c.Next()
+	})
+	api.Get("/status", text("status"))
+	api.Group("/users/:id").Get("/posts/:post", text("post"))
+
+	send(t, h, http.MethodGet, "/api/status")
+	send(t, h, http.MethodGet, "/api/users/1/posts/2")
+	equal(t, "full paths seen by the group middleware",
+		[]string{"/api/status", "/api/users/:id/posts/:post"}, fullPaths)
+}
+
+func testMiddlewareOrder(t TB, h handler.HTTPHandler) {
+	var order []string
+	record := func(name string) handler.HTTPHandlerFunc {
+		return func(c unicontext.UniversalContext) error {
+			order = append(order, name+" before")
+			err := c.Next()
+			order = append(order, name+" after")
+			return err
+		}
+	}
+
+	h.Use(record("root"))
+	api := h.Group("/api", record("group"))
+	api.Use(record("use"))
+	api.Get("/test", record("route"), func(c unicontext.UniversalContext) error {
+		order = append(order, "handler")
+		return c.Text(http.StatusOK, "ok")
+	})
+
+	equal(t, "body", "ok", send(t, h, http.MethodGet, "/api/test").body)
+	equal(t, "order", []string{
+		"root before", "group before", "use before", "route before", "handler",
+		"route after", "use after", "group after", "root after",
+	}, order)
+
+	// unmatched requests run the middlewares of the root group.
+	order = nil
+	equal(t, "unrouted path status", http.StatusNotFound, send(t, h, http.MethodGet, "/missing").code)
+	equal(t, "unrouted path order", []string{"root before", "root after"}, order)
+}
+
+// recordCalls returns a handler recording its name in called.
+func recordCalls(called *[]string, name string) handler.HTTPHandlerFunc {
+	return func(unicontext.UniversalContext) error {
+		*called = append(*called, name)
+		return nil
+	}
+}
+
+func testAbort(t TB, h handler.HTTPHandler) {
+	var called []string
+	h.Get("/abort", func(c unicontext.UniversalContext) error {
+		return c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}, recordCalls(&called, "abort"))
+
+	resp := send(t, h, http.MethodGet, "/abort")
+	equal(t, "status", http.StatusUnauthorized, resp.code)
+	equal(t, "media type", "application/json", mediaType(resp.header))
+	equalJSON(t, "body", `{"error":"unauthorized"}`, resp.body)
+	equal(t, "handlers called after abort", []string(nil), called)
+}
+
+func testNextAfterAbort(t TB, h handler.HTTPHandler) {
+	var called []string
+	h.Get("/abort-next", func(c unicontext.UniversalContext) error {
+		_ = c.AbortWithStatusJSON(http.StatusForbidden, map[string]string{"error": "forbidden"})
+		return c.Next()
+	}, recordCalls(&called, "abort-next"))
+
+	resp := send(t, h, http.MethodGet, "/abort-next")
+	equal(t, "status", http.StatusForbidden, resp.code)
+	equalJSON(t, "body", `{"error":"forbidden"}`, resp.body)
+	equal(t, "handlers called by Next after abort", []string(nil), called)
+}
+
+func testStopWithoutNext(t TB, h handler.HTTPHandler) {
+	var called []string
+	h.Get("/stop", text("stopped"), recordCalls(&called, "stop"))
+
+	equal(t, "body", "stopped", send(t, h, http.MethodGet, "/stop").body)
+	equal(t, "handlers called without Next", []string(nil), called)
+}
+
+func testErrors(t TB, h handler.HTTPHandler) {
+	h.Get("/error", func(unicontext.UniversalContext) error {
+		return errors.New("boom")
+	})
+
+	resp := send(t, h, http.MethodGet, "/error")
+	equal(t, "status", http.StatusInternalServerError, resp.code)
+	equal(t, "body", "boom", resp.body)
+}
+
+func testErrorAfterResponse(t TB, h handler.HTTPHandler) {
+	h.Get("/written", func(c unicontext.UniversalContext) error {
+		_ = c.Text(http.StatusBadRequest, "bad")
+		return errors.New("boom")
+	})
+
+	// a response written already is kept.
+	resp := send(t, h, http.MethodGet, "/written")
+	equal(t, "status", http.StatusBadRequest, resp.code)
+	equal(t, "body", "bad", resp.body)
+}
+
+func testResponses(t TB, h handler.HTTPHandler) {
+	var before, after int
+	h.Use(func(c unicontext.UniversalContext) error {
+		before = c.StatusCode()
+		err := c.Next()
+		after = c.StatusCode()
+		return err
+	})
+	h.Get("/json", func(c unicontext.UniversalContext) error {
+		return c.JSON(http.StatusCreated, map[string]string{"message": "ok"})
+	})
+	h.Get("/text", func(c unicontext.UniversalContext) error {
+		return c.Text(http.StatusAccepted, "text")
+	})
+	h.Get("/string", func(c unicontext.UniversalContext) error {
+		return c.String(http.StatusOK, "hello %s", "world")
+	})
+	h.Get("/data", func(c unicontext.UniversalContext) error {
+		return c.Data(http.StatusOK, "application/octet-stream", []byte{1, 2})
+	})
+	h.Get("/redirect", func(c unicontext.UniversalContext) error {
+		return c.Redirect(http.StatusFound, "/login")
+	})
+	h.Get("/writer", func(c unicontext.UniversalContext) error {
+		c.ResponseWriter().Header().Set("X-Test", "1")
+		c.ResponseWriter().WriteHeader(http.StatusTeapot)
+		_, err := c.ResponseWriter().Write([]byte("writer"))
+		return err
+	})
+
+	resp := send(t, h, http.MethodGet, "/json")
+	equal(t, "JSON status", http.StatusCreated, resp.code)
+	equal(t, "JSON media type", "application/json", mediaType(resp.header))
+	equalJSON(t, "JSON body", `{"message":"ok"}`, resp.body)
+	// StatusCode is 200 until the response is written.
+	equal(t, "status code before writing", http.StatusOK, before)
+	equal(t, "status code after writing", http.StatusCreated, after)
+
+	resp = send(t, h, http.MethodGet, "/text")
+	equal(t, "text status", http.StatusAccepted, resp.code)
+	equal(t, "text media type", "text/plain", mediaType(resp.header))
+	equal(t, "text body", "text", resp.body)
+
+	equal(t, "formatted string", "hello world", send(t, h, http.MethodGet, "/string").body)
+
+	resp = send(t, h, http.MethodGet, "/data")
+	equal(t, "data media type", "application/octet-stream", mediaType(resp.header))
+	equal(t, "data body", string([]byte{1, 2}), resp.body)
+
+	resp = send(t, h, http.MethodGet, "/redirect")
+	equal(t, "redirect status", http.StatusFound, resp.code)
+	equal(t, "redirect location", "/login", resp.header.Get("Location"))
+
+	resp = send(t, h, http.MethodGet, "/writer")
+	equal(t, "writer status", http.StatusTeapot, resp.code)
+	equal(t, "writer header", "1", resp.header.Get("X-Test"))
+	equal(t, "writer body", "writer", resp.body)
+	equal(t, "status code after writer", http.StatusTeapot, after)
+}
+
+// bindName returns a handler answering the name bound with BindJSON.
+func bindName() handler.HTTPHandlerFunc {
+	return func(c unicontext.UniversalContext) error {
+		var p struct {
+			Name string `json:"name"`
+		}
+		if err := c.BindJSON(&p); err != nil {
+			return c.Text(http.StatusBadRequest, "invalid")
+		}
+		return c.Text(http.StatusOK, p.Name)
+	}
+}
+
+func testBindJSON(t TB, h handler.HTTPHandler) {
+	h.Post("/bind", bindName())
+
+	resp := post(t, h, "/bind", "application/json", `{"name":"mastiff"}`)
+	equal(t, "status", http.StatusOK, resp.code)
+	equal(t, "body", "mastiff", resp.body)
+
+	equal(t, "invalid JSON status", http.StatusBadRequest, post(t, h, "/bind", "application/json", `{"name":`).code)
+}
+
+func testBindJSONWithoutContentType(t TB, h handler.HTTPHandler) {
+	h.Post("/bind", bindName())
+
+	resp := post(t, h, "/bind", "", `{"name":"mastiff"}`)
+	equal(t, "status", http.StatusOK, resp.code)
+	equal(t, "body", "mastiff", resp.body)
+}
+
+func testBodyReread(t TB, h handler.HTTPHandler) {
+	h.Post("/bind", func(c unicontext.UniversalContext) error {
+		var p struct {
+			Name string `json:"name"`
+		}
+		if err := c.BindJSON(&p); err != nil {
+			return c.Text(http.StatusBadRequest, "invalid")
+		}
+		body, err := c.Body()
+		if err != nil {
+			return err
+		}
+		return c.Text(http.StatusOK, p.Name+"|"+string(body))
+	})
+	h.Post("/body", func(c unicontext.UniversalContext) error {
+		first, err := c.Body()
+		if err != nil {
+			return err
+		}
+		second, err := c.Body()
+		if err != nil {
+			return err
+		}
+		return c.Text(http.StatusOK, string(first)+"|"+string(second))
+	})
+
+	resp := post(t, h, "/bind", "application/json", `{"name":"mastiff"}`)
+	equal(t, "body after BindJSON", `mastiff|{"name":"mastiff"}`, resp.body)
+	resp = post(t, h, "/body", "text/plain", "content")
+	equal(t, "body read twice", "content|content", resp.body)
+}
+
+func testForm(t TB, h handler.HTTPHandler) {
+	h.Post("/form", func(c unicontext.UniversalContext) error {
+		return c.Text(http.StatusOK, c.FormValue("name")+" "+c.FormValue("missing"))
+	})
+	h.Post("/upload", func(c unicontext.UniversalContext) error {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return c.Text(http.StatusBadRequest, "missing file")
+		}
+		return c.Text(http.StatusOK, fmt.Sprintf("%s %d %s", fh.Filename, fh.Size, c.FormValue("name")))
+	})
+
+	resp := post(t, h, "/form", "application/x-www-form-urlencoded", url.Values{"name": {"mastiff"}}.Encode())
+	equal(t, "form value", "mastiff ", resp.body)
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	must(t, "write field", mw.WriteField("name", "mastiff"))
+	fw, err := mw.CreateFormFile("file", "test.txt")
+	must(t, "create form file", err)
+	_, err = fw.Write([]byte("content"))
+	must(t, "write form file", err)
+	must(t, "close multipart writer", mw.Close())
+
+	resp = post(t, h, "/upload", mw.FormDataContentType(), buf.String())
+	equal(t, "upload status", http.StatusOK, resp.code)
+	equal(t, "upload", "test.txt 7 mastiff", resp.body)
+
+	resp = post(t, h, "/upload", "application/x-www-form-urlencoded", url.Values{"name": {"mastiff"}}.Encode())
+	equal(t, "missing file status", http.StatusBadRequest, resp.code)
+}
+
+// bindRequest is the request testBind binds.
+type bindRequest struct {
+	Token string   `header:"X-Token" validate:"required"`
+	Name  string   `json:"name" form:"name" validate:"required"`
+	Tags  []string `query:"tag"`
+	ID    int      `uri:"id"`
+	Page  int      `query:"page" validate:"gte=1"`
+}
+
+func testBind(t TB, h handler.HTTPHandler) {
+	bind := func(method func(unicontext.UniversalContext, any) error) handler.HTTPHandlerFunc {
+		return func(c unicontext.UniversalContext) error {
+			var req bindRequest
+			if err := method(c, &req); err != nil {
+				return c.JSON(http.StatusBadRequest, err)
+			}
+			return c.JSON(http.StatusOK, req)
+		}
+	}
+	h.Post("/bind/:id", bind(unicontext.UniversalContext.Bind))
+	h.Get("/query", bind(unicontext.UniversalContext.BindQuery))
+	h.Get("/uri/:id", bind(unicontext.UniversalContext.BindURI))
+	h.Get("/header", bind(unicontext.UniversalContext.BindHeader))
+	h.Post("/form", bind(unicontext.UniversalContext.BindForm))
+
+	request := func(method, target, contentType, body string) response {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		req.Header.Set("X-Token", "secret")
+		return do(t, h, req)
+	}
+	valid := `{"Token":"secret","name":"mastiff","ID":7,"Tags":["a","b"],"Page":2}`
+
+	resp := request(http.MethodPost, "/bind/7?tag=a&tag=b&page=2", "application/json", `{"name":"mastiff"}`)
+	equal(t, "JSON status", http.StatusOK, resp.code)
+	equalJSON(t, "JSON", valid, resp.body)
+
+	resp = request(http.MethodPost, "/bind/7?tag=a&tag=b&page=2", "", `{"name":"mastiff"}`)
+	equalJSON(t, "JSON without content type", valid, resp.body)
+
+	resp = request(http.MethodPost, "/bind/7?tag=a&tag=b&page=2", "application/x-www-form-urlencoded", "name=mastiff")
+	equalJSON(t, "form", valid, resp.body)
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	must(t, "write field", mw.WriteField("name", "mastiff"))
+	must(t, "close multipart writer", mw.Close())
+	resp = request(http.MethodPost, "/bind/7?tag=a&tag=b&page=2", mw.FormDataContentType(), buf.String())
+	equalJSON(t, "multipart form", valid, resp.body)
+
+	resp = request(http.MethodPost, "/bind/x?page=0", "application/json", `{}`)
+	equal(t, "invalid param status", http.StatusBadRequest, resp.code)
+	equalJSON(t, "invalid param", `[{"field":"id","rule":"type","message":"id is not a valid int"}]`, resp.body)
+
+	resp = request(http.MethodPost, "/bind/7?page=0", "application/json", `{}`)
+	equal(t, "invalid request status", http.StatusBadRequest, resp.code)
+	equalJSON(t, "invalid request", `[
+		{"field":"name","rule":"required","message":"name failed on the required rule"},
+		{"field":"page","rule":"gte","param":"1","message":"page failed on the gte=1 rule"}
+	]`, resp.body)
+
+	resp = request(http.MethodPost, "/bind/7?page=1", "text/plain", "mastiff")
+	equal(t, "unsupported media type status", http.StatusBadRequest, resp.code)
+
+	// the Bind methods of a single part validate the whole request.
+	resp = request(http.MethodGet, "/query?tag=a&page=0", "", "")
+	equal(t, "query status", http.StatusBadRequest, resp.code)
+	equalJSON(t, "query", `[
+		{"field":"X-Token","rule":"required","message":"X-Token failed on the required rule"},
+		{"field":"name","rule":"required","message":"name failed on the required rule"},
+		{"field":"page","rule":"gte","param":"1","message":"page failed on the gte=1 rule"}
+	]`, resp.body)
+
+	resp = request(http.MethodPost, "/form?page=2", "application/x-www-form-urlencoded", "name=mastiff")
+	equal(t, "form only status", http.StatusBadRequest, resp.code)
+	equalJSON(t, "form only", `[
+		{"field":"X-Token","rule":"required","message":"X-Token failed on the required rule"},
+		{"field":"page","rule":"gte","param":"1","message":"page failed on the gte=1 rule"}
+	]`, resp.body)
+
+	resp = request(http.MethodGet, "/uri/x", "", "")
+	equalJSON(t, "uri", `[{"field":"id","rule":"type","message":"id is not a valid int"}]`, resp.body)
+
+	resp = request(http.MethodGet, "/header", "", "")
+	equal(t, "header status", http.StatusBadRequest, resp.code)
+	if strings.Contains(resp.body, "X-Token") {
+		t.Errorf("header: %s reports the bound header", resp.body)
+	}
+}
+
+func testFiles(t TB, h handler.HTTPHandler) {
+	path := filepath.Join(t.TempDir(), "test.txt")
+	must(t, "write file", os.WriteFile(path, []byte("file content"), 0o600))
+
+	h.Get("/file", func(c unicontext.UniversalContext) error {
+		return c.File(path)
+	})
+	h.Get("/attachment", func(c unicontext.UniversalContext) error {
+		return c.Attachment(path, "report.txt")
+	})
+
+	resp := send(t, h, http.MethodGet, "/file")
+	equal(t, "file status", http.StatusOK, resp.code)
+	equal(t, "file body", "file content", resp.body)
+	equal(t, "file disposition", "", resp.header.Get("Content-Disposition"))
+
+	resp = send(t, h, http.MethodGet, "/attachment")
+	equal(t, "attachment status", http.StatusOK, resp.code)
+	equal(t, "attachment body", "file content", resp.body)
+	equal(t, "attachment disposition", `attachment; filename="report.txt"`, resp.header.Get("Content-Disposition"))
+}
+
+type (
+	// contextKey is the key of the context value injected by the suite.
+	contextKey struct{}
+	// incomingKey is the key of the context value of the incoming request.
+	incomingKey struct{}
+)
+
+func testContext(t TB, h handler.HTTPHandler) {
+	h.Use(func(c unicontext.UniversalContext) error {
+		c.Set("user", "mastiff")
+		unicontext.InjectContext(context.WithValue(unicontext.ContextFrom(c), contextKey{}, "injected"), c)
+		return c.Next()
+	})
+	h.Get("/context", func(c unicontext.UniversalContext) error {
+		user, _ := c.Get("user")
+		_, missing := c.Get("missing")
+		return c.Text(http.StatusOK, fmt.Sprintf("%v %v %v|%v",
+			user, missing, unicontext.ContextFrom(c).Value(contextKey{}), c.Request().Context().Value(contextKey{})))
+	})
+
+	equal(t, "values and injected context", "mastiff false injected|injected", send(t, h, http.MethodGet, "/context").body)
+}
+
+func testIncomingRequestContext(t TB, h handler.HTTPHandler) {
+	h.Get("/incoming", func(c unicontext.UniversalContext) error {
+		return c.Text(http.StatusOK, fmt.Sprint(c.Request().Context().Value(incomingKey{})))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/incoming", nil)
+	req = req.WithContext(context.WithValue(req.Context(), incomingKey{}, "incoming"))
+	equal(t, "incoming context value", "incoming", do(t, h, req).body)
+}
@@ -0,0 +1,180 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/hewen/mastiff-go/server/httpx/binding"
+)
+
+// Version is the version of the OpenAPI specification documents follow.
+const Version = "3.0.3"
+
+// Info describes the API in OpenAPI documents.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Document is an OpenAPI document.
+type Document struct {
+	Paths      map[string]PathItem `json:"paths"`
+	Components *Components         `json:"components,omitempty"`
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+}
+
+// PathItem holds the operations of a path, by their lower case HTTP method.
+type PathItem map[string]*Operation
+
+// Operation describes a route.
+type Operation struct {
+	Responses   map[string]*Response `json:"responses"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+}
+
+// Parameter describes a route parameter, query parameter or header of requests.
+type Parameter struct {
+	Schema   *Schema `json:"schema"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+}
+
+// RequestBody describes the bodies of requests, by their media type.
+type RequestBody struct {
+	Content map[string]*MediaType `json:"content"`
+}
+
+// Response describes the responses of a status.
+type Response struct {
+	Content     map[string]*MediaType `json:"content,omitempty"`
+	Description string                `json:"description"`
+}
+
+// MediaType describes a body of a media type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the schemas of the named structs, referenced from the rest of the document.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// NewDocument returns the OpenAPI document of the routes. The schemas of their requests and
+// responses follow the json tags of the structs, the parameters their uri, query and header
+// tags, and the form bodies their form tags. The rules of the validate tags constrain them.
+func NewDocument(info Info, routes []Route) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]PathItem),
+	}
+
+	s := newSchemas()
+	for _, route := range routes {
+		path, pathParams := pathTemplate(route.Path)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = make(PathItem)
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = s.operation(route, pathParams)
+	}
+
+	if len(s.components) > 0 {
+		doc.Components = &Components{Schemas: s.components}
+	}
+	return doc
+}
+
+// operation returns the operation of the route, whose path has the parameters.
+func (s *schemas) operation(route Route, pathParams []string) *Operation {
+	op := &Operation{Responses: make(map[string]*Response)}
+
+	if route.Request != nil {
+		for _, p := range parameters(route.Request) {
+			if p.In != inPath || slices.Contains(pathParams, p.Name) {
+				op.Parameters = append(op.Parameters, p)
+			}
+		}
+		if route.Method != http.MethodGet && route.Method != http.MethodHead {
+			op.RequestBody = s.requestBody(route.Request)
+		}
+	}
+	// parameters of the path the request is not bound from are documented as strings.
+	for _, name := range pathParams {
+		if !slices.ContainsFunc(op.Parameters, func(p *Parameter) bool {
+			return p.In == inPath && p.Name == name
+		}) {
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name:     name,
+				In:       inPath,
+				Required: true,
+				Schema:   &Schema{Type: typeString},
+			})
+		}
+	}
+
+	op.Responses["200"] = s.response(http.StatusText(http.StatusOK), route.Response)
+	if route.Error != nil {
+		if len(op.Parameters) > 0 || op.RequestBody != nil {
+			op.Responses["400"] = s.response(http.StatusText(http.StatusBadRequest), route.Error)
+		}
+		op.Responses["default"] = s.response("Error", route.Error)
+	}
+	return op
+}
+
+// requestBody returns the request body bound into t, nil if t is bound from no body.
+func (s *schemas) requestBody(t reflect.Type) *RequestBody {
+	content := make(map[string]*MediaType)
+	if schema := s.body(t); schema != nil {
+		content[binding.MIMEJSON] = &MediaType{Schema: schema}
+	}
+	if schema := formSchema(t); schema != nil {
+		content[binding.MIMEPOSTForm] = &MediaType{Schema: schema}
+		content[binding.MIMEMultipartPOSTForm] = &MediaType{Schema: schema}
+	}
+	if len(content) == 0 {
+		return nil
+	}
+	return &RequestBody{Content: content}
+}
+
+// response returns the response with the JSON bodies of type t, without body if t is nil.
+func (s *schemas) response(description string, t reflect.Type) *Response {
+	resp := &Response{Description: description}
+	if t != nil {
+		resp.Content = map[string]*MediaType{binding.MIMEJSON: {Schema: s.of(t)}}
+	}
+	return resp
+}
+
+// pathTemplate converts the ":name" and "*name" parameters of the path to the "{name}" ones of
+// OpenAPI, returning the path and the names of its parameters.
+func pathTemplate(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, segment := range segments {
+		var name string
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			name = strings.TrimRight(segment[1:], "?+")
+		case segment == "*":
+			name = "wildcard"
+		case strings.HasPrefix(segment, "*"):
+			name = segment[1:]
+		default:
+			continue
+		}
+		params = append(params, name)
+		segments[i] = "{" + name + "}"
+	}
+	return strings.Join(segments, "/"), params
+}
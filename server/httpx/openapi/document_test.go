@@ -0,0 +1,219 @@
+package openapi
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type user struct {
+	Address  *address  `json:"address,omitempty"`
+	Created  time.Time `json:"created"`
+	Name     string    `json:"name" validate:"required,min=3,max=32"`
+	Email    string    `json:"email,omitempty" validate:"omitempty,email"`
+	Role     string    `json:"role" validate:"oneof=admin member"`
+	Secret   string    `json:"-"`
+	internal string
+	Friends  []user `json:"friends,omitempty"`
+	Age      uint8  `json:"age" validate:"lte=150"`
+}
+
+type createUser struct {
+	Token string `header:"X-Token" validate:"required"`
+	user
+	DryRun bool `query:"dry_run"`
+}
+
+type getUser struct {
+	Fields []string `query:"field" validate:"max=5,dive,min=1"`
+	ID     int64    `uri:"id" validate:"gt=0"`
+}
+
+type uploadAvatar struct {
+	Name string `form:"name" validate:"required"`
+	ID   int64  `uri:"id"`
+	Size int    `form:"size"`
+}
+
+type envelope[T any] struct {
+	Data T `json:"data"`
+}
+
+type failure struct {
+	Message string `json:"message"`
+}
+
+// testDocument returns the document of routes covering the parts of requests.
+func testDocument() *Document {
+	return NewDocument(Info{Title: "users", Version: "1.0.0"}, []Route{
+		{
+			Method:   http.MethodGet,
+			Path:     "/users/:id",
+			Request:  reflect.TypeFor[getUser](),
+			Response: reflect.TypeFor[envelope[user]](),
+			Error:    reflect.TypeFor[failure](),
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/users",
+			Request:  reflect.TypeFor[createUser](),
+			Response: reflect.TypeFor[envelope[user]](),
+			Error:    reflect.TypeFor[failure](),
+		},
+		{
+			Method:  http.MethodPut,
+			Path:    "/users/:id/avatar",
+			Request: reflect.TypeFor[uploadAvatar](),
+			Error:   reflect.TypeFor[failure](),
+		},
+		{Method: http.MethodGet, Path: "/files/*path"},
+	})
+}
+
+// assertJSON asserts that v encodes to the JSON.
+func assertJSON(t *testing.T, expected string, v any) {
+	t.Helper()
+	actual, err := json.Marshal(v)
+	require.NoError(t, err)
+	assert.JSONEq(t, expected, string(actual))
+}
+
+func TestNewDocument(t *testing.T) {
+	doc := testDocument()
+
+	assert.Equal(t, Version, doc.OpenAPI)
+	assert.Equal(t, Info{Title: "users", Version: "1.0.0"}, doc.Info)
+	assert.ElementsMatch(t, []string{"/users", "/users/{id}", "/users/{id}/avatar", "/files/{path}"},
+		slices.Collect(maps.Keys(doc.Paths)))
+
+	require.NotNil(t, doc.Components)
+	assertJSON(t, `{
+		"address": {"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"]},
+		"envelope_user": {"type": "object", "properties": {"data": {"$ref": "#/components/schemas/user"}}},
+		"failure": {"type": "object", "properties": {"message": {"type": "string"}}},
+		"user": {
+			"type": "object",
+			"properties": {
+				"address": {"$ref": "#/components/schemas/address"},
+				"age": {"type": "integer", "format": "int32", "minimum": 0, "maximum": 150},
+				"created": {"type": "string", "format": "date-time"},
+				"email": {"type": "string", "format": "email"},
+				"friends": {"type": "array", "items": {"$ref": "#/components/schemas/user"}},
+				"name": {"type": "string", "minLength": 3, "maxLength": 32},
+				"role": {"type": "string", "enum": ["admin", "member"]}
+			},
+			"required": ["name"]
+		}
+	}`, doc.Components.Schemas)
+}
+
+func TestNewDocument_Parameters(t *testing.T) {
+	doc := testDocument()
+
+	assertJSON(t, `[
+		{"name": "id", "in": "path", "required": true,
+			"schema": {"type": "integer", "format": "int64", "minimum": 0, "exclusiveMinimum": true}},
+		{"name": "field", "in": "query", "schema": {"type": "array", "items": {"type": "string"}, "maxItems": 5}}
+	]`, doc.Paths["/users/{id}"]["get"].Parameters)
+	assert.Nil(t, doc.Paths["/users/{id}"]["get"].RequestBody)
+
+	assertJSON(t, `[
+		{"name": "dry_run", "in": "query", "schema": {"type": "boolean"}},
+		{"name": "X-Token", "in": "header", "required": true, "schema": {"type": "string"}}
+	]`, doc.Paths["/users"]["post"].Parameters)
+
+	// parameters of the path the request is not bound from are strings.
+	assertJSON(t, `[{"name": "path", "in": "path", "required": true, "schema": {"type": "string"}}]`,
+		doc.Paths["/files/{path}"]["get"].Parameters)
+}
+
+func TestNewDocument_RequestBody(t *testing.T) {
+	doc := testDocument()
+
+	// the fields bound from the rest of the request are left out of the body.
+	body := doc.Paths["/users"]["post"].RequestBody
+	require.NotNil(t, body)
+	require.Contains(t, body.Content, "application/json")
+	schema := body.Content["application/json"].Schema
+	assert.Empty(t, schema.Ref)
+	assert.ElementsMatch(t, []string{"address", "age", "created", "email", "friends", "name", "role"},
+		slices.Collect(maps.Keys(schema.Properties)))
+
+	body = doc.Paths["/users/{id}/avatar"]["put"].RequestBody
+	require.NotNil(t, body)
+	form := `{
+		"type": "object",
+		"properties": {"name": {"type": "string"}, "size": {"type": "integer", "format": "int64"}},
+		"required": ["name"]
+	}`
+	assert.NotContains(t, body.Content, "application/json")
+	assertJSON(t, form, body.Content["application/x-www-form-urlencoded"].Schema)
+	assertJSON(t, form, body.Content["multipart/form-data"].Schema)
+}
+
+func TestNewDocument_Responses(t *testing.T) {
+	doc := testDocument()
+
+	assertJSON(t, `{
+		"200": {"description": "OK", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/envelope_user"}}}},
+		"400": {"description": "Bad Request", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/failure"}}}},
+		"default": {"description": "Error", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/failure"}}}}
+	}`, doc.Paths["/users/{id}"]["get"].Responses)
+
+	assertJSON(t, `{"200": {"description": "OK"}}`, doc.Paths["/files/{path}"]["get"].Responses)
+}
+
+type item struct {
+	Name string `json:"name"`
+}
+
+func TestNewDocument_Components(t *testing.T) {
+	other := reflect.StructOf([]reflect.StructField{{Name: "ID", Type: reflect.TypeFor[int]()}})
+
+	doc := NewDocument(Info{}, []Route{
+		{Method: http.MethodGet, Path: "/a", Response: reflect.TypeFor[envelope[[]item]]()},
+		{Method: http.MethodGet, Path: "/b", Response: reflect.TypeFor[envelope[item]]()},
+		{Method: http.MethodGet, Path: "/c", Response: reflect.TypeFor[map[string]item]()},
+		{Method: http.MethodGet, Path: "/d", Response: other},
+	})
+
+	assertJSON(t, `{"type": "object", "additionalProperties": {"$ref": "#/components/schemas/item"}}`,
+		doc.Paths["/c"]["get"].Responses["200"].Content["application/json"].Schema)
+	assertJSON(t, `{"type": "object", "properties": {"ID": {"type": "integer", "format": "int64"}}}`,
+		doc.Paths["/d"]["get"].Responses["200"].Content["application/json"].Schema)
+
+	// types of the same name are numbered.
+	assert.Contains(t, doc.Components.Schemas, "envelope_item")
+	assert.Contains(t, doc.Components.Schemas, "envelope_item2")
+	assert.Contains(t, doc.Components.Schemas, "item")
+}
+
+func TestPathTemplate(t *testing.T) {
+	tests := []struct {
+		path     string
+		template string
+		params   []string
+	}{
+		{"/", "/", nil},
+		{"/users/:id", "/users/{id}", []string{"id"}},
+		{"/users/:id/posts/:post?", "/users/{id}/posts/{post}", []string{"id", "post"}},
+		{"/files/*path", "/files/{path}", []string{"path"}},
+		{"/static/*", "/static/{wildcard}", []string{"wildcard"}},
+	}
+	for _, tt := range tests {
+		template, params := pathTemplate(tt.path)
+		assert.Equal(t, tt.template, template, tt.path)
+		assert.Equal(t, tt.params, params, tt.path)
+	}
+}
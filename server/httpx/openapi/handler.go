@@ -0,0 +1,40 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+)
+
+// viewerPage is the page viewing an OpenAPI document with Swagger UI.
+//
+//go:embed viewer.html
+var viewerPage string
+
+var viewerTemplate = template.Must(template.New("viewer").Parse(viewerPage))
+
+// Handler returns a handler serving the OpenAPI document of the routes of the registry. The
+// document is built on each request, so that it lists the routes added after the handler.
+func Handler(r *Registry, info Info) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		body, err := json.Marshal(r.Document(info))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	})
+}
+
+// ViewerHandler returns a handler serving a page viewing the OpenAPI document at documentURL.
+func ViewerHandler(title, documentURL string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = viewerTemplate.Execute(w, struct {
+			Title       string
+			DocumentURL string
+		}{title, documentURL})
+	})
+}
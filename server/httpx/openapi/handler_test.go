@@ -0,0 +1,53 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	h := Handler(r, Info{Title: "users", Version: "1.0.0"})
+
+	// routes added after the handler are served.
+	r.Add(Route{Method: http.MethodGet, Path: "/users/:id", Request: reflect.TypeFor[getUser]()})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, DocumentPath, nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var doc Document
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, Version, doc.OpenAPI)
+	assert.Equal(t, "users", doc.Info.Title)
+	assert.Contains(t, doc.Paths, "/users/{id}")
+}
+
+func TestHandler_MarshalError(t *testing.T) {
+	r := NewRegistry()
+	r.Add(Route{Method: http.MethodGet, Path: "/", Request: reflect.TypeFor[struct {
+		Size float64 `query:"size" validate:"max=NaN"`
+	}]()})
+
+	w := httptest.NewRecorder()
+	Handler(r, Info{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, DocumentPath, nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestViewerHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	ViewerHandler("users <api>", DocumentPath).ServeHTTP(w, httptest.NewRequest(http.MethodGet, ViewerPath, nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "<title>users &lt;api&gt;</title>")
+	assert.Contains(t, w.Body.String(), `url: "\/openapi.json"`)
+	assert.Contains(t, w.Body.String(), "SwaggerUIBundle")
+}
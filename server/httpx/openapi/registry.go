@@ -0,0 +1,70 @@
+// Package openapi records the routes of HTTP handlers with the types of their requests and
+// responses, and describes them in OpenAPI 3 documents.
+package openapi
+
+import (
+	"reflect"
+	"slices"
+	"sync"
+)
+
+const (
+	// DocumentPath is the path the OpenAPI document is served at.
+	DocumentPath = "/openapi.json"
+	// ViewerPath is the path the page viewing the OpenAPI document is served at.
+	ViewerPath = "/docs"
+)
+
+// Route is a route described in OpenAPI documents.
+type Route struct {
+	// Request is the type requests are bound into from their body, route parameters, query and
+	// headers, nil if the route takes no input.
+	Request reflect.Type
+	// Response is the type of the bodies of successful responses, nil if they have none.
+	Response reflect.Type
+	// Error is the type of the bodies of error responses, nil if they have none.
+	Error reflect.Type
+	// Method is the HTTP method of the route.
+	Method string
+	// Path is the path of the route, with the ":name" and "*name" parameters of gin and fiber.
+	Path string
+}
+
+// Registry records the routes of an HTTP handler. It is safe for concurrent use, and a nil
+// Registry records nothing.
+type Registry struct {
+	routes []Route
+	mu     sync.RWMutex
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Add records the route.
+func (r *Registry) Add(route Route) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = append(r.routes, route)
+}
+
+// Routes returns the recorded routes, in the order they were added.
+func (r *Registry) Routes() []Route {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.routes)
+}
+
+// Document returns the OpenAPI document of the recorded routes.
+func (r *Registry) Document(info Info) *Document {
+	return NewDocument(info, r.Routes())
+}
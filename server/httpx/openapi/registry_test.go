@@ -0,0 +1,37 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	assert.Empty(t, r.Routes())
+
+	r.Add(Route{Method: http.MethodGet, Path: "/users/:id"})
+	r.Add(Route{Method: http.MethodPost, Path: "/users", Request: reflect.TypeFor[createUser]()})
+
+	routes := r.Routes()
+	assert.Equal(t, []Route{
+		{Method: http.MethodGet, Path: "/users/:id"},
+		{Method: http.MethodPost, Path: "/users", Request: reflect.TypeFor[createUser]()},
+	}, routes)
+
+	// the routes returned are a copy.
+	routes[0].Path = "/"
+	assert.Equal(t, "/users/:id", r.Routes()[0].Path)
+
+	doc := r.Document(Info{Title: "users", Version: "1.0.0"})
+	assert.Len(t, doc.Paths, 2)
+}
+
+func TestRegistry_Nil(t *testing.T) {
+	var r *Registry
+	r.Add(Route{Method: http.MethodGet, Path: "/"})
+	assert.Nil(t, r.Routes())
+	assert.Empty(t, r.Document(Info{}).Paths)
+}
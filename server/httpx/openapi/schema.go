@@ -0,0 +1,455 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hewen/mastiff-go/server/httpx/binding"
+)
+
+// Types of schemas.
+const (
+	typeArray   = "array"
+	typeBoolean = "boolean"
+	typeInteger = "integer"
+	typeNumber  = "number"
+	typeObject  = "object"
+	typeString  = "string"
+)
+
+// inPath is where route parameters are.
+const inPath = "path"
+
+// Schema describes the values of a type.
+type Schema struct {
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+}
+
+var (
+	timeType            = reflect.TypeFor[time.Time]()
+	durationType        = reflect.TypeFor[time.Duration]()
+	rawMessageType      = reflect.TypeFor[json.RawMessage]()
+	jsonMarshalerType   = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// formats are the formats of the strings checked by validate rules.
+var formats = map[string]string{
+	"email":    "email",
+	"hostname": "hostname",
+	"ipv4":     "ipv4",
+	"ipv6":     "ipv6",
+	"uri":      "uri",
+	"url":      "uri",
+	"uuid":     "uuid",
+	"uuid4":    "uuid",
+}
+
+// schemas builds the schemas of types, keeping the named structs as components.
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+// newSchemas creates a schemas without components.
+func newSchemas() *schemas {
+	return &schemas{
+		components: make(map[string]*Schema),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+// of returns the schema of the JSON encoding of the values of type t.
+func (s *schemas) of(t reflect.Type) *Schema {
+	t = deref(t)
+	switch {
+	case t == timeType:
+		return &Schema{Type: typeString, Format: "date-time"}
+	case t == rawMessageType || implements(t, jsonMarshalerType):
+		return &Schema{}
+	case implements(t, textMarshalerType):
+		return &Schema{Type: typeString}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t.Name() == "" {
+			schema, _ := s.object(t, false)
+			return schema
+		}
+		return s.ref(t)
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: typeString, Format: "byte"}
+		}
+		return &Schema{Type: typeArray, Items: s.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: typeObject, AdditionalProperties: s.of(t.Elem())}
+	default:
+		return scalar(t)
+	}
+}
+
+// ref returns a reference to the component of the named struct t, adding it if missing.
+func (s *schemas) ref(t reflect.Type) *Schema {
+	name, ok := s.names[t]
+	if !ok {
+		name = componentName(t.Name())
+		for i := 2; s.components[name] != nil; i++ {
+			name = componentName(t.Name()) + strconv.Itoa(i)
+		}
+		s.names[t] = name
+
+		// the component is added before its fields for recursive types to reference it.
+		component := &Schema{}
+		s.components[name] = component
+		schema, _ := s.object(t, false)
+		*component = *schema
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// object returns the schema of the JSON encoding of the struct t. For request bodies, the fields
+// without json tag bound from another part of the request are left out, and object reports
+// whether there were any.
+func (s *schemas) object(t reflect.Type, body bool) (*Schema, bool) {
+	schema := &Schema{Type: typeObject, Properties: make(map[string]*Schema)}
+	left := s.addFields(schema, t, body)
+	return schema, left
+}
+
+// addFields adds the properties of the fields of the struct t to the schema, flattening embedded
+// structs as encoding/json does. It reports whether fields of request bodies were left out.
+func (s *schemas) addFields(schema *Schema, t reflect.Type, body bool) bool {
+	var left bool
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, tagged := f.Tag.Lookup("json")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if f.Anonymous && name == "" && deref(f.Type).Kind() == reflect.Struct {
+			left = s.addFields(schema, deref(f.Type), body) || left
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if body && !tagged && boundElsewhere(f) {
+			left = true
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		property := s.of(f.Type)
+		if applyRules(property, f.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+	return left
+}
+
+// body returns the schema of the JSON bodies bound into t, nil if t is bound from no JSON body.
+func (s *schemas) body(t reflect.Type) *Schema {
+	t = deref(t)
+	switch t.Kind() {
+	case reflect.Interface:
+		return nil
+	case reflect.Struct:
+		if t == timeType || implements(t, jsonMarshalerType) {
+			return s.of(t)
+		}
+		schema, left := s.object(t, true)
+		switch {
+		case len(schema.Properties) == 0:
+			return nil
+		case !left:
+			return s.of(t)
+		default:
+			return schema
+		}
+	default:
+		return s.of(t)
+	}
+}
+
+// parameter is where the parameters bound by a tag are.
+type parameter struct {
+	tag string
+	in  string
+}
+
+// parameterTags are the tags of the fields bound from parameters.
+var parameterTags = []parameter{
+	{binding.TagURI, inPath},
+	{binding.TagQuery, "query"},
+	{binding.TagHeader, "header"},
+}
+
+// parameters returns the parameters the fields of t are bound from.
+func parameters(t reflect.Type) []*Parameter {
+	t = deref(t)
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var params []*Parameter
+	for _, p := range parameterTags {
+		walkTagged(t, p.tag, func(name string, f reflect.StructField) {
+			schema := valueSchema(f.Type)
+			required := applyRules(schema, f.Tag.Get("validate"))
+			params = append(params, &Parameter{
+				Name:     name,
+				In:       p.in,
+				Required: required || p.in == inPath,
+				Schema:   schema,
+			})
+		})
+	}
+	return params
+}
+
+// formSchema returns the schema of the form bodies bound into t, nil if t has no form fields.
+func formSchema(t reflect.Type) *Schema {
+	t = deref(t)
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	schema := &Schema{Type: typeObject, Properties: make(map[string]*Schema)}
+	walkTagged(t, binding.TagForm, func(name string, f reflect.StructField) {
+		property := valueSchema(f.Type)
+		if applyRules(property, f.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	})
+	if len(schema.Properties) == 0 {
+		return nil
+	}
+	return schema
+}
+
+// walkTagged calls fn with the name in the tag of the fields of the struct t bound by the tag,
+// recursing into untagged structs as binding.Decode does.
+func walkTagged(t reflect.Type, tag string, fn func(name string, f reflect.StructField)) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		switch {
+		case name == "-":
+		case name == "":
+			if f.Type.Kind() == reflect.Struct && !isScalar(f.Type) {
+				walkTagged(f.Type, tag, fn)
+			}
+		default:
+			fn(name, f)
+		}
+	}
+}
+
+// boundElsewhere reports whether the field is bound from another part of the request than the
+// JSON body.
+func boundElsewhere(f reflect.StructField) bool {
+	for _, tag := range []string{binding.TagURI, binding.TagQuery, binding.TagHeader, binding.TagForm} {
+		if name, _, _ := strings.Cut(f.Tag.Get(tag), ","); name != "" && name != "-" {
+			return true
+		}
+	}
+	return false
+}
+
+// valueSchema returns the schema of the values of type t parsed from strings by binding.Decode.
+func valueSchema(t reflect.Type) *Schema {
+	t = deref(t)
+	switch {
+	case t == timeType:
+		return &Schema{Type: typeString, Format: "date-time"}
+	case t == durationType:
+		return &Schema{Type: typeString, Format: "duration"}
+	case isScalar(t):
+		return &Schema{Type: typeString}
+	case t.Kind() == reflect.Slice:
+		return &Schema{Type: typeArray, Items: valueSchema(t.Elem())}
+	default:
+		return scalar(t)
+	}
+}
+
+// scalar returns the schema of the values of the basic type t, an empty schema accepting any
+// value for other types.
+func scalar(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: typeBoolean}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: typeInteger, Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: typeInteger, Format: "int32"}
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: typeInteger, Format: "int64", Minimum: new(float64)}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: typeInteger, Format: "int32", Minimum: new(float64)}
+	case reflect.Float32:
+		return &Schema{Type: typeNumber, Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: typeNumber, Format: "double"}
+	case reflect.String:
+		return &Schema{Type: typeString}
+	default:
+		return &Schema{}
+	}
+}
+
+// applyRules constrains the schema by the rules of a validate tag, and reports whether they
+// require the value. The rules following dive apply to the elements of the value, and are left
+// out.
+func applyRules(schema *Schema, rules string) bool {
+	var required bool
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch {
+		case name == "dive":
+			return required
+		case name == "required":
+			required = true
+		case schema.Ref == "":
+			// siblings of references are ignored, so only inline schemas are constrained.
+			applyRule(schema, name, param)
+		}
+	}
+	return required
+}
+
+// applyRule constrains the schema by the rule with the parameter.
+func applyRule(schema *Schema, name, param string) {
+	switch name {
+	case "min", "gte":
+		setBound(schema, param, 0, &schema.Minimum, &schema.MinLength, &schema.MinItems)
+	case "max", "lte":
+		setBound(schema, param, 0, &schema.Maximum, &schema.MaxLength, &schema.MaxItems)
+	case "gt":
+		setBound(schema, param, 1, &schema.Minimum, &schema.MinLength, &schema.MinItems)
+		schema.ExclusiveMinimum = schema.Minimum != nil
+	case "lt":
+		setBound(schema, param, -1, &schema.Maximum, &schema.MaxLength, &schema.MaxItems)
+		schema.ExclusiveMaximum = schema.Maximum != nil
+	case "len":
+		setBound(schema, param, 0, &schema.Minimum, &schema.MinLength, &schema.MinItems)
+		setBound(schema, param, 0, &schema.Maximum, &schema.MaxLength, &schema.MaxItems)
+	case "oneof":
+		schema.Enum = enum(schema.Type, strings.Fields(param))
+	default:
+		if format, ok := formats[name]; ok {
+			schema.Format = format
+		}
+	}
+}
+
+// setBound sets the bound of the schema to the parameter of a rule: its value for numbers, or
+// its length for strings and arrays, shifted by offset for exclusive bounds.
+func setBound(schema *Schema, param string, offset int, value **float64, length, items **int) {
+	switch schema.Type {
+	case typeInteger, typeNumber:
+		if v, err := strconv.ParseFloat(param, 64); err == nil {
+			*value = &v
+		}
+	case typeString, typeArray:
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return
+		}
+		n += offset
+		if schema.Type == typeString {
+			*length = &n
+		} else {
+			*items = &n
+		}
+	}
+}
+
+// enum returns the values of a oneof rule, as numbers for numeric schemas.
+func enum(schemaType string, params []string) []any {
+	values := make([]any, len(params))
+	for i, p := range params {
+		values[i] = p
+		switch schemaType {
+		case typeInteger:
+			if v, err := strconv.ParseInt(p, 10, 64); err == nil {
+				values[i] = v
+			}
+		case typeNumber:
+			if v, err := strconv.ParseFloat(p, 64); err == nil {
+				values[i] = v
+			}
+		}
+	}
+	return values
+}
+
+// componentName returns the name of the component of a type: the type arguments of generic
+// types are named without their package, and the characters OpenAPI does not allow are replaced.
+func componentName(name string) string {
+	if base, args, ok := strings.Cut(name, "["); ok {
+		name = base
+		for _, arg := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+			arg = arg[strings.LastIndex(arg, "/")+1:]
+			name += "_" + arg[strings.LastIndex(arg, ".")+1:]
+		}
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// deref returns the type pointers of type t point to in the end.
+func deref(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// implements reports whether t or pointers to t implement the interface i.
+func implements(t, i reflect.Type) bool {
+	return t.Implements(i) || reflect.PointerTo(t).Implements(i)
+}
+
+// isScalar reports whether values of type t are parsed from a single string even if it is a
+// struct or a slice.
+func isScalar(t reflect.Type) bool {
+	return implements(t, textUnmarshalerType) || t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
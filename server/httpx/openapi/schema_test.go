@@ -0,0 +1,121 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyRules(t *testing.T) {
+	tests := []struct {
+		schema   *Schema
+		rules    string
+		expected string
+		required bool
+	}{
+		{&Schema{Type: typeString}, "required,min=1,max=8", `{"type": "string", "minLength": 1, "maxLength": 8}`, true},
+		{&Schema{Type: typeString}, "gt=1,lt=8", `{"type": "string", "minLength": 2, "maxLength": 7}`, false},
+		{&Schema{Type: typeString}, "len=4", `{"type": "string", "minLength": 4, "maxLength": 4}`, false},
+		{&Schema{Type: typeString}, "omitempty,uuid4", `{"type": "string", "format": "uuid"}`, false},
+		{&Schema{Type: typeString}, "email|url", `{"type": "string"}`, false},
+		{&Schema{Type: typeInteger}, "gte=1,lte=10", `{"type": "integer", "minimum": 1, "maximum": 10}`, false},
+		{&Schema{Type: typeInteger}, "oneof=1 2 x", `{"type": "integer", "enum": [1, 2, "x"]}`, false},
+		{&Schema{Type: typeNumber}, "gt=0.5,lt=1", `{"type": "number", "minimum": 0.5, "exclusiveMinimum": true,
+			"maximum": 1, "exclusiveMaximum": true}`, false},
+		{&Schema{Type: typeNumber}, "oneof=0.5 1", `{"type": "number", "enum": [0.5, 1]}`, false},
+		{&Schema{Type: typeNumber}, "min=x", `{"type": "number"}`, false},
+		{&Schema{Type: typeArray}, "min=1,dive,required,max=3", `{"type": "array", "minItems": 1}`, false},
+		{&Schema{Type: typeArray}, "max=x", `{"type": "array"}`, false},
+		{&Schema{Type: typeBoolean}, "min=1", `{"type": "boolean"}`, false},
+		{&Schema{Ref: "#/components/schemas/user"}, "required,min=1", `{"$ref": "#/components/schemas/user"}`, true},
+		{&Schema{}, "", `{}`, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.required, applyRules(tt.schema, tt.rules), tt.rules)
+		assertJSON(t, tt.expected, tt.schema)
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	tests := []struct {
+		t        reflect.Type
+		expected string
+	}{
+		{reflect.TypeFor[bool](), `{"type": "boolean"}`},
+		{reflect.TypeFor[*int](), `{"type": "integer", "format": "int64"}`},
+		{reflect.TypeFor[int16](), `{"type": "integer", "format": "int32"}`},
+		{reflect.TypeFor[uint](), `{"type": "integer", "format": "int64", "minimum": 0}`},
+		{reflect.TypeFor[float32](), `{"type": "number", "format": "float"}`},
+		{reflect.TypeFor[float64](), `{"type": "number", "format": "double"}`},
+		{reflect.TypeFor[[]byte](), `{"type": "string", "format": "byte"}`},
+		{reflect.TypeFor[[2]string](), `{"type": "array", "items": {"type": "string"}}`},
+		{reflect.TypeFor[time.Time](), `{"type": "string", "format": "date-time"}`},
+		{reflect.TypeFor[time.Duration](), `{"type": "integer", "format": "int64"}`},
+		{reflect.TypeFor[net.IP](), `{"type": "string"}`},
+		{reflect.TypeFor[json.RawMessage](), `{}`},
+		{reflect.TypeFor[any](), `{}`},
+		{reflect.TypeFor[chan int](), `{}`},
+	}
+	for _, tt := range tests {
+		assertJSON(t, tt.expected, newSchemas().of(tt.t))
+	}
+}
+
+func TestValueSchema(t *testing.T) {
+	tests := []struct {
+		t        reflect.Type
+		expected string
+	}{
+		{reflect.TypeFor[*int](), `{"type": "integer", "format": "int64"}`},
+		{reflect.TypeFor[[]uint16](), `{"type": "array", "items": {"type": "integer", "format": "int32", "minimum": 0}}`},
+		{reflect.TypeFor[[]byte](), `{"type": "string"}`},
+		{reflect.TypeFor[time.Time](), `{"type": "string", "format": "date-time"}`},
+		{reflect.TypeFor[time.Duration](), `{"type": "string", "format": "duration"}`},
+		{reflect.TypeFor[net.IP](), `{"type": "string"}`},
+	}
+	for _, tt := range tests {
+		assertJSON(t, tt.expected, valueSchema(tt.t))
+	}
+}
+
+func TestSchemas_Body(t *testing.T) {
+	type params struct {
+		Page int `query:"page"`
+	}
+	type embedded struct {
+		params
+		Name string `json:"name"`
+	}
+
+	s := newSchemas()
+	assert.Nil(t, s.body(reflect.TypeFor[any]()))
+	assert.Nil(t, s.body(reflect.TypeFor[params]()))
+	assert.Nil(t, s.body(reflect.TypeFor[struct{}]()))
+	assertJSON(t, `{"type": "string", "format": "date-time"}`, s.body(reflect.TypeFor[time.Time]()))
+	assertJSON(t, `{"type": "array", "items": {"$ref": "#/components/schemas/address"}}`,
+		s.body(reflect.TypeFor[[]address]()))
+	assertJSON(t, `{"$ref": "#/components/schemas/address"}`, s.body(reflect.TypeFor[*address]()))
+	assertJSON(t, `{"type": "object", "properties": {"name": {"type": "string"}}}`,
+		s.body(reflect.TypeFor[embedded]()))
+
+	assert.Nil(t, parameters(reflect.TypeFor[[]address]()))
+	assert.Nil(t, formSchema(reflect.TypeFor[[]address]()))
+}
+
+func TestComponentName(t *testing.T) {
+	tests := map[string]string{
+		"User": "User",
+		"RespWithData[github.com/acme/app/api.User]":   "RespWithData_User",
+		"Pair[string,github.com/acme/app/api.User]":    "Pair_string_User",
+		"RespWithData[[]github.com/acme/app/api.User]": "RespWithData_User",
+		"RespWithData[map[string]int]":                 "RespWithData_map_string_int",
+		"Page[github.com/acme/app/api.Cursor[int64]]":  "Page_Cursor_int64_",
+	}
+	for name, expected := range tests {
+		assert.Equal(t, expected, componentName(name), name)
+	}
+}
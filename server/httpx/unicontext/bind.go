@@ -0,0 +1,103 @@
+package unicontext
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/hewen/mastiff-go/server/httpx/binding"
+)
+
+// requestValues holds the getters of the parts of a request structs are bound from.
+type requestValues struct {
+	uri    binding.Getter
+	query  binding.Getter
+	header binding.Getter
+	form   binding.Getter
+}
+
+// bindValues binds a part of the request into obj by the given tag, then validates obj.
+func bindValues(obj any, tag string, get binding.Getter) error {
+	if err := binding.Decode(obj, tag, get); err != nil {
+		return err
+	}
+	return binding.Validate(obj)
+}
+
+// bindRequest binds the body of the request by its content type, then the route parameters,
+// query and headers of the request, and validates obj. A body without content type is bound as
+// JSON.
+func bindRequest(c UniversalContext, obj any, v requestValues) error {
+	var mediaType string
+	if ct := c.Header("Content-Type"); ct != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(ct); err != nil {
+			return fmt.Errorf("%w: %s", binding.ErrUnsupportedMediaType, ct)
+		}
+	}
+
+	var errs binding.Errors
+	collect := func(err error) error {
+		var fieldErrs binding.Errors
+		if errors.As(err, &fieldErrs) {
+			errs = append(errs, fieldErrs...)
+			return nil
+		}
+		return err
+	}
+
+	switch mediaType {
+	case "", binding.MIMEJSON:
+		body, err := c.Body()
+		if err != nil {
+			return err
+		}
+		if len(body) > 0 {
+			if err := collect(binding.JSON(body, obj)); err != nil {
+				return err
+			}
+		}
+	case binding.MIMEPOSTForm, binding.MIMEMultipartPOSTForm:
+		if err := collect(binding.Decode(obj, binding.TagForm, v.form)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: %s", binding.ErrUnsupportedMediaType, mediaType)
+	}
+
+	for _, part := range []struct {
+		get binding.Getter
+		tag string
+	}{
+		{v.uri, binding.TagURI},
+		{v.query, binding.TagQuery},
+		{v.header, binding.TagHeader},
+	} {
+		if err := collect(binding.Decode(obj, part.tag, part.get)); err != nil {
+			return err
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return binding.Validate(obj)
+}
+
+// nonEmpty returns a getter of the single value get returns, present if not empty.
+func nonEmpty(get func(key string) string) binding.Getter {
+	return func(key string) ([]string, bool) {
+		if v := get(key); v != "" {
+			return []string{v}, true
+		}
+		return nil, false
+	}
+}
+
+// headerValues returns a getter of the values of the headers.
+func headerValues(h http.Header) binding.Getter {
+	return func(key string) ([]string, bool) {
+		values := h.Values(key)
+		return values, len(values) > 0
+	}
+}
@@ -3,23 +3,36 @@ package unicontext
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"mime/multipart"
 	"net/http"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/hewen/mastiff-go/pkg/contextkeys"
+	"github.com/hewen/mastiff-go/server/httpx/binding"
+	"github.com/valyala/fasthttp"
 )
 
+// fiberWrittenKey is the key of the locals of a fiber.Ctx whose response was written.
+type fiberWrittenKey struct{}
+
 // FiberContext implements the UniversalContext interface for Fiber.
 type FiberContext struct {
-	Ctx *fiber.Ctx
-	rw  *fiberResponseWriter
+	Ctx     *fiber.Ctx
+	rw      *fiberResponseWriter
+	aborted bool
 }
 
-// Request returns the HTTP request.
+// Request returns the HTTP request, whose context is the one set by the contextkeys.ContextKey
+// key if any.
 func (c *FiberContext) Request() *http.Request {
+	var ctx context.Context = c.Ctx.Context()
+	if injected, ok := c.Ctx.Locals(contextkeys.ContextKey).(context.Context); ok {
+		ctx = injected
+	}
 	req, _ := http.NewRequestWithContext(
-		c.Ctx.Context(),
+		ctx,
 		c.Ctx.Method(),
 		c.Ctx.OriginalURL(),
 		bytes.NewReader(c.Ctx.Body()),
@@ -45,11 +58,32 @@ func (c *FiberContext) ResponseWriter() http.ResponseWriter {
 	return c.rw
 }
 
-// Next calls the next handler in the chain.
+// Next calls the next handler in the chain and returns its error. The chain stops at a handler
+// not calling Next, or once aborted.
 func (c *FiberContext) Next() error {
+	if c.aborted {
+		return nil
+	}
 	return c.Ctx.Next()
 }
 
+// Written reports whether the response was written.
+func (c *FiberContext) Written() bool {
+	written, _ := c.Ctx.Locals(fiberWrittenKey{}).(bool)
+	return written
+}
+
+// markWritten records that the response was written.
+func (c *FiberContext) markWritten() {
+	c.Ctx.Locals(fiberWrittenKey{}, true)
+}
+
+// status sets the status code of the response, which is then written.
+func (c *FiberContext) status(status int) *fiber.Ctx {
+	c.markWritten()
+	return c.Ctx.Status(status)
+}
+
 // Param returns the value of the URL parameter with the given key.
 // It returns an empty string if the key does not exist.
 func (c *FiberContext) Param(key string) string {
@@ -77,53 +111,127 @@ func (c *FiberContext) Cookie(key string) string {
 // Data writes some data into the body stream and updates the HTTP code.
 func (c *FiberContext) Data(status int, contentType string, data []byte) error {
 	c.Ctx.Context().SetContentType(contentType)
-	return c.Ctx.Status(status).Send(data)
+	return c.status(status).Send(data)
 }
 
 // JSON sends a JSON response with the given status code and data.
 func (c *FiberContext) JSON(status int, data any) error {
-	return c.Ctx.Status(status).JSON(data)
+	return c.status(status).JSON(data)
 }
 
 // AbortWithStatusJSON writes the status code and return a JSON body.
 func (c *FiberContext) AbortWithStatusJSON(status int, data any) error {
+	c.aborted = true
 	return c.JSON(status, data)
 }
 
 // Text sends a text response with the given status code and text.
 func (c *FiberContext) Text(status int, text string) error {
-	return c.Ctx.Status(status).SendString(text)
+	return c.status(status).SendString(text)
 }
 
 // String sends a string response with the given status code and formatted text.
 func (c *FiberContext) String(status int, format string, values ...any) error {
-	return c.Ctx.Status(status).SendString(fmt.Sprintf(format, values...))
+	return c.status(status).SendString(fmt.Sprintf(format, values...))
 }
 
 // HTML sends an HTML response with the given status code and HTML template name and data.
 func (c *FiberContext) HTML(status int, name string, obj any) error {
-	return c.Ctx.Status(status).Render(name, obj)
+	return c.status(status).Render(name, obj)
 }
 
 // Redirect sends a redirect response with the given status code and URL.
 func (c *FiberContext) Redirect(status int, url string) error {
+	c.markWritten()
 	return c.Ctx.Redirect(url, status)
 }
 
 // File sends a file response with the given filepath.
 func (c *FiberContext) File(filepath string) error {
+	c.markWritten()
 	return c.Ctx.SendFile(filepath)
 }
 
 // Attachment sends an attachment response with the given filepath and filename.
 func (c *FiberContext) Attachment(filepath, filename string) error {
+	c.markWritten()
 	c.Ctx.Attachment(filename)
 	return c.Ctx.SendFile(filepath)
 }
 
-// BindJSON binds the JSON request body into the given object.
+// BindJSON binds the JSON request body into the given object, whatever its content type.
 func (c *FiberContext) BindJSON(obj any) error {
-	return c.Ctx.BodyParser(obj)
+	return c.Ctx.App().Config().JSONDecoder(c.Ctx.Body(), obj)
+}
+
+// Bind binds the request into obj by its struct tags, then validates it. The body is bound by
+// its content type, as JSON or by the "form" tags, then the route parameters, query and headers
+// by the "uri", "query" and "header" tags.
+func (c *FiberContext) Bind(obj any) error {
+	return bindRequest(c, obj, c.values())
+}
+
+// BindQuery binds the query of the request into obj by its "query" tags, then validates it.
+func (c *FiberContext) BindQuery(obj any) error {
+	return bindValues(obj, binding.TagQuery, c.values().query)
+}
+
+// BindURI binds the route parameters of the request into obj by its "uri" tags, then validates it.
+func (c *FiberContext) BindURI(obj any) error {
+	return bindValues(obj, binding.TagURI, c.values().uri)
+}
+
+// BindHeader binds the headers of the request into obj by its "header" tags, then validates it.
+func (c *FiberContext) BindHeader(obj any) error {
+	return bindValues(obj, binding.TagHeader, c.values().header)
+}
+
+// BindForm binds the form of the request body into obj by its "form" tags, then validates it.
+func (c *FiberContext) BindForm(obj any) error {
+	return bindValues(obj, binding.TagForm, c.values().form)
+}
+
+// values returns the getters of the parts of the request.
+func (c *FiberContext) values() requestValues {
+	req := c.Ctx.Request()
+	return requestValues{
+		uri:   nonEmpty(c.Param),
+		query: argsValues(req.URI().QueryArgs()),
+		header: func(key string) ([]string, bool) {
+			values := bytesToStrings(req.Header.PeekAll(key))
+			return values, len(values) > 0
+		},
+		form: func(key string) ([]string, bool) {
+			if !bytes.HasPrefix(req.Header.ContentType(), []byte(binding.MIMEMultipartPOSTForm)) {
+				return argsValues(req.PostArgs())(key)
+			}
+			form, err := c.Ctx.MultipartForm()
+			if err != nil {
+				return nil, false
+			}
+			values, ok := form.Value[key]
+			return values, ok
+		},
+	}
+}
+
+// argsValues returns a getter of the values of the arguments.
+func argsValues(args *fasthttp.Args) binding.Getter {
+	return func(key string) ([]string, bool) {
+		if !args.Has(key) {
+			return nil, false
+		}
+		return bytesToStrings(args.PeekMulti(key)), true
+	}
+}
+
+// bytesToStrings copies the byte slices into strings.
+func bytesToStrings(b [][]byte) []string {
+	s := make([]string, len(b))
+	for i, v := range b {
+		s[i] = string(v)
+	}
+	return s
 }
 
 // FormValue returns the value of the form field with the given key.
@@ -153,13 +261,22 @@ func (c *FiberContext) Path() string {
 	return c.Ctx.Path()
 }
 
-// FullPath returns the route pattern of the request.
+// FullPath returns the route pattern of the request, or its path when no route matches. The
+// middlewares of a group see the pattern of the route matched after them, not of the group.
 func (c *FiberContext) FullPath() string {
-	if c.Ctx.Route().Path == "/" || c.Ctx.Route().Path == "" {
-		return c.Ctx.Path()
+	conf := c.Ctx.App().Config()
+	path := c.Ctx.Path()
+	if route := c.Ctx.Route(); fiber.RoutePatternMatch(path, route.Path, conf) {
+		return route.Path
 	}
 
-	return c.Ctx.Route().Path
+	// the route is the prefix of a middleware.
+	for _, route := range c.Ctx.App().GetRoutes(true) {
+		if route.Method == c.Ctx.Method() && fiber.RoutePatternMatch(path, route.Path, conf) {
+			return route.Path
+		}
+	}
+	return path
 }
 
 // ClientIP returns the client IP of the request.
@@ -210,6 +327,7 @@ func (w *fiberResponseWriter) WriteHeader(statusCode int) {
 		return
 	}
 	w.wroteHead = true
+	w.ctx.Locals(fiberWrittenKey{}, true)
 
 	for k, v := range w.header {
 		for _, val := range v {
@@ -37,21 +37,23 @@ func testFiberFullPathWithRoute(t *testing.T) {
 	assert.Equal(t, "/users/:id/posts", string(body))
 }
 
-// Helper function to test Fiber FullPath fallback logic.
-func testFiberFullPathFallback(t *testing.T) {
-	// Since we can't easily force a real Fiber context to have nil route,
-	// let's test the logic separately to ensure it works correctly
-
-	// route is nil (fallback to path)
-	path := "/fallback/path"
-
-	// Simulate the FullPath logic when route is nil
-	result := path // Direct assignment since we know route is nil
-
-	assert.Equal(t, "/fallback/path", result)
+// Helper function to test Fiber FullPath in a group middleware and without matching route.
+func testFiberFullPathMiddleware(t *testing.T) {
+	var fullPaths []string
+	app := fiber.New()
+	app.Group("/api", func(c *fiber.Ctx) error {
+		fullPaths = append(fullPaths, (&FiberContext{Ctx: c}).FullPath())
+		return c.Next()
+	}).Get("/users/:id", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
 
-	// This ensures both branches of the logic are tested
-	// even if we can't force the real Fiber context to have nil route
+	for _, target := range []string{"/api/users/1", "/api/missing"} {
+		resp, err := app.Test(httptest.NewRequest("GET", target, nil))
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+	assert.Equal(t, []string{"/api/users/:id", "/api/missing"}, fullPaths)
 }
 
 // nolint
@@ -825,7 +827,7 @@ func TestFiberContext_EdgeCases(t *testing.T) {
 // nolint
 func TestFiberContext_FullPath(t *testing.T) {
 	t.Run("with route pattern", testFiberFullPathWithRoute)
-	t.Run("test nil route logic separately", testFiberFullPathFallback)
+	t.Run("in group middleware", testFiberFullPathMiddleware)
 
 	t.Run("direct fullpath calls", func(t *testing.T) {
 		// Create multiple contexts and call FullPath to ensure coverage
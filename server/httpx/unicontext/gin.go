@@ -2,15 +2,59 @@
 package unicontext
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"mime/multipart"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	ginbinding "github.com/gin-gonic/gin/binding"
+	"github.com/hewen/mastiff-go/pkg/contextkeys"
+	"github.com/hewen/mastiff-go/server/httpx/binding"
 )
 
+// ginChainKey is the key of the gin.Context the state of its chain of handlers is kept by.
+const ginChainKey = "unicontext.ginChain"
+
+// ginChain is the state of the chain of handlers of a gin request.
+type ginChain struct {
+	// err is the error of the handler run last.
+	err error
+}
+
+// chainOf returns the state of the chain of handlers of the gin.Context.
+func chainOf(c *gin.Context) *ginChain {
+	if chain, ok := c.Value(ginChainKey).(*ginChain); ok {
+		return chain
+	}
+	chain := &ginChain{}
+	c.Set(ginChainKey, chain)
+	return chain
+}
+
+// HandleGin runs the handler on the gin.Context as a handler of its chain. The chain stops at a
+// handler not calling Next, and the error of the handler is returned by the Next that ran it,
+// or by GinError once the chain returned.
+func HandleGin(c *gin.Context, handler func(UniversalContext) error) {
+	ctx := &GinContext{Ctx: c}
+	err := handler(ctx)
+	chainOf(c).err = err
+	if !ctx.nextCalled {
+		c.Abort()
+	}
+}
+
+// GinError returns the error returned by the chain of handlers of the gin.Context.
+func GinError(c *gin.Context) error {
+	return chainOf(c).err
+}
+
 // GinContext implements the UniversalContext interface for Gin.
 type GinContext struct {
-	Ctx *gin.Context
+	Ctx        *gin.Context
+	nextCalled bool
 }
 
 // Request returns the HTTP request.
@@ -23,16 +67,30 @@ func (c *GinContext) ResponseWriter() http.ResponseWriter {
 	return c.Ctx.Writer
 }
 
-// Next calls the next handler in the chain.
+// Next calls the next handler in the chain and returns its error. The chain stops at a handler
+// not calling Next, or once aborted.
 func (c *GinContext) Next() error {
+	c.nextCalled = true
+	chain := chainOf(c.Ctx)
+	chain.err = nil
 	c.Ctx.Next()
-	return nil
+	return chain.err
+}
+
+// Written reports whether the response was written.
+func (c *GinContext) Written() bool {
+	return c.Ctx.Writer.Written()
 }
 
 // Param returns the value of the URL parameter with the given key.
 // It returns an empty string if the key does not exist.
 func (c *GinContext) Param(key string) string {
-	return c.Ctx.Param(key)
+	value := c.Ctx.Param(key)
+	// gin keeps the leading slash of the rest of the path a "*name" parameter matches.
+	if strings.HasSuffix(c.Ctx.FullPath(), "/*"+key) {
+		return strings.TrimPrefix(value, "/")
+	}
+	return value
 }
 
 // Query returns the value of the URL query parameter with the given key.
@@ -108,9 +166,50 @@ func (c *GinContext) Attachment(filepath, filename string) error {
 	return nil
 }
 
-// BindJSON binds the JSON request body into the given object.
+// BindJSON binds the JSON request body into the given object, whatever its content type.
 func (c *GinContext) BindJSON(obj any) error {
-	return c.Ctx.ShouldBindJSON(obj)
+	body, err := c.Body()
+	if err != nil {
+		return err
+	}
+	return ginbinding.JSON.BindBody(body, obj)
+}
+
+// Bind binds the request into obj by its struct tags, then validates it. The body is bound by
+// its content type, as JSON or by the "form" tags, then the route parameters, query and headers
+// by the "uri", "query" and "header" tags.
+func (c *GinContext) Bind(obj any) error {
+	return bindRequest(c, obj, c.values())
+}
+
+// BindQuery binds the query of the request into obj by its "query" tags, then validates it.
+func (c *GinContext) BindQuery(obj any) error {
+	return bindValues(obj, binding.TagQuery, c.values().query)
+}
+
+// BindURI binds the route parameters of the request into obj by its "uri" tags, then validates it.
+func (c *GinContext) BindURI(obj any) error {
+	return bindValues(obj, binding.TagURI, c.values().uri)
+}
+
+// BindHeader binds the headers of the request into obj by its "header" tags, then validates it.
+func (c *GinContext) BindHeader(obj any) error {
+	return bindValues(obj, binding.TagHeader, c.values().header)
+}
+
+// BindForm binds the form of the request body into obj by its "form" tags, then validates it.
+func (c *GinContext) BindForm(obj any) error {
+	return bindValues(obj, binding.TagForm, c.values().form)
+}
+
+// values returns the getters of the parts of the request.
+func (c *GinContext) values() requestValues {
+	return requestValues{
+		uri:    nonEmpty(c.Param),
+		query:  c.Ctx.GetQueryArray,
+		header: headerValues(c.Ctx.Request.Header),
+		form:   c.Ctx.GetPostFormArray,
+	}
 }
 
 // FormValue returns the value of the form field with the given key.
@@ -125,9 +224,25 @@ func (c *GinContext) FormFile(key string) (*multipart.FileHeader, error) {
 	return c.Ctx.FormFile(key)
 }
 
-// Body returns the body of the request.
+// Body returns the body of the request. The body is kept, so it can be read again, by Body or
+// from the request.
 func (c *GinContext) Body() ([]byte, error) {
-	return c.Ctx.GetRawData()
+	if body, ok := c.Ctx.Value(gin.BodyBytesKey).([]byte); ok {
+		return body, nil
+	}
+	if c.Ctx.Request.Body == nil {
+		c.Ctx.Set(gin.BodyBytesKey, []byte(nil))
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(c.Ctx.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	_ = c.Ctx.Request.Body.Close()
+	c.Ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Ctx.Set(gin.BodyBytesKey, body)
+	return body, nil
 }
 
 // Method returns the HTTP method of the request.
@@ -158,9 +273,14 @@ func (c *GinContext) RemoteAddr() string {
 	return c.Ctx.Request.RemoteAddr
 }
 
-// Set sets the value of the context with the given key.
+// Set sets the value of the context with the given key. A context.Context set by the
+// contextkeys.ContextKey key also becomes the context of the request.
 func (c *GinContext) Set(key string, value any) {
 	c.Ctx.Set(key, value)
+
+	if ctx, ok := value.(context.Context); ok && key == contextkeys.ContextKey {
+		c.Ctx.Request = c.Ctx.Request.WithContext(ctx)
+	}
 }
 
 // Get returns the value of the context with the given key.
@@ -3,6 +3,7 @@ package unicontext
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -157,6 +158,54 @@ func TestGinContext_Param(t *testing.T) {
 	assert.Equal(t, "123", w.Body.String())
 }
 
+func TestGinContext_ParamWildcard(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	router.GET("/files/*path", func(c *gin.Context) {
+		ginCtx := &GinContext{Ctx: c}
+		c.String(http.StatusOK, ginCtx.Param("path"))
+	})
+
+	req := httptest.NewRequest("GET", "/files/docs/readme.md", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "docs/readme.md", w.Body.String())
+}
+
+func TestHandleGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var called bool
+	var nextErr, chainErr error
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Next()
+		chainErr = GinError(c)
+	})
+	router.GET("/test", func(c *gin.Context) {
+		HandleGin(c, func(ctx UniversalContext) error {
+			nextErr = ctx.Next()
+			return errors.New("middleware")
+		})
+	}, func(c *gin.Context) {
+		HandleGin(c, func(UniversalContext) error {
+			return errors.New("handler")
+		})
+	}, func(*gin.Context) {
+		called = true
+	})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.False(t, called)
+	assert.EqualError(t, nextErr, "handler")
+	assert.EqualError(t, chainErr, "middleware")
+}
+
 func TestGinContext_Query(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
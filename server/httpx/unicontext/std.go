@@ -0,0 +1,394 @@
+// Package unicontext provides a context interface for HTTP handlers.
+package unicontext
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hewen/mastiff-go/pkg/contextkeys"
+	"github.com/hewen/mastiff-go/server/httpx/binding"
+)
+
+const (
+	// WildcardParam is the name of the path value an unnamed "*" route parameter is stored in.
+	WildcardParam = "wildcard"
+	// defaultMaxMemory is the memory multipart forms are parsed in, as by net/http.
+	defaultMaxMemory = 32 << 20
+)
+
+// ErrNoTemplates is returned when rendering HTML without templates.
+var ErrNoTemplates = errors.New("html: no templates")
+
+// StdContext implements the UniversalContext interface for net/http.
+type StdContext struct {
+	// Templates holds the templates HTML renders by name.
+	Templates *template.Template
+	w         *stdResponseWriter
+	r         *http.Request
+	keys      map[string]any
+	query     url.Values
+	// Route is the route pattern the request matched, as registered.
+	Route    string
+	body     []byte
+	handlers []func(UniversalContext) error
+	index    int
+	aborted  bool
+	bodyRead bool
+}
+
+// NewStdContext creates a StdContext running the handlers on the request, starting with the
+// first call of Next.
+func NewStdContext(w http.ResponseWriter, r *http.Request, handlers ...func(UniversalContext) error) *StdContext {
+	return &StdContext{
+		w:        &stdResponseWriter{ResponseWriter: w, status: http.StatusOK},
+		r:        r,
+		handlers: handlers,
+		index:    -1,
+	}
+}
+
+// Request returns the HTTP request.
+func (c *StdContext) Request() *http.Request {
+	return c.r
+}
+
+// ResponseWriter returns the HTTP response writer.
+func (c *StdContext) ResponseWriter() http.ResponseWriter {
+	return c.w
+}
+
+// Next calls the next handler in the chain. The chain stops at a handler not calling Next,
+// or once aborted.
+func (c *StdContext) Next() error {
+	c.index++
+	if c.aborted || c.index >= len(c.handlers) {
+		return nil
+	}
+	return c.handlers[c.index](c)
+}
+
+// Written reports whether the response header was written.
+func (c *StdContext) Written() bool {
+	return c.w.written
+}
+
+// Param returns the value of the URL parameter with the given key.
+// It returns an empty string if the key does not exist.
+func (c *StdContext) Param(key string) string {
+	if key == "*" {
+		key = WildcardParam
+	}
+	return c.r.PathValue(key)
+}
+
+// Query returns the value of the URL query parameter with the given key.
+// It returns an empty string if the key does not exist.
+func (c *StdContext) Query(key string) string {
+	if c.query == nil {
+		c.query = c.r.URL.Query()
+	}
+	return c.query.Get(key)
+}
+
+// Header returns the value of the HTTP header with the given key.
+// It returns an empty string if the key does not exist.
+func (c *StdContext) Header(key string) string {
+	return c.r.Header.Get(key)
+}
+
+// Cookie returns the value of the HTTP cookie with the given key.
+// It returns an empty string if the key does not exist.
+func (c *StdContext) Cookie(key string) string {
+	cookie, err := c.r.Cookie(key)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// Data writes some data into the body stream and updates the HTTP code.
+func (c *StdContext) Data(status int, contentType string, data []byte) error {
+	c.w.Header().Set("Content-Type", contentType)
+	c.w.WriteHeader(status)
+	_, err := c.w.Write(data)
+	return err
+}
+
+// JSON sends a JSON response with the given status code and data.
+func (c *StdContext) JSON(status int, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return c.Data(status, "application/json; charset=utf-8", b)
+}
+
+// AbortWithStatusJSON writes the status code and return a JSON body.
+func (c *StdContext) AbortWithStatusJSON(status int, data any) error {
+	c.aborted = true
+	return c.JSON(status, data)
+}
+
+// Text sends a text response with the given status code and text.
+func (c *StdContext) Text(status int, text string) error {
+	return c.Data(status, "text/plain; charset=utf-8", []byte(text))
+}
+
+// String sends a string response with the given status code and formatted text.
+func (c *StdContext) String(status int, format string, values ...any) error {
+	if len(values) > 0 {
+		format = fmt.Sprintf(format, values...)
+	}
+	return c.Text(status, format)
+}
+
+// HTML sends an HTML response with the given status code and HTML template name and data.
+func (c *StdContext) HTML(status int, name string, obj any) error {
+	if c.Templates == nil {
+		return ErrNoTemplates
+	}
+	var buf bytes.Buffer
+	if err := c.Templates.ExecuteTemplate(&buf, name, obj); err != nil {
+		return err
+	}
+	return c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// Redirect sends a redirect response with the given status code and URL.
+func (c *StdContext) Redirect(status int, url string) error {
+	http.Redirect(c.w, c.r, url, status)
+	return nil
+}
+
+// File sends a file response with the given filepath.
+func (c *StdContext) File(filepath string) error {
+	http.ServeFile(c.w, c.r, filepath)
+	return nil
+}
+
+// Attachment sends an attachment response with the given filepath and filename.
+func (c *StdContext) Attachment(filepath, filename string) error {
+	if isASCII(filename) {
+		c.w.Header().Set("Content-Disposition", `attachment; filename="`+quoteEscaper.Replace(filename)+`"`)
+	} else {
+		c.w.Header().Set("Content-Disposition", `attachment; filename*=UTF-8''`+url.QueryEscape(filename))
+	}
+	http.ServeFile(c.w, c.r, filepath)
+	return nil
+}
+
+// BindJSON binds the JSON request body into the given object.
+func (c *StdContext) BindJSON(obj any) error {
+	body, err := c.Body()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, obj)
+}
+
+// Bind binds the request into obj by its struct tags, then validates it. The body is bound by
+// its content type, as JSON or by the "form" tags, then the route parameters, query and headers
+// by the "uri", "query" and "header" tags.
+func (c *StdContext) Bind(obj any) error {
+	return bindRequest(c, obj, c.values())
+}
+
+// BindQuery binds the query of the request into obj by its "query" tags, then validates it.
+func (c *StdContext) BindQuery(obj any) error {
+	return bindValues(obj, binding.TagQuery, c.values().query)
+}
+
+// BindURI binds the route parameters of the request into obj by its "uri" tags, then validates it.
+func (c *StdContext) BindURI(obj any) error {
+	return bindValues(obj, binding.TagURI, c.values().uri)
+}
+
+// BindHeader binds the headers of the request into obj by its "header" tags, then validates it.
+func (c *StdContext) BindHeader(obj any) error {
+	return bindValues(obj, binding.TagHeader, c.values().header)
+}
+
+// BindForm binds the form of the request body into obj by its "form" tags, then validates it.
+func (c *StdContext) BindForm(obj any) error {
+	return bindValues(obj, binding.TagForm, c.values().form)
+}
+
+// values returns the getters of the parts of the request.
+func (c *StdContext) values() requestValues {
+	return requestValues{
+		uri: nonEmpty(c.Param),
+		query: func(key string) ([]string, bool) {
+			if c.query == nil {
+				c.query = c.r.URL.Query()
+			}
+			values, ok := c.query[key]
+			return values, ok
+		},
+		header: headerValues(c.r.Header),
+		form: func(key string) ([]string, bool) {
+			if c.r.PostForm == nil {
+				_ = c.r.ParseMultipartForm(defaultMaxMemory)
+			}
+			values, ok := c.r.PostForm[key]
+			return values, ok
+		},
+	}
+}
+
+// FormValue returns the value of the form field with the given key.
+// It returns an empty string if the key does not exist.
+func (c *StdContext) FormValue(key string) string {
+	return c.r.PostFormValue(key)
+}
+
+// FormFile returns the file header of the form file with the given key.
+// It returns an error if the key does not exist.
+func (c *StdContext) FormFile(key string) (*multipart.FileHeader, error) {
+	f, fh, err := c.r.FormFile(key)
+	if err != nil {
+		return nil, err
+	}
+	_ = f.Close()
+	return fh, nil
+}
+
+// Body returns the body of the request. The body is read once, and stays readable from the
+// request afterwards.
+func (c *StdContext) Body() ([]byte, error) {
+	if c.bodyRead {
+		return c.body, nil
+	}
+	if c.r.Body == nil {
+		c.bodyRead = true
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(c.r.Body)
+	if err != nil {
+		return nil, err
+	}
+	_ = c.r.Body.Close()
+	c.r.Body = io.NopCloser(bytes.NewReader(body))
+	c.body, c.bodyRead = body, true
+	return body, nil
+}
+
+// Method returns the HTTP method of the request.
+func (c *StdContext) Method() string {
+	return c.r.Method
+}
+
+// Path returns the path of the request.
+func (c *StdContext) Path() string {
+	return c.r.URL.Path
+}
+
+// FullPath returns the route pattern of the request.
+func (c *StdContext) FullPath() string {
+	if c.Route == "" {
+		return c.Path()
+	}
+	return c.Route
+}
+
+// ClientIP returns the client IP of the request.
+func (c *StdContext) ClientIP() string {
+	host, _, err := net.SplitHostPort(c.r.RemoteAddr)
+	if err != nil {
+		return c.r.RemoteAddr
+	}
+	return host
+}
+
+// RemoteAddr returns the "IP:port" of the request.
+func (c *StdContext) RemoteAddr() string {
+	return c.r.RemoteAddr
+}
+
+// Set sets the value of the context with the given key. A context.Context set by the
+// contextkeys.ContextKey key also becomes the context of the request.
+func (c *StdContext) Set(key string, value any) {
+	if c.keys == nil {
+		c.keys = make(map[string]any)
+	}
+	c.keys[key] = value
+
+	if ctx, ok := value.(context.Context); ok && key == contextkeys.ContextKey {
+		c.r = c.r.WithContext(ctx)
+	}
+}
+
+// Get returns the value of the context with the given key.
+// It returns nil if the key does not exist.
+func (c *StdContext) Get(key string) (any, bool) {
+	val, ok := c.keys[key]
+	return val, ok
+}
+
+// StatusCode returns the status code of the response.
+func (c *StdContext) StatusCode() int {
+	return c.w.status
+}
+
+// stdResponseWriter is a response writer recording the status code of the response.
+type stdResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	written bool
+}
+
+// WriteHeader writes the status code and header to the response.
+func (w *stdResponseWriter) WriteHeader(statusCode int) {
+	if w.written {
+		return
+	}
+	w.written = true
+	w.status = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write writes the data to the response.
+func (w *stdResponseWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends any buffered data to the client.
+func (w *stdResponseWriter) Flush() {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying response writer, for http.ResponseController.
+func (w *stdResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// quoteEscaper escapes the quotes of a filename in a Content-Disposition header.
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// isASCII reports whether s contains only printable ASCII characters.
+func isASCII(s string) bool {
+	for i := range len(s) {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,265 @@
+package unicontext
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hewen/mastiff-go/pkg/contextkeys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStdContext creates a StdContext for the request, recording its response.
+func newTestStdContext(req *http.Request, handlers ...func(UniversalContext) error) (*StdContext, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	return NewStdContext(w, req, handlers...), w
+}
+
+func TestStdContext_InterfaceCompliance(_ *testing.T) {
+	var _ UniversalContext = &StdContext{}
+}
+
+func TestStdContext_Next(t *testing.T) {
+	var order []string
+	errStop := errors.New("stop")
+	c, _ := newTestStdContext(httptest.NewRequest(http.MethodGet, "/", nil),
+		func(c UniversalContext) error {
+			order = append(order, "first")
+			return c.Next()
+		},
+		func(UniversalContext) error {
+			order = append(order, "second")
+			return errStop
+		},
+		func(UniversalContext) error {
+			order = append(order, "third")
+			return nil
+		},
+	)
+
+	assert.ErrorIs(t, c.Next(), errStop)
+	assert.Equal(t, []string{"first", "second"}, order)
+	// the chain does not run again.
+	assert.NoError(t, c.Next())
+}
+
+func TestStdContext_Request(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/users/1?name=mastiff&tag=a", strings.NewReader(`{"id":1}`))
+	req.Header.Set("X-Token", "secret")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	req.SetPathValue("id", "1")
+	req.SetPathValue(WildcardParam, "rest/of/path")
+	req.RemoteAddr = "10.0.0.1:1234"
+	c, _ := newTestStdContext(req)
+	c.Route = "/users/:id"
+
+	assert.Same(t, req, c.Request())
+	assert.Equal(t, "1", c.Param("id"))
+	assert.Equal(t, "rest/of/path", c.Param("*"))
+	assert.Equal(t, "mastiff", c.Query("name"))
+	assert.Equal(t, "", c.Query("missing"))
+	assert.Equal(t, "secret", c.Header("X-Token"))
+	assert.Equal(t, "abc", c.Cookie("session"))
+	assert.Equal(t, "", c.Cookie("missing"))
+	assert.Equal(t, http.MethodPost, c.Method())
+	assert.Equal(t, "/users/1", c.Path())
+	assert.Equal(t, "/users/:id", c.FullPath())
+	assert.Equal(t, "10.0.0.1", c.ClientIP())
+	assert.Equal(t, "10.0.0.1:1234", c.RemoteAddr())
+
+	c.Route = ""
+	assert.Equal(t, "/users/1", c.FullPath())
+	req.RemoteAddr = "pipe"
+	assert.Equal(t, "pipe", c.ClientIP())
+}
+
+func TestStdContext_Body(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"mastiff"}`))
+	c, _ := newTestStdContext(req)
+
+	var target struct {
+		Name string `json:"name"`
+	}
+	require.NoError(t, c.BindJSON(&target))
+	assert.Equal(t, "mastiff", target.Name)
+
+	// the body is read once and stays readable.
+	body, err := c.Body()
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"mastiff"}`, string(body))
+	body, err = io.ReadAll(c.Request().Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"mastiff"}`, string(body))
+
+	empty, _ := newTestStdContext(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Error(t, empty.BindJSON(&target))
+
+	noBody := httptest.NewRequest(http.MethodGet, "/", nil)
+	noBody.Body = nil
+	c, _ = newTestStdContext(noBody)
+	body, err = c.Body()
+	assert.NoError(t, err)
+	assert.Empty(t, body)
+}
+
+func TestStdContext_Form(t *testing.T) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	require.NoError(t, mw.WriteField("name", "mastiff"))
+	fw, err := mw.CreateFormFile("file", "test.txt")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("content"))
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	c, _ := newTestStdContext(req)
+
+	assert.Equal(t, "mastiff", c.FormValue("name"))
+	fh, err := c.FormFile("file")
+	require.NoError(t, err)
+	assert.Equal(t, "test.txt", fh.Filename)
+	_, err = c.FormFile("missing")
+	assert.Error(t, err)
+
+	form := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url.Values{"name": {"fiber"}}.Encode()))
+	form.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c, _ = newTestStdContext(form)
+	assert.Equal(t, "fiber", c.FormValue("name"))
+}
+
+func TestStdContext_Responses(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	c, w := newTestStdContext(req)
+	assert.Equal(t, http.StatusOK, c.StatusCode())
+	assert.False(t, c.Written())
+	require.NoError(t, c.JSON(http.StatusCreated, map[string]string{"message": "ok"}))
+	assert.Equal(t, http.StatusCreated, c.StatusCode())
+	assert.True(t, c.Written())
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"message":"ok"}`, w.Body.String())
+
+	c, _ = newTestStdContext(req)
+	assert.Error(t, c.JSON(http.StatusOK, make(chan int)))
+	assert.False(t, c.Written())
+
+	c, w = newTestStdContext(req)
+	require.NoError(t, c.Text(http.StatusAccepted, "text"))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "text", w.Body.String())
+
+	c, w = newTestStdContext(req)
+	require.NoError(t, c.String(http.StatusOK, "hello %s", "world"))
+	assert.Equal(t, "hello world", w.Body.String())
+
+	c, w = newTestStdContext(req)
+	require.NoError(t, c.String(http.StatusOK, "100%"))
+	assert.Equal(t, "100%", w.Body.String())
+
+	c, w = newTestStdContext(req)
+	require.NoError(t, c.Data(http.StatusOK, "application/octet-stream", []byte{1, 2}))
+	assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, []byte{1, 2}, w.Body.Bytes())
+
+	c, w = newTestStdContext(req)
+	require.NoError(t, c.Redirect(http.StatusFound, "/login"))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+
+	c, _ = newTestStdContext(req)
+	assert.ErrorIs(t, c.HTML(http.StatusOK, "page", nil), ErrNoTemplates)
+}
+
+func TestStdContext_AbortWithStatusJSON(t *testing.T) {
+	var called bool
+	c, w := newTestStdContext(httptest.NewRequest(http.MethodGet, "/", nil),
+		func(c UniversalContext) error {
+			_ = c.AbortWithStatusJSON(http.StatusForbidden, map[string]string{"error": "forbidden"})
+			return c.Next()
+		},
+		func(UniversalContext) error {
+			called = true
+			return nil
+		},
+	)
+
+	require.NoError(t, c.Next())
+	assert.False(t, called)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"error":"forbidden"}`, w.Body.String())
+}
+
+func TestStdContext_File(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.txt")
+	require.NoError(t, os.WriteFile(path, []byte("file content"), 0o600))
+	req := httptest.NewRequest(http.MethodGet, "/download", nil)
+
+	c, w := newTestStdContext(req)
+	require.NoError(t, c.File(path))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "file content", w.Body.String())
+
+	c, w = newTestStdContext(req)
+	require.NoError(t, c.Attachment(path, `report "1".txt`))
+	assert.Equal(t, `attachment; filename="report \"1\".txt"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "file content", w.Body.String())
+
+	c, w = newTestStdContext(req)
+	require.NoError(t, c.Attachment(path, "报告.txt"))
+	assert.Equal(t, "attachment; filename*=UTF-8''"+url.QueryEscape("报告.txt"), w.Header().Get("Content-Disposition"))
+}
+
+func TestStdContext_SetAndGet(t *testing.T) {
+	c, _ := newTestStdContext(httptest.NewRequest(http.MethodGet, "/", nil))
+
+	_, ok := c.Get("missing")
+	assert.False(t, ok)
+	c.Set("key", "value")
+	val, ok := c.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, "value", val)
+
+	// a context set by the context key becomes the context of the request.
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "traced")
+	c.Set(contextkeys.ContextKey, ctx)
+	assert.Equal(t, "traced", c.Request().Context().Value(key{}))
+	assert.Equal(t, ctx, ContextFrom(c))
+}
+
+func TestStdResponseWriter(t *testing.T) {
+	c, w := newTestStdContext(httptest.NewRequest(http.MethodGet, "/", nil))
+	rw := c.ResponseWriter()
+
+	rw.Header().Set("X-Test", "1")
+	rw.WriteHeader(http.StatusTeapot)
+	rw.WriteHeader(http.StatusOK)
+	_, err := rw.Write([]byte("body"))
+	require.NoError(t, err)
+	require.NoError(t, http.NewResponseController(rw).Flush())
+
+	assert.Equal(t, http.StatusTeapot, c.StatusCode())
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-Test"))
+	assert.True(t, w.Flushed)
+	assert.Same(t, w, rw.(interface{ Unwrap() http.ResponseWriter }).Unwrap())
+
+	c, w = newTestStdContext(httptest.NewRequest(http.MethodGet, "/", nil))
+	c.ResponseWriter().(http.Flusher).Flush()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, c.Written())
+}
@@ -52,6 +52,20 @@ type UniversalContext interface {
 	Attachment(filepath, filename string) error
 	// BindJSON binds the JSON request body into the given object.
 	BindJSON(target any) error
+	// Bind binds the request into target by its struct tags, then validates it. The body is
+	// bound by its content type, as JSON or by the "form" tags, then the route parameters, query
+	// and headers by the "uri", "query" and "header" tags.
+	Bind(target any) error
+	// BindQuery binds the query of the request into target by its "query" tags, then validates it.
+	BindQuery(target any) error
+	// BindURI binds the route parameters into target by its "uri" tags, then validates it.
+	BindURI(target any) error
+	// BindHeader binds the headers of the request into target by its "header" tags, then
+	// validates it.
+	BindHeader(target any) error
+	// BindForm binds the form of the request body into target by its "form" tags, then
+	// validates it.
+	BindForm(target any) error
 	// FormValue returns the value of the form field with the given key.
 	// It returns an empty string if the key does not exist.
 	FormValue(key string) string
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultReadyTimeout is the default duration a server has to pass its checks and become ready.
+	DefaultReadyTimeout = 30 * time.Second
+	// DefaultStopTimeout is the default duration a server has to stop.
+	DefaultStopTimeout = 30 * time.Second
+	// checkInterval is the interval between attempts of a failing WaitFor check.
+	checkInterval = 500 * time.Millisecond
+)
+
+var (
+	// ErrUnknownDependency is returned when a server depends on a server that was not added.
+	ErrUnknownDependency = errors.New("unknown dependency")
+	// ErrDependencyCycle is returned when servers depend on each other.
+	ErrDependencyCycle = errors.New("dependency cycle")
+	// ErrNotReady is returned when a server does not become ready within its ready timeout.
+	ErrNotReady = errors.New("server not ready")
+	// ErrAlreadyStarted is returned when starting servers twice.
+	ErrAlreadyStarted = errors.New("servers already started")
+)
+
+// Servable is implemented by servers whose start can fail. Servers calls Serve instead of
+// Start, so the error stops the other servers and is returned by Servers.Start.
+type Servable interface {
+	// Serve runs the server until it is stopped, returning an error if it fails.
+	Serve() error
+}
+
+// ReadyNotifier is implemented by servers that become ready some time after they are started,
+// such as once they listen. Servers without it are ready as soon as they are started.
+type ReadyNotifier interface {
+	// Ready returns a channel closed once the server is ready, or nil if it is ready on start.
+	Ready() <-chan struct{}
+}
+
+// AddOption configures how Servers starts and stops an added server.
+type AddOption func(e *entry)
+
+// DependsOn makes the server start once the given servers are ready, and stop before them.
+func DependsOn(servers ...Server) AddOption {
+	return func(e *entry) {
+		e.deps = append(e.deps, servers...)
+	}
+}
+
+// WaitFor makes the server start once the check succeeds, such as a ping of a database. The
+// check is retried until it succeeds or the ready timeout of the server elapses.
+func WaitFor(check func(ctx context.Context) error) AddOption {
+	return func(e *entry) {
+		e.checks = append(e.checks, check)
+	}
+}
+
+// WithReadyTimeout sets the duration the server has to pass its checks and become ready.
+func WithReadyTimeout(d time.Duration) AddOption {
+	return func(e *entry) {
+		if d > 0 {
+			e.readyTimeout = d
+		}
+	}
+}
+
+// WithStopTimeout sets the duration the server has to stop before the servers it depends on
+// are stopped anyway. The shutdown timeout of Servers still bounds it.
+func WithStopTimeout(d time.Duration) AddOption {
+	return func(e *entry) {
+		if d > 0 {
+			e.stopTimeout = d
+		}
+	}
+}
+
+// entry is a server added to Servers.
+type entry struct {
+	server       *LoggingServer
+	ready        chan struct{}
+	deps         []Server
+	depEntries   []*entry
+	checks       []func(ctx context.Context) error
+	readyTimeout time.Duration
+	stopTimeout  time.Duration
+}
+
+// matches reports whether the entry is the given server, either as added or unwrapped.
+func (e *entry) matches(s Server) bool {
+	if ls, ok := s.(*LoggingServer); ok && ls == e.server {
+		return true
+	}
+	return e.server.Inner == s
+}
+
+// resolve returns the entries in start order, in which every entry comes after its
+// dependencies and otherwise keeps the order they were added in.
+func resolve(entries []*entry) ([]*entry, error) {
+	for _, e := range entries {
+		e.depEntries = e.depEntries[:0]
+		for _, dep := range e.deps {
+			found := false
+			for _, other := range entries {
+				if other.matches(dep) {
+					e.depEntries = append(e.depEntries, other)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("%s: %w", e.server.Name(), ErrUnknownDependency)
+			}
+		}
+	}
+
+	order := make([]*entry, 0, len(entries))
+	placed := make(map[*entry]bool, len(entries))
+	for len(order) < len(entries) {
+		progressed := false
+		for _, e := range entries {
+			if placed[e] || !allPlaced(e.depEntries, placed) {
+				continue
+			}
+			order = append(order, e)
+			placed[e] = true
+			progressed = true
+		}
+		if !progressed {
+			return nil, ErrDependencyCycle
+		}
+	}
+	return order, nil
+}
+
+// allPlaced reports whether all entries are placed.
+func allPlaced(entries []*entry, placed map[*entry]bool) bool {
+	for _, e := range entries {
+		if !placed[e] {
+			return false
+		}
+	}
+	return true
+}
+
+// run waits for the dependencies and checks of the entry, then runs its server until it
+// stops. Nothing is started once stopping is closed.
+func (s *Servers) run(e *entry, stopping <-chan struct{}) error {
+	for _, dep := range e.depEntries {
+		select {
+		case <-dep.ready:
+		case <-stopping:
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.readyTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-stopping:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for _, check := range e.checks {
+		if err := waitFor(ctx, check); err != nil {
+			select {
+			case <-stopping:
+				return nil
+			default:
+				return fmt.Errorf("%s: %w: %v", e.server.Name(), ErrNotReady, err)
+			}
+		}
+	}
+
+	select {
+	case <-stopping:
+		return nil
+	default:
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- e.server.Serve()
+	}()
+
+	ready := e.server.Ready()
+	if ready == nil {
+		close(e.ready)
+	} else {
+		select {
+		case <-ready:
+			close(e.ready)
+		case err := <-served:
+			return startError(e, err)
+		case <-ctx.Done():
+			select {
+			case <-stopping:
+			default:
+				go s.Stop()
+				err := fmt.Errorf("%s: %w after %s", e.server.Name(), ErrNotReady, e.readyTimeout)
+				return errors.Join(err, startError(e, <-served))
+			}
+		}
+	}
+
+	return startError(e, <-served)
+}
+
+// waitFor retries the check until it succeeds or ctx is done.
+func waitFor(ctx context.Context, check func(ctx context.Context) error) error {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		err := check(ctx)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+// startError wraps the error a server returned from Serve.
+func startError(e *entry, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("start %s: %w", e.server.Name(), err)
+}
@@ -0,0 +1,236 @@
+// Package listener creates the network listeners of servers, inheriting them from a parent
+// process and handing them over to a new process on upgrade, so restarts drop no connections.
+//
+// Listeners are passed as file descriptors starting at 3, described by the LISTEN_FDS and
+// LISTEN_FDNAMES environment variables as in systemd socket activation. A process started by
+// Upgrade reports readiness through the descriptor in LISTEN_READY_FD, after which the parent
+// drains its connections and exits. Listeners are only inherited and handed over on unix; on
+// other systems a Factory only creates them, and Upgrade fails.
+package listener
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// EnvListenFDs is the number of inherited listeners.
+	EnvListenFDs = "LISTEN_FDS"
+	// EnvListenPID is the process the listeners are meant for. Upgrade does not set it, as the
+	// pid of the new process is unknown before it starts.
+	EnvListenPID = "LISTEN_PID"
+	// EnvListenFDNames is the colon separated names of the inherited listeners. Upgrade names
+	// them by the query escaped address they were requested for.
+	EnvListenFDNames = "LISTEN_FDNAMES"
+	// EnvReadyFD is the descriptor a process started by Upgrade reports readiness through.
+	EnvReadyFD = "LISTEN_READY_FD"
+
+	// DefaultUpgradeTimeout is the default duration a new process has to become ready.
+	DefaultUpgradeTimeout = 30 * time.Second
+)
+
+var (
+	// ErrUpgradeInProgress is returned when upgrading while an upgrade is in progress.
+	ErrUpgradeInProgress = errors.New("upgrade in progress")
+	// ErrNotReady is returned when the new process exits or times out before it is ready.
+	ErrNotReady = errors.New("new process not ready")
+)
+
+// inherited is a listener inherited from the parent process.
+type inherited struct {
+	ln   net.Listener
+	name string
+}
+
+// Factory creates listeners, reusing the ones inherited from the parent process. Servers
+// sharing a process share one Factory, passed to them as an option, so Upgrade hands all of
+// their listeners over. A nil Factory listens without inheriting.
+type Factory struct {
+	readyFile  *os.File
+	command    func() (*exec.Cmd, error)
+	inherited  []*inherited
+	active     []*listener
+	upgradable bool
+	upgrading  bool
+	mu         sync.Mutex
+}
+
+// New creates a factory with the listeners inherited through the environment, which it then
+// unsets so they are not passed on to other processes.
+func New() (*Factory, error) {
+	f := &Factory{command: executable}
+	defer unsetEnv()
+
+	if err := f.inherit(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Listen returns the inherited listener requested for or bound to the address, or listens on
+// it. Closing the listener removes it from the listeners handed over on upgrade.
+func (f *Factory) Listen(network, addr string) (net.Listener, error) {
+	if f == nil {
+		return net.Listen(network, addr)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var ln net.Listener
+	for i, in := range f.inherited {
+		if in.name == addr || matches(in.ln.Addr(), network, addr) {
+			ln = in.ln
+			f.inherited = append(f.inherited[:i], f.inherited[i+1:]...)
+			break
+		}
+	}
+	if ln == nil {
+		var err error
+		if ln, err = net.Listen(network, addr); err != nil {
+			return nil, err
+		}
+	}
+
+	l := &listener{Listener: ln, factory: f, name: addr}
+	f.active = append(f.active, l)
+	return l, nil
+}
+
+// Inherited reports whether the process inherited listeners from its parent.
+func (f *Factory) Inherited() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.readyFile != nil || len(f.inherited) > 0
+}
+
+// EnableUpgrade marks the process as upgradable, so servers binding their own sockets set
+// SO_REUSEPORT and a new process can bind them while this one drains.
+func (f *Factory) EnableUpgrade() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.upgradable = true
+}
+
+// Upgradable reports whether the process may be upgraded.
+func (f *Factory) Upgradable() bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.upgradable
+}
+
+// Ready reports to the parent process that this process is ready, so the parent drains, and
+// closes the inherited listeners no server asked for. It does nothing for a process not
+// started by Upgrade.
+func (f *Factory) Ready() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closeInherited()
+	if f.readyFile == nil {
+		return nil
+	}
+	_, err := f.readyFile.Write([]byte{1})
+	err = errors.Join(err, f.readyFile.Close())
+	f.readyFile = nil
+	return err
+}
+
+// closeInherited closes the inherited listeners no server asked for.
+func (f *Factory) closeInherited() {
+	for _, in := range f.inherited {
+		_ = in.ln.Close()
+	}
+	f.inherited = nil
+}
+
+// remove removes a closed listener from the active ones.
+func (f *Factory) remove(l *listener) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, active := range f.active {
+		if active == l {
+			f.active = append(f.active[:i], f.active[i+1:]...)
+			return
+		}
+	}
+}
+
+// listener is a listener created by a Factory.
+type listener struct {
+	net.Listener
+	factory *Factory
+	name    string
+	once    sync.Once
+}
+
+// Close closes the listener and removes it from the listeners handed over on upgrade.
+func (l *listener) Close() error {
+	l.once.Do(func() {
+		l.factory.remove(l)
+	})
+	return l.Listener.Close()
+}
+
+// matches reports whether a listener bound to bound serves the address requested on network.
+func matches(bound net.Addr, network, addr string) bool {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		tcp, ok := bound.(*net.TCPAddr)
+		if !ok {
+			return false
+		}
+		want, err := net.ResolveTCPAddr(network, addr)
+		if err != nil || want.Port == 0 || want.Port != tcp.Port {
+			return false
+		}
+		if want.IP == nil || want.IP.IsUnspecified() {
+			return tcp.IP == nil || tcp.IP.IsUnspecified()
+		}
+		return want.IP.Equal(tcp.IP)
+	case "unix":
+		return bound.Network() == network && bound.String() == addr
+	default:
+		return false
+	}
+}
+
+// executable returns a command running the executable of the process with its arguments.
+func executable() (*exec.Cmd, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd, nil
+}
+
+// environ returns the environment of the process without the variables of this package.
+func environ() []string {
+	env := os.Environ()
+	kept := env[:0:0]
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		switch name {
+		case EnvListenFDs, EnvListenPID, EnvListenFDNames, EnvReadyFD:
+		default:
+			kept = append(kept, kv)
+		}
+	}
+	return kept
+}
+
+// unsetEnv unsets the variables of this package.
+func unsetEnv() {
+	for _, name := range []string{EnvListenFDs, EnvListenPID, EnvListenFDNames, EnvReadyFD} {
+		_ = os.Unsetenv(name)
+	}
+}
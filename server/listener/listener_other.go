@@ -0,0 +1,19 @@
+//go:build !unix
+
+package listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// inherit does nothing, as only unix processes inherit listeners.
+func (f *Factory) inherit() error {
+	return nil
+}
+
+// Upgrade fails, as only unix processes can hand their listeners over.
+func (f *Factory) Upgrade(_ context.Context) error {
+	return fmt.Errorf("upgrade: %w", errors.ErrUnsupported)
+}
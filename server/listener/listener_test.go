@@ -0,0 +1,170 @@
+//go:build unix
+
+package listener
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const envHelper = "LISTENER_TEST_HELPER"
+
+// TestHelperProcess is the new process started by the upgrade tests. It serves the inherited
+// listener of the address in LISTENER_TEST_HELPER, answering "child" to every connection.
+func TestHelperProcess(_ *testing.T) {
+	addr := os.Getenv(envHelper)
+	if addr == "" {
+		return
+	}
+	if addr == "fail" {
+		os.Exit(1)
+	}
+
+	f, err := New()
+	if err != nil || !f.Inherited() {
+		os.Exit(2)
+	}
+	ln, err := f.Listen("tcp", addr)
+	if err != nil {
+		os.Exit(3)
+	}
+	if err := f.Ready(); err != nil {
+		os.Exit(4)
+	}
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			os.Exit(0)
+		}
+		_, _ = conn.Write([]byte("child\n"))
+		_ = conn.Close()
+	}
+}
+
+// helperCommand returns a command running TestHelperProcess with the given address.
+func helperCommand(addr string) func() (*exec.Cmd, error) {
+	return func() (*exec.Cmd, error) {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+		cmd.Env = append(environ(), envHelper+"="+addr)
+		return cmd, nil
+	}
+}
+
+func TestFactory_Listen(t *testing.T) {
+	f, err := New()
+	require.NoError(t, err)
+	assert.False(t, f.Inherited())
+
+	ln, err := f.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	files, names, err := f.files()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, []string{"127.0.0.1%3A0"}, names)
+	_ = files[0].Close()
+
+	// closed listeners are not handed over.
+	require.NoError(t, ln.Close())
+	files, _, err = f.files()
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	_, err = f.Listen("tcp", "invalid")
+	assert.Error(t, err)
+
+	f.EnableUpgrade()
+	assert.True(t, f.Upgradable())
+	assert.NoError(t, f.Ready())
+}
+
+func TestFactory_Nil(t *testing.T) {
+	var f *Factory
+	ln, err := f.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	assert.NoError(t, ln.Close())
+	assert.False(t, f.Upgradable())
+}
+
+func TestNew_InvalidEnv(t *testing.T) {
+	t.Setenv(EnvListenFDs, "x")
+	_, err := New()
+	assert.ErrorContains(t, err, EnvListenFDs)
+	// the environment is unset so it is not passed on.
+	_, ok := os.LookupEnv(EnvListenFDs)
+	assert.False(t, ok)
+
+	t.Setenv(EnvListenFDs, "1")
+	t.Setenv(EnvListenPID, "1")
+	f, err := New()
+	require.NoError(t, err)
+	assert.False(t, f.Inherited())
+}
+
+func TestFactory_Upgrade(t *testing.T) {
+	f, err := New()
+	require.NoError(t, err)
+	ln, err := f.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+
+	f.command = helperCommand("127.0.0.1:0")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, f.Upgrade(ctx))
+
+	// once this process stops accepting, the new one serves the same socket.
+	require.NoError(t, ln.Close())
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "child\n", line)
+}
+
+func TestFactory_UpgradeNotReady(t *testing.T) {
+	f, err := New()
+	require.NoError(t, err)
+	ln, err := f.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	f.command = helperCommand("fail")
+	assert.ErrorIs(t, f.Upgrade(context.Background()), ErrNotReady)
+
+	// the new process is killed once ctx is done.
+	f.command = func() (*exec.Cmd, error) {
+		return exec.Command("sleep", "10"), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = f.Upgrade(ctx)
+	assert.ErrorIs(t, err, ErrNotReady)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestMatches(t *testing.T) {
+	any4 := &net.TCPAddr{IP: net.IPv4zero, Port: 8080}
+	local := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}
+
+	assert.True(t, matches(any4, "tcp", ":8080"))
+	assert.True(t, matches(local, "tcp", "127.0.0.1:8080"))
+	assert.False(t, matches(local, "tcp", ":8080"))
+	assert.False(t, matches(any4, "tcp", ":8081"))
+	assert.False(t, matches(any4, "tcp", ":0"))
+	assert.False(t, matches(any4, "unix", "/tmp/s.sock"))
+	assert.True(t, matches(&net.UnixAddr{Name: "/tmp/s.sock", Net: "unix"}, "unix", "/tmp/s.sock"))
+	assert.False(t, matches(any4, "udp", ":8080"))
+}
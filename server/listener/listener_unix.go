@@ -0,0 +1,180 @@
+//go:build unix
+
+package listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// listenFDsStart is the first inherited descriptor.
+const listenFDsStart = 3
+
+// filer is implemented by listeners backed by a file descriptor.
+type filer interface {
+	File() (*os.File, error)
+}
+
+// inherit takes the readiness descriptor and the listeners passed through the environment.
+func (f *Factory) inherit() error {
+	if fd := os.Getenv(EnvReadyFD); fd != "" {
+		n, err := strconv.Atoi(fd)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvReadyFD, err)
+		}
+		syscall.CloseOnExec(n)
+		f.readyFile = os.NewFile(uintptr(n), "ready")
+	}
+
+	fds := os.Getenv(EnvListenFDs)
+	if fds == "" {
+		return nil
+	}
+	if pid := os.Getenv(EnvListenPID); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+	n, err := strconv.Atoi(fds)
+	if err != nil {
+		return fmt.Errorf("%s: %w", EnvListenFDs, err)
+	}
+	names := strings.Split(os.Getenv(EnvListenFDNames), ":")
+
+	for i := range n {
+		file := os.NewFile(uintptr(listenFDsStart+i), "listener")
+		ln, err := net.FileListener(file)
+		_ = file.Close()
+		if err != nil {
+			f.closeInherited()
+			return fmt.Errorf("inherit listener %d: %w", i, err)
+		}
+		in := &inherited{ln: ln}
+		if i < len(names) {
+			in.name, _ = url.QueryUnescape(names[i])
+		}
+		f.inherited = append(f.inherited, in)
+	}
+	return nil
+}
+
+// Upgrade starts the executable of the process with its arguments, handing over the active
+// listeners, and waits for it to report readiness. Once it returns nil, the caller should stop
+// its servers so the new process takes over. The new process is killed if it is not ready
+// before ctx is done.
+func (f *Factory) Upgrade(ctx context.Context) error {
+	f.mu.Lock()
+	if f.upgrading {
+		f.mu.Unlock()
+		return ErrUpgradeInProgress
+	}
+	f.upgrading = true
+	files, names, err := f.files()
+	command := f.command
+	f.mu.Unlock()
+
+	defer func() {
+		for _, file := range files {
+			_ = file.Close()
+		}
+		f.mu.Lock()
+		f.upgrading = false
+		f.mu.Unlock()
+	}()
+	if err != nil {
+		return err
+	}
+
+	cmd, err := command()
+	if err != nil {
+		return err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	env := cmd.Env
+	if env == nil {
+		env = environ()
+	}
+	cmd.ExtraFiles = append(files, w)
+	cmd.Env = append(env,
+		EnvListenFDs+"="+strconv.Itoa(len(files)),
+		EnvListenFDNames+"="+strings.Join(names, ":"),
+		EnvReadyFD+"="+strconv.Itoa(listenFDsStart+len(files)),
+	)
+	err = cmd.Start()
+	_ = w.Close()
+	if err != nil {
+		return err
+	}
+
+	ready := make(chan error, 1)
+	go func() {
+		_, err := r.Read(make([]byte, 1))
+		ready <- err
+	}()
+
+	select {
+	case err = <-ready:
+		if err == nil {
+			go func() {
+				_ = cmd.Wait()
+			}()
+			f.handedOver()
+			return nil
+		}
+		if errors.Is(err, io.EOF) {
+			err = ErrNotReady
+		}
+	case <-ctx.Done():
+		err = fmt.Errorf("%w: %w", ErrNotReady, ctx.Err())
+	}
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+	return err
+}
+
+// files returns duplicates of the descriptors of the active listeners and their names.
+func (f *Factory) files() ([]*os.File, []string, error) {
+	files := make([]*os.File, 0, len(f.active))
+	names := make([]string, 0, len(f.active))
+	for _, l := range f.active {
+		fl, ok := l.Listener.(filer)
+		if !ok {
+			continue
+		}
+		file, err := fl.File()
+		if err != nil {
+			for _, file := range files {
+				_ = file.Close()
+			}
+			return nil, nil, fmt.Errorf("hand over %s: %w", l.name, err)
+		}
+		files = append(files, file)
+		names = append(names, url.QueryEscape(l.name))
+	}
+	return files, names, nil
+}
+
+// handedOver keeps the unix sockets handed over to the new process from being removed when
+// this process closes its listeners.
+func (f *Factory) handedOver() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, l := range f.active {
+		if ul, ok := l.Listener.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+}
@@ -24,6 +24,27 @@ func (s *LoggingServer) Start() {
 	s.Inner.Start()
 }
 
+// Serve starts the inner server and logs the event, returning the error of the inner server
+// if it implements Servable.
+func (s *LoggingServer) Serve() error {
+	inner, ok := s.Inner.(Servable)
+	if !ok {
+		s.Start()
+		return nil
+	}
+
+	s.Logger.Infof("[server] starting %s", s.Inner.Name())
+	return inner.Serve()
+}
+
+// Ready returns the ready channel of the inner server if it implements ReadyNotifier, or nil.
+func (s *LoggingServer) Ready() <-chan struct{} {
+	if inner, ok := s.Inner.(ReadyNotifier); ok {
+		return inner.Ready()
+	}
+	return nil
+}
+
 // Stop stops the inner server and logs the event.
 func (s *LoggingServer) Stop() {
 	s.Logger.Infof("[server] stopping %s", s.Inner.Name())
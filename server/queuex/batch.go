@@ -0,0 +1,327 @@
+package queuex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/hewen/mastiff-go/middleware/metrics"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// DefaultBatchSize is the default maximum number of messages handled in one batch.
+	DefaultBatchSize = 100
+	// DefaultBatchMaxWait is the default maximum time spent filling a batch.
+	DefaultBatchMaxWait = 1 * time.Second
+)
+
+// BatchHandler interface: handle decoded messages in batches. QueueServer switches to batch
+// mode when its handler implements it.
+type BatchHandler[T any] interface {
+	HandleBatch(ctx context.Context, msgs []T) error
+}
+
+// BatchQueue interface: a Queue that can pop many messages per round trip.
+type BatchQueue interface {
+	Queue
+	// PopBatch pops up to n messages without blocking.
+	PopBatch(ctx context.Context, n int) ([][]byte, error)
+}
+
+// BatchError reports the failed items of a batch. When HandleBatch returns a *BatchError,
+// only the listed items are retried or dead-lettered and the others are treated as handled;
+// any other error fails the whole batch.
+type BatchError struct {
+	// Failed maps the index of a failed item to its error.
+	Failed map[int]error
+}
+
+// NewBatchError creates an empty BatchError.
+func NewBatchError() *BatchError {
+	return &BatchError{Failed: make(map[int]error)}
+}
+
+// Add records the failure of the item at index i.
+func (e *BatchError) Add(i int, err error) {
+	e.Failed[i] = err
+}
+
+// Err returns e if any item failed, nil otherwise.
+func (e *BatchError) Err() error {
+	if len(e.Failed) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error implements error interface.
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d items of batch failed", len(e.Failed))
+}
+
+// BatchHandlerFunc adapts a function to the BatchHandler interface. It also implements
+// Handler by handling a single message as a batch of one.
+type BatchHandlerFunc[T any] func(ctx context.Context, msgs []T) error
+
+// HandleBatch calls f(ctx, msgs).
+func (f BatchHandlerFunc[T]) HandleBatch(ctx context.Context, msgs []T) error {
+	if f == nil {
+		return nil
+	}
+	return f(ctx, msgs)
+}
+
+// Handle calls f with a batch of one message.
+func (f BatchHandlerFunc[T]) Handle(ctx context.Context, msg T) error {
+	err := f.HandleBatch(ctx, []T{msg})
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		return batchErr.Failed[0]
+	}
+	return err
+}
+
+// PopBatch pops up to n messages from the queue without blocking.
+func (r RedisQueue) PopBatch(_ context.Context, n int) ([][]byte, error) {
+	var items *redis.StringSliceCmd
+	_, err := r.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		items = pipe.LRange(r.queueName, 0, int64(n-1))
+		pipe.LTrim(r.queueName, int64(n), -1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toBatch(items.Val()), nil
+}
+
+// JSONRedisBatchHandler handles JSON messages in a Redis queue in batches.
+type JSONRedisBatchHandler[T any] struct {
+	BatchHandlerFunc[T]
+	JSONCodec[T]
+	RedisQueue
+}
+
+// NewJSONRedisBatchHandler creates a new JSONRedisBatchHandler instance.
+func NewJSONRedisBatchHandler[T any](
+	client *redis.Client,
+	queueName string,
+	handlerFn func(ctx context.Context, msgs []T) error,
+) *JSONRedisBatchHandler[T] {
+	return &JSONRedisBatchHandler[T]{
+		BatchHandlerFunc: handlerFn,
+		JSONCodec:        JSONCodec[T]{},
+		RedisQueue:       NewRedisQueue(client, queueName),
+	}
+}
+
+// ProtoRedisBatchHandler handles protobuf messages in a Redis queue in batches.
+type ProtoRedisBatchHandler[T proto.Message] struct {
+	BatchHandlerFunc[T]
+	ProtoCodec[T]
+	RedisQueue
+}
+
+// NewProtoRedisBatchHandler creates a new ProtoRedisBatchHandler instance.
+func NewProtoRedisBatchHandler[T proto.Message](
+	client *redis.Client,
+	queueName string,
+	handlerFn func(ctx context.Context, msgs []T) error,
+	newMsgFn func() T,
+) *ProtoRedisBatchHandler[T] {
+	return &ProtoRedisBatchHandler[T]{
+		BatchHandlerFunc: handlerFn,
+		ProtoCodec:       ProtoCodec[T]{newMsg: newMsgFn},
+		RedisQueue:       NewRedisQueue(client, queueName),
+	}
+}
+
+// runBatchOnce fills a batch from the queue and processes it using the goroutine pool,
+// reporting whether it was submitted. A batch takes one concurrency slot.
+func (qs *QueueServer[T]) runBatchOnce(ctx context.Context) (bool, error) {
+	batch, popErr := qs.collect(ctx)
+	for i := range batch.data {
+		metrics.QueuePopped.WithLabelValues(qs.queueName(batch.source(i))).Inc()
+	}
+
+	msgs := make([]T, 0, len(batch.data))
+	keys := make([]string, 0, len(batch.data))
+	var tracked popped
+	for i, data := range batch.data {
+		// handlers of a batch share one context, so the trace IDs of enveloped messages are not restored.
+		_, payload, key := qs.unwrap(ctx, data)
+		msg, err := qs.decode(payload, batch.source(i))
+		if err != nil {
+			queue := qs.queueName(batch.source(i))
+			metrics.QueueDecodeErrors.WithLabelValues(queue).Inc()
+			qs.logger.Errorf("[queue:%s] decode failed: %v", queue, err)
+			qs.deadLetter(ctx, data, batch.source(i), err)
+			qs.ack(ctx, data)
+			continue
+		}
+		if !qs.claim(ctx, data, key, batch.source(i)) {
+			continue
+		}
+		msgs = append(msgs, msg)
+		keys = append(keys, key)
+		tracked.add(data, batch.source(i))
+	}
+
+	var submitted bool
+	if len(msgs) > 0 {
+		id := qs.track(tracked)
+		err := qs.pool.Submit(func() {
+			defer qs.running.Done()
+			defer qs.releaseSlot()
+			start := time.Now()
+			handleErr := qs.handleBatch.Handle(ctx, msgs)
+			qs.observeBatch(start, tracked, handleErr)
+			for i, key := range keys {
+				qs.settleClaim(ctx, key, batchItemErr(handleErr, i))
+			}
+
+			if _, ok := qs.untrack(id); !ok {
+				// the batch was pushed back to the queue when draining timed out.
+				return
+			}
+
+			qs.settleBatch(ctx, tracked, handleErr)
+			qs.logger.Debugf("submit batch to pool success => [queue: %s, size: %d, cap: %d, running: %d, free: %d]", qs.name, len(msgs), qs.pool.Cap(), qs.pool.Running(), qs.pool.Free())
+		})
+		if err != nil {
+			for _, key := range keys {
+				qs.settleClaim(ctx, key, err)
+			}
+			if qs.submitFailed(id, err) {
+				qs.settleBatch(ctx, tracked, err)
+			}
+		}
+		submitted = err == nil
+	}
+
+	if popErr != nil {
+		return submitted, fmt.Errorf("pop failed: %w", popErr)
+	}
+	return submitted, nil
+}
+
+// collect waits for a message with the blocking Pop of the queue, then pops more without
+// blocking until the batch is full or BatchMaxWait has elapsed since the first message. With
+// a rate limiter, messages are popped one at a time, each once a token was taken.
+func (qs *QueueServer[T]) collect(ctx context.Context) (popped, error) {
+	if !qs.throttle(ctx) {
+		return popped{}, nil
+	}
+	batch, err := qs.pop(ctx)
+	if err != nil || len(batch.data) == 0 {
+		return popped{}, err
+	}
+	qs.token = false
+
+	fillCtx, cancel := context.WithTimeout(ctx, qs.batchMaxWait)
+	defer cancel()
+	for len(batch.data) < qs.batchSize {
+		n := qs.batchSize - len(batch.data)
+		if qs.limiter != nil {
+			if !qs.throttle(fillCtx) {
+				return batch, nil
+			}
+			n = 1
+		}
+
+		items, err := qs.popBatch(ctx, n)
+		for i, data := range items.data {
+			batch.add(data, items.source(i))
+			qs.token = false
+		}
+		if err != nil || fillCtx.Err() != nil {
+			return batch, err
+		}
+
+		if len(items.data) == 0 {
+			select {
+			case <-qs.done:
+				return batch, nil
+			case <-fillCtx.Done():
+				return batch, nil
+			case <-time.After(qs.EmptySleepInterval):
+			}
+		}
+	}
+	return batch, nil
+}
+
+// popBatch pops up to n messages, one at a time when the queue cannot pop in batches.
+func (qs *QueueServer[T]) popBatch(ctx context.Context, n int) (popped, error) {
+	if qs.sourceQueue != nil {
+		data, sources, err := qs.sourceQueue.PopBatchSource(ctx, n)
+		return popped{data: data, sources: sources}, err
+	}
+	if bq, ok := qs.handler.(BatchQueue); ok {
+		data, err := bq.PopBatch(ctx, n)
+		return popped{data: data}, err
+	}
+	return qs.pop(ctx)
+}
+
+// settleBatch acknowledges or retries every message of a batch according to the handling result.
+func (qs *QueueServer[T]) settleBatch(ctx context.Context, batch popped, err error) {
+	if err != nil {
+		qs.logger.Errorf("[queue:%s] failed to handle batch: %v", qs.name, err)
+	}
+
+	for i, data := range batch.data {
+		if itemErr := batchItemErr(err, i); itemErr != nil {
+			qs.retry(ctx, data, batch.source(i), itemErr)
+		} else {
+			qs.ack(ctx, data)
+		}
+	}
+}
+
+// observeBatch records the outcome of handling a batch that started at start, for each queue
+// its messages were popped from.
+func (qs *QueueServer[T]) observeBatch(start time.Time, batch popped, err error) {
+	type outcome struct {
+		handled, failed int
+	}
+	outcomes := make(map[string]outcome)
+	for i := range batch.data {
+		queue := qs.queueName(batch.source(i))
+		o := outcomes[queue]
+		if batchItemErr(err, i) != nil {
+			o.failed++
+		} else {
+			o.handled++
+		}
+		outcomes[queue] = o
+	}
+	for queue, o := range outcomes {
+		qs.observeHandled(queue, start, o.handled, o.failed)
+	}
+}
+
+// batchItemErr returns the error of the item at index i according to the handling result of its batch.
+func batchItemErr(err error, i int) error {
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		return batchErr.Failed[i]
+	}
+	return err
+}
+
+// toBatch converts popped strings to raw messages.
+func toBatch(items []string) [][]byte {
+	if len(items) == 0 {
+		return nil
+	}
+
+	batch := make([][]byte, len(items))
+	for i := range items {
+		batch[i] = []byte(items[i])
+	}
+	return batch
+}
@@ -0,0 +1,390 @@
+package queuex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/server/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRedisQueue_PopBatch(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewRedisQueue(client, "bq")
+	ctx := context.Background()
+
+	batch, err := q.PopBatch(ctx, 2)
+	require.NoError(t, err)
+	assert.Empty(t, batch)
+
+	for _, v := range []string{"a", "b", "c"} {
+		require.NoError(t, q.Push(ctx, []byte(v)))
+	}
+
+	batch, err = q.PopBatch(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("c"), []byte("b")}, batch)
+
+	batch, err = q.PopBatch(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a")}, batch)
+	assert.Zero(t, client.LLen("bq").Val())
+}
+
+func TestReliableRedisQueue_PopBatch(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewReliableRedisQueue(client, serverconf.QueueConfig{QueueName: "rq", ConsumerName: "c1"})
+	ctx := context.Background()
+
+	batch, err := q.PopBatch(ctx, 2)
+	require.NoError(t, err)
+	assert.Empty(t, batch)
+
+	for _, v := range []string{"a", "b", "c"} {
+		require.NoError(t, q.Push(ctx, []byte(v)))
+	}
+
+	batch, err = q.PopBatch(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("c"), []byte("b")}, batch)
+	assert.Equal(t, int64(2), client.LLen("rq:processing:c1").Val())
+	assert.Equal(t, int64(2), client.ZCard("rq:inflight").Val())
+	assert.True(t, client.SIsMember("rq:consumers", "rq:processing:c1").Val())
+
+	attempts, err := q.Attempts(ctx, batch[0])
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts)
+
+	for _, data := range batch {
+		require.NoError(t, q.Ack(ctx, data))
+	}
+	assert.Zero(t, client.LLen("rq:processing:c1").Val())
+	assert.Equal(t, int64(1), client.LLen("rq").Val())
+}
+
+func TestRedisStreamQueue_PopBatch(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewRedisStreamQueue(client, serverconf.QueueConfig{QueueName: "sq", ConsumerName: "c1"})
+	ctx := context.Background()
+
+	// the first read creates the consumer group.
+	batch, err := q.PopBatch(ctx, 2)
+	require.NoError(t, err)
+	assert.Empty(t, batch)
+
+	for _, v := range []string{"a", "b", "c"} {
+		require.NoError(t, q.Push(ctx, []byte(v)))
+	}
+
+	batch, err = q.PopBatch(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b")}, batch)
+
+	pending, err := q.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), pending)
+
+	for _, data := range batch {
+		require.NoError(t, q.Ack(ctx, data))
+	}
+
+	batch, err = q.PopBatch(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("c")}, batch)
+
+	batch, err = q.PopBatch(ctx, 2)
+	require.NoError(t, err)
+	assert.Empty(t, batch)
+}
+
+func TestRedisStreamQueue_PopBatchClaimed(t *testing.T) {
+	_, client := newTestRedis(t)
+	conf := serverconf.QueueConfig{QueueName: "sq", VisibilityTimeout: 10 * time.Millisecond}
+	conf.ConsumerName = "dead"
+	dead := NewRedisStreamQueue(client, conf)
+	conf.ConsumerName = "alive"
+	alive := NewRedisStreamQueue(client, conf)
+	ctx := context.Background()
+
+	require.NoError(t, dead.Push(ctx, []byte("a")))
+	assert.Equal(t, []byte("a"), popStream(t, dead))
+	require.NoError(t, dead.Push(ctx, []byte("b")))
+
+	time.Sleep(20 * time.Millisecond)
+	n, err := alive.Recover(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	batch, err := alive.PopBatch(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b")}, batch)
+}
+
+func TestRedisQueue_PopBatchError(t *testing.T) {
+	mr, client := newTestRedis(t)
+	ctx := context.Background()
+	mr.Close()
+
+	_, err := NewRedisQueue(client, "bq").PopBatch(ctx, 2)
+	assert.Error(t, err)
+	_, err = NewReliableRedisQueue(client, serverconf.QueueConfig{QueueName: "rq"}).PopBatch(ctx, 2)
+	assert.Error(t, err)
+	_, err = NewRedisStreamQueue(client, serverconf.QueueConfig{QueueName: "sq"}).PopBatch(ctx, 2)
+	assert.Error(t, err)
+}
+
+func TestBatchError(t *testing.T) {
+	batchErr := NewBatchError()
+	assert.NoError(t, batchErr.Err())
+
+	batchErr.Add(1, errors.New("bad item"))
+	assert.Equal(t, batchErr, batchErr.Err())
+	assert.Equal(t, "1 items of batch failed", batchErr.Error())
+}
+
+func TestBatchHandlerFunc_Handle(t *testing.T) {
+	var fn BatchHandlerFunc[MyTestMsg]
+	assert.NoError(t, fn.Handle(context.Background(), MyTestMsg{}))
+
+	itemErr := errors.New("bad item")
+	fn = func(_ context.Context, msgs []MyTestMsg) error {
+		require.Len(t, msgs, 1)
+		batchErr := NewBatchError()
+		if msgs[0].ID == 1 {
+			batchErr.Add(0, itemErr)
+		}
+		return batchErr.Err()
+	}
+	assert.ErrorIs(t, fn.Handle(context.Background(), MyTestMsg{ID: 1}), itemErr)
+	assert.NoError(t, fn.Handle(context.Background(), MyTestMsg{ID: 2}))
+}
+
+func TestQueueServer_Batch(t *testing.T) {
+	_, client := newTestRedis(t)
+	conf := serverconf.QueueConfig{
+		QueueName:          "bq",
+		PoolSize:           2,
+		EmptySleepInterval: time.Millisecond,
+		BatchSize:          3,
+		BatchMaxWait:       50 * time.Millisecond,
+	}
+
+	var mu sync.Mutex
+	var sizes []int
+	handler := NewJSONRedisBatchHandler(client, conf.QueueName, func(_ context.Context, msgs []MyTestMsg) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sizes = append(sizes, len(msgs))
+		return nil
+	})
+
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		data, err := handler.Encode(MyTestMsg{ID: i})
+		require.NoError(t, err)
+		require.NoError(t, handler.Push(context.Background(), data))
+	}
+
+	go qs.Start()
+	defer qs.Stop()
+
+	// a full batch is handled at once, the rest after BatchMaxWait.
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(sizes) == 2
+	}, time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []int{3, 1}, sizes)
+}
+
+func TestQueueServer_BatchPartialFailure(t *testing.T) {
+	_, client := newTestRedis(t)
+	conf := serverconf.QueueConfig{
+		QueueName:          "bq",
+		PoolSize:           2,
+		EmptySleepInterval: time.Millisecond,
+		BatchSize:          2,
+		BatchMaxWait:       20 * time.Millisecond,
+	}
+
+	handler := NewJSONRedisBatchHandler(client, conf.QueueName, func(_ context.Context, msgs []MyTestMsg) error {
+		batchErr := NewBatchError()
+		for i, msg := range msgs {
+			if msg.ID == 1 {
+				batchErr.Add(i, errors.New("bad item"))
+			}
+		}
+		return batchErr.Err()
+	})
+
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+	dlq := NewRedisDeadLetterQueue(client, conf.QueueName)
+	qs.WithDeadLetterQueue(dlq)
+
+	require.NoError(t, handler.Push(context.Background(), []byte("not json")))
+	for i := 0; i < 2; i++ {
+		data, err := handler.Encode(MyTestMsg{ID: i})
+		require.NoError(t, err)
+		require.NoError(t, handler.Push(context.Background(), data))
+	}
+
+	go qs.Start()
+	defer qs.Stop()
+
+	// the undecodable message and the failed item are dead-lettered, the other item is handled.
+	require.Eventually(t, func() bool {
+		n, _ := dlq.Len(context.Background())
+		return n == 2
+	}, time.Second, 5*time.Millisecond)
+
+	letters, err := dlq.List(context.Background(), 0, 10)
+	require.NoError(t, err)
+	var payloads []string
+	for _, letter := range letters {
+		payloads = append(payloads, string(letter.Payload))
+	}
+	assert.ElementsMatch(t, []string{"not json", `{"body":"","id":1}`}, payloads)
+}
+
+func TestQueueServer_BatchFallbackPop(t *testing.T) {
+	handler := &mockBatchHandler{}
+	conf := serverconf.QueueConfig{
+		QueueName:          "test",
+		PoolSize:           2,
+		EmptySleepInterval: time.Millisecond,
+		BatchSize:          10,
+		BatchMaxWait:       20 * time.Millisecond,
+	}
+
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		data, err := handler.Encode(MyTestMsg{ID: i})
+		require.NoError(t, err)
+		require.NoError(t, handler.Push(context.Background(), data))
+	}
+
+	go qs.Start()
+	defer qs.Stop()
+
+	require.Eventually(t, func() bool {
+		handler.mu.Lock()
+		defer handler.mu.Unlock()
+		return len(handler.batches) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	assert.Len(t, handler.batches[0], 3)
+}
+
+func TestQueueServer_CollectIdle(t *testing.T) {
+	handler := &popBatchCounter{}
+	qs, err := NewQueueServer(serverconf.QueueConfig{
+		QueueName:          "test",
+		EmptySleepInterval: time.Millisecond,
+		BatchSize:          10,
+		BatchMaxWait:       20 * time.Millisecond,
+	}, handler)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// an empty queue is waited on with the blocking Pop, never polled in batches.
+	batch, err := qs.collect(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, batch.data)
+	assert.Zero(t, handler.calls)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, handler.Push(ctx, []byte{byte(i)}))
+	}
+	batch, err = qs.collect(ctx)
+	require.NoError(t, err)
+	assert.Len(t, batch.data, 3)
+	assert.Positive(t, handler.calls)
+}
+
+func TestQueueServer_BatchPopError(t *testing.T) {
+	handler := &mockBatchHandler{}
+	handler.popErr = errors.New("pop failed")
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "test"}, handler)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, qs.runOnce(context.Background()), handler.popErr)
+}
+
+func TestProtoRedisBatchHandler(t *testing.T) {
+	_, client := newTestRedis(t)
+	handler := NewProtoRedisBatchHandler(client, "bq", nil, func() *test.TestMsg {
+		return &test.TestMsg{}
+	})
+	ctx := context.Background()
+
+	msg := &test.TestMsg{Id: 1, Name: "Alice"}
+	data, err := handler.Encode(msg)
+	require.NoError(t, err)
+	require.NoError(t, handler.Push(ctx, data))
+
+	batch, err := handler.PopBatch(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+
+	decoded, err := handler.Decode(batch[0])
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(msg, decoded))
+	assert.NoError(t, handler.HandleBatch(ctx, []*test.TestMsg{decoded}))
+}
+
+// popBatchCounter is a batch handler counting its calls to PopBatch.
+type popBatchCounter struct {
+	mockBatchHandler
+
+	calls int
+}
+
+func (h *popBatchCounter) PopBatch(ctx context.Context, n int) ([][]byte, error) {
+	h.calls++
+	var batch [][]byte
+	for len(batch) < n {
+		data, err := h.Pop(ctx)
+		if err != nil || len(data) == 0 {
+			return batch, err
+		}
+		batch = append(batch, data)
+	}
+	return batch, nil
+}
+
+type mockBatchHandler struct {
+	mockQueueHandler
+
+	popErr  error
+	batches [][]MyTestMsg
+}
+
+func (h *mockBatchHandler) Pop(ctx context.Context) ([]byte, error) {
+	if h.popErr != nil {
+		return nil, h.popErr
+	}
+	return h.mockQueueHandler.Pop(ctx)
+}
+
+func (h *mockBatchHandler) HandleBatch(_ context.Context, msgs []MyTestMsg) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.batches = append(h.batches, msgs)
+	return nil
+}
@@ -0,0 +1,52 @@
+package queuex
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrDeadLetterNotFound is returned when a dead letter does not exist.
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetter is a message that could not be handled and was moved aside for inspection.
+type DeadLetter struct {
+	// FailedAt represents the time the message was dead-lettered.
+	FailedAt time.Time `json:"failed_at"`
+	// ID represents the dead letter ID.
+	ID string `json:"id"`
+	// Queue represents the name of the queue the message was consumed from.
+	Queue string `json:"queue"`
+	// Source represents the source the message was popped from when the queue is a
+	// SourceQueue, such as a level of a priority queue, empty otherwise.
+	Source string `json:"source,omitempty"`
+	// Error represents the error that caused the message to be dead-lettered.
+	Error string `json:"error"`
+	// TraceID represents the trace ID of the failed handling.
+	TraceID string `json:"trace_id"`
+	// Payload represents the raw message.
+	Payload []byte `json:"payload"`
+	// Attempts represents how many times the message was delivered.
+	Attempts int `json:"attempts"`
+}
+
+// DeadLetterQueue interface: store and manage dead letters. It is used by QueueServer to capture
+// messages that failed decoding or exhausted their attempts.
+type DeadLetterQueue interface {
+	// Add stores a dead letter, assigning an ID when it is empty.
+	Add(ctx context.Context, letter DeadLetter) error
+	// List returns dead letters ordered by failure time, oldest first.
+	List(ctx context.Context, offset, limit int64) ([]DeadLetter, error)
+	// Get returns the dead letter with the given ID.
+	Get(ctx context.Context, id string) (DeadLetter, error)
+	// Replay pushes the payload of the dead letter back to its queue and removes the dead letter.
+	// The push and the removal are not atomic, so a replayed message may be pushed twice and
+	// handlers must tolerate duplicates, for example with QueueServer.WithIdempotency.
+	Replay(ctx context.Context, id string) error
+	// Delete removes the dead letters with the given IDs.
+	Delete(ctx context.Context, ids ...string) error
+	// Purge removes all dead letters.
+	Purge(ctx context.Context) error
+	// Len returns the number of dead letters.
+	Len(ctx context.Context) (int64, error)
+}
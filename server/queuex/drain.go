@@ -0,0 +1,111 @@
+package queuex
+
+import (
+	"context"
+	"errors"
+
+	"github.com/panjf2000/ants/v2"
+)
+
+// popped holds messages popped from the queue, with the sources they were popped from when
+// the queue is a SourceQueue.
+type popped struct {
+	data    [][]byte
+	sources []string
+}
+
+// add appends a message popped from source.
+func (p *popped) add(data []byte, source string) {
+	p.data = append(p.data, data)
+	p.sources = append(p.sources, source)
+}
+
+// source returns the source of the message at index i, empty if unknown.
+func (p popped) source(i int) string {
+	if i < len(p.sources) {
+		return p.sources[i]
+	}
+	return ""
+}
+
+// track records messages submitted to the pool until they are settled, so they can be pushed
+// back to the queue if draining times out.
+func (qs *QueueServer[T]) track(msgs popped) uint64 {
+	qs.running.Add(1)
+
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	qs.nextInflightID++
+	qs.inflight[qs.nextInflightID] = msgs
+	return qs.nextInflightID
+}
+
+// untrack removes tracked messages and reports whether they were still in flight. Only the
+// caller that untracks the messages may settle them.
+func (qs *QueueServer[T]) untrack(id uint64) (popped, bool) {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	data, ok := qs.inflight[id]
+	delete(qs.inflight, id)
+	return data, ok
+}
+
+// submitFailed releases tracked messages the pool refused and reports whether the caller
+// should settle them as failed. Messages refused because the server is stopping are pushed
+// back to the queue instead.
+func (qs *QueueServer[T]) submitFailed(id uint64, err error) bool {
+	defer qs.running.Done()
+	qs.logger.Errorf("submit to goroutine pool failed: %v", err)
+
+	batch, ok := qs.untrack(id)
+	if !ok {
+		return false
+	}
+	if errors.Is(err, ants.ErrPoolClosed) {
+		qs.requeue(batch)
+		return false
+	}
+	return true
+}
+
+// requeueInflight pushes every message still in flight back to the queue.
+func (qs *QueueServer[T]) requeueInflight() {
+	qs.mu.Lock()
+	inflight := qs.inflight
+	qs.inflight = make(map[uint64]popped)
+	qs.mu.Unlock()
+
+	var n int
+	for _, batch := range inflight {
+		n += qs.requeue(batch)
+	}
+	qs.logger.Infof("[queue:%s] requeued %d in-flight messages", qs.name, n)
+}
+
+// requeue pushes unhandled messages back to the queue, or to their source when it is known,
+// and returns how many were pushed.
+func (qs *QueueServer[T]) requeue(msgs popped) int {
+	// the handler context is cancelled once draining ends, so the messages are pushed back without it.
+	ctx := context.Background()
+
+	var n int
+	for i, data := range msgs.data {
+		var err error
+		switch source := msgs.source(i); {
+		case qs.ackQueue != nil:
+			err = qs.ackQueue.Retry(ctx, data, 0)
+		case qs.sourceQueue != nil && source != "":
+			err = qs.sourceQueue.PushSource(ctx, data, source)
+		default:
+			err = qs.handler.Push(ctx, data)
+		}
+
+		if err != nil {
+			qs.logger.Errorf("[queue:%s] requeue message failed: %v", qs.name, err)
+			continue
+		}
+		n++
+	}
+	return n
+}
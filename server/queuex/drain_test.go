@@ -0,0 +1,182 @@
+package queuex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/panjf2000/ants/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueServer_StopDrainsInflight(t *testing.T) {
+	handler := &mockQueueHandler{handleDelay: 50 * time.Millisecond}
+	conf := serverconf.QueueConfig{
+		QueueName:          "test",
+		PoolSize:           2,
+		EmptySleepInterval: time.Millisecond,
+		DrainTimeout:       time.Second,
+	}
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+
+	data, err := handler.Encode(MyTestMsg{ID: 1})
+	require.NoError(t, err)
+	require.NoError(t, handler.Push(context.Background(), data))
+
+	go qs.Start()
+	require.Eventually(t, func() bool {
+		return qs.pool.Running() == 1
+	}, time.Second, time.Millisecond)
+
+	qs.Stop()
+	qs.Stop()
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	assert.Len(t, handler.handled, 1)
+	assert.Len(t, handler.messages, 1)
+}
+
+func TestQueueServer_StopDrainTimeout(t *testing.T) {
+	handler := &mockQueueHandler{}
+	var cancelled sync.WaitGroup
+	cancelled.Add(1)
+	handler.handleFn = func(ctx context.Context, _ MyTestMsg) error {
+		<-ctx.Done()
+		cancelled.Done()
+		return ctx.Err()
+	}
+
+	conf := serverconf.QueueConfig{
+		QueueName:          "test",
+		PoolSize:           1,
+		EmptySleepInterval: time.Millisecond,
+		DrainTimeout:       50 * time.Millisecond,
+	}
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+	_, client := newTestRedis(t)
+	dlq := NewRedisDeadLetterQueue(client, conf.QueueName)
+	qs.WithDeadLetterQueue(dlq)
+
+	data, err := handler.Encode(MyTestMsg{ID: 1})
+	require.NoError(t, err)
+	require.NoError(t, handler.Push(context.Background(), data))
+
+	go qs.Start()
+	require.Eventually(t, func() bool {
+		handler.mu.Lock()
+		defer handler.mu.Unlock()
+		return len(handler.handled) == 1
+	}, time.Second, time.Millisecond)
+
+	start := time.Now()
+	qs.Stop()
+	assert.Less(t, time.Since(start), time.Second)
+
+	// the hung handler sees its context cancelled, and its message is pushed back instead of dead-lettered.
+	cancelled.Wait()
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	assert.Equal(t, [][]byte{data, data}, handler.messages)
+	n, err := dlq.Len(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestQueueServer_StopDrainTimeoutReliable(t *testing.T) {
+	_, client := newTestRedis(t)
+	conf := serverconf.QueueConfig{
+		QueueName:          "rq",
+		ConsumerName:       "c1",
+		PoolSize:           1,
+		EmptySleepInterval: time.Millisecond,
+		DrainTimeout:       50 * time.Millisecond,
+	}
+
+	handling := make(chan struct{})
+	handler := NewJSONReliableRedisHandler(client, conf, func(ctx context.Context, _ MyTestMsg) error {
+		close(handling)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+
+	data, err := handler.Encode(MyTestMsg{ID: 1})
+	require.NoError(t, err)
+	require.NoError(t, handler.Push(context.Background(), data))
+
+	go qs.Start()
+	<-handling
+	qs.Stop()
+
+	assert.Equal(t, int64(1), client.LLen("rq").Val())
+	assert.Zero(t, client.LLen("rq:processing:c1").Val())
+	assert.Zero(t, client.ZCard("rq:delayed").Val())
+}
+
+func TestQueueServer_StopDrainTimeoutPriority(t *testing.T) {
+	_, client := newTestRedis(t)
+	conf := serverconf.QueueConfig{
+		QueueName:          "pq",
+		PoolSize:           1,
+		EmptySleepInterval: time.Millisecond,
+		DrainTimeout:       50 * time.Millisecond,
+	}
+
+	handling := make(chan struct{})
+	handler, err := NewJSONPriorityRedisHandler(client, conf, func(ctx context.Context, _ MyTestMsg) error {
+		close(handling)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.NoError(t, err)
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+
+	data, err := handler.Encode(MyTestMsg{ID: 1})
+	require.NoError(t, err)
+	require.NoError(t, handler.PushWithPriority(context.Background(), data, 0))
+
+	go qs.Start()
+	<-handling
+	qs.Stop()
+
+	// the urgent message is pushed back to its level rather than the lowest one.
+	assert.Equal(t, []string{string(data)}, client.LRange("pq:priority:0", 0, -1).Val())
+	assert.Zero(t, client.LLen("pq:priority:2").Val())
+}
+
+func TestQueueServer_StopBeforeStart(t *testing.T) {
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "test"}, &mockQueueHandler{})
+	require.NoError(t, err)
+
+	qs.Stop()
+	qs.Start()
+}
+
+func TestQueueServer_SubmitFailed(t *testing.T) {
+	handler := &mockQueueHandler{}
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "test"}, handler)
+	require.NoError(t, err)
+
+	id := qs.track(popped{data: [][]byte{[]byte("a")}})
+	assert.True(t, qs.submitFailed(id, errors.New("overload")))
+
+	// messages refused by a closed pool are pushed back to the queue.
+	id = qs.track(popped{data: [][]byte{[]byte("b")}})
+	assert.False(t, qs.submitFailed(id, ants.ErrPoolClosed))
+	assert.Equal(t, [][]byte{[]byte("b")}, handler.messages)
+
+	// messages already requeued by draining are left alone.
+	id = qs.track(popped{data: [][]byte{[]byte("c")}})
+	qs.requeueInflight()
+	assert.False(t, qs.submitFailed(id, ants.ErrPoolClosed))
+	assert.Equal(t, [][]byte{[]byte("b"), []byte("c")}, handler.messages)
+}
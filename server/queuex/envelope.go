@@ -0,0 +1,80 @@
+package queuex
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/hewen/mastiff-go/pkg/contextkeys"
+)
+
+// envelopeVersion marks a message as an Envelope, telling it apart from a bare payload.
+const envelopeVersion = "queuex/v1"
+
+// Envelope wraps a message pushed by Producer with the metadata needed to correlate producer
+// and consumer. QueueServer unwraps it before decoding, so handlers receive the payload as
+// before and can read the envelope with EnvelopeFromContext.
+type Envelope struct {
+	// EnqueuedAt is when the message was pushed.
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	// Headers holds application defined metadata.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Version is always envelopeVersion.
+	Version string `json:"envelope"`
+	// ID uniquely identifies the message.
+	ID string `json:"id"`
+	// TraceID is the trace ID of the producer context.
+	TraceID string `json:"trace_id,omitempty"`
+	// Payload is the message encoded by the Codec of the queue.
+	Payload []byte `json:"payload"`
+	// Attempt is the delivery attempt, filled in by QueueServer when the queue tracks attempts.
+	Attempt int `json:"attempt"`
+}
+
+// envelopeKey is the context key of the Envelope of the message being handled.
+type envelopeKey struct{}
+
+// EnvelopeFromContext returns the Envelope of the message being handled, if it was pushed by Producer.
+func EnvelopeFromContext(ctx context.Context) (Envelope, bool) {
+	env, ok := ctx.Value(envelopeKey{}).(Envelope)
+	return env, ok
+}
+
+// encodeEnvelope encodes an Envelope.
+func encodeEnvelope(env Envelope) ([]byte, error) {
+	env.Version = envelopeVersion
+	return json.Marshal(env)
+}
+
+// decodeEnvelope decodes an Envelope, reporting false when data is a bare payload.
+func decodeEnvelope(data []byte) (Envelope, bool) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Version != envelopeVersion {
+		return Envelope{}, false
+	}
+	return env, true
+}
+
+// unwrap returns the payload of a message, its idempotency key given by messageKey, and a
+// context carrying its Envelope and trace ID when the message was pushed by Producer.
+func (qs *QueueServer[T]) unwrap(ctx context.Context, data []byte) (context.Context, []byte, string) {
+	env, ok := decodeEnvelope(data)
+	if !ok {
+		return ctx, data, qs.messageKey(Envelope{}, data)
+	}
+
+	if qs.ackQueue != nil {
+		if attempts, err := qs.ackQueue.Attempts(ctx, data); err == nil && attempts > 0 {
+			env.Attempt = attempts
+		}
+	}
+	return withEnvelope(ctx, env), env.Payload, qs.messageKey(env, data)
+}
+
+// withEnvelope returns a context carrying the Envelope and its trace ID.
+func withEnvelope(ctx context.Context, env Envelope) context.Context {
+	if env.TraceID != "" {
+		ctx = contextkeys.SetTraceID(ctx, env.TraceID)
+	}
+	return context.WithValue(ctx, envelopeKey{}, env)
+}
@@ -0,0 +1,232 @@
+package queuex
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/hewen/mastiff-go/config/serverconf"
+)
+
+const (
+	// DefaultIdempotencyTTL is the default duration processed message keys are remembered.
+	DefaultIdempotencyTTL = 24 * time.Hour
+)
+
+// IdempotencyState is the state of a message key in an IdempotencyStore.
+type IdempotencyState string
+
+const (
+	// IdempotencyClaimed means the caller claimed the key and should handle the message.
+	IdempotencyClaimed IdempotencyState = "claimed"
+	// IdempotencyInProgress means another consumer is handling the message.
+	IdempotencyInProgress IdempotencyState = "processing"
+	// IdempotencyDone means the message was already handled.
+	IdempotencyDone IdempotencyState = "done"
+)
+
+var (
+	// ErrDuplicateMessage is returned by Producer when a message was already pushed within the dedup window.
+	ErrDuplicateMessage = errors.New("duplicate message")
+	// ErrMessageInProgress is the retry cause of a message another consumer is handling.
+	ErrMessageInProgress = errors.New("message is being handled by another consumer")
+)
+
+// claimScript claims a key unless it is already claimed or done, returning its state.
+var claimScript = redis.NewScript(`
+local state = redis.call('GET', KEYS[1])
+if state then
+	return state
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return ARGV[3]
+`)
+
+// IdempotencyStore interface: records which messages were handled, so QueueServer skips
+// duplicates caused by retries and redeliveries.
+type IdempotencyStore interface {
+	// Claim marks the key as being handled unless it is already claimed or done.
+	Claim(ctx context.Context, key string) (IdempotencyState, error)
+	// Complete marks the key as handled.
+	Complete(ctx context.Context, key string) error
+	// Release removes the claim, so the message can be handled again.
+	Release(ctx context.Context, key string) error
+}
+
+// DedupStore interface: remembers pushed messages for a window, so Producer drops double-sends.
+type DedupStore interface {
+	// Reserve records the key for the window, reporting false if it is already recorded.
+	Reserve(ctx context.Context, key string, window time.Duration) (bool, error)
+	// Unreserve removes the key, so the message can be pushed again.
+	Unreserve(ctx context.Context, key string) error
+}
+
+// RedisIdempotencyStore implements IdempotencyStore and DedupStore using Redis keys with a TTL.
+type RedisIdempotencyStore struct {
+	client   *redis.Client
+	prefix   string
+	ttl      time.Duration
+	claimTTL time.Duration
+}
+
+// NewRedisIdempotencyStore creates a new RedisIdempotencyStore instance. Processed keys are
+// kept for IdempotencyTTL, and claims of messages being handled expire after
+// VisibilityTimeout, when a crashed consumer's message is redelivered.
+func NewRedisIdempotencyStore(client *redis.Client, conf serverconf.QueueConfig) RedisIdempotencyStore {
+	if conf.IdempotencyTTL <= 0 {
+		conf.IdempotencyTTL = DefaultIdempotencyTTL
+	}
+	if conf.VisibilityTimeout <= 0 {
+		conf.VisibilityTimeout = DefaultVisibilityTimeout
+	}
+
+	return RedisIdempotencyStore{
+		client:   client,
+		prefix:   conf.QueueName,
+		ttl:      conf.IdempotencyTTL,
+		claimTTL: conf.VisibilityTimeout,
+	}
+}
+
+// Claim marks the key as being handled unless it is already claimed or done.
+func (s RedisIdempotencyStore) Claim(_ context.Context, key string) (IdempotencyState, error) {
+	keys := []string{s.idempotencyKey(key)}
+	state, err := claimScript.Run(s.client, keys, string(IdempotencyInProgress), s.claimTTL.Milliseconds(), string(IdempotencyClaimed)).Text()
+	return IdempotencyState(state), err
+}
+
+// Complete marks the key as handled.
+func (s RedisIdempotencyStore) Complete(_ context.Context, key string) error {
+	return s.client.Set(s.idempotencyKey(key), string(IdempotencyDone), s.ttl).Err()
+}
+
+// Release removes the claim, so the message can be handled again.
+func (s RedisIdempotencyStore) Release(_ context.Context, key string) error {
+	return s.client.Del(s.idempotencyKey(key)).Err()
+}
+
+// Reserve records the key for the window, reporting false if it is already recorded.
+func (s RedisIdempotencyStore) Reserve(_ context.Context, key string, window time.Duration) (bool, error) {
+	return s.client.SetNX(s.dedupKey(key), 1, window).Result()
+}
+
+// Unreserve removes the key, so the message can be pushed again.
+func (s RedisIdempotencyStore) Unreserve(_ context.Context, key string) error {
+	return s.client.Del(s.dedupKey(key)).Err()
+}
+
+// dedupKey returns the Redis key recording a pushed message.
+func (s RedisIdempotencyStore) dedupKey(key string) string {
+	return s.prefix + ":dedup:" + key
+}
+
+// idempotencyKey returns the Redis key recording the state of a message key.
+func (s RedisIdempotencyStore) idempotencyKey(key string) string {
+	return s.prefix + ":idempotency:" + key
+}
+
+// WithIdempotency sets the store used to skip messages that were already handled. Only
+// messages pushed by Producer are deduplicated, by the ID of their Envelope.
+func (qs *QueueServer[T]) WithIdempotency(store IdempotencyStore) {
+	qs.idempotency = store
+	qs.payloadKeys = false
+}
+
+// WithPayloadIdempotency sets the store like WithIdempotency, also deduplicating messages
+// without an Envelope by a hash of their payload. Identical payloads are then handled once
+// within IdempotencyTTL, so it only suits queues whose messages are unique.
+func (qs *QueueServer[T]) WithPayloadIdempotency(store IdempotencyStore) {
+	qs.idempotency = store
+	qs.payloadKeys = true
+}
+
+// claim reports whether the message should be handled. Duplicates of handled messages are
+// acknowledged. Messages another consumer is handling are handled again later, in case that
+// consumer fails.
+func (qs *QueueServer[T]) claim(ctx context.Context, data []byte, key, source string) bool {
+	if qs.idempotency == nil || key == "" {
+		return true
+	}
+
+	state, err := qs.idempotency.Claim(ctx, key)
+	if err != nil {
+		// handling a message twice is better than losing it.
+		qs.logger.Errorf("[queue:%s] claim message failed: %v", qs.queueName(source), err)
+		return true
+	}
+
+	switch state {
+	case IdempotencyInProgress:
+		qs.postpone(ctx, data, source)
+		return false
+	case IdempotencyDone:
+		qs.logger.Infof("[queue:%s] skipped duplicate message", qs.queueName(source))
+		qs.ack(ctx, data)
+		return false
+	default:
+		return true
+	}
+}
+
+// postpone re-queues a message another consumer is handling for RetryBackoff: retried when the
+// queue supports acknowledgement, without counting as a failed attempt, delayed when it
+// supports delays, and pushed back otherwise, pausing the pops so the message is not popped
+// again at once.
+func (qs *QueueServer[T]) postpone(ctx context.Context, data []byte, source string) {
+	var err error
+	switch {
+	case qs.ackQueue != nil:
+		err = qs.ackQueue.Retry(ctx, data, qs.retryBackoff)
+	case qs.delayedQueue != nil:
+		err = qs.delayedQueue.PushDelayed(ctx, data, qs.retryBackoff)
+	default:
+		qs.requeue(popped{data: [][]byte{data}, sources: []string{source}})
+		timer := time.NewTimer(qs.retryBackoff)
+		defer timer.Stop()
+		select {
+		case <-qs.done:
+		case <-timer.C:
+		}
+	}
+	if err != nil {
+		qs.logger.Errorf("[queue:%s] postpone message failed: %v", qs.queueName(source), err)
+	}
+}
+
+// settleClaim records the message with the key as handled, or releases its claim when handling failed.
+func (qs *QueueServer[T]) settleClaim(ctx context.Context, key string, handleErr error) {
+	if qs.idempotency == nil || key == "" {
+		return
+	}
+
+	var err error
+	if handleErr != nil {
+		err = qs.idempotency.Release(ctx, key)
+	} else {
+		err = qs.idempotency.Complete(ctx, key)
+	}
+	if err != nil {
+		qs.logger.Errorf("[queue:%s] settle message claim failed: %v", qs.name, err)
+	}
+}
+
+// messageKey returns the ID of the Envelope of the message, or a hash of its payload when
+// set by WithPayloadIdempotency, empty if the message is not deduplicated.
+func (qs *QueueServer[T]) messageKey(env Envelope, data []byte) string {
+	if env.ID != "" {
+		return env.ID
+	}
+	if qs.payloadKeys {
+		return payloadHash(data)
+	}
+	return ""
+}
+
+// payloadHash returns the hex encoded SHA-256 of data.
+func payloadHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,260 @@
+package queuex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisIdempotencyStore(t *testing.T) {
+	mr, client := newTestRedis(t)
+	store := NewRedisIdempotencyStore(client, serverconf.QueueConfig{QueueName: "iq", IdempotencyTTL: time.Hour})
+	ctx := context.Background()
+
+	state, err := store.Claim(ctx, "m1")
+	require.NoError(t, err)
+	assert.Equal(t, IdempotencyClaimed, state)
+	assert.Equal(t, DefaultVisibilityTimeout, mr.TTL("iq:idempotency:m1"))
+
+	state, err = store.Claim(ctx, "m1")
+	require.NoError(t, err)
+	assert.Equal(t, IdempotencyInProgress, state)
+
+	require.NoError(t, store.Release(ctx, "m1"))
+	state, err = store.Claim(ctx, "m1")
+	require.NoError(t, err)
+	assert.Equal(t, IdempotencyClaimed, state)
+
+	require.NoError(t, store.Complete(ctx, "m1"))
+	assert.Equal(t, time.Hour, mr.TTL("iq:idempotency:m1"))
+	state, err = store.Claim(ctx, "m1")
+	require.NoError(t, err)
+	assert.Equal(t, IdempotencyDone, state)
+
+	// claims of crashed consumers expire.
+	state, err = store.Claim(ctx, "m2")
+	require.NoError(t, err)
+	require.Equal(t, IdempotencyClaimed, state)
+	mr.FastForward(DefaultVisibilityTimeout)
+	state, err = store.Claim(ctx, "m2")
+	require.NoError(t, err)
+	assert.Equal(t, IdempotencyClaimed, state)
+
+	reserved, err := store.Reserve(ctx, "m1", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+	reserved, err = store.Reserve(ctx, "m1", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	require.NoError(t, store.Unreserve(ctx, "m1"))
+	reserved, err = store.Reserve(ctx, "m1", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+}
+
+func TestQueueServer_MessageKey(t *testing.T) {
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "iq"}, &mockQueueHandler{})
+	require.NoError(t, err)
+	data, err := encodeEnvelope(Envelope{ID: "m1"})
+	require.NoError(t, err)
+	messageKey := func(data []byte) string {
+		_, _, key := qs.unwrap(context.Background(), data)
+		return key
+	}
+
+	// identical bare payloads are not deduplicated unless asked to.
+	qs.WithIdempotency(nil)
+	assert.Empty(t, messageKey([]byte("a")))
+	assert.Equal(t, "m1", messageKey(data))
+
+	qs.WithPayloadIdempotency(nil)
+	assert.Equal(t, payloadHash([]byte("a")), messageKey([]byte("a")))
+	assert.NotEqual(t, messageKey([]byte("a")), messageKey([]byte("b")))
+	assert.Equal(t, "m1", messageKey(data))
+}
+
+func TestQueueServer_Idempotency(t *testing.T) {
+	_, client := newTestRedis(t)
+	conf := serverconf.QueueConfig{
+		QueueName:          "iq",
+		PoolSize:           1,
+		EmptySleepInterval: time.Millisecond,
+		RetryBackoff:       time.Millisecond,
+		RecoverInterval:    5 * time.Millisecond,
+	}
+
+	var mu sync.Mutex
+	calls := map[int]int{}
+	handler := NewJSONReliableRedisHandler(client, conf, func(_ context.Context, msg MyTestMsg) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[msg.ID]++
+		if msg.ID == 2 && calls[msg.ID] == 1 {
+			return errors.New("first attempt fails")
+		}
+		return nil
+	})
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+	qs.WithIdempotency(NewRedisIdempotencyStore(client, conf))
+
+	ctx := context.Background()
+	producer := NewProducer[MyTestMsg](handler, handler)
+	// the same message delivered twice is handled once, a failed one is handled again.
+	require.NoError(t, producer.Push(ctx, MyTestMsg{ID: 1}, WithMessageID("m1")))
+	require.NoError(t, producer.Push(ctx, MyTestMsg{ID: 1}, WithMessageID("m1")))
+	require.NoError(t, producer.Push(ctx, MyTestMsg{ID: 2}, WithMessageID("m2")))
+
+	go qs.Start()
+	defer qs.Stop()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls[2] == 2 && client.LLen("iq").Val() == 0 && client.ZCard("iq:delayed").Val() == 0
+	}, time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, calls[1])
+	assert.Equal(t, "done", client.Get("iq:idempotency:m2").Val())
+}
+
+func TestQueueServer_IdempotencyInProgress(t *testing.T) {
+	_, client := newTestRedis(t)
+	store := NewRedisIdempotencyStore(client, serverconf.QueueConfig{QueueName: "iq"})
+	ctx := context.Background()
+
+	data, err := encodeEnvelope(Envelope{ID: "m1", Payload: []byte(`{"id":1}`)})
+	require.NoError(t, err)
+	_, err = store.Claim(ctx, "m1")
+	require.NoError(t, err)
+
+	// without acknowledgement, a message another consumer is handling is pushed back.
+	handler := &mockQueueHandler{}
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "iq"}, handler)
+	require.NoError(t, err)
+	qs.WithIdempotency(store)
+	qs.retryBackoff = time.Millisecond
+	assert.False(t, qs.claim(ctx, data, "m1", ""))
+	assert.Equal(t, [][]byte{data}, handler.messages)
+
+	// to its source when the queue has several.
+	priority, err := NewJSONPriorityRedisHandler[MyTestMsg](client, serverconf.QueueConfig{QueueName: "pq"}, nil)
+	require.NoError(t, err)
+	pqs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "pq"}, priority)
+	require.NoError(t, err)
+	pqs.WithIdempotency(store)
+	assert.False(t, pqs.claim(ctx, data, "m1", "pq:priority:0"))
+	assert.Equal(t, int64(1), client.LLen("pq:priority:0").Val())
+
+	// with delays, it is pushed back later.
+	delayed := NewJSONRedisHandler[MyTestMsg](client, "dq", nil)
+	dqs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "dq"}, delayed)
+	require.NoError(t, err)
+	dqs.WithIdempotency(store)
+	assert.False(t, dqs.claim(ctx, data, "m1", ""))
+	assert.Equal(t, int64(1), client.ZCard("dq:delayed").Val())
+
+	// with acknowledgement, it is retried later.
+	reliable := NewJSONReliableRedisHandler[MyTestMsg](client, serverconf.QueueConfig{QueueName: "rq", ConsumerName: "c1"}, nil)
+	require.NoError(t, reliable.Push(ctx, data))
+	popped, err := reliable.Pop(ctx)
+	require.NoError(t, err)
+
+	rqs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "rq"}, reliable)
+	require.NoError(t, err)
+	rqs.WithIdempotency(store)
+	rqs.maxAttempts = 1
+	// a message handled elsewhere does not use up its attempts, so it is not dead-lettered.
+	dlq := NewRedisDeadLetterQueue(client, "rq")
+	rqs.WithDeadLetterQueue(dlq)
+	assert.False(t, rqs.claim(ctx, popped, "m1", ""))
+	assert.Equal(t, int64(1), client.ZCard("rq:delayed").Val())
+	n, err := dlq.Len(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestQueueServer_IdempotencyStoreDown(t *testing.T) {
+	mr, client := newTestRedis(t)
+	handler := &mockQueueHandler{}
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "iq"}, handler)
+	require.NoError(t, err)
+	qs.WithPayloadIdempotency(NewRedisIdempotencyStore(client, serverconf.QueueConfig{QueueName: "iq"}))
+	mr.Close()
+
+	// messages are handled when the store is unavailable.
+	assert.True(t, qs.claim(context.Background(), []byte("a"), payloadHash([]byte("a")), ""))
+	qs.settleClaim(context.Background(), payloadHash([]byte("a")), nil)
+}
+
+func TestQueueServer_BatchIdempotency(t *testing.T) {
+	_, client := newTestRedis(t)
+	handler := &mockBatchHandler{}
+	conf := serverconf.QueueConfig{QueueName: "iq", BatchSize: 3, BatchMaxWait: time.Millisecond}
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+	qs.WithPayloadIdempotency(NewRedisIdempotencyStore(client, conf))
+
+	ctx := context.Background()
+	for _, id := range []int{1, 1, 2} {
+		data, err := handler.Encode(MyTestMsg{ID: id})
+		require.NoError(t, err)
+		require.NoError(t, handler.Push(ctx, data))
+	}
+
+	require.NoError(t, qs.runOnce(ctx))
+	qs.Stop()
+
+	require.Len(t, handler.batches, 1)
+	assert.Equal(t, []MyTestMsg{{ID: 1}, {ID: 2}}, handler.batches[0])
+}
+
+func TestProducer_Dedup(t *testing.T) {
+	mr, client := newTestRedis(t)
+	handler := &mockQueueHandler{}
+	producer := NewProducer[MyTestMsg](handler, handler)
+	producer.WithDedup(NewRedisIdempotencyStore(client, serverconf.QueueConfig{QueueName: "dq"}), time.Minute)
+	ctx := context.Background()
+
+	require.NoError(t, producer.Push(ctx, MyTestMsg{ID: 1}))
+	assert.ErrorIs(t, producer.Push(ctx, MyTestMsg{ID: 1}), ErrDuplicateMessage)
+	require.NoError(t, producer.Push(ctx, MyTestMsg{ID: 2}))
+
+	require.NoError(t, producer.Push(ctx, MyTestMsg{ID: 1}, WithMessageID("m1")))
+	assert.ErrorIs(t, producer.Push(ctx, MyTestMsg{ID: 3}, WithMessageID("m1")), ErrDuplicateMessage)
+	assert.Len(t, handler.messages, 3)
+
+	mr.FastForward(time.Minute)
+	require.NoError(t, producer.Push(ctx, MyTestMsg{ID: 1}))
+	assert.Len(t, handler.messages, 4)
+}
+
+func TestProducer_DedupPushFailed(t *testing.T) {
+	mr, client := newTestRedis(t)
+	store := NewRedisIdempotencyStore(client, serverconf.QueueConfig{QueueName: "dq"})
+	queue, err := NewMemoryQueue(serverconf.QueueConfig{Capacity: 1})
+	require.NoError(t, err)
+	producer := NewProducer[MyTestMsg](JSONCodec[MyTestMsg]{}, queue)
+	producer.WithDedup(store, time.Minute)
+	ctx := context.Background()
+
+	require.NoError(t, producer.Push(ctx, MyTestMsg{ID: 1}))
+	assert.ErrorIs(t, producer.Push(ctx, MyTestMsg{ID: 2}), ErrQueueFull)
+
+	// a message that failed to be pushed can be pushed again.
+	_, err = queue.Pop(ctx)
+	require.NoError(t, err)
+	require.NoError(t, producer.Push(ctx, MyTestMsg{ID: 2}))
+
+	require.NoError(t, queue.Close())
+	mr.Close()
+	assert.Error(t, producer.Push(ctx, MyTestMsg{ID: 3}))
+}
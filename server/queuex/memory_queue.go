@@ -0,0 +1,227 @@
+package queuex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// DefaultMemoryQueueCapacity is the default maximum number of messages held by a MemoryQueue.
+	DefaultMemoryQueueCapacity = 1024
+)
+
+var (
+	// ErrQueueFull is returned when pushing to a MemoryQueue that is at capacity.
+	ErrQueueFull = errors.New("queue is full")
+	// ErrQueueClosed is returned when pushing to a MemoryQueue that has been closed.
+	ErrQueueClosed = errors.New("queue is closed")
+)
+
+// MemoryQueue implements Queue interface using a buffered channel, for tests and
+// single-process use. Messages are lost when the process exits unless PersistPath is set,
+// in which case the messages left in the queue are saved on Close and restored by
+// NewMemoryQueue.
+type MemoryQueue struct {
+	messages chan []byte
+	closing  chan struct{}
+	path     string
+	mu       sync.RWMutex
+	closed   bool
+}
+
+// NewMemoryQueue creates a new MemoryQueue instance, restoring the messages saved to
+// conf.PersistPath if the file exists.
+func NewMemoryQueue(conf serverconf.QueueConfig) (*MemoryQueue, error) {
+	if conf.Capacity <= 0 {
+		conf.Capacity = DefaultMemoryQueueCapacity
+	}
+
+	saved, err := loadMessages(conf.PersistPath)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &MemoryQueue{
+		messages: make(chan []byte, max(conf.Capacity, len(saved))),
+		closing:  make(chan struct{}),
+		path:     conf.PersistPath,
+	}
+	for _, data := range saved {
+		q.messages <- data
+	}
+	return q, nil
+}
+
+// Push adds a message to the queue, returning ErrQueueFull if the queue is at capacity.
+func (q *MemoryQueue) Push(_ context.Context, data []byte) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.messages <- data:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Pop retrieves a message from the queue, waiting up to one second for one to arrive.
+func (q *MemoryQueue) Pop(ctx context.Context) ([]byte, error) {
+	timer := time.NewTimer(1 * time.Second)
+	defer timer.Stop()
+
+	select {
+	case data := <-q.messages:
+		return data, nil
+	case <-q.closing:
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, nil
+	}
+}
+
+// PopBatch pops up to n messages from the queue without blocking.
+func (q *MemoryQueue) PopBatch(_ context.Context, n int) ([][]byte, error) {
+	var batch [][]byte
+	for len(batch) < n {
+		select {
+		case data := <-q.messages:
+			batch = append(batch, data)
+		default:
+			return batch, nil
+		}
+	}
+	return batch, nil
+}
+
+// Len returns the number of messages in the queue.
+func (q *MemoryQueue) Len(_ context.Context) (int64, error) {
+	return int64(len(q.messages)), nil
+}
+
+// Close stops accepting messages and saves the messages left in the queue to PersistPath.
+// QueueServer closes its queue on Stop.
+func (q *MemoryQueue) Close() error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.closing)
+	q.mu.Unlock()
+
+	if q.path == "" {
+		return nil
+	}
+
+	var left [][]byte
+	for {
+		select {
+		case data := <-q.messages:
+			left = append(left, data)
+		default:
+			return saveMessages(q.path, left)
+		}
+	}
+}
+
+// loadMessages reads the messages saved to path and removes the file, so they are restored only once.
+func loadMessages(path string) ([][]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages [][]byte
+	if err = json.Unmarshal(b, &messages); err != nil {
+		return nil, err
+	}
+	return messages, os.Remove(path)
+}
+
+// saveMessages writes the messages to path, replacing the file atomically.
+func saveMessages(path string, messages [][]byte) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(messages)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// JSONMemoryHandler handles JSON messages in an in-memory queue.
+type JSONMemoryHandler[T any] struct {
+	HandlerFunc[T]
+	JSONCodec[T]
+	*MemoryQueue
+}
+
+// NewJSONMemoryHandler creates a new JSONMemoryHandler instance.
+func NewJSONMemoryHandler[T any](
+	conf serverconf.QueueConfig,
+	handlerFn func(ctx context.Context, msg T) error,
+) (*JSONMemoryHandler[T], error) {
+	q, err := NewMemoryQueue(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JSONMemoryHandler[T]{
+		HandlerFunc: handlerFn,
+		JSONCodec:   JSONCodec[T]{},
+		MemoryQueue: q,
+	}, nil
+}
+
+// ProtoMemoryHandler handles protobuf messages in an in-memory queue.
+type ProtoMemoryHandler[T proto.Message] struct {
+	HandlerFunc[T]
+	ProtoCodec[T]
+	*MemoryQueue
+}
+
+// NewProtoMemoryHandler creates a new ProtoMemoryHandler instance.
+func NewProtoMemoryHandler[T proto.Message](
+	conf serverconf.QueueConfig,
+	handlerFn func(ctx context.Context, msg T) error,
+	newMsgFn func() T,
+) (*ProtoMemoryHandler[T], error) {
+	q, err := NewMemoryQueue(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProtoMemoryHandler[T]{
+		HandlerFunc: handlerFn,
+		ProtoCodec:  ProtoCodec[T]{newMsg: newMsgFn},
+		MemoryQueue: q,
+	}, nil
+}
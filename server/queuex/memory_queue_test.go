@@ -0,0 +1,185 @@
+package queuex
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/server/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMemoryQueue_PushPop(t *testing.T) {
+	q, err := NewMemoryQueue(serverconf.QueueConfig{Capacity: 2})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, q.Push(ctx, []byte("a")))
+	require.NoError(t, q.Push(ctx, []byte("b")))
+	assert.ErrorIs(t, q.Push(ctx, []byte("c")), ErrQueueFull)
+	assertMemoryLen(t, q, 2)
+
+	data, err := q.Pop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("a"), data)
+
+	batch, err := q.PopBatch(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("b")}, batch)
+	assertMemoryLen(t, q, 0)
+}
+
+func TestMemoryQueue_PopBlocks(t *testing.T) {
+	q, err := NewMemoryQueue(serverconf.QueueConfig{})
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = q.Push(context.Background(), []byte("a"))
+	}()
+
+	data, err := q.Pop(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []byte("a"), data)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = q.Pop(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestMemoryQueue_Close(t *testing.T) {
+	q, err := NewMemoryQueue(serverconf.QueueConfig{})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, q.Close())
+	require.NoError(t, q.Close())
+	assert.ErrorIs(t, q.Push(ctx, []byte("a")), ErrQueueClosed)
+
+	data, err := q.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+}
+
+func TestMemoryQueue_Persist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	conf := serverconf.QueueConfig{Capacity: 1, PersistPath: path}
+	ctx := context.Background()
+
+	q, err := NewMemoryQueue(conf)
+	require.NoError(t, err)
+	require.NoError(t, q.Close())
+	assert.NoFileExists(t, path)
+
+	conf.Capacity = 2
+	q, err = NewMemoryQueue(conf)
+	require.NoError(t, err)
+	require.NoError(t, q.Push(ctx, []byte("a")))
+	require.NoError(t, q.Push(ctx, []byte("b")))
+	require.NoError(t, q.Close())
+	assert.FileExists(t, path)
+
+	// restored messages are kept even when they exceed the capacity.
+	conf.Capacity = 1
+	q, err = NewMemoryQueue(conf)
+	require.NoError(t, err)
+	assertMemoryLen(t, q, 2)
+	assert.NoFileExists(t, path)
+
+	batch, err := q.PopBatch(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b")}, batch)
+}
+
+func TestMemoryQueue_PersistErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "broken.json")
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0o600))
+	_, err := NewMemoryQueue(serverconf.QueueConfig{PersistPath: path})
+	assert.Error(t, err)
+
+	_, err = NewJSONMemoryHandler[MyTestMsg](serverconf.QueueConfig{PersistPath: dir}, nil)
+	assert.Error(t, err)
+	_, err = NewProtoMemoryHandler(serverconf.QueueConfig{PersistPath: dir}, nil, func() *test.TestMsg {
+		return &test.TestMsg{}
+	})
+	assert.Error(t, err)
+
+	q, err := NewMemoryQueue(serverconf.QueueConfig{PersistPath: filepath.Join(dir, "missing", "queue.json")})
+	require.NoError(t, err)
+	require.NoError(t, q.Push(context.Background(), []byte("a")))
+	assert.Error(t, q.Close())
+}
+
+func TestQueueServer_Memory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	conf := serverconf.QueueConfig{
+		QueueName:          "mq",
+		PoolSize:           2,
+		EmptySleepInterval: time.Millisecond,
+		PersistPath:        path,
+	}
+
+	var mu sync.Mutex
+	var handled []MyTestMsg
+	handler, err := NewJSONMemoryHandler(conf, func(_ context.Context, msg MyTestMsg) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, msg)
+		return nil
+	})
+	require.NoError(t, err)
+
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+
+	data, err := handler.Encode(MyTestMsg{ID: 1, Body: "hello"})
+	require.NoError(t, err)
+	require.NoError(t, handler.Push(context.Background(), data))
+
+	go qs.Start()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	qs.Stop()
+	assert.ErrorIs(t, handler.Push(context.Background(), data), ErrQueueClosed)
+}
+
+func TestProtoMemoryHandler(t *testing.T) {
+	handler, err := NewProtoMemoryHandler(serverconf.QueueConfig{}, nil, func() *test.TestMsg {
+		return &test.TestMsg{}
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	msg := &test.TestMsg{Id: 1, Name: "Alice"}
+	data, err := handler.Encode(msg)
+	require.NoError(t, err)
+	require.NoError(t, handler.Push(ctx, data))
+
+	popped, err := handler.Pop(ctx)
+	require.NoError(t, err)
+	decoded, err := handler.Decode(popped)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(msg, decoded))
+	assert.NoError(t, handler.Handle(ctx, decoded))
+}
+
+func assertMemoryLen(t *testing.T, q *MemoryQueue, expected int64) {
+	t.Helper()
+	n, err := q.Len(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, expected, n)
+}
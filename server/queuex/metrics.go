@@ -0,0 +1,82 @@
+package queuex
+
+import (
+	"context"
+	"time"
+
+	"github.com/hewen/mastiff-go/middleware/metrics"
+)
+
+const (
+	// DefaultMetricsInterval is the default interval for polling queue depth and pool gauges.
+	DefaultMetricsInterval = 10 * time.Second
+)
+
+// DepthQueue interface: a Queue that can report how many messages are waiting. QueueServer
+// polls it for the queue_depth gauge.
+type DepthQueue interface {
+	Queue
+	Len(ctx context.Context) (int64, error)
+}
+
+// sourceDepthQueue is implemented by queues made of several queues, such as MultiQueue, which
+// report the depth of each of them rather than their total.
+type sourceDepthQueue interface {
+	sourceLens(ctx context.Context) (map[string]int64, error)
+}
+
+// metricsLoop periodically records the queue depth and pool gauges until the server stops.
+func (qs *QueueServer[T]) metricsLoop(ctx context.Context) {
+	ticker := time.NewTicker(qs.metricsInterval)
+	defer ticker.Stop()
+
+	for {
+		qs.collectMetrics(ctx)
+
+		select {
+		case <-qs.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// collectMetrics records the queue depth and pool gauges.
+func (qs *QueueServer[T]) collectMetrics(ctx context.Context) {
+	metrics.QueuePoolRunning.WithLabelValues(qs.name).Set(float64(qs.pool.Running()))
+	metrics.QueuePoolFree.WithLabelValues(qs.name).Set(float64(qs.pool.Free()))
+
+	if sd, ok := qs.handler.(sourceDepthQueue); ok {
+		depths, err := sd.sourceLens(ctx)
+		if err != nil {
+			qs.logger.Errorf("[queue:%s] get queue depth failed: %v", qs.name, err)
+			return
+		}
+		for source, n := range depths {
+			metrics.QueueDepth.WithLabelValues(qs.queueName(source)).Set(float64(n))
+		}
+		return
+	}
+
+	dq, ok := qs.handler.(DepthQueue)
+	if !ok {
+		return
+	}
+	n, err := dq.Len(ctx)
+	if err != nil {
+		qs.logger.Errorf("[queue:%s] get queue depth failed: %v", qs.name, err)
+		return
+	}
+	metrics.QueueDepth.WithLabelValues(qs.name).Set(float64(n))
+}
+
+// observeHandled records the outcome of handling messages of the queue that started at start.
+func (qs *QueueServer[T]) observeHandled(queue string, start time.Time, handled, failed int) {
+	metrics.QueueHandleDuration.WithLabelValues(queue).Observe(time.Since(start).Seconds())
+	if handled > 0 {
+		metrics.QueueHandled.WithLabelValues(queue).Add(float64(handled))
+	}
+	if failed > 0 {
+		metrics.QueueFailed.WithLabelValues(queue).Add(float64(failed))
+	}
+}
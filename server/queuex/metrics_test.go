@@ -0,0 +1,157 @@
+package queuex
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/middleware/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueServer_Metrics(t *testing.T) {
+	_, client := newTestRedis(t)
+	conf := serverconf.QueueConfig{
+		QueueName:          "metrics-q",
+		PoolSize:           2,
+		EmptySleepInterval: time.Millisecond,
+		MetricsInterval:    5 * time.Millisecond,
+	}
+
+	handler := NewJSONRedisHandler(client, conf.QueueName, func(_ context.Context, msg MyTestMsg) error {
+		if msg.ID == 1 {
+			return errors.New("handle failed")
+		}
+		return nil
+	})
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for _, id := range []int{1, 2, 3} {
+		data, err := handler.Encode(MyTestMsg{ID: id})
+		require.NoError(t, err)
+		require.NoError(t, handler.Push(ctx, data))
+	}
+	require.NoError(t, handler.Push(ctx, []byte("not json")))
+
+	qs.collectMetrics(ctx)
+	assert.Equal(t, float64(4), testutil.ToFloat64(metrics.QueueDepth.WithLabelValues(conf.QueueName)))
+
+	go qs.Start()
+	defer qs.Stop()
+
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(metrics.QueueHandled.WithLabelValues(conf.QueueName)) == 2 &&
+			testutil.ToFloat64(metrics.QueueFailed.WithLabelValues(conf.QueueName)) == 1 &&
+			testutil.ToFloat64(metrics.QueueDepth.WithLabelValues(conf.QueueName)) == 0
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, float64(4), testutil.ToFloat64(metrics.QueuePopped.WithLabelValues(conf.QueueName)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.QueueDecodeErrors.WithLabelValues(conf.QueueName)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.QueuePoolRunning.WithLabelValues(conf.QueueName))+testutil.ToFloat64(metrics.QueuePoolFree.WithLabelValues(conf.QueueName)))
+}
+
+func TestQueueServer_BatchMetrics(t *testing.T) {
+	handler := &mockBatchHandler{}
+	conf := serverconf.QueueConfig{QueueName: "metrics-batch-q", BatchSize: 3}
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		data, err := handler.Encode(MyTestMsg{ID: i})
+		require.NoError(t, err)
+		require.NoError(t, handler.Push(ctx, data))
+	}
+
+	require.NoError(t, qs.runOnce(ctx))
+	qs.Stop()
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(metrics.QueuePopped.WithLabelValues(conf.QueueName)))
+	assert.Equal(t, float64(3), testutil.ToFloat64(metrics.QueueHandled.WithLabelValues(conf.QueueName)))
+}
+
+func TestQueueServer_MetricsDepthError(t *testing.T) {
+	mr, client := newTestRedis(t)
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "metrics-err-q"}, NewJSONRedisHandler[MyTestMsg](client, "metrics-err-q", nil))
+	require.NoError(t, err)
+	mr.Close()
+
+	qs.collectMetrics(context.Background())
+	assert.Zero(t, testutil.ToFloat64(metrics.QueueDepth.WithLabelValues("metrics-err-q")))
+
+	// queues without a depth only record the pool gauges.
+	qs, err = NewQueueServer(serverconf.QueueConfig{QueueName: "metrics-mock-q"}, &mockQueueHandler{})
+	require.NoError(t, err)
+	qs.collectMetrics(context.Background())
+}
+
+func TestQueueServer_ObserveBatch(t *testing.T) {
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "metrics-observe-q"}, &mockQueueHandler{})
+	require.NoError(t, err)
+
+	batchErr := NewBatchError()
+	batchErr.Add(1, errors.New("failed"))
+	batchErr.Add(5, errors.New("out of range"))
+	qs.observeBatch(time.Now(), popped{data: make([][]byte, 3)}, batchErr)
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.QueueHandled.WithLabelValues("metrics-observe-q")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.QueueFailed.WithLabelValues("metrics-observe-q")))
+
+	qs.observeBatch(time.Now(), popped{data: make([][]byte, 2)}, errors.New("failed"))
+	assert.Equal(t, float64(3), testutil.ToFloat64(metrics.QueueFailed.WithLabelValues("metrics-observe-q")))
+}
+
+func TestRedisQueue_Len(t *testing.T) {
+	_, client := newTestRedis(t)
+	ctx := context.Background()
+
+	q := NewRedisQueue(client, "lq")
+	require.NoError(t, q.Push(ctx, []byte("a")))
+	n, err := q.Len(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	sq := NewRedisStreamQueue(client, serverconf.QueueConfig{QueueName: "sq"})
+	n, err = sq.Len(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// without a consumer group, every entry is waiting.
+	require.NoError(t, sq.Push(ctx, []byte("a")))
+	require.NoError(t, sq.Push(ctx, []byte("b")))
+	n, err = sq.Len(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	// the lag of the consumer group is reported once it exists.
+	gq := NewRedisStreamQueue(client, serverconf.QueueConfig{QueueName: "gq"})
+	require.NoError(t, gq.createGroup())
+	require.NoError(t, gq.Push(ctx, []byte("a")))
+	n, err = gq.Len(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestQueueServer_Check(t *testing.T) {
+	mr, client := newTestRedis(t)
+	handler := NewJSONRedisHandler(client, "check-q", func(_ context.Context, _ MyTestMsg) error {
+		return nil
+	})
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "check-q"}, handler)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, qs.Check(ctx))
+
+	mr.SetError("connection refused")
+	assert.Error(t, qs.Check(ctx))
+	mr.SetError("")
+
+	qs.Stop()
+	assert.ErrorIs(t, qs.Check(ctx), ErrServerStopped)
+}
@@ -0,0 +1,31 @@
+package queuex
+
+// Middleware wraps a Handler, such as to recover from panics or to log handled messages.
+type Middleware[T any] func(next Handler[T]) Handler[T]
+
+// Chain wraps h with the middlewares, the first one being the outermost.
+func Chain[T any](h Handler[T], mws ...Middleware[T]) Handler[T] {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Use adds middlewares wrapping the handling of every message, the first one being the
+// outermost. Batch handlers are wrapped by the middlewares added with UseBatch instead.
+func (qs *QueueServer[T]) Use(mws ...Middleware[T]) {
+	qs.middlewares = append(qs.middlewares, mws...)
+	qs.handle = Chain[T](qs.handler, qs.middlewares...)
+}
+
+// UseBatch adds middlewares wrapping the handling of every batch when the handler is a
+// BatchHandler, the first one being the outermost. They see a batch as one message, so the
+// middlewares of a slice of messages, such as the ones of LoadQueueMiddlewares[[]T], fit.
+// Errors of the batch handler, including a *BatchError, are passed through unchanged.
+func (qs *QueueServer[T]) UseBatch(mws ...Middleware[[]T]) {
+	if qs.batchHandler == nil {
+		return
+	}
+	qs.batchMiddlewares = append(qs.batchMiddlewares, mws...)
+	qs.handleBatch = Chain[[]T](HandlerFunc[[]T](qs.batchHandler.HandleBatch), qs.batchMiddlewares...)
+}
@@ -0,0 +1,107 @@
+package queuex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recordingMiddleware(name string, calls *[]string) Middleware[MyTestMsg] {
+	return func(next Handler[MyTestMsg]) Handler[MyTestMsg] {
+		return HandlerFunc[MyTestMsg](func(ctx context.Context, msg MyTestMsg) error {
+			*calls = append(*calls, name)
+			return next.Handle(ctx, msg)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	h := Chain[MyTestMsg](HandlerFunc[MyTestMsg](func(_ context.Context, _ MyTestMsg) error {
+		calls = append(calls, "handler")
+		return nil
+	}), recordingMiddleware("outer", &calls), recordingMiddleware("inner", &calls))
+
+	require.NoError(t, h.Handle(context.Background(), MyTestMsg{}))
+	assert.Equal(t, []string{"outer", "inner", "handler"}, calls)
+}
+
+func TestQueueServer_Use(t *testing.T) {
+	handler := &mockQueueHandler{}
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "test"}, handler)
+	require.NoError(t, err)
+
+	var calls []string
+	qs.Use(recordingMiddleware("first", &calls))
+	qs.Use(recordingMiddleware("second", &calls), func(Handler[MyTestMsg]) Handler[MyTestMsg] {
+		return HandlerFunc[MyTestMsg](func(_ context.Context, _ MyTestMsg) error {
+			return errors.New("rejected")
+		})
+	})
+
+	data, err := handler.Encode(MyTestMsg{ID: 1})
+	require.NoError(t, err)
+	require.NoError(t, handler.Push(context.Background(), data))
+
+	require.NoError(t, qs.runOnce(context.Background()))
+	qs.Stop()
+
+	assert.Equal(t, []string{"first", "second"}, calls)
+	assert.Empty(t, handler.handled)
+}
+
+func TestQueueServer_UseBatch(t *testing.T) {
+	handler := &mockBatchHandler{}
+	conf := serverconf.QueueConfig{QueueName: "test", BatchSize: 2, BatchMaxWait: time.Millisecond}
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+
+	// a panic of the batch handler is turned into the error of the batch by a middleware.
+	var sizes []int
+	qs.UseBatch(func(next Handler[[]MyTestMsg]) Handler[[]MyTestMsg] {
+		return HandlerFunc[[]MyTestMsg](func(ctx context.Context, msgs []MyTestMsg) (err error) {
+			sizes = append(sizes, len(msgs))
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			return next.Handle(ctx, msgs)
+		})
+	}, func(Handler[[]MyTestMsg]) Handler[[]MyTestMsg] {
+		return HandlerFunc[[]MyTestMsg](func(_ context.Context, _ []MyTestMsg) error {
+			panic("batch")
+		})
+	})
+	_, client := newTestRedis(t)
+	dlq := NewRedisDeadLetterQueue(client, "test")
+	qs.WithDeadLetterQueue(dlq)
+
+	ctx := context.Background()
+	for _, id := range []int{1, 2} {
+		data, err := handler.Encode(MyTestMsg{ID: id})
+		require.NoError(t, err)
+		require.NoError(t, handler.Push(ctx, data))
+	}
+	require.NoError(t, qs.runOnce(ctx))
+	qs.Stop()
+
+	assert.Equal(t, []int{2}, sizes)
+	assert.Empty(t, handler.batches)
+	letters, err := dlq.List(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+	assert.Equal(t, "panic: batch", letters[0].Error)
+
+	// servers without a batch handler ignore batch middlewares.
+	single, err := NewQueueServer(conf, &mockQueueHandler{})
+	require.NoError(t, err)
+	single.UseBatch(nil)
+	assert.Nil(t, single.handleBatch)
+}
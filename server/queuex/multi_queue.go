@@ -0,0 +1,274 @@
+package queuex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+var (
+	// ErrDuplicateSubscription is returned when subscribing to a queue twice.
+	ErrDuplicateSubscription = errors.New("queue is already subscribed")
+	// ErrInvalidWeight is returned when subscribing with a weight that is not positive.
+	ErrInvalidWeight = errors.New("weight must be positive")
+	// ErrUnknownSource is returned when a MultiQueue is given a queue it is not subscribed to.
+	ErrUnknownSource = errors.New("queue is not subscribed")
+)
+
+// MultiMessage is a message of a MultiQueue, decoded by the codec of its queue.
+type MultiMessage struct {
+	handle func(ctx context.Context) error
+	// Queue is the name of the queue the message was popped from.
+	Queue string
+}
+
+// subscription is a queue consumed by a MultiQueue.
+type subscription struct {
+	// decode decodes a payload into a call of the handler with the message.
+	decode func(payload []byte) (func(ctx context.Context) error, error)
+	queue  string
+	weight int
+}
+
+// MultiQueue is the handler of a QueueServer consuming several Redis list queues, such as
+// the ones of RedisQueue, with one goroutine pool and one blocking pop across their keys, so
+// many small queues do not need a QueueServer each. Each pop first tries a queue picked with
+// a probability proportional to its weight, falling back to the others in subscription
+// order, so busy queues do not starve quiet ones.
+//
+// Messages are decoded and handled by the codec and handler of their queue, and pushed back
+// to it when draining times out. Metrics, logs and dead letters are labelled with the name of
+// that queue rather than the one of the QueueServer. Like RedisQueue, messages are popped at most once; use a
+// QueueServer per ReliableRedisQueue when they must survive a crash.
+type MultiQueue struct {
+	client *redis.Client
+	intn   func(n int) int
+	subs   []*subscription
+	total  int
+	mu     sync.Mutex
+}
+
+// NewMultiQueue creates a new MultiQueue instance. Queues are added with Subscribe.
+func NewMultiQueue(client *redis.Client) *MultiQueue {
+	return &MultiQueue{
+		client: client,
+		intn:   rand.IntN,
+	}
+}
+
+// Subscribe registers the handler of a queue, whose messages are decoded with codec. It is a
+// function because methods cannot have type parameters.
+func Subscribe[T any](q *MultiQueue, queueName string, weight int, codec Codec[T], handler Handler[T]) error {
+	if queueName == "" {
+		return ErrEmptyQueueName
+	}
+	if weight <= 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidWeight, weight)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.lookup(queueName) != nil {
+		return fmt.Errorf("%w: %s", ErrDuplicateSubscription, queueName)
+	}
+
+	q.subs = append(q.subs, &subscription{
+		queue:  queueName,
+		weight: weight,
+		decode: func(payload []byte) (func(ctx context.Context) error, error) {
+			msg, err := codec.Decode(payload)
+			if err != nil {
+				return nil, err
+			}
+			return func(ctx context.Context) error {
+				return handler.Handle(ctx, msg)
+			}, nil
+		},
+	})
+	q.total += weight
+	return nil
+}
+
+// Push is not supported, as messages are pushed to the queue of their subscription.
+func (q *MultiQueue) Push(_ context.Context, _ []byte) error {
+	return fmt.Errorf("push to multi queue: %w", errors.ErrUnsupported)
+}
+
+// Pop retrieves a message from the first non-empty queue in pop order.
+func (q *MultiQueue) Pop(ctx context.Context) ([]byte, error) {
+	data, _, err := q.PopSource(ctx)
+	return data, err
+}
+
+// PopSource retrieves a message from the first non-empty queue in pop order, waiting up to one
+// second for one to arrive, and returns the name of its queue.
+func (q *MultiQueue) PopSource(_ context.Context) ([]byte, string, error) {
+	keys := q.popOrder()
+	if len(keys) == 0 {
+		return nil, "", nil
+	}
+
+	res, err := q.client.BLPop(1*time.Second, keys...).Result()
+	if err != nil && err != redis.Nil {
+		return nil, "", err
+	}
+	if len(res) != 2 {
+		return nil, "", nil
+	}
+	return []byte(res[1]), res[0], nil
+}
+
+// PopBatchSource pops up to n messages without blocking, draining queues in pop order, and
+// returns the names of their queues.
+func (q *MultiQueue) PopBatchSource(_ context.Context, n int) ([][]byte, []string, error) {
+	var batch [][]byte
+	var sources []string
+	for _, key := range q.popOrder() {
+		if len(batch) >= n {
+			break
+		}
+
+		var items *redis.StringSliceCmd
+		_, err := q.client.TxPipelined(func(pipe redis.Pipeliner) error {
+			items = pipe.LRange(key, 0, int64(n-len(batch)-1))
+			pipe.LTrim(key, int64(n-len(batch)), -1)
+			return nil
+		})
+		if err != nil {
+			return batch, sources, err
+		}
+		for _, item := range toBatch(items.Val()) {
+			batch = append(batch, item)
+			sources = append(sources, key)
+		}
+	}
+	return batch, sources, nil
+}
+
+// PushSource pushes a message back to the subscribed queue it was popped from.
+func (q *MultiQueue) PushSource(_ context.Context, data []byte, source string) error {
+	q.mu.Lock()
+	sub := q.lookup(source)
+	q.mu.Unlock()
+	if sub == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownSource, source)
+	}
+	return q.client.LPush(source, data).Err()
+}
+
+// Len returns the number of messages waiting across the subscribed queues.
+func (q *MultiQueue) Len(ctx context.Context) (int64, error) {
+	lens, err := q.sourceLens(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	for _, l := range lens {
+		n += l
+	}
+	return n, nil
+}
+
+// sourceLens returns the number of messages waiting in each subscribed queue, so the queue
+// server reports the depth of each of them.
+func (q *MultiQueue) sourceLens(_ context.Context) (map[string]int64, error) {
+	keys := q.popOrder()
+	cmds := make([]*redis.IntCmd, len(keys))
+	_, err := q.client.Pipelined(func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.LLen(key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lens := make(map[string]int64, len(keys))
+	for i, cmd := range cmds {
+		lens[keys[i]] = cmd.Val()
+	}
+	return lens, nil
+}
+
+// sourceName returns the name of the queue a message was popped from, its source, so the
+// queue server labels its metrics, logs and dead letters with the subscribed queue.
+func (q *MultiQueue) sourceName(source string) string {
+	return source
+}
+
+// Encode is not supported, as messages are encoded by the codec of their queue.
+func (q *MultiQueue) Encode(_ MultiMessage) ([]byte, error) {
+	return nil, fmt.Errorf("encode multi queue message: %w", errors.ErrUnsupported)
+}
+
+// Decode fails, as messages are decoded by the codec of the queue they were popped from.
+func (q *MultiQueue) Decode(_ []byte) (MultiMessage, error) {
+	return MultiMessage{}, fmt.Errorf("%w: unknown source", ErrUnknownSource)
+}
+
+// DecodeSource decodes a message with the codec of the queue it was popped from.
+func (q *MultiQueue) DecodeSource(source string, data []byte) (MultiMessage, error) {
+	q.mu.Lock()
+	sub := q.lookup(source)
+	q.mu.Unlock()
+	if sub == nil {
+		return MultiMessage{}, fmt.Errorf("%w: %s", ErrUnknownSource, source)
+	}
+
+	handle, err := sub.decode(data)
+	if err != nil {
+		return MultiMessage{}, err
+	}
+	return MultiMessage{Queue: source, handle: handle}, nil
+}
+
+// Handle calls the handler of the queue the message was popped from.
+func (q *MultiQueue) Handle(ctx context.Context, msg MultiMessage) error {
+	if msg.handle == nil {
+		return nil
+	}
+	return msg.handle(ctx)
+}
+
+// popOrder returns the subscribed queues in the order they are popped from.
+func (q *MultiQueue) popOrder() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.subs) == 0 {
+		return nil
+	}
+
+	weights := make([]int, len(q.subs))
+	for i, sub := range q.subs {
+		weights[i] = sub.weight
+	}
+	first := weightedPick(weights, q.total, q.intn)
+
+	keys := make([]string, 0, len(q.subs))
+	keys = append(keys, q.subs[first].queue)
+	for i, sub := range q.subs {
+		if i != first {
+			keys = append(keys, sub.queue)
+		}
+	}
+	return keys
+}
+
+// lookup returns the subscription of the queue, nil if it is not subscribed. The caller holds mu.
+func (q *MultiQueue) lookup(queue string) *subscription {
+	i := slices.IndexFunc(q.subs, func(sub *subscription) bool {
+		return sub.queue == queue
+	})
+	if i < 0 {
+		return nil
+	}
+	return q.subs[i]
+}
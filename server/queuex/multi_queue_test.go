@@ -0,0 +1,262 @@
+package queuex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/logger"
+	"github.com/hewen/mastiff-go/middleware/metrics"
+	"github.com/hewen/mastiff-go/server/test"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiQueue_Subscribe(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewMultiQueue(client)
+
+	h := HandlerFunc[MyTestMsg](nil)
+	require.NoError(t, Subscribe[MyTestMsg](q, "a", 1, JSONCodec[MyTestMsg]{}, h))
+	require.NoError(t, Subscribe[*test.TestMsg](q, "b", 2, ProtoCodec[*test.TestMsg]{newMsg: func() *test.TestMsg {
+		return &test.TestMsg{}
+	}}, HandlerFunc[*test.TestMsg](nil)))
+
+	assert.ErrorIs(t, Subscribe[MyTestMsg](q, "", 1, JSONCodec[MyTestMsg]{}, h), ErrEmptyQueueName)
+	assert.ErrorIs(t, Subscribe[MyTestMsg](q, "c", 0, JSONCodec[MyTestMsg]{}, h), ErrInvalidWeight)
+	assert.ErrorIs(t, Subscribe[MyTestMsg](q, "a", 1, JSONCodec[MyTestMsg]{}, h), ErrDuplicateSubscription)
+
+	// a pick in the share of the second queue pops it first.
+	q.intn = func(int) int { return 1 }
+	assert.Equal(t, []string{"b", "a"}, q.popOrder())
+
+	q.intn = func(int) int { return 0 }
+	assert.Equal(t, []string{"a", "b"}, q.popOrder())
+}
+
+func TestMultiQueue_Source(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewMultiQueue(client)
+	ctx := context.Background()
+
+	// nothing is popped without subscriptions.
+	data, source, err := q.PopSource(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, data)
+	assert.Empty(t, source)
+
+	require.NoError(t, Subscribe[MyTestMsg](q, "a", 1, JSONCodec[MyTestMsg]{}, HandlerFunc[MyTestMsg](nil)))
+	require.NoError(t, Subscribe[MyTestMsg](q, "b", 1, JSONCodec[MyTestMsg]{}, HandlerFunc[MyTestMsg](nil)))
+	q.intn = func(int) int { return 0 }
+
+	require.NoError(t, q.PushSource(ctx, []byte(`{"id":1}`), "b"))
+	require.NoError(t, q.PushSource(ctx, []byte(`{"id":2}`), "a"))
+	assert.ErrorIs(t, q.PushSource(ctx, nil, "c"), ErrUnknownSource)
+	n, err := q.Len(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	data, source, err = q.PopSource(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`{"id":2}`), data)
+	assert.Equal(t, "a", source)
+
+	batch, sources, err := q.PopBatchSource(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte(`{"id":1}`)}, batch)
+	assert.Equal(t, []string{"b"}, sources)
+
+	data, err = q.Pop(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, data)
+
+	msg, err := q.DecodeSource("b", []byte(`{"id":1}`))
+	require.NoError(t, err)
+	assert.Equal(t, "b", msg.Queue)
+	assert.NoError(t, q.Handle(ctx, msg))
+	assert.NoError(t, q.Handle(ctx, MultiMessage{}))
+	_, err = q.DecodeSource("b", []byte("not json"))
+	assert.Error(t, err)
+	_, err = q.DecodeSource("c", nil)
+	assert.ErrorIs(t, err, ErrUnknownSource)
+	_, err = q.Decode(nil)
+	assert.ErrorIs(t, err, ErrUnknownSource)
+
+	assert.ErrorIs(t, q.Push(ctx, nil), errors.ErrUnsupported)
+	_, err = q.Encode(msg)
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+}
+
+func TestMultiQueue_QueueServer(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewMultiQueue(client)
+
+	var mu sync.Mutex
+	var ids []int
+	var names []string
+	var traceIDs []string
+	var queues []string
+	require.NoError(t, Subscribe[MyTestMsg](q, "orders", 1, JSONCodec[MyTestMsg]{}, HandlerFunc[MyTestMsg](func(ctx context.Context, msg MyTestMsg) error {
+		mu.Lock()
+		defer mu.Unlock()
+		ids = append(ids, msg.ID)
+		if env, ok := EnvelopeFromContext(ctx); ok {
+			traceIDs = append(traceIDs, env.TraceID)
+		}
+		return nil
+	})))
+	protoCodec := ProtoCodec[*test.TestMsg]{newMsg: func() *test.TestMsg {
+		return &test.TestMsg{}
+	}}
+	require.NoError(t, Subscribe[*test.TestMsg](q, "emails", 1, protoCodec, HandlerFunc[*test.TestMsg](func(_ context.Context, msg *test.TestMsg) error {
+		mu.Lock()
+		defer mu.Unlock()
+		names = append(names, msg.Name)
+		return nil
+	})))
+
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "multi", PoolSize: 2, EmptySleepInterval: time.Millisecond}, q)
+	require.NoError(t, err)
+	// middlewares see the queue each message was popped from.
+	qs.Use(func(next Handler[MultiMessage]) Handler[MultiMessage] {
+		return HandlerFunc[MultiMessage](func(ctx context.Context, msg MultiMessage) error {
+			mu.Lock()
+			queues = append(queues, msg.Queue)
+			mu.Unlock()
+			return next.Handle(ctx, msg)
+		})
+	})
+
+	ctx := logger.NewOutgoingContextWithIncomingContext(context.Background())
+	require.NoError(t, NewProducer[MyTestMsg](JSONCodec[MyTestMsg]{}, NewRedisQueue(client, "orders")).Push(ctx, MyTestMsg{ID: 1}))
+	require.NoError(t, NewProducer[*test.TestMsg](protoCodec, NewRedisQueue(client, "emails")).Push(ctx, &test.TestMsg{Name: "Alice"}))
+
+	go qs.Start()
+	defer qs.Stop()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(ids) == 1 && len(names) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []int{1}, ids)
+	assert.Equal(t, []string{"Alice"}, names)
+	assert.Equal(t, []string{logger.GetTraceIDWithContext(ctx)}, traceIDs)
+	assert.ElementsMatch(t, []string{"orders", "emails"}, queues)
+}
+
+func TestMultiQueue_DeadLetter(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewMultiQueue(client)
+	errHandle := errors.New("handle failed")
+	require.NoError(t, Subscribe[MyTestMsg](q, "orders", 1, JSONCodec[MyTestMsg]{}, HandlerFunc[MyTestMsg](func(_ context.Context, _ MyTestMsg) error {
+		return errHandle
+	})))
+
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "multi"}, q)
+	require.NoError(t, err)
+	defer qs.Stop()
+	dlq := NewRedisDeadLetterQueue(client, "dlq")
+	qs.WithDeadLetterQueue(dlq)
+
+	ctx := context.Background()
+	orders := NewRedisQueue(client, "orders")
+	require.NoError(t, orders.Push(ctx, []byte("not json")))
+	assert.ErrorContains(t, qs.runOnce(ctx), "decode failed")
+
+	require.NoError(t, orders.Push(ctx, []byte(`{"id":1}`)))
+	require.NoError(t, qs.runOnce(ctx))
+	require.Eventually(t, func() bool {
+		n, err := dlq.Len(ctx)
+		return err == nil && n == 2
+	}, time.Second, 5*time.Millisecond)
+
+	letters, err := dlq.List(ctx, 0, 2)
+	require.NoError(t, err)
+	var causes []string
+	for _, letter := range letters {
+		causes = append(causes, letter.Error)
+		assert.Equal(t, "orders", letter.Queue)
+		assert.Equal(t, "orders", letter.Source)
+	}
+	assert.Contains(t, causes, errHandle.Error())
+}
+
+func TestMultiQueue_Metrics(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewMultiQueue(client)
+	require.NoError(t, Subscribe[MyTestMsg](q, "multi-metrics-a", 1, JSONCodec[MyTestMsg]{}, HandlerFunc[MyTestMsg](nil)))
+	require.NoError(t, Subscribe[MyTestMsg](q, "multi-metrics-b", 1, JSONCodec[MyTestMsg]{}, HandlerFunc[MyTestMsg](func(_ context.Context, _ MyTestMsg) error {
+		return errors.New("handle failed")
+	})))
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "multi-metrics"}, q)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, q.PushSource(ctx, []byte(`{"id":1}`), "multi-metrics-a"))
+	require.NoError(t, q.PushSource(ctx, []byte(`{"id":2}`), "multi-metrics-b"))
+	require.NoError(t, q.PushSource(ctx, []byte(`{"id":3}`), "multi-metrics-b"))
+
+	// metrics are labelled with the subscribed queue of each message.
+	qs.collectMetrics(ctx)
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.QueueDepth.WithLabelValues("multi-metrics-a")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.QueueDepth.WithLabelValues("multi-metrics-b")))
+
+	for range 3 {
+		require.NoError(t, qs.runOnce(ctx))
+	}
+	qs.Stop()
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.QueuePopped.WithLabelValues("multi-metrics-a")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.QueuePopped.WithLabelValues("multi-metrics-b")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.QueueHandled.WithLabelValues("multi-metrics-a")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.QueueFailed.WithLabelValues("multi-metrics-b")))
+	assert.Zero(t, testutil.ToFloat64(metrics.QueuePopped.WithLabelValues("multi-metrics")))
+}
+
+func TestMultiQueue_StopDrainTimeout(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewMultiQueue(client)
+	started := make(chan struct{})
+	require.NoError(t, Subscribe[MyTestMsg](q, "orders", 1, JSONCodec[MyTestMsg]{}, HandlerFunc[MyTestMsg](func(ctx context.Context, _ MyTestMsg) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})))
+	require.NoError(t, Subscribe[MyTestMsg](q, "emails", 1, JSONCodec[MyTestMsg]{}, HandlerFunc[MyTestMsg](nil)))
+	require.NoError(t, NewRedisQueue(client, "orders").Push(context.Background(), []byte(`{"id":1}`)))
+
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "multi", PoolSize: 1, DrainTimeout: 50 * time.Millisecond}, q)
+	require.NoError(t, err)
+	go qs.Start()
+	<-started
+	qs.Stop()
+
+	// the hung message is pushed back to its queue instead of being lost.
+	assert.Equal(t, []string{`{"id":1}`}, client.LRange("orders", 0, -1).Val())
+	assert.Zero(t, client.LLen("emails").Val())
+}
+
+func TestMultiQueue_Errors(t *testing.T) {
+	mr, client := newTestRedis(t)
+	q := NewMultiQueue(client)
+	require.NoError(t, Subscribe[MyTestMsg](q, "orders", 1, JSONCodec[MyTestMsg]{}, HandlerFunc[MyTestMsg](nil)))
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "multi"}, q)
+	require.NoError(t, err)
+	ctx := context.Background()
+	assert.NoError(t, qs.Check(ctx))
+
+	mr.Close()
+	assert.Error(t, qs.Check(ctx))
+	_, _, err = q.PopSource(ctx)
+	assert.Error(t, err)
+	_, _, err = q.PopBatchSource(ctx, 2)
+	assert.Error(t, err)
+}
@@ -0,0 +1,146 @@
+package queuex
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hewen/mastiff-go/logger"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+var (
+	// ErrDelayNotSupported is returned when pushing a delayed message to a queue that does not implement DelayedQueue.
+	ErrDelayNotSupported = errors.New("queue does not support delayed messages")
+	// ErrPriorityNotSupported is returned when pushing with a priority to a queue that does not implement PriorityQueue.
+	ErrPriorityNotSupported = errors.New("queue does not support priorities")
+)
+
+// PushOption customizes the Envelope of a message pushed by Producer.
+type PushOption func(*Envelope)
+
+// WithHeader sets a header of the message.
+func WithHeader(key, value string) PushOption {
+	return func(env *Envelope) {
+		if env.Headers == nil {
+			env.Headers = make(map[string]string)
+		}
+		env.Headers[key] = value
+	}
+}
+
+// WithMessageID sets the message ID instead of generating one.
+func WithMessageID(id string) PushOption {
+	return func(env *Envelope) {
+		env.ID = id
+	}
+}
+
+// Producer pushes typed messages to a queue, wrapping them in an Envelope that carries the
+// trace ID of the producer context.
+type Producer[T any] struct {
+	codec       Codec[T]
+	queue       Queue
+	dedup       DedupStore
+	dedupWindow time.Duration
+}
+
+// NewProducer creates a new Producer instance. Handlers such as JSONRedisHandler implement
+// both Codec and Queue, so they can be passed as both.
+func NewProducer[T any](codec Codec[T], queue Queue) *Producer[T] {
+	return &Producer[T]{codec: codec, queue: queue}
+}
+
+// WithDedup drops messages pushed again within the window, returning ErrDuplicateMessage.
+// Messages are identified by the ID set with WithMessageID, or by their encoded payload.
+func (p *Producer[T]) WithDedup(store DedupStore, window time.Duration) {
+	p.dedup = store
+	p.dedupWindow = window
+}
+
+// Push encodes msg and adds it to the queue.
+func (p *Producer[T]) Push(ctx context.Context, msg T, opts ...PushOption) error {
+	return p.send(ctx, msg, opts, func(data []byte) error {
+		return p.queue.Push(ctx, data)
+	})
+}
+
+// PushWithPriority encodes msg and adds it to the queue with the given priority, 0 being the highest.
+func (p *Producer[T]) PushWithPriority(ctx context.Context, msg T, priority int, opts ...PushOption) error {
+	pq, ok := p.queue.(PriorityQueue)
+	if !ok {
+		return ErrPriorityNotSupported
+	}
+
+	return p.send(ctx, msg, opts, func(data []byte) error {
+		return pq.PushWithPriority(ctx, data, priority)
+	})
+}
+
+// PushDelayed encodes msg and adds it to the queue once the delay has elapsed.
+func (p *Producer[T]) PushDelayed(ctx context.Context, msg T, delay time.Duration, opts ...PushOption) error {
+	return p.PushAt(ctx, msg, time.Now().Add(delay), opts...)
+}
+
+// PushAt encodes msg and adds it to the queue at the given time.
+func (p *Producer[T]) PushAt(ctx context.Context, msg T, at time.Time, opts ...PushOption) error {
+	dq, ok := p.queue.(DelayedQueue)
+	if !ok {
+		return ErrDelayNotSupported
+	}
+
+	return p.send(ctx, msg, opts, func(data []byte) error {
+		return dq.PushAt(ctx, data, at)
+	})
+}
+
+// send wraps msg into an Envelope and pushes it, unless it is a duplicate.
+func (p *Producer[T]) send(ctx context.Context, msg T, opts []PushOption, push func(data []byte) error) error {
+	payload, err := p.codec.Encode(msg)
+	if err != nil {
+		return err
+	}
+
+	env := Envelope{
+		TraceID:    logger.GetTraceIDWithContext(ctx),
+		EnqueuedAt: time.Now(),
+		Attempt:    1,
+		Payload:    payload,
+	}
+	for _, opt := range opts {
+		opt(&env)
+	}
+
+	dedupKey := env.ID
+	if env.ID == "" {
+		dedupKey = payloadHash(payload)
+		if env.ID, err = gonanoid.New(); err != nil {
+			return err
+		}
+	}
+
+	data, err := encodeEnvelope(env)
+	if err != nil {
+		return err
+	}
+
+	if p.dedup == nil {
+		return push(data)
+	}
+
+	reserved, err := p.dedup.Reserve(ctx, dedupKey, p.dedupWindow)
+	if err != nil {
+		return err
+	}
+	if !reserved {
+		return ErrDuplicateMessage
+	}
+	if err = push(data); err != nil {
+		// the message was not pushed, so pushing it again is not a duplicate.
+		if unreserveErr := p.dedup.Unreserve(ctx, dedupKey); unreserveErr != nil {
+			return errors.Join(err, unreserveErr)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package queuex
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/pkg/contextkeys"
+	"github.com/hewen/mastiff-go/server/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProducer_Push(t *testing.T) {
+	handler := &mockQueueHandler{}
+	producer := NewProducer[MyTestMsg](handler, handler)
+	ctx := contextkeys.SetTraceID(context.Background(), "trace-1")
+
+	require.NoError(t, producer.Push(ctx, MyTestMsg{ID: 1}, WithHeader("k1", "v1"), WithHeader("k2", "v2")))
+	require.NoError(t, producer.Push(context.Background(), MyTestMsg{ID: 2}, WithMessageID("msg-2")))
+	require.Len(t, handler.messages, 2)
+
+	env, ok := decodeEnvelope(handler.messages[0])
+	require.True(t, ok)
+	assert.Len(t, env.ID, delayedIDLen)
+	assert.Equal(t, "trace-1", env.TraceID)
+	assert.Equal(t, map[string]string{"k1": "v1", "k2": "v2"}, env.Headers)
+	assert.Equal(t, 1, env.Attempt)
+	assert.WithinDuration(t, time.Now(), env.EnqueuedAt, time.Second)
+
+	msg, err := handler.Decode(env.Payload)
+	require.NoError(t, err)
+	assert.Equal(t, MyTestMsg{ID: 1}, msg)
+
+	env, ok = decodeEnvelope(handler.messages[1])
+	require.True(t, ok)
+	assert.Equal(t, "msg-2", env.ID)
+	assert.NotEmpty(t, env.TraceID)
+}
+
+func TestProducer_PushDelayed(t *testing.T) {
+	_, client := newTestRedis(t)
+	handler := NewJSONRedisHandler[MyTestMsg](client, "pq", nil)
+	producer := NewProducer[MyTestMsg](handler, handler)
+	ctx := context.Background()
+
+	require.NoError(t, producer.PushDelayed(ctx, MyTestMsg{ID: 1}, 10*time.Millisecond))
+	assert.Equal(t, int64(1), client.ZCard("pq:delayed").Val())
+
+	time.Sleep(20 * time.Millisecond)
+	n, err := handler.PromoteDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	data, err := handler.Pop(ctx)
+	require.NoError(t, err)
+	_, ok := decodeEnvelope(data)
+	assert.True(t, ok)
+
+	mock := &mockQueueHandler{}
+	err = NewProducer[MyTestMsg](mock, mock).PushAt(ctx, MyTestMsg{}, time.Now())
+	assert.ErrorIs(t, err, ErrDelayNotSupported)
+}
+
+func TestProducer_EncodeError(t *testing.T) {
+	handler := &mockQueueHandler{}
+	producer := NewProducer[MyTestMsg](failingCodec{}, handler)
+
+	assert.Error(t, producer.Push(context.Background(), MyTestMsg{}))
+	assert.Empty(t, handler.messages)
+}
+
+func TestDecodeEnvelope(t *testing.T) {
+	_, ok := decodeEnvelope([]byte(`{"id":1,"body":"bare"}`))
+	assert.False(t, ok)
+	_, ok = decodeEnvelope([]byte{0x08, 0x01})
+	assert.False(t, ok)
+
+	_, ok = EnvelopeFromContext(context.Background())
+	assert.False(t, ok)
+}
+
+func TestQueueServer_RestoresTrace(t *testing.T) {
+	_, client := newTestRedis(t)
+	conf := serverconf.QueueConfig{
+		QueueName:          "pq",
+		PoolSize:           2,
+		EmptySleepInterval: time.Millisecond,
+	}
+
+	type delivery struct {
+		env     Envelope
+		traceID string
+	}
+	deliveries := make(chan delivery, 2)
+	handler := NewJSONReliableRedisHandler(client, conf, func(ctx context.Context, _ MyTestMsg) error {
+		env, _ := EnvelopeFromContext(ctx)
+		traceID, _ := contextkeys.GetTraceID(ctx)
+		deliveries <- delivery{env: env, traceID: traceID}
+		return nil
+	})
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+
+	ctx := contextkeys.SetTraceID(context.Background(), "trace-1")
+	require.NoError(t, NewProducer[MyTestMsg](handler, handler).Push(ctx, MyTestMsg{ID: 1}, WithHeader("k", "v")))
+
+	// bare payloads pushed without a producer are still handled.
+	data, err := handler.Encode(MyTestMsg{ID: 2})
+	require.NoError(t, err)
+	require.NoError(t, handler.Push(ctx, data))
+
+	go qs.Start()
+	defer qs.Stop()
+
+	got := map[string]delivery{}
+	for i := 0; i < 2; i++ {
+		select {
+		case d := <-deliveries:
+			got[d.env.ID] = d
+		case <-time.After(time.Second):
+			t.Fatal("message not handled")
+		}
+	}
+
+	bare, ok := got[""]
+	require.True(t, ok)
+	assert.Empty(t, bare.traceID)
+
+	delete(got, "")
+	require.Len(t, got, 1)
+	for _, d := range got {
+		assert.Equal(t, "trace-1", d.traceID)
+		assert.Equal(t, "v", d.env.Headers["k"])
+		assert.Equal(t, 1, d.env.Attempt)
+	}
+}
+
+func TestQueueServer_EnvelopeDeadLetter(t *testing.T) {
+	_, client := newTestRedis(t)
+	handler := &handleErrorHandler{}
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "test"}, handler)
+	require.NoError(t, err)
+	dlq := NewRedisDeadLetterQueue(client, "test")
+	qs.WithDeadLetterQueue(dlq)
+
+	ctx := contextkeys.SetTraceID(context.Background(), "trace-1")
+	require.NoError(t, NewProducer[MyTestMsg](handler, handler).Push(ctx, MyTestMsg{ID: 1}))
+
+	require.NoError(t, qs.runOnce(context.Background()))
+	require.Eventually(t, func() bool {
+		n, _ := dlq.Len(context.Background())
+		return n == 1
+	}, time.Second, 5*time.Millisecond)
+
+	letters, err := dlq.List(context.Background(), 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "trace-1", letters[0].TraceID)
+	_, ok := decodeEnvelope(letters[0].Payload)
+	assert.True(t, ok)
+}
+
+func TestProducer_Proto(t *testing.T) {
+	handler := newTestProtoMemoryHandler(t)
+	producer := NewProducer[*test.TestMsg](handler, handler)
+	ctx := context.Background()
+
+	msg := &test.TestMsg{Id: 1, Name: "Alice"}
+	require.NoError(t, producer.Push(ctx, msg))
+
+	data, err := handler.Pop(ctx)
+	require.NoError(t, err)
+	env, ok := decodeEnvelope(data)
+	require.True(t, ok)
+
+	decoded, err := handler.Decode(env.Payload)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(msg, decoded))
+}
+
+func newTestProtoMemoryHandler(t *testing.T) *ProtoMemoryHandler[*test.TestMsg] {
+	t.Helper()
+	handler, err := NewProtoMemoryHandler(serverconf.QueueConfig{}, nil, func() *test.TestMsg {
+		return &test.TestMsg{}
+	})
+	require.NoError(t, err)
+	return handler
+}
+
+type failingCodec struct{}
+
+func (failingCodec) Encode(MyTestMsg) ([]byte, error) {
+	return nil, errors.New("encode failed")
+}
+
+func (failingCodec) Decode([]byte) (MyTestMsg, error) {
+	return MyTestMsg{}, errors.New("decode failed")
+}
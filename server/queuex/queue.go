@@ -6,21 +6,40 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"sync"
 	"time"
 
 	"github.com/hewen/mastiff-go/config/serverconf"
 	"github.com/hewen/mastiff-go/logger"
+	"github.com/hewen/mastiff-go/middleware/metrics"
+	"github.com/hewen/mastiff-go/pkg/contextkeys"
 	"github.com/panjf2000/ants/v2"
+	"golang.org/x/time/rate"
 	"google.golang.org/protobuf/proto"
 )
 
 const (
 	// DefaultQueueGoroutinePoolSize is the default size of the goroutine pool for processing queue messages.
 	DefaultQueueGoroutinePoolSize = 1000
+	// DefaultMaxAttempts is the default maximum number of deliveries of a message.
+	DefaultMaxAttempts = 5
+	// DefaultRetryBackoff is the default delay before the first retry of a failed message.
+	DefaultRetryBackoff = 1 * time.Second
+	// DefaultRetryMaxBackoff is the default upper bound of the retry delay.
+	DefaultRetryMaxBackoff = 1 * time.Minute
+	// DefaultRecoverInterval is the default interval for re-queueing expired and due messages.
+	DefaultRecoverInterval = 1 * time.Second
+	// DefaultDrainTimeout is the default duration Stop waits for in-flight messages to be handled.
+	DefaultDrainTimeout = 30 * time.Second
 )
 
-// ErrEmptyQueueName is returned when the queue name is empty.
-var ErrEmptyQueueName = errors.New("queue name is empty")
+var (
+	// ErrEmptyQueueName is returned when the queue name is empty.
+	ErrEmptyQueueName = errors.New("queue name is empty")
+	// ErrServerStopped is reported by the health check of a stopped server.
+	ErrServerStopped = errors.New("queue server is stopped")
+)
 
 // QueueMessage is an interface for queue messages.
 type QueueMessage any
@@ -28,12 +47,41 @@ type QueueMessage any
 // QueueServer is a queue server that processes messages from a queue using a goroutine pool. It is used to provide a queue server implementation.
 type QueueServer[T any] struct {
 	handler            ServerHandler[T]
+	handle             Handler[T]
+	middlewares        []Middleware[T]
+	batchHandler       BatchHandler[T]
+	handleBatch        Handler[[]T]
+	batchMiddlewares   []Middleware[[]T]
+	ackQueue           AckQueue
+	sourceQueue        SourceQueue
+	delayedQueue       DelayedQueue
+	deadLetters        DeadLetterQueue
+	idempotency        IdempotencyStore
+	limiter            RateLimiter
 	logger             logger.Logger
 	pool               *ants.Pool
+	ctx                context.Context
+	cancel             context.CancelFunc
 	done               chan struct{}
+	stopped            chan struct{}
+	slots              chan struct{}
+	inflight           map[uint64]popped
 	name               string
 	EmptySleepInterval time.Duration
 	poolSize           int
+	maxAttempts        int
+	retryBackoff       time.Duration
+	retryMaxBackoff    time.Duration
+	recoverInterval    time.Duration
+	batchSize          int
+	batchMaxWait       time.Duration
+	drainTimeout       time.Duration
+	metricsInterval    time.Duration
+	nextInflightID     uint64
+	running            sync.WaitGroup
+	payloadKeys        bool
+	token              bool
+	mu                 sync.Mutex
 }
 
 // Codec interface: encode/decode message. It is used to provide a codec for queue messages.
@@ -48,6 +96,60 @@ type Queue interface {
 	Pop(ctx context.Context) ([]byte, error)
 }
 
+// AckQueue interface: a Queue with at-least-once delivery. Popped messages stay in-flight
+// until they are acknowledged or retried, and are re-queued by Recover once their
+// visibility timeout expires. QueueServer enables ack mode when its handler implements it.
+type AckQueue interface {
+	Queue
+	// Ack marks a message as handled.
+	Ack(ctx context.Context, data []byte) error
+	// Retry re-queues a message after the delay.
+	Retry(ctx context.Context, data []byte, delay time.Duration) error
+	// Attempts returns how many times the message has been delivered.
+	Attempts(ctx context.Context, data []byte) (int, error)
+	// Recover re-queues expired in-flight messages and due retries.
+	Recover(ctx context.Context) (int, error)
+}
+
+// SourceQueue interface: a Queue made of several sources, such as the levels of a priority
+// queue, that reports the source each message was popped from. QueueServer pushes unhandled
+// messages back to their source when draining times out.
+type SourceQueue interface {
+	Queue
+	// PopSource pops a message like Pop and returns the source it was popped from.
+	PopSource(ctx context.Context) ([]byte, string, error)
+	// PopBatchSource pops up to n messages without blocking and returns the sources they were popped from.
+	PopBatchSource(ctx context.Context, n int) ([][]byte, []string, error)
+	// PushSource pushes a message back to the source it was popped from.
+	PushSource(ctx context.Context, data []byte, source string) error
+}
+
+// sourceNamer is implemented by queues whose sources are queues of their own, such as
+// MultiQueue. QueueServer labels the metrics, logs and dead letters of their messages with
+// the name of the queue they were popped from rather than its own.
+type sourceNamer interface {
+	sourceName(source string) string
+}
+
+// SourceCodec interface: a Codec of a SourceQueue decoding messages according to the source
+// they were popped from. QueueServer decodes with it when its handler implements it.
+type SourceCodec[T any] interface {
+	// DecodeSource decodes a message popped from source.
+	DecodeSource(source string, data []byte) (T, error)
+}
+
+// DelayedQueue interface: a Queue that accepts messages to be handled later. QueueServer
+// periodically promotes due messages when its handler implements it.
+type DelayedQueue interface {
+	Queue
+	// PushDelayed adds a message to the queue once the delay has elapsed.
+	PushDelayed(ctx context.Context, data []byte, delay time.Duration) error
+	// PushAt adds a message to the queue at the given time.
+	PushAt(ctx context.Context, data []byte, at time.Time) error
+	// PromoteDue moves due messages to the queue.
+	PromoteDue(ctx context.Context) (int, error)
+}
+
 // Handler interface: handle decoded message. It is used to provide a handler for queue messages.
 type Handler[T any] interface {
 	Handle(ctx context.Context, msg T) error
@@ -104,6 +206,33 @@ func NewQueueServer[T any](conf serverconf.QueueConfig, handler ServerHandler[T]
 	if conf.EmptySleepInterval <= 0 {
 		conf.EmptySleepInterval = 10 * time.Millisecond
 	}
+	if conf.MaxAttempts <= 0 {
+		conf.MaxAttempts = DefaultMaxAttempts
+	}
+	if conf.RetryBackoff <= 0 {
+		conf.RetryBackoff = DefaultRetryBackoff
+	}
+	if conf.RetryMaxBackoff < conf.RetryBackoff {
+		conf.RetryMaxBackoff = max(DefaultRetryMaxBackoff, conf.RetryBackoff)
+	}
+	if conf.RecoverInterval <= 0 {
+		conf.RecoverInterval = DefaultRecoverInterval
+	}
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = DefaultBatchSize
+	}
+	if conf.BatchMaxWait <= 0 {
+		conf.BatchMaxWait = DefaultBatchMaxWait
+	}
+	if conf.DrainTimeout <= 0 {
+		conf.DrainTimeout = DefaultDrainTimeout
+	}
+	if conf.MetricsInterval <= 0 {
+		conf.MetricsInterval = DefaultMetricsInterval
+	}
+	if conf.RateBurst <= 0 {
+		conf.RateBurst = DefaultRateBurst
+	}
 
 	log := logger.NewLogger()
 	log.Infof("init goroutine pool size: %d", conf.PoolSize)
@@ -113,50 +242,152 @@ func NewQueueServer[T any](conf serverconf.QueueConfig, handler ServerHandler[T]
 		return nil, err
 	}
 
+	ackQueue, _ := handler.(AckQueue)
+	sourceQueue, _ := handler.(SourceQueue)
+	delayedQueue, _ := handler.(DelayedQueue)
+	batchHandler, _ := handler.(BatchHandler[T])
+	var handleBatch Handler[[]T]
+	if batchHandler != nil {
+		handleBatch = HandlerFunc[[]T](batchHandler.HandleBatch)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var limiter RateLimiter
+	if conf.RateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(conf.RateLimit), conf.RateBurst)
+	}
+	var slots chan struct{}
+	if conf.MaxConcurrency > 0 {
+		slots = make(chan struct{}, conf.MaxConcurrency)
+	}
+
 	return &QueueServer[T]{
 		name:               conf.QueueName,
+		ctx:                ctx,
+		cancel:             cancel,
 		done:               make(chan struct{}, 1),
+		inflight:           make(map[uint64]popped),
+		slots:              slots,
 		pool:               pool,
+		limiter:            limiter,
 		handler:            handler,
+		handle:             handler,
+		batchHandler:       batchHandler,
+		handleBatch:        handleBatch,
+		ackQueue:           ackQueue,
+		sourceQueue:        sourceQueue,
+		delayedQueue:       delayedQueue,
 		logger:             log,
 		poolSize:           conf.PoolSize,
 		EmptySleepInterval: conf.EmptySleepInterval,
+		maxAttempts:        conf.MaxAttempts,
+		retryBackoff:       conf.RetryBackoff,
+		retryMaxBackoff:    conf.RetryMaxBackoff,
+		recoverInterval:    conf.RecoverInterval,
+		batchSize:          conf.BatchSize,
+		batchMaxWait:       conf.BatchMaxWait,
+		drainTimeout:       conf.DrainTimeout,
+		metricsInterval:    conf.MetricsInterval,
 	}, nil
 }
 
-// Start begins the queue server, processing messages at regular intervals.
+// Start begins the queue server, processing messages at regular intervals. Handlers receive
+// a context that is cancelled when Stop gives up draining.
 func (qs *QueueServer[T]) Start() {
-	ctx := context.Background()
+	qs.mu.Lock()
+	select {
+	case <-qs.done:
+		qs.mu.Unlock()
+		return
+	default:
+	}
+	qs.stopped = make(chan struct{})
+	qs.mu.Unlock()
+	defer close(qs.stopped)
+
+	if qs.ackQueue != nil || qs.delayedQueue != nil {
+		go qs.recoverLoop(qs.ctx)
+	}
+	go qs.metricsLoop(qs.ctx)
+
 	for {
 		select {
 		case <-qs.done:
 			return
 		default:
-			if err := qs.runOnce(ctx); err != nil {
+			if err := qs.runOnce(qs.ctx); err != nil {
 				qs.logger.Errorf("error: %v", err)
 			}
 		}
 	}
 }
 
-// Stop stops the queue server and releases resources.
+// Stop stops popping messages, drains in-flight messages and releases resources.
 func (qs *QueueServer[T]) Stop() {
+	qs.mu.Lock()
 	select {
 	case <-qs.done:
+		qs.mu.Unlock()
 		return
 	default:
 		close(qs.done)
 	}
+	qs.mu.Unlock()
 
 	qs.ShutdownWait()
+
+	// queues holding process-local state, such as MemoryQueue, release it once handlers are done.
+	if closer, ok := qs.handler.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			qs.logger.Errorf("[queue:%s] close queue failed: %v", qs.name, err)
+		}
+	}
 }
 
-// ShutdownWait waits for all goroutines to finish and releases the pool.
+// ShutdownWait waits up to DrainTimeout for the message being popped and the in-flight
+// messages to be handled, then pushes the unfinished ones back to the queue, cancels the
+// handler context and releases the pool.
 func (qs *QueueServer[T]) ShutdownWait() {
-	for qs.pool.Running() > 0 {
-		time.Sleep(10 * time.Millisecond)
+	qs.mu.Lock()
+	stopped := qs.stopped
+	qs.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		// nothing is submitted once the loop has returned, so waiting on running is safe.
+		if stopped != nil {
+			<-stopped
+		}
+		qs.running.Wait()
+		close(drained)
+	}()
+
+	timer := time.NewTimer(qs.drainTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-drained:
+	case <-timer.C:
+		qs.logger.Errorf("[queue:%s] drain timed out after %s", qs.name, qs.drainTimeout)
+		qs.requeueInflight()
 	}
+
+	qs.cancel()
 	qs.pool.Release()
+
+	// a message popped after draining timed out is pushed back when the closed pool refuses it.
+	if stopped != nil {
+		<-stopped
+	}
+}
+
+// queueName returns the name labelling a message popped from source: the name of its queue
+// when the queue is a sourceNamer, the name of the server otherwise.
+func (qs *QueueServer[T]) queueName(source string) string {
+	if namer, ok := qs.handler.(sourceNamer); ok && source != "" {
+		return namer.sourceName(source)
+	}
+	return qs.name
 }
 
 // Name returns the name of the queue server.
@@ -164,6 +395,22 @@ func (qs *QueueServer[T]) Name() string {
 	return fmt.Sprintf("queue server (%s)", qs.name)
 }
 
+// Check implements health.Checker. It fails once the server is stopped, or when the queue
+// cannot report its depth if it implements DepthQueue.
+func (qs *QueueServer[T]) Check(ctx context.Context) error {
+	select {
+	case <-qs.done:
+		return ErrServerStopped
+	default:
+	}
+
+	if dq, ok := qs.handler.(DepthQueue); ok {
+		_, err := dq.Len(ctx)
+		return err
+	}
+	return nil
+}
+
 // WithLogger sets the logger for the queue server.
 func (qs *QueueServer[T]) WithLogger(l logger.Logger) {
 	if l != nil {
@@ -171,31 +418,227 @@ func (qs *QueueServer[T]) WithLogger(l logger.Logger) {
 	}
 }
 
-// runOnce retrieves a message from the queue and processes it using the goroutine pool.
+// WithDeadLetterQueue sets the dead-letter queue that captures messages which fail decoding or exhaust their attempts.
+func (qs *QueueServer[T]) WithDeadLetterQueue(dlq DeadLetterQueue) {
+	qs.deadLetters = dlq
+}
+
+// runOnce retrieves a message, or a batch of messages for batch handlers, and processes it
+// using the goroutine pool once a concurrency slot is free.
 func (qs *QueueServer[T]) runOnce(ctx context.Context) error {
-	data, err := qs.handler.Pop(ctx)
+	if !qs.acquireSlot() {
+		return nil
+	}
+
+	var submitted bool
+	var err error
+	if qs.batchHandler != nil {
+		submitted, err = qs.runBatchOnce(ctx)
+	} else {
+		submitted, err = qs.runSingleOnce(ctx)
+	}
+	if !submitted {
+		qs.releaseSlot()
+	}
+	return err
+}
+
+// runSingleOnce retrieves a message from the queue and processes it using the goroutine pool,
+// reporting whether it was submitted.
+func (qs *QueueServer[T]) runSingleOnce(ctx context.Context) (bool, error) {
+	if !qs.throttle(ctx) {
+		return false, nil
+	}
+
+	msgs, err := qs.pop(ctx)
 	if err != nil {
-		return fmt.Errorf("pop failed: %w", err)
+		return false, fmt.Errorf("pop failed: %w", err)
 	}
-	if len(data) == 0 {
+	if len(msgs.data) == 0 {
 		time.Sleep(qs.EmptySleepInterval)
-		return nil
+		return false, nil
 	}
+	qs.token = false
+	data, source := msgs.data[0], msgs.source(0)
+	queue := qs.queueName(source)
+	metrics.QueuePopped.WithLabelValues(queue).Inc()
 
-	msg, err := qs.handler.Decode(data)
+	ctx, payload, key := qs.unwrap(ctx, data)
+	msg, err := qs.decode(payload, source)
 	if err != nil {
-		return fmt.Errorf("decode failed: %w", err)
+		// a message that cannot be decoded will never succeed, so it is not retried.
+		metrics.QueueDecodeErrors.WithLabelValues(queue).Inc()
+		qs.deadLetter(ctx, data, source, err)
+		qs.ack(ctx, data)
+		return false, fmt.Errorf("[queue:%s] decode failed: %w", queue, err)
+	}
+
+	if !qs.claim(ctx, data, key, source) {
+		return false, nil
 	}
 
+	id := qs.track(msgs)
 	err = qs.pool.Submit(func() {
-		if handleErr := qs.handler.Handle(ctx, msg); handleErr != nil {
-			qs.logger.Errorf("[queue:%s] failed to handle message: %v", qs.name, handleErr)
+		defer qs.running.Done()
+		defer qs.releaseSlot()
+		start := time.Now()
+		handleErr := qs.handle.Handle(ctx, msg)
+		qs.settleClaim(ctx, key, handleErr)
+		if handleErr != nil {
+			qs.observeHandled(queue, start, 0, 1)
+		} else {
+			qs.observeHandled(queue, start, 1, 0)
+		}
+
+		if _, ok := qs.untrack(id); !ok {
+			// the message was pushed back to the queue when draining timed out.
+			return
+		}
+
+		if handleErr != nil {
+			qs.logger.Errorf("[queue:%s] failed to handle message: %v", queue, handleErr)
+			qs.retry(ctx, data, source, handleErr)
+		} else {
+			qs.ack(ctx, data)
 		}
-		qs.logger.Infof("submit to pool success => [queue: %s, cap: %d, running: %d, free: %d]", qs.name, qs.pool.Cap(), qs.pool.Running(), qs.pool.Free())
+		qs.logger.Debugf("submit to pool success => [queue: %s, cap: %d, running: %d, free: %d]", qs.name, qs.pool.Cap(), qs.pool.Running(), qs.pool.Free())
 	})
 	if err != nil {
-		qs.logger.Errorf("submit to goroutine pool failed: %v", err)
+		qs.settleClaim(ctx, key, err)
+		if qs.submitFailed(id, err) {
+			qs.retry(ctx, data, source, err)
+		}
+		return false, nil
 	}
 
-	return nil
+	return true, nil
+}
+
+// pop pops a message, with the source it was popped from when the queue is a SourceQueue.
+func (qs *QueueServer[T]) pop(ctx context.Context) (popped, error) {
+	if qs.sourceQueue != nil {
+		data, source, err := qs.sourceQueue.PopSource(ctx)
+		if err != nil || len(data) == 0 {
+			return popped{}, err
+		}
+		return popped{data: [][]byte{data}, sources: []string{source}}, nil
+	}
+
+	data, err := qs.handler.Pop(ctx)
+	if err != nil || len(data) == 0 {
+		return popped{}, err
+	}
+	return popped{data: [][]byte{data}}, nil
+}
+
+// decode decodes a payload popped from source, with the SourceCodec of the handler if it has one.
+func (qs *QueueServer[T]) decode(payload []byte, source string) (T, error) {
+	if sc, ok := qs.handler.(SourceCodec[T]); ok && qs.sourceQueue != nil {
+		return sc.DecodeSource(source, payload)
+	}
+	return qs.handler.Decode(payload)
+}
+
+// ack acknowledges a message when the queue supports acknowledgement.
+func (qs *QueueServer[T]) ack(ctx context.Context, data []byte) {
+	if qs.ackQueue == nil {
+		return
+	}
+	if err := qs.ackQueue.Ack(ctx, data); err != nil {
+		qs.logger.Errorf("[queue:%s] ack failed: %v", qs.name, err)
+	}
+}
+
+// retry schedules a failed message for redelivery with exponential backoff, dead-lettering it once MaxAttempts is reached.
+func (qs *QueueServer[T]) retry(ctx context.Context, data []byte, source string, cause error) {
+	queue := qs.queueName(source)
+	if qs.ackQueue == nil {
+		qs.deadLetter(ctx, data, source, cause)
+		return
+	}
+
+	attempts, err := qs.ackQueue.Attempts(ctx, data)
+	if err != nil {
+		qs.logger.Errorf("[queue:%s] get attempts failed: %v", queue, err)
+		return
+	}
+	if attempts >= qs.maxAttempts {
+		qs.logger.Errorf("[queue:%s] message gave up after %d attempts", queue, attempts)
+		qs.deadLetter(ctx, data, source, cause)
+		qs.ack(ctx, data)
+		return
+	}
+
+	if err := qs.ackQueue.Retry(ctx, data, qs.backoff(attempts)); err != nil {
+		qs.logger.Errorf("[queue:%s] retry failed: %v", queue, err)
+	}
+}
+
+// deadLetter moves a failed message popped from source to the dead-letter queue, if one is set.
+func (qs *QueueServer[T]) deadLetter(ctx context.Context, data []byte, source string, cause error) {
+	if qs.deadLetters == nil {
+		return
+	}
+
+	letter := DeadLetter{
+		Queue:    qs.queueName(source),
+		Source:   source,
+		Payload:  data,
+		Error:    cause.Error(),
+		Attempts: 1,
+		FailedAt: time.Now(),
+	}
+	letter.TraceID, _ = contextkeys.GetTraceID(ctx)
+	if qs.ackQueue != nil {
+		if attempts, err := qs.ackQueue.Attempts(ctx, data); err == nil && attempts > 0 {
+			letter.Attempts = attempts
+		}
+	}
+
+	if err := qs.deadLetters.Add(ctx, letter); err != nil {
+		qs.logger.Errorf("[queue:%s] add dead letter failed: %v", letter.Queue, err)
+	}
+}
+
+// backoff returns the retry delay after the given number of attempts.
+func (qs *QueueServer[T]) backoff(attempts int) time.Duration {
+	delay := qs.retryBackoff
+	for i := 1; i < attempts && delay < qs.retryMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, qs.retryMaxBackoff)
+}
+
+// recoverLoop periodically re-queues expired in-flight messages and due delayed messages until the server stops.
+func (qs *QueueServer[T]) recoverLoop(ctx context.Context) {
+	ticker := time.NewTicker(qs.recoverInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-qs.done:
+			return
+		case <-ticker.C:
+			qs.recoverOnce(ctx)
+		}
+	}
+}
+
+// recoverOnce re-queues expired in-flight messages and due delayed messages.
+func (qs *QueueServer[T]) recoverOnce(ctx context.Context) {
+	var n int
+	var err error
+	if qs.ackQueue != nil {
+		n, err = qs.ackQueue.Recover(ctx)
+	} else {
+		n, err = qs.delayedQueue.PromoteDue(ctx)
+	}
+
+	if err != nil {
+		qs.logger.Errorf("[queue:%s] recover failed: %v", qs.name, err)
+		return
+	}
+	if n > 0 {
+		qs.logger.Infof("[queue:%s] recovered %d messages", qs.name, n)
+	}
 }
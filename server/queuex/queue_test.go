@@ -299,3 +299,18 @@ func TestQueueServer_DefaultConfig(t *testing.T) {
 	}, handler)
 	require.Nil(t, err)
 }
+
+func TestQueueServer_Backoff(t *testing.T) {
+	s, err := NewQueueServer(serverconf.QueueConfig{
+		QueueName:       "test",
+		RetryBackoff:    10 * time.Millisecond,
+		RetryMaxBackoff: 50 * time.Millisecond,
+	}, &mockQueueHandler{})
+	require.NoError(t, err)
+
+	assert.Equal(t, 10*time.Millisecond, s.backoff(1))
+	assert.Equal(t, 20*time.Millisecond, s.backoff(2))
+	assert.Equal(t, 40*time.Millisecond, s.backoff(3))
+	assert.Equal(t, 50*time.Millisecond, s.backoff(4))
+	assert.Equal(t, 50*time.Millisecond, s.backoff(100))
+}
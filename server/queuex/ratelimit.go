@@ -0,0 +1,152 @@
+package queuex
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/hewen/mastiff-go/config/serverconf"
+)
+
+const (
+	// DefaultRateBurst is the default number of messages handled at once above the rate limit.
+	DefaultRateBurst = 1
+)
+
+// RateLimiter interface: throttles how fast messages are handled. *rate.Limiter implements it.
+type RateLimiter interface {
+	// Wait blocks until a message may be handled.
+	Wait(ctx context.Context) error
+}
+
+// tokenBucketScript refills a token bucket for the elapsed time and takes a token from it,
+// returning 0 if one was taken, or how many milliseconds to wait for the next one.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return wait
+`)
+
+// RedisRateLimiter implements RateLimiter using a token bucket stored in Redis, so replicas
+// consuming the same queue share one rate limit. Buckets are refilled using the clocks of the
+// replicas, which are assumed to be roughly in sync.
+type RedisRateLimiter struct {
+	client *redis.Client
+	key    string
+	rate   float64
+	burst  int
+}
+
+// NewRedisRateLimiter creates a new RedisRateLimiter instance limited to RateLimit messages per
+// second with bursts of RateBurst messages.
+func NewRedisRateLimiter(client *redis.Client, conf serverconf.QueueConfig) RedisRateLimiter {
+	if conf.RateBurst <= 0 {
+		conf.RateBurst = DefaultRateBurst
+	}
+
+	return RedisRateLimiter{
+		client: client,
+		key:    conf.QueueName + ":ratelimit",
+		rate:   conf.RateLimit,
+		burst:  conf.RateBurst,
+	}
+}
+
+// Wait blocks until a token is taken from the shared bucket. A limiter without a positive
+// rate does not throttle.
+func (r RedisRateLimiter) Wait(ctx context.Context) error {
+	if r.rate <= 0 {
+		return nil
+	}
+
+	rate := strconv.FormatFloat(r.rate, 'f', -1, 64)
+	for {
+		wait, err := tokenBucketScript.Run(r.client, []string{r.key}, rate, r.burst, time.Now().UnixMilli()).Int64()
+		if err != nil {
+			return err
+		}
+		if wait == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(time.Duration(wait) * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// WithRateLimiter sets the limiter throttling how fast messages are handled, replacing the one
+// created from RateLimit. Use a RedisRateLimiter to share the limit between replicas.
+func (qs *QueueServer[T]) WithRateLimiter(l RateLimiter) {
+	qs.limiter = l
+}
+
+// acquireSlot waits until fewer than MaxConcurrency handlers are running, reporting false
+// when the server is stopping. Slots are taken before popping, so messages are not held
+// while waiting for one.
+func (qs *QueueServer[T]) acquireSlot() bool {
+	if qs.slots == nil {
+		return true
+	}
+
+	select {
+	case qs.slots <- struct{}{}:
+		return true
+	case <-qs.done:
+		return false
+	}
+}
+
+// releaseSlot releases a slot taken by acquireSlot.
+func (qs *QueueServer[T]) releaseSlot() {
+	if qs.slots != nil {
+		<-qs.slots
+	}
+}
+
+// throttle takes a token from the rate limiter before a message is popped, so no message is
+// held while waiting, reporting false when ctx is done or the server is stopping. The token
+// of a pop that found no message is kept for the next one. Messages are popped anyway if the
+// limiter fails, as stalling the queue is worse.
+func (qs *QueueServer[T]) throttle(ctx context.Context) bool {
+	if qs.limiter == nil || qs.token {
+		return true
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-qs.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := qs.limiter.Wait(ctx); err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+		qs.logger.Errorf("[queue:%s] rate limiter failed: %v", qs.name, err)
+	}
+	qs.token = true
+	return true
+}
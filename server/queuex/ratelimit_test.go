@@ -0,0 +1,228 @@
+package queuex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingRateLimiter struct {
+	err error
+}
+
+func (l failingRateLimiter) Wait(_ context.Context) error {
+	return l.err
+}
+
+func TestQueueServer_RateLimit(t *testing.T) {
+	conf := serverconf.QueueConfig{
+		QueueName:          "test",
+		EmptySleepInterval: time.Millisecond,
+		RateLimit:          20,
+	}
+
+	var mu sync.Mutex
+	var handled []time.Time
+	handler, err := NewJSONMemoryHandler(conf, func(_ context.Context, _ MyTestMsg) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, time.Now())
+		return nil
+	})
+	require.NoError(t, err)
+	producer := NewProducer[MyTestMsg](handler, handler)
+	for i := range 4 {
+		require.NoError(t, producer.Push(context.Background(), MyTestMsg{ID: i}))
+	}
+
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+	go qs.Start()
+	defer qs.Stop()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 4
+	}, time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	// a burst of 1 spaces messages by 50ms.
+	assert.GreaterOrEqual(t, handled[3].Sub(handled[0]), 140*time.Millisecond)
+}
+
+func TestQueueServer_MaxConcurrency(t *testing.T) {
+	conf := serverconf.QueueConfig{
+		QueueName:          "test",
+		PoolSize:           10,
+		EmptySleepInterval: time.Millisecond,
+		MaxConcurrency:     2,
+	}
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var running, maxRunning, handled int
+	handler, err := NewJSONMemoryHandler(conf, func(_ context.Context, _ MyTestMsg) error {
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mu.Unlock()
+
+		<-release
+
+		mu.Lock()
+		running--
+		handled++
+		mu.Unlock()
+		return nil
+	})
+	require.NoError(t, err)
+	producer := NewProducer[MyTestMsg](handler, handler)
+	for i := range 5 {
+		require.NoError(t, producer.Push(context.Background(), MyTestMsg{ID: i}))
+	}
+
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+	go qs.Start()
+	defer qs.Stop()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return running == 2
+	}, time.Second, 5*time.Millisecond)
+
+	// messages are not popped while every slot is taken.
+	time.Sleep(20 * time.Millisecond)
+	assertMemoryLen(t, handler.MemoryQueue, 3)
+
+	close(release)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled == 5
+	}, time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, maxRunning)
+}
+
+func TestQueueServer_MaxConcurrencyBatch(t *testing.T) {
+	handler := &mockBatchHandler{}
+	conf := serverconf.QueueConfig{QueueName: "test", BatchSize: 2, BatchMaxWait: time.Millisecond, MaxConcurrency: 1}
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := range 3 {
+		data, err := handler.Encode(MyTestMsg{ID: i})
+		require.NoError(t, err)
+		require.NoError(t, handler.Push(ctx, data))
+	}
+
+	require.NoError(t, qs.runOnce(ctx))
+	require.Eventually(t, func() bool {
+		return len(qs.slots) == 0
+	}, time.Second, time.Millisecond)
+
+	// an empty batch releases its slot.
+	require.NoError(t, qs.runOnce(ctx))
+	require.NoError(t, qs.runOnce(ctx))
+	qs.Stop()
+	assert.Empty(t, qs.slots)
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	assert.Len(t, handler.batches, 2)
+
+	// a stopped server does not wait for a slot.
+	qs.slots <- struct{}{}
+	assert.NoError(t, qs.runOnce(ctx))
+}
+
+// blockingRateLimiter never hands out a token.
+type blockingRateLimiter struct{}
+
+func (blockingRateLimiter) Wait(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestQueueServer_Throttle(t *testing.T) {
+	handler := &mockQueueHandler{}
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "test"}, handler)
+	require.NoError(t, err)
+	defer qs.Stop()
+
+	// messages are popped when the limiter fails.
+	qs.WithRateLimiter(failingRateLimiter{err: errors.New("redis down")})
+	assert.True(t, qs.throttle(context.Background()))
+
+	// the token of a pop that found no message is kept.
+	qs.WithRateLimiter(blockingRateLimiter{})
+	assert.True(t, qs.throttle(context.Background()))
+	qs.token = false
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, qs.throttle(ctx))
+}
+
+func TestQueueServer_ThrottleStop(t *testing.T) {
+	handler := &mockQueueHandler{}
+	qs, err := NewQueueServer(serverconf.QueueConfig{
+		QueueName:    "test",
+		DrainTimeout: 10 * time.Second,
+	}, handler)
+	require.NoError(t, err)
+	qs.WithRateLimiter(blockingRateLimiter{})
+	require.NoError(t, handler.Push(context.Background(), []byte(`{"id":1}`)))
+
+	go qs.Start()
+	time.Sleep(20 * time.Millisecond)
+
+	// the message is not popped while waiting for a token, and Stop does not wait for one.
+	start := time.Now()
+	qs.Stop()
+	assert.Less(t, time.Since(start), time.Second)
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	assert.Zero(t, handler.popIndex)
+}
+
+func TestRedisRateLimiter(t *testing.T) {
+	mr, client := newTestRedis(t)
+	conf := serverconf.QueueConfig{QueueName: "rq", RateLimit: 20, RateBurst: 2}
+	// replicas share the bucket.
+	a := NewRedisRateLimiter(client, conf)
+	b := NewRedisRateLimiter(client, conf)
+	ctx := context.Background()
+
+	start := time.Now()
+	require.NoError(t, a.Wait(ctx))
+	require.NoError(t, b.Wait(ctx))
+	assert.Less(t, time.Since(start), 40*time.Millisecond)
+	assert.True(t, mr.Exists("rq:ratelimit"))
+
+	require.NoError(t, a.Wait(ctx))
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, b.Wait(cancelled), context.Canceled)
+
+	unlimited := NewRedisRateLimiter(client, serverconf.QueueConfig{QueueName: "rq"})
+	assert.NoError(t, unlimited.Wait(cancelled))
+
+	mr.Close()
+	assert.Error(t, a.Wait(ctx))
+}
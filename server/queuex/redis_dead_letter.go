@@ -0,0 +1,166 @@
+package queuex
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+// RedisDeadLetterQueue implements DeadLetterQueue using Redis. Dead letters are stored as JSON
+// in a hash, indexed by failure time in a sorted set.
+type RedisDeadLetterQueue struct {
+	client *redis.Client
+	target Queue
+	key    string
+}
+
+// NewRedisDeadLetterQueue creates a new RedisDeadLetterQueue for the queue. Replayed messages
+// are pushed back to the queue with RedisQueue semantics, unless another queue is set with
+// WithTarget.
+func NewRedisDeadLetterQueue(client *redis.Client, queueName string) *RedisDeadLetterQueue {
+	return &RedisDeadLetterQueue{
+		client: client,
+		target: NewRedisQueue(client, queueName),
+		key:    queueName + ":dead",
+	}
+}
+
+// WithTarget sets the queue replayed messages are pushed back to, such as the
+// RedisStreamQueue, PriorityRedisQueue or MultiQueue the dead letters come from.
+func (d *RedisDeadLetterQueue) WithTarget(target Queue) {
+	if target != nil {
+		d.target = target
+	}
+}
+
+// Add stores a dead letter.
+func (d *RedisDeadLetterQueue) Add(_ context.Context, letter DeadLetter) error {
+	if letter.ID == "" {
+		id, err := gonanoid.New()
+		if err != nil {
+			return err
+		}
+		letter.ID = id
+	}
+	if letter.FailedAt.IsZero() {
+		letter.FailedAt = time.Now()
+	}
+
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.HSet(d.key, letter.ID, data)
+		pipe.ZAdd(d.indexKey(), &redis.Z{Score: float64(letter.FailedAt.UnixMilli()), Member: letter.ID})
+		return nil
+	})
+	return err
+}
+
+// List returns dead letters ordered by failure time, oldest first.
+func (d *RedisDeadLetterQueue) List(_ context.Context, offset, limit int64) ([]DeadLetter, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	ids, err := d.client.ZRange(d.indexKey(), offset, offset+limit-1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	values, err := d.client.HMGet(d.key, ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]DeadLetter, 0, len(values))
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal([]byte(s), &letter); err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+// Get returns the dead letter with the given ID.
+func (d *RedisDeadLetterQueue) Get(_ context.Context, id string) (DeadLetter, error) {
+	var letter DeadLetter
+
+	data, err := d.client.HGet(d.key, id).Bytes()
+	if err == redis.Nil {
+		return letter, ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return letter, err
+	}
+
+	err = json.Unmarshal(data, &letter)
+	return letter, err
+}
+
+// Replay pushes the payload of the dead letter back to its queue, to the source it was popped
+// from when the queue is a SourceQueue, and removes the dead letter. The target may not be
+// stored in Redis, so the push and the removal are not atomic: a dead letter whose removal
+// fails, or that is replayed twice concurrently, is pushed again, and handlers must tolerate
+// duplicates.
+func (d *RedisDeadLetterQueue) Replay(ctx context.Context, id string) error {
+	letter, err := d.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if sq, ok := d.target.(SourceQueue); ok && letter.Source != "" {
+		err = sq.PushSource(ctx, letter.Payload, letter.Source)
+	} else {
+		err = d.target.Push(ctx, letter.Payload)
+	}
+	if err != nil {
+		return err
+	}
+	return d.Delete(ctx, id)
+}
+
+// Delete removes the dead letters with the given IDs.
+func (d *RedisDeadLetterQueue) Delete(_ context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	members := make([]any, len(ids))
+	for i := range ids {
+		members[i] = ids[i]
+	}
+
+	_, err := d.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.HDel(d.key, ids...)
+		pipe.ZRem(d.indexKey(), members...)
+		return nil
+	})
+	return err
+}
+
+// Purge removes all dead letters.
+func (d *RedisDeadLetterQueue) Purge(_ context.Context) error {
+	return d.client.Del(d.key, d.indexKey()).Err()
+}
+
+// Len returns the number of dead letters.
+func (d *RedisDeadLetterQueue) Len(_ context.Context) (int64, error) {
+	return d.client.ZCard(d.indexKey()).Result()
+}
+
+// indexKey returns the key of the sorted set indexing dead letters by failure time.
+func (d *RedisDeadLetterQueue) indexKey() string {
+	return d.key + ":index"
+}
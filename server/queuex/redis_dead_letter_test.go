@@ -0,0 +1,222 @@
+package queuex
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/pkg/contextkeys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisDeadLetterQueue(t *testing.T) {
+	_, client := newTestRedis(t)
+	dlq := NewRedisDeadLetterQueue(client, "dq")
+	ctx := context.Background()
+
+	require.NoError(t, dlq.Add(ctx, DeadLetter{Queue: "dq", Payload: []byte("first"), Error: "boom", Attempts: 3}))
+	require.NoError(t, dlq.Add(ctx, DeadLetter{Queue: "dq", Payload: []byte("second"), FailedAt: time.Now().Add(time.Second)}))
+
+	n, err := dlq.Len(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	letters, err := dlq.List(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+	assert.Equal(t, []byte("first"), letters[0].Payload)
+	assert.Equal(t, "boom", letters[0].Error)
+	assert.Equal(t, 3, letters[0].Attempts)
+	assert.NotEmpty(t, letters[0].ID)
+
+	letter, err := dlq.Get(ctx, letters[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second"), letter.Payload)
+
+	require.NoError(t, dlq.Replay(ctx, letter.ID))
+	assert.Equal(t, "second", client.LIndex("dq", 0).Val())
+
+	_, err = dlq.Get(ctx, letter.ID)
+	assert.Equal(t, ErrDeadLetterNotFound, err)
+	assert.Equal(t, ErrDeadLetterNotFound, dlq.Replay(ctx, letter.ID))
+
+	require.NoError(t, dlq.Delete(ctx, letters[0].ID))
+	n, err = dlq.Len(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.NoError(t, dlq.Delete(ctx))
+}
+
+func TestRedisDeadLetterQueue_ListEmpty(t *testing.T) {
+	_, client := newTestRedis(t)
+	dlq := NewRedisDeadLetterQueue(client, "dq")
+	ctx := context.Background()
+
+	letters, err := dlq.List(ctx, 0, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, letters)
+
+	letters, err = dlq.List(ctx, 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, letters)
+}
+
+func TestRedisDeadLetterQueue_Purge(t *testing.T) {
+	_, client := newTestRedis(t)
+	dlq := NewRedisDeadLetterQueue(client, "dq")
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, dlq.Add(ctx, DeadLetter{Payload: []byte("x")}))
+	}
+	require.NoError(t, dlq.Purge(ctx))
+
+	n, err := dlq.Len(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestRedisDeadLetterQueue_ReplayTarget(t *testing.T) {
+	_, client := newTestRedis(t)
+	ctx := context.Background()
+
+	multi := NewMultiQueue(client)
+	require.NoError(t, Subscribe[MyTestMsg](multi, "a", 1, JSONCodec[MyTestMsg]{}, HandlerFunc[MyTestMsg](nil)))
+	require.NoError(t, Subscribe[MyTestMsg](multi, "b", 1, JSONCodec[MyTestMsg]{}, HandlerFunc[MyTestMsg](nil)))
+	dlq := NewRedisDeadLetterQueue(client, "multi")
+	dlq.WithTarget(multi)
+	dlq.WithTarget(nil)
+
+	// a dead letter is replayed to the source it was popped from.
+	require.NoError(t, dlq.Add(ctx, DeadLetter{ID: "1", Queue: "multi", Source: "b", Payload: []byte(`{"id":1}`)}))
+	require.NoError(t, dlq.Replay(ctx, "1"))
+	assert.Equal(t, []string{`{"id":1}`}, client.LRange("b", 0, -1).Val())
+	assert.Zero(t, client.LLen("multi").Val())
+
+	// without a source, it is pushed to the target like any message.
+	require.NoError(t, dlq.Add(ctx, DeadLetter{ID: "2", Payload: []byte("x")}))
+	assert.ErrorIs(t, dlq.Replay(ctx, "2"), errors.ErrUnsupported)
+
+	stream := NewRedisStreamQueue(client, serverconf.QueueConfig{QueueName: "events"})
+	dlq = NewRedisDeadLetterQueue(client, "events")
+	dlq.WithTarget(stream)
+	require.NoError(t, dlq.Add(ctx, DeadLetter{ID: "3", Payload: []byte("event")}))
+	require.NoError(t, dlq.Replay(ctx, "3"))
+	assert.Equal(t, int64(1), client.XLen("events").Val())
+	n, err := dlq.Len(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestRedisDeadLetterQueue_Errors(t *testing.T) {
+	mr, client := newTestRedis(t)
+	dlq := NewRedisDeadLetterQueue(client, "dq")
+	ctx := context.Background()
+
+	require.NoError(t, client.HSet("dq:dead", "bad", "{").Err())
+	require.NoError(t, client.ZAdd("dq:dead:index", redisZ(1, "bad")).Err())
+
+	_, err := dlq.Get(ctx, "bad")
+	assert.Error(t, err)
+	_, err = dlq.List(ctx, 0, 10)
+	assert.Error(t, err)
+
+	mr.Close()
+	_, err = dlq.Get(ctx, "bad")
+	assert.Error(t, err)
+	_, err = dlq.List(ctx, 0, 10)
+	assert.Error(t, err)
+}
+
+func TestQueueServer_DeadLetterDecodeError(t *testing.T) {
+	_, client := newTestRedis(t)
+	dlq := NewRedisDeadLetterQueue(client, "test")
+
+	handler := &decodeErrorHandler{}
+	_ = handler.Push(context.Background(), []byte("invalid json"))
+
+	server, err := NewQueueServer(serverconf.QueueConfig{
+		QueueName:          "test",
+		PoolSize:           1,
+		EmptySleepInterval: time.Millisecond,
+	}, handler)
+	require.NoError(t, err)
+	server.WithDeadLetterQueue(dlq)
+
+	require.Error(t, server.runOnce(context.Background()))
+
+	letters, err := dlq.List(context.Background(), 0, 10)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, []byte("invalid json"), letters[0].Payload)
+	assert.Equal(t, "decode error", letters[0].Error)
+	assert.Equal(t, 1, letters[0].Attempts)
+}
+
+func TestQueueServer_DeadLetterHandleError(t *testing.T) {
+	_, client := newTestRedis(t)
+	dlq := NewRedisDeadLetterQueue(client, "test")
+
+	handler := &handleErrorHandler{}
+	data, _ := handler.Encode(MyTestMsg{ID: 1, Body: "fail"})
+	require.NoError(t, handler.Push(context.Background(), data))
+
+	server, err := NewQueueServer(serverconf.QueueConfig{
+		QueueName:          "test",
+		PoolSize:           1,
+		EmptySleepInterval: time.Millisecond,
+	}, handler)
+	require.NoError(t, err)
+	server.WithDeadLetterQueue(dlq)
+
+	ctx := contextkeys.SetTraceID(context.Background(), "trace-1")
+	require.NoError(t, server.runOnce(ctx))
+	server.Stop()
+
+	letters, err := dlq.List(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, "handle error", letters[0].Error)
+	assert.Equal(t, "trace-1", letters[0].TraceID)
+}
+
+func TestQueueServer_DeadLetterReliableGiveUp(t *testing.T) {
+	_, client := newTestRedis(t)
+	conf := serverconf.QueueConfig{
+		QueueName:          "rq",
+		PoolSize:           2,
+		EmptySleepInterval: time.Millisecond,
+		MaxAttempts:        2,
+		RetryBackoff:       time.Millisecond,
+		RecoverInterval:    5 * time.Millisecond,
+	}
+	handler := NewJSONReliableRedisHandler(client, conf, func(_ context.Context, _ MyTestMsg) error {
+		return errors.New("always fails")
+	})
+	dlq := NewRedisDeadLetterQueue(client, conf.QueueName)
+
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+	qs.WithDeadLetterQueue(dlq)
+
+	data, err := handler.Encode(MyTestMsg{ID: 1})
+	require.NoError(t, err)
+	require.NoError(t, handler.Push(context.Background(), data))
+
+	go qs.Start()
+	defer qs.Stop()
+
+	require.Eventually(t, func() bool {
+		n, _ := dlq.Len(context.Background())
+		return n == 1
+	}, 2*time.Second, 5*time.Millisecond)
+
+	letters, err := dlq.List(context.Background(), 0, 1)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, 2, letters[0].Attempts)
+	assert.Equal(t, "rq", letters[0].Queue)
+}
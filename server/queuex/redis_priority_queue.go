@@ -0,0 +1,269 @@
+package queuex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// DefaultPriorityLevels is the default number of priority levels of a PriorityRedisQueue.
+	DefaultPriorityLevels = 3
+)
+
+var (
+	// ErrInvalidPriority is returned when pushing with a priority outside the configured levels.
+	ErrInvalidPriority = errors.New("invalid priority")
+	// ErrInvalidPriorityWeights is returned when the priority weights do not match the priority levels.
+	ErrInvalidPriorityWeights = errors.New("priority weights must be one non-negative weight per level with a positive sum")
+)
+
+// PriorityQueue interface: a Queue that pops messages with a higher priority first.
+type PriorityQueue interface {
+	Queue
+	// PushWithPriority adds a message with the given priority, 0 being the highest.
+	PushWithPriority(ctx context.Context, data []byte, priority int) error
+}
+
+// PriorityRedisQueue implements PriorityQueue using one Redis list per priority level.
+//
+// Without weights, Pop always serves the highest non-empty level, so a steady stream of
+// urgent messages starves bulk ones. With PriorityWeights, each Pop first tries a level
+// picked with a probability proportional to its weight, falling back to the other levels
+// in priority order, so every level with a positive weight gets its share of pops.
+//
+// It implements SourceQueue, so QueueServer pushes unhandled messages back to their level.
+type PriorityRedisQueue struct {
+	client    *redis.Client
+	intn      func(n int) int
+	queueName string
+	weights   []int
+	total     int
+}
+
+// NewPriorityRedisQueue creates a new PriorityRedisQueue instance.
+func NewPriorityRedisQueue(client *redis.Client, conf serverconf.QueueConfig) (PriorityRedisQueue, error) {
+	if conf.PriorityLevels <= 0 {
+		conf.PriorityLevels = DefaultPriorityLevels
+		if len(conf.PriorityWeights) > 0 {
+			conf.PriorityLevels = len(conf.PriorityWeights)
+		}
+	}
+
+	var total int
+	if len(conf.PriorityWeights) > 0 {
+		if len(conf.PriorityWeights) != conf.PriorityLevels {
+			return PriorityRedisQueue{}, ErrInvalidPriorityWeights
+		}
+		for _, w := range conf.PriorityWeights {
+			if w < 0 {
+				return PriorityRedisQueue{}, ErrInvalidPriorityWeights
+			}
+			total += w
+		}
+		if total == 0 {
+			return PriorityRedisQueue{}, ErrInvalidPriorityWeights
+		}
+	}
+
+	weights := conf.PriorityWeights
+	if weights == nil {
+		weights = make([]int, conf.PriorityLevels)
+	}
+	return PriorityRedisQueue{
+		client:    client,
+		intn:      rand.IntN,
+		queueName: conf.QueueName,
+		weights:   weights,
+		total:     total,
+	}, nil
+}
+
+// Push adds a message with the lowest priority.
+func (r PriorityRedisQueue) Push(ctx context.Context, data []byte) error {
+	return r.PushWithPriority(ctx, data, len(r.weights)-1)
+}
+
+// PushWithPriority adds a message with the given priority, 0 being the highest.
+func (r PriorityRedisQueue) PushWithPriority(_ context.Context, data []byte, priority int) error {
+	if priority < 0 || priority >= len(r.weights) {
+		return fmt.Errorf("%w: %d not in [0, %d)", ErrInvalidPriority, priority, len(r.weights))
+	}
+	return r.client.LPush(r.levelKey(priority), data).Err()
+}
+
+// Pop retrieves a message from the first non-empty level in pop order.
+func (r PriorityRedisQueue) Pop(ctx context.Context) ([]byte, error) {
+	data, _, err := r.PopSource(ctx)
+	return data, err
+}
+
+// PopSource retrieves a message from the first non-empty level in pop order and returns the
+// key of its level.
+func (r PriorityRedisQueue) PopSource(_ context.Context) ([]byte, string, error) {
+	res, err := r.client.BLPop(1*time.Second, r.popOrder()...).Result()
+	if err != nil && err != redis.Nil {
+		return nil, "", err
+	}
+
+	if len(res) != 2 {
+		return nil, "", nil
+	}
+	return []byte(res[1]), res[0], nil
+}
+
+// PopBatch pops up to n messages without blocking, draining levels in pop order.
+func (r PriorityRedisQueue) PopBatch(ctx context.Context, n int) ([][]byte, error) {
+	batch, _, err := r.PopBatchSource(ctx, n)
+	return batch, err
+}
+
+// PopBatchSource pops up to n messages without blocking, draining levels in pop order, and
+// returns the keys of their levels.
+func (r PriorityRedisQueue) PopBatchSource(_ context.Context, n int) ([][]byte, []string, error) {
+	var batch [][]byte
+	var sources []string
+	for _, key := range r.popOrder() {
+		if len(batch) >= n {
+			break
+		}
+
+		var items *redis.StringSliceCmd
+		_, err := r.client.TxPipelined(func(pipe redis.Pipeliner) error {
+			items = pipe.LRange(key, 0, int64(n-len(batch)-1))
+			pipe.LTrim(key, int64(n-len(batch)), -1)
+			return nil
+		})
+		if err != nil {
+			return batch, sources, err
+		}
+		for _, item := range toBatch(items.Val()) {
+			batch = append(batch, item)
+			sources = append(sources, key)
+		}
+	}
+	return batch, sources, nil
+}
+
+// PushSource pushes a message back to the level it was popped from, given by its key.
+func (r PriorityRedisQueue) PushSource(_ context.Context, data []byte, source string) error {
+	for i := range r.weights {
+		if r.levelKey(i) == source {
+			return r.client.LPush(source, data).Err()
+		}
+	}
+	return fmt.Errorf("%w: unknown level %q", ErrInvalidPriority, source)
+}
+
+// Len returns the number of messages waiting across all levels.
+func (r PriorityRedisQueue) Len(_ context.Context) (int64, error) {
+	cmds := make([]*redis.IntCmd, len(r.weights))
+	_, err := r.client.Pipelined(func(pipe redis.Pipeliner) error {
+		for i := range cmds {
+			cmds[i] = pipe.LLen(r.levelKey(i))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	for _, cmd := range cmds {
+		n += cmd.Val()
+	}
+	return n, nil
+}
+
+// popOrder returns the level keys in the order they are popped from.
+func (r PriorityRedisQueue) popOrder() []string {
+	first := weightedPick(r.weights, r.total, r.intn)
+
+	keys := make([]string, 0, len(r.weights))
+	keys = append(keys, r.levelKey(first))
+	for i := range r.weights {
+		if i != first {
+			keys = append(keys, r.levelKey(i))
+		}
+	}
+	return keys
+}
+
+// weightedPick returns an index picked with a probability proportional to its weight, or 0
+// when the weights sum to total 0.
+func weightedPick(weights []int, total int, intn func(n int) int) int {
+	if total <= 0 {
+		return 0
+	}
+
+	pick := intn(total)
+	for i, w := range weights {
+		if pick < w {
+			return i
+		}
+		pick -= w
+	}
+	return len(weights) - 1
+}
+
+// levelKey returns the key of the list holding messages of the given priority.
+func (r PriorityRedisQueue) levelKey(priority int) string {
+	return fmt.Sprintf("%s:priority:%d", r.queueName, priority)
+}
+
+// JSONPriorityRedisHandler handles JSON messages in a priority Redis queue.
+type JSONPriorityRedisHandler[T any] struct {
+	HandlerFunc[T]
+	JSONCodec[T]
+	PriorityRedisQueue
+}
+
+// NewJSONPriorityRedisHandler creates a new JSONPriorityRedisHandler instance.
+func NewJSONPriorityRedisHandler[T any](
+	client *redis.Client,
+	conf serverconf.QueueConfig,
+	handlerFn func(ctx context.Context, msg T) error,
+) (*JSONPriorityRedisHandler[T], error) {
+	q, err := NewPriorityRedisQueue(client, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JSONPriorityRedisHandler[T]{
+		HandlerFunc:        handlerFn,
+		JSONCodec:          JSONCodec[T]{},
+		PriorityRedisQueue: q,
+	}, nil
+}
+
+// ProtoPriorityRedisHandler handles protobuf messages in a priority Redis queue.
+type ProtoPriorityRedisHandler[T proto.Message] struct {
+	HandlerFunc[T]
+	ProtoCodec[T]
+	PriorityRedisQueue
+}
+
+// NewProtoPriorityRedisHandler creates a new ProtoPriorityRedisHandler instance.
+func NewProtoPriorityRedisHandler[T proto.Message](
+	client *redis.Client,
+	conf serverconf.QueueConfig,
+	handlerFn func(ctx context.Context, msg T) error,
+	newMsgFn func() T,
+) (*ProtoPriorityRedisHandler[T], error) {
+	q, err := NewPriorityRedisQueue(client, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProtoPriorityRedisHandler[T]{
+		HandlerFunc:        handlerFn,
+		ProtoCodec:         ProtoCodec[T]{newMsg: newMsgFn},
+		PriorityRedisQueue: q,
+	}, nil
+}
@@ -0,0 +1,200 @@
+package queuex
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/server/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestPriorityRedisQueue_StrictOrder(t *testing.T) {
+	_, client := newTestRedis(t)
+	q, err := NewPriorityRedisQueue(client, serverconf.QueueConfig{QueueName: "pq"})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, q.Push(ctx, []byte("bulk")))
+	require.NoError(t, q.PushWithPriority(ctx, []byte("normal"), 1))
+	require.NoError(t, q.PushWithPriority(ctx, []byte("urgent"), 0))
+	assert.ErrorIs(t, q.PushWithPriority(ctx, []byte("x"), 3), ErrInvalidPriority)
+	assert.ErrorIs(t, q.PushWithPriority(ctx, []byte("x"), -1), ErrInvalidPriority)
+
+	n, err := q.Len(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	for _, expected := range []string{"urgent", "normal", "bulk"} {
+		data, err := q.Pop(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []byte(expected), data)
+	}
+}
+
+func TestPriorityRedisQueue_Weighted(t *testing.T) {
+	_, client := newTestRedis(t)
+	q, err := NewPriorityRedisQueue(client, serverconf.QueueConfig{QueueName: "pq", PriorityWeights: []int{3, 1}})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, q.PushWithPriority(ctx, []byte("urgent"), 0))
+		require.NoError(t, q.PushWithPriority(ctx, []byte("bulk"), 1))
+	}
+
+	// a pick in the share of the low level serves it first, without starving it.
+	q.intn = func(int) int { return 3 }
+	data, err := q.Pop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("bulk"), data)
+
+	q.intn = func(int) int { return 2 }
+	data, err = q.Pop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("urgent"), data)
+
+	q.intn = func(int) int { return 3 }
+	batch, err := q.PopBatch(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("bulk"), []byte("urgent")}, batch)
+
+	data, err = q.Pop(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, data)
+}
+
+func TestPriorityRedisQueue_Config(t *testing.T) {
+	_, client := newTestRedis(t)
+
+	q, err := NewPriorityRedisQueue(client, serverconf.QueueConfig{QueueName: "pq", PriorityWeights: []int{1, 1}})
+	require.NoError(t, err)
+	assert.Len(t, q.weights, 2)
+
+	q, err = NewPriorityRedisQueue(client, serverconf.QueueConfig{QueueName: "pq", PriorityLevels: 5})
+	require.NoError(t, err)
+	assert.Len(t, q.weights, 5)
+
+	for _, conf := range []serverconf.QueueConfig{
+		{PriorityLevels: 3, PriorityWeights: []int{1, 1}},
+		{PriorityWeights: []int{1, -1}},
+		{PriorityWeights: []int{0, 0}},
+	} {
+		_, err = NewPriorityRedisQueue(client, conf)
+		assert.ErrorIs(t, err, ErrInvalidPriorityWeights)
+	}
+
+	_, err = NewJSONPriorityRedisHandler[MyTestMsg](client, serverconf.QueueConfig{PriorityWeights: []int{0}}, nil)
+	assert.Error(t, err)
+	_, err = NewProtoPriorityRedisHandler(client, serverconf.QueueConfig{PriorityWeights: []int{0}}, nil, func() *test.TestMsg {
+		return &test.TestMsg{}
+	})
+	assert.Error(t, err)
+}
+
+func TestPriorityRedisQueue_Source(t *testing.T) {
+	_, client := newTestRedis(t)
+	q, err := NewPriorityRedisQueue(client, serverconf.QueueConfig{QueueName: "pq"})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, q.PushWithPriority(ctx, []byte("urgent"), 0))
+	require.NoError(t, q.Push(ctx, []byte("bulk")))
+
+	data, source, err := q.PopSource(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("urgent"), data)
+	assert.Equal(t, "pq:priority:0", source)
+	require.NoError(t, q.PushSource(ctx, data, source))
+
+	batch, sources, err := q.PopBatchSource(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("urgent"), []byte("bulk")}, batch)
+	assert.Equal(t, []string{"pq:priority:0", "pq:priority:2"}, sources)
+
+	assert.ErrorIs(t, q.PushSource(ctx, data, "other"), ErrInvalidPriority)
+}
+
+func TestPriorityRedisQueue_Errors(t *testing.T) {
+	mr, client := newTestRedis(t)
+	q, err := NewPriorityRedisQueue(client, serverconf.QueueConfig{QueueName: "pq"})
+	require.NoError(t, err)
+	ctx := context.Background()
+	mr.Close()
+
+	_, err = q.Pop(ctx)
+	assert.Error(t, err)
+	_, err = q.PopBatch(ctx, 2)
+	assert.Error(t, err)
+	_, err = q.Len(ctx)
+	assert.Error(t, err)
+}
+
+func TestQueueServer_Priority(t *testing.T) {
+	_, client := newTestRedis(t)
+	conf := serverconf.QueueConfig{
+		QueueName:          "pq",
+		PoolSize:           1,
+		EmptySleepInterval: time.Millisecond,
+	}
+
+	var mu sync.Mutex
+	var handled []int
+	handler, err := NewJSONPriorityRedisHandler(client, conf, func(_ context.Context, msg MyTestMsg) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, msg.ID)
+		return nil
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	producer := NewProducer[MyTestMsg](handler, handler)
+	require.NoError(t, producer.Push(ctx, MyTestMsg{ID: 3}))
+	require.NoError(t, producer.PushWithPriority(ctx, MyTestMsg{ID: 2}, 1))
+	require.NoError(t, producer.PushWithPriority(ctx, MyTestMsg{ID: 1}, 0))
+
+	mock := &mockQueueHandler{}
+	assert.ErrorIs(t, NewProducer[MyTestMsg](mock, mock).PushWithPriority(ctx, MyTestMsg{}, 0), ErrPriorityNotSupported)
+	assert.Error(t, NewProducer[MyTestMsg](failingCodec{}, handler).PushWithPriority(ctx, MyTestMsg{}, 0))
+
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+	go qs.Start()
+	defer qs.Stop()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 3
+	}, time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []int{1, 2, 3}, handled)
+}
+
+func TestProtoPriorityRedisHandler(t *testing.T) {
+	_, client := newTestRedis(t)
+	handler, err := NewProtoPriorityRedisHandler(client, serverconf.QueueConfig{QueueName: "pq"}, nil, func() *test.TestMsg {
+		return &test.TestMsg{}
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	msg := &test.TestMsg{Id: 1, Name: "Alice"}
+	data, err := handler.Encode(msg)
+	require.NoError(t, err)
+	require.NoError(t, handler.PushWithPriority(ctx, data, 0))
+
+	popped, err := handler.Pop(ctx)
+	require.NoError(t, err)
+	decoded, err := handler.Decode(popped)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(msg, decoded))
+	assert.NoError(t, handler.Handle(ctx, decoded))
+}
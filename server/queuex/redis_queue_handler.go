@@ -5,9 +5,28 @@ import (
 	"time"
 
 	"github.com/go-redis/redis/v7"
+	gonanoid "github.com/matoous/go-nanoid/v2"
 	"google.golang.org/protobuf/proto"
 )
 
+const (
+	// delayedIDLen is the length of the unique prefix of delayed set members, which keeps
+	// identical payloads scheduled at different times apart.
+	delayedIDLen = 21
+	// promoteBatchSize is the maximum number of due messages promoted per round trip.
+	promoteBatchSize = 1000
+)
+
+// promoteScript moves due messages from the delayed set to the ready list.
+var promoteScript = redis.NewScript(`
+local items = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, tonumber(ARGV[2]))
+for _, item in ipairs(items) do
+	redis.call('ZREM', KEYS[2], item)
+	redis.call('LPUSH', KEYS[1], string.sub(item, tonumber(ARGV[3]) + 1))
+end
+return #items
+`)
+
 // RedisQueue implements Queue interface using Redis.
 type RedisQueue struct {
 	client    *redis.Client
@@ -37,6 +56,45 @@ func (r RedisQueue) Pop(_ context.Context) ([]byte, error) {
 	return []byte(res[1]), nil
 }
 
+// Len returns the number of messages waiting in the queue.
+func (r RedisQueue) Len(_ context.Context) (int64, error) {
+	return r.client.LLen(r.queueName).Result()
+}
+
+// PushDelayed adds a message to the queue once the delay has elapsed.
+func (r RedisQueue) PushDelayed(ctx context.Context, data []byte, delay time.Duration) error {
+	return r.PushAt(ctx, data, time.Now().Add(delay))
+}
+
+// PushAt adds a message to the queue at the given time.
+func (r RedisQueue) PushAt(_ context.Context, data []byte, at time.Time) error {
+	member, err := delayedMember(data)
+	if err != nil {
+		return err
+	}
+	return r.client.ZAdd(r.delayedKey(), &redis.Z{Score: float64(at.UnixMilli()), Member: member}).Err()
+}
+
+// PromoteDue moves due delayed messages to the queue and returns how many were moved.
+func (r RedisQueue) PromoteDue(_ context.Context) (int, error) {
+	keys := []string{r.queueName, r.delayedKey()}
+	return promoteScript.Run(r.client, keys, time.Now().UnixMilli(), promoteBatchSize, delayedIDLen).Int()
+}
+
+// delayedKey returns the key of the sorted set holding messages scheduled for later.
+func (r RedisQueue) delayedKey() string {
+	return r.queueName + ":delayed"
+}
+
+// delayedMember prefixes the payload with a unique ID for the delayed set.
+func delayedMember(data []byte) (string, error) {
+	id, err := gonanoid.New(delayedIDLen)
+	if err != nil {
+		return "", err
+	}
+	return id + string(data), nil
+}
+
 // JSONRedisHandler handles JSON messages in a Redis queue.
 type JSONRedisHandler[T any] struct {
 	handlerFn func(ctx context.Context, msg T) error
@@ -170,3 +170,80 @@ func TestProtoRedisHandler_Handle_NilHandler(t *testing.T) {
 	err := dummy.Handle(context.Background(), &test.TestMsg{})
 	assert.NoError(t, err)
 }
+
+func TestRedisQueue_PushDelayed(t *testing.T) {
+	_, client := newTestRedis(t)
+	queue := NewRedisQueue(client, "delayed_queue")
+	ctx := context.Background()
+
+	require.NoError(t, queue.PushDelayed(ctx, []byte("later"), 20*time.Millisecond))
+	require.NoError(t, queue.PushDelayed(ctx, []byte("later"), 20*time.Millisecond))
+	require.NoError(t, queue.PushAt(ctx, []byte("past"), time.Now().Add(-time.Second)))
+
+	n, err := queue.PromoteDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	data, err := queue.Pop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("past"), data)
+
+	time.Sleep(30 * time.Millisecond)
+	n, err = queue.PromoteDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"later", "later"}, client.LRange("delayed_queue", 0, -1).Val())
+}
+
+func TestQueueServer_DelayedMessages(t *testing.T) {
+	_, client := newTestRedis(t)
+
+	var mu sync.Mutex
+	var handled []string
+	handler := NewJSONRedisHandler(client, "delayed_server", func(_ context.Context, msg MyTestMsg) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, msg.Body)
+		return nil
+	})
+
+	qs, err := NewQueueServer(serverconf.QueueConfig{
+		QueueName:          "delayed_server",
+		PoolSize:           2,
+		EmptySleepInterval: time.Millisecond,
+		RecoverInterval:    5 * time.Millisecond,
+	}, handler)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	data, err := handler.Encode(MyTestMsg{Body: "delayed"})
+	require.NoError(t, err)
+	require.NoError(t, handler.PushDelayed(ctx, data, 50*time.Millisecond))
+
+	go qs.Start()
+	defer qs.Stop()
+
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	assert.Empty(t, handled)
+	mu.Unlock()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 1
+	}, 2*time.Second, 5*time.Millisecond)
+}
+
+func TestRedisQueue_PromoteDueError(t *testing.T) {
+	mr, client := newTestRedis(t)
+	queue := NewRedisQueue(client, "delayed_queue")
+	mr.Close()
+
+	_, err := queue.PromoteDue(context.Background())
+	assert.Error(t, err)
+
+	qs, err := NewQueueServer(serverconf.QueueConfig{QueueName: "delayed_queue"}, NewJSONRedisHandler[MyTestMsg](client, "delayed_queue", nil))
+	require.NoError(t, err)
+	qs.recoverOnce(context.Background())
+}
@@ -0,0 +1,278 @@
+package queuex
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// DefaultVisibilityTimeout is the default duration a popped message may stay unacknowledged.
+	DefaultVisibilityTimeout = 30 * time.Second
+)
+
+// recoverScript moves expired in-flight messages from the processing list KEYS[3] back to
+// the ready list. Messages found in the processing list without a deadline (moved there by
+// an older version of the queue) get a fresh deadline.
+var recoverScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local moved = 0
+for _, item in ipairs(redis.call('LRANGE', KEYS[3], 0, -1)) do
+	local deadline = redis.call('ZSCORE', KEYS[2], item)
+	if not deadline then
+		redis.call('ZADD', KEYS[2], now + tonumber(ARGV[2]), item)
+	elseif tonumber(deadline) <= now then
+		redis.call('LREM', KEYS[3], 1, item)
+		redis.call('ZREM', KEYS[2], item)
+		redis.call('LPUSH', KEYS[1], item)
+		moved = moved + 1
+	end
+end
+return moved
+`)
+
+// popBatchScript moves up to ARGV[1] messages from the head of the ready list into a
+// processing list, recording their visibility deadline and delivery attempt.
+var popBatchScript = redis.NewScript(`
+local items = {}
+for i = 1, tonumber(ARGV[1]) do
+	local item = redis.call('LPOP', KEYS[1])
+	if not item then
+		break
+	end
+	redis.call('RPUSH', KEYS[2], item)
+	redis.call('ZADD', KEYS[3], ARGV[2], item)
+	redis.call('HINCRBY', KEYS[4], item, 1)
+	items[#items + 1] = item
+end
+if #items > 0 then
+	redis.call('SADD', KEYS[5], KEYS[2])
+end
+return items
+`)
+
+// ReliableRedisQueue implements AckQueue using Redis lists. Popped messages are moved
+// atomically into a per-consumer processing list and stay there until they are
+// acknowledged, so a handler failure or a crash never loses a message.
+//
+// Messages are popped from the head of the list like RedisQueue, so both queues deliver in
+// the same order.
+//
+// Delivery attempts are tracked per payload, so identical payloads share one counter.
+type ReliableRedisQueue struct {
+	RedisQueue
+	consumer          string
+	visibilityTimeout time.Duration
+}
+
+// NewReliableRedisQueue creates a new ReliableRedisQueue instance.
+func NewReliableRedisQueue(client *redis.Client, conf serverconf.QueueConfig) ReliableRedisQueue {
+	if conf.ConsumerName == "" {
+		conf.ConsumerName = defaultConsumerName()
+	}
+	if conf.VisibilityTimeout <= 0 {
+		conf.VisibilityTimeout = DefaultVisibilityTimeout
+	}
+
+	return ReliableRedisQueue{
+		RedisQueue:        NewRedisQueue(client, conf.QueueName),
+		consumer:          conf.ConsumerName,
+		visibilityTimeout: conf.VisibilityTimeout,
+	}
+}
+
+// Pop moves a message from the queue into the processing list of this consumer and returns it.
+// It waits up to one second for a message with BLMOVE, which leaves the list unchanged, then
+// moves the message and records it in flight in one script (requires Redis 6.2).
+func (r ReliableRedisQueue) Pop(ctx context.Context) ([]byte, error) {
+	err := r.client.Do("BLMOVE", r.queueName, r.queueName, "LEFT", "LEFT", 1).Err()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	batch, err := r.PopBatch(ctx, 1)
+	if len(batch) == 0 {
+		return nil, err
+	}
+	return batch[0], nil
+}
+
+// PopBatch moves up to n messages from the queue into the processing list of this consumer without blocking.
+func (r ReliableRedisQueue) PopBatch(_ context.Context, n int) ([][]byte, error) {
+	keys := []string{r.queueName, r.processingKey(), r.inflightKey(), r.attemptsKey(), r.consumersKey()}
+	deadline := time.Now().Add(r.visibilityTimeout).UnixMilli()
+
+	res, err := popBatchScript.Run(r.client, keys, n, deadline).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	items, _ := res.([]any)
+	batch := make([][]byte, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			batch = append(batch, []byte(s))
+		}
+	}
+	if len(batch) == 0 {
+		return nil, nil
+	}
+	return batch, nil
+}
+
+// Ack removes a handled message from the processing list.
+func (r ReliableRedisQueue) Ack(_ context.Context, data []byte) error {
+	_, err := r.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.LRem(r.processingKey(), 1, data)
+		pipe.ZRem(r.inflightKey(), data)
+		pipe.HDel(r.attemptsKey(), string(data))
+		return nil
+	})
+	return err
+}
+
+// Retry removes a message from the processing list and re-queues it after the delay.
+func (r ReliableRedisQueue) Retry(_ context.Context, data []byte, delay time.Duration) error {
+	member, err := delayedMember(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.LRem(r.processingKey(), 1, data)
+		pipe.ZRem(r.inflightKey(), data)
+		if delay <= 0 {
+			pipe.LPush(r.queueName, data)
+			return nil
+		}
+		due := float64(time.Now().Add(delay).UnixMilli())
+		pipe.ZAdd(r.delayedKey(), &redis.Z{Score: due, Member: member})
+		return nil
+	})
+	return err
+}
+
+// Attempts returns how many times the message has been delivered.
+func (r ReliableRedisQueue) Attempts(_ context.Context, data []byte) (int, error) {
+	n, err := r.client.HGet(r.attemptsKey(), string(data)).Int()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
+
+// Recover re-queues messages whose visibility timeout expired and retries that are due.
+// The processing lists are recovered one at a time, so every key the script touches is
+// declared.
+func (r ReliableRedisQueue) Recover(ctx context.Context) (int, error) {
+	lists, err := r.client.SMembers(r.consumersKey()).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	var expired int
+	now := time.Now().UnixMilli()
+	for _, list := range lists {
+		keys := []string{r.queueName, r.inflightKey(), list}
+		n, err := recoverScript.Run(r.client, keys, now, r.visibilityTimeout.Milliseconds()).Int()
+		if err != nil {
+			return expired, err
+		}
+		expired += n
+	}
+
+	due, err := r.PromoteDue(ctx)
+	return expired + due, err
+}
+
+// processingKey returns the key of the processing list of this consumer.
+func (r ReliableRedisQueue) processingKey() string {
+	return r.queueName + ":processing:" + r.consumer
+}
+
+// inflightKey returns the key of the sorted set holding visibility deadlines.
+func (r ReliableRedisQueue) inflightKey() string {
+	return r.queueName + ":inflight"
+}
+
+// consumersKey returns the key of the set holding all processing lists.
+func (r ReliableRedisQueue) consumersKey() string {
+	return r.queueName + ":consumers"
+}
+
+// attemptsKey returns the key of the hash holding delivery attempts.
+func (r ReliableRedisQueue) attemptsKey() string {
+	return r.queueName + ":attempts"
+}
+
+// defaultConsumerName returns a consumer name unique to this process.
+func defaultConsumerName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// HandlerFunc adapts a function to the Handler interface.
+type HandlerFunc[T any] func(ctx context.Context, msg T) error
+
+// Handle calls f(ctx, msg).
+func (f HandlerFunc[T]) Handle(ctx context.Context, msg T) error {
+	if f == nil {
+		return nil
+	}
+	return f(ctx, msg)
+}
+
+// JSONReliableRedisHandler handles JSON messages in a reliable Redis queue.
+type JSONReliableRedisHandler[T any] struct {
+	HandlerFunc[T]
+	JSONCodec[T]
+	ReliableRedisQueue
+}
+
+// NewJSONReliableRedisHandler creates a new JSONReliableRedisHandler instance.
+func NewJSONReliableRedisHandler[T any](
+	client *redis.Client,
+	conf serverconf.QueueConfig,
+	handlerFn func(ctx context.Context, msg T) error,
+) *JSONReliableRedisHandler[T] {
+	return &JSONReliableRedisHandler[T]{
+		HandlerFunc:        handlerFn,
+		JSONCodec:          JSONCodec[T]{},
+		ReliableRedisQueue: NewReliableRedisQueue(client, conf),
+	}
+}
+
+// ProtoReliableRedisHandler handles protobuf messages in a reliable Redis queue.
+type ProtoReliableRedisHandler[T proto.Message] struct {
+	HandlerFunc[T]
+	ProtoCodec[T]
+	ReliableRedisQueue
+}
+
+// NewProtoReliableRedisHandler creates a new ProtoReliableRedisHandler instance.
+func NewProtoReliableRedisHandler[T proto.Message](
+	client *redis.Client,
+	conf serverconf.QueueConfig,
+	handlerFn func(ctx context.Context, msg T) error,
+	newMsgFn func() T,
+) *ProtoReliableRedisHandler[T] {
+	return &ProtoReliableRedisHandler[T]{
+		HandlerFunc:        handlerFn,
+		ProtoCodec:         ProtoCodec[T]{newMsg: newMsgFn},
+		ReliableRedisQueue: NewReliableRedisQueue(client, conf),
+	}
+}
@@ -0,0 +1,295 @@
+package queuex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/server/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+
+	return mr, redis.NewClient(&redis.Options{Addr: mr.Addr()})
+}
+
+func TestReliableRedisQueue_PopAck(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewReliableRedisQueue(client, serverconf.QueueConfig{QueueName: "rq", ConsumerName: "c1"})
+	ctx := context.Background()
+
+	require.NoError(t, q.Push(ctx, []byte("a")))
+
+	data, err := q.Pop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("a"), data)
+
+	processing, err := client.LRange("rq:processing:c1", 0, -1).Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, processing)
+
+	attempts, err := q.Attempts(ctx, data)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts)
+
+	require.NoError(t, q.Ack(ctx, data))
+	assert.Zero(t, client.LLen("rq:processing:c1").Val())
+	assert.Zero(t, client.ZCard("rq:inflight").Val())
+
+	attempts, err = q.Attempts(ctx, data)
+	require.NoError(t, err)
+	assert.Zero(t, attempts)
+}
+
+func TestReliableRedisQueue_PopOrder(t *testing.T) {
+	_, client := newTestRedis(t)
+	reliable := NewReliableRedisQueue(client, serverconf.QueueConfig{QueueName: "rq", ConsumerName: "c1"})
+	plain := NewRedisQueue(client, "q")
+	ctx := context.Background()
+
+	for _, v := range []string{"a", "b", "c"} {
+		require.NoError(t, reliable.Push(ctx, []byte(v)))
+		require.NoError(t, plain.Push(ctx, []byte(v)))
+	}
+
+	for range 3 {
+		want, err := plain.Pop(ctx)
+		require.NoError(t, err)
+		got, err := reliable.Pop(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	assert.Equal(t, int64(3), client.ZCard("rq:inflight").Val())
+}
+
+func TestReliableRedisQueue_PopEmpty(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewReliableRedisQueue(client, serverconf.QueueConfig{QueueName: "rq"})
+
+	data, err := q.Pop(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+	assert.NotEmpty(t, q.consumer)
+}
+
+func TestReliableRedisQueue_PopError(t *testing.T) {
+	mr, client := newTestRedis(t)
+	q := NewReliableRedisQueue(client, serverconf.QueueConfig{QueueName: "rq"})
+	mr.Close()
+
+	_, err := q.Pop(context.Background())
+	assert.Error(t, err)
+}
+
+func TestReliableRedisQueue_Retry(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewReliableRedisQueue(client, serverconf.QueueConfig{QueueName: "rq", ConsumerName: "c1"})
+	ctx := context.Background()
+
+	require.NoError(t, q.Push(ctx, []byte("a")))
+	data, err := q.Pop(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Retry(ctx, data, 0))
+	assert.Equal(t, int64(1), client.LLen("rq").Val())
+	assert.Zero(t, client.LLen("rq:processing:c1").Val())
+
+	data, err = q.Pop(ctx)
+	require.NoError(t, err)
+	require.NoError(t, q.Retry(ctx, data, 10*time.Millisecond))
+	assert.Zero(t, client.LLen("rq").Val())
+	assert.Equal(t, int64(1), client.ZCard("rq:delayed").Val())
+
+	time.Sleep(20 * time.Millisecond)
+	n, err := q.Recover(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	data, err = q.Pop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("a"), data)
+
+	attempts, err := q.Attempts(ctx, data)
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestReliableRedisQueue_RecoverVisibilityTimeout(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewReliableRedisQueue(client, serverconf.QueueConfig{
+		QueueName:         "rq",
+		ConsumerName:      "c1",
+		VisibilityTimeout: 10 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	require.NoError(t, q.Push(ctx, []byte("a")))
+	_, err := q.Pop(ctx)
+	require.NoError(t, err)
+
+	n, err := q.Recover(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	time.Sleep(20 * time.Millisecond)
+	n, err = q.Recover(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, int64(1), client.LLen("rq").Val())
+	assert.Zero(t, client.LLen("rq:processing:c1").Val())
+}
+
+func TestReliableRedisQueue_RecoverMissingDeadline(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewReliableRedisQueue(client, serverconf.QueueConfig{QueueName: "rq", ConsumerName: "dead"})
+
+	require.NoError(t, client.RPush("rq:processing:dead", "orphan").Err())
+	require.NoError(t, client.SAdd("rq:consumers", "rq:processing:dead").Err())
+
+	n, err := q.Recover(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	_, err = client.ZScore("rq:inflight", "orphan").Result()
+	assert.NoError(t, err)
+}
+
+func TestReliableRedisQueue_RecoverConsumers(t *testing.T) {
+	_, client := newTestRedis(t)
+	ctx := context.Background()
+	conf := serverconf.QueueConfig{QueueName: "rq", VisibilityTimeout: 10 * time.Millisecond}
+
+	for _, consumer := range []string{"c1", "c2"} {
+		conf.ConsumerName = consumer
+		q := NewReliableRedisQueue(client, conf)
+		require.NoError(t, q.Push(ctx, []byte(consumer)))
+		_, err := q.Pop(ctx)
+		require.NoError(t, err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	n, err := NewReliableRedisQueue(client, conf).Recover(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, int64(2), client.LLen("rq").Val())
+}
+
+func TestReliableRedisQueue_RecoverError(t *testing.T) {
+	mr, client := newTestRedis(t)
+	q := NewReliableRedisQueue(client, serverconf.QueueConfig{QueueName: "rq", ConsumerName: "c1"})
+	ctx := context.Background()
+
+	// the script fails on the inflight key holding the wrong type.
+	require.NoError(t, q.Push(ctx, []byte("a")))
+	_, err := q.Pop(ctx)
+	require.NoError(t, err)
+	require.NoError(t, client.Set("rq:inflight", "x", 0).Err())
+	_, err = q.Recover(ctx)
+	assert.Error(t, err)
+
+	mr.Close()
+	_, err = q.Recover(ctx)
+	assert.Error(t, err)
+}
+
+func TestQueueServer_ReliableRetry(t *testing.T) {
+	_, client := newTestRedis(t)
+	conf := serverconf.QueueConfig{
+		QueueName:          "rq",
+		PoolSize:           2,
+		EmptySleepInterval: time.Millisecond,
+		RetryBackoff:       time.Millisecond,
+		RecoverInterval:    5 * time.Millisecond,
+	}
+
+	var mu sync.Mutex
+	var calls int
+	handler := NewJSONReliableRedisHandler(client, conf, func(_ context.Context, _ MyTestMsg) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			return errors.New("first attempt fails")
+		}
+		return nil
+	})
+
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+
+	data, err := handler.Encode(MyTestMsg{ID: 1})
+	require.NoError(t, err)
+	require.NoError(t, handler.Push(context.Background(), data))
+
+	go qs.Start()
+	defer qs.Stop()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls == 2
+	}, 2*time.Second, 5*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		return client.HLen("rq:attempts").Val() == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestQueueServer_ReliableGiveUp(t *testing.T) {
+	_, client := newTestRedis(t)
+	conf := serverconf.QueueConfig{
+		QueueName:          "rq",
+		PoolSize:           2,
+		EmptySleepInterval: time.Millisecond,
+		MaxAttempts:        2,
+		RetryBackoff:       time.Millisecond,
+		RecoverInterval:    5 * time.Millisecond,
+	}
+
+	var mu sync.Mutex
+	var calls int
+	handler := NewProtoReliableRedisHandler(client, conf, func(_ context.Context, _ *test.TestMsg) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return errors.New("always fails")
+	}, func() *test.TestMsg { return &test.TestMsg{} })
+
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+
+	data, err := handler.Encode(&test.TestMsg{Id: 1})
+	require.NoError(t, err)
+	require.NoError(t, handler.Push(context.Background(), data))
+
+	go qs.Start()
+	defer qs.Stop()
+
+	require.Eventually(t, func() bool {
+		return client.HLen("rq:attempts").Val() == 0 && client.ZCard("rq:inflight").Val() == 0 && client.ZCard("rq:delayed").Val() == 0 && client.LLen("rq").Val() == 0
+	}, 2*time.Second, 5*time.Millisecond)
+
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, calls)
+}
+
+func TestHandlerFunc_Nil(t *testing.T) {
+	var fn HandlerFunc[MyTestMsg]
+	assert.NoError(t, fn.Handle(context.Background(), MyTestMsg{}))
+}
+
+func redisZ(score float64, member string) *redis.Z {
+	return &redis.Z{Score: score, Member: member}
+}
@@ -0,0 +1,451 @@
+package queuex
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// DefaultConsumerGroup is the default consumer group of a stream queue.
+	DefaultConsumerGroup = "queuex"
+
+	// streamDataField is the stream entry field holding the message.
+	streamDataField = "data"
+	// streamClaimBatchSize is the maximum number of entries claimed per XAUTOCLAIM.
+	streamClaimBatchSize = 100
+)
+
+// streamPromoteScript moves due messages from the delayed set to the stream, trimming the
+// stream to about ARGV[5] entries unless it is 0.
+var streamPromoteScript = redis.NewScript(`
+local items = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, tonumber(ARGV[2]))
+for _, item in ipairs(items) do
+	redis.call('ZREM', KEYS[2], item)
+	local data = string.sub(item, tonumber(ARGV[3]) + 1)
+	if tonumber(ARGV[5]) > 0 then
+		redis.call('XADD', KEYS[1], 'MAXLEN', '~', ARGV[5], '*', ARGV[4], data)
+	else
+		redis.call('XADD', KEYS[1], '*', ARGV[4], data)
+	end
+end
+return #items
+`)
+
+// RedisStreamQueue implements AckQueue and DelayedQueue using a Redis stream and a consumer
+// group. Every consumer group receives every message; within a group each message is
+// delivered to one consumer and stays pending until it is acknowledged. Entries left pending
+// by dead consumers are claimed by Recover once they have been idle for the visibility timeout.
+//
+// Retries are redelivered by claiming, so retry delays are capped at the visibility timeout.
+// The stream grows without bound unless QueueConfig.StreamMaxLen is set.
+type RedisStreamQueue struct {
+	client            *redis.Client
+	inflight          map[string][]string
+	stream            string
+	group             string
+	consumer          string
+	claimed           []redis.XMessage
+	visibilityTimeout time.Duration
+	maxLen            int64
+	mu                sync.Mutex
+}
+
+// NewRedisStreamQueue creates a new RedisStreamQueue instance. The consumer group is created
+// on first use and starts from the beginning of the stream.
+func NewRedisStreamQueue(client *redis.Client, conf serverconf.QueueConfig) *RedisStreamQueue {
+	if conf.ConsumerGroup == "" {
+		conf.ConsumerGroup = DefaultConsumerGroup
+	}
+	if conf.ConsumerName == "" {
+		conf.ConsumerName = defaultConsumerName()
+	}
+	if conf.VisibilityTimeout <= 0 {
+		conf.VisibilityTimeout = DefaultVisibilityTimeout
+	}
+
+	return &RedisStreamQueue{
+		client:            client,
+		inflight:          make(map[string][]string),
+		stream:            conf.QueueName,
+		group:             conf.ConsumerGroup,
+		consumer:          conf.ConsumerName,
+		visibilityTimeout: conf.VisibilityTimeout,
+		maxLen:            max(conf.StreamMaxLen, 0),
+	}
+}
+
+// Push adds a message to the stream, trimming it to about StreamMaxLen entries.
+func (r *RedisStreamQueue) Push(_ context.Context, data []byte) error {
+	return r.client.XAdd(&redis.XAddArgs{
+		Stream:       r.stream,
+		MaxLenApprox: r.maxLen,
+		Values:       map[string]any{streamDataField: data},
+	}).Err()
+}
+
+// Pop retrieves a message for this consumer, preferring entries claimed from dead consumers.
+func (r *RedisStreamQueue) Pop(_ context.Context) ([]byte, error) {
+	if claimed := r.nextClaimed(1); len(claimed) > 0 {
+		return r.track(claimed[0]), nil
+	}
+
+	msgs, err := r.read(1, 1*time.Second)
+	if err != nil || len(msgs) == 0 {
+		return nil, err
+	}
+	return r.track(msgs[0]), nil
+}
+
+// PopBatch retrieves up to n messages for this consumer without blocking, preferring entries claimed from dead consumers.
+func (r *RedisStreamQueue) PopBatch(_ context.Context, n int) ([][]byte, error) {
+	msgs := r.nextClaimed(n)
+	if len(msgs) < n {
+		read, err := r.read(int64(n-len(msgs)), -1)
+		if err != nil && len(msgs) == 0 {
+			return nil, err
+		}
+		msgs = append(msgs, read...)
+	}
+
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+	batch := make([][]byte, len(msgs))
+	for i := range msgs {
+		batch[i] = r.track(msgs[i])
+	}
+	return batch, nil
+}
+
+// Ack acknowledges a message in the consumer group.
+func (r *RedisStreamQueue) Ack(_ context.Context, data []byte) error {
+	id, ok := r.untrack(data)
+	if !ok {
+		return nil
+	}
+	return r.client.XAck(r.stream, r.group, id).Err()
+}
+
+// Retry leaves a message pending and makes it claimable again after the delay.
+func (r *RedisStreamQueue) Retry(ctx context.Context, data []byte, delay time.Duration) error {
+	attempts, err := r.Attempts(ctx, data)
+	if err != nil {
+		return err
+	}
+	id, ok := r.untrack(data)
+	if !ok {
+		return nil
+	}
+
+	idle := max(r.visibilityTimeout-delay, 0)
+	return r.client.Do("XCLAIM", r.stream, r.group, r.consumer, 0, id,
+		"IDLE", idle.Milliseconds(), "RETRYCOUNT", attempts, "JUSTID").Err()
+}
+
+// Attempts returns how many times the message has been delivered to the consumer group.
+func (r *RedisStreamQueue) Attempts(_ context.Context, data []byte) (int, error) {
+	r.mu.Lock()
+	ids := r.inflight[string(data)]
+	r.mu.Unlock()
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	pending, err := r.client.XPendingExt(&redis.XPendingExtArgs{
+		Stream: r.stream,
+		Group:  r.group,
+		Start:  ids[0],
+		End:    ids[0],
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+	return int(pending[0].RetryCount), nil
+}
+
+// Recover claims entries idle for longer than the visibility timeout and promotes due delayed messages.
+// Nothing is claimed while previously claimed entries are still waiting to be popped.
+func (r *RedisStreamQueue) Recover(ctx context.Context) (int, error) {
+	claimed, err := r.claim()
+	if err != nil {
+		return 0, err
+	}
+
+	due, err := r.PromoteDue(ctx)
+	return claimed + due, err
+}
+
+// claim claims idle entries into the local buffer and returns how many were claimed.
+func (r *RedisStreamQueue) claim() (int, error) {
+	r.mu.Lock()
+	buffered := len(r.claimed)
+	r.mu.Unlock()
+	if buffered > 0 {
+		return 0, nil
+	}
+
+	res, err := r.client.Do("XAUTOCLAIM", r.stream, r.group, r.consumer,
+		r.visibilityTimeout.Milliseconds(), "0-0", "COUNT", streamClaimBatchSize).Result()
+	if err != nil && !isNoGroupErr(err) {
+		return 0, err
+	}
+
+	claimed, deleted := parseAutoClaim(res)
+	if len(deleted) > 0 {
+		// deleted entries cannot be delivered, so they are acknowledged instead of being claimed again.
+		if err := r.client.XAck(r.stream, r.group, deleted...).Err(); err != nil {
+			return 0, err
+		}
+	}
+	r.mu.Lock()
+	r.claimed = append(r.claimed, claimed...)
+	r.mu.Unlock()
+	return len(claimed), nil
+}
+
+// Pending returns the number of entries delivered to the consumer group but not yet acknowledged.
+func (r *RedisStreamQueue) Pending(_ context.Context) (int64, error) {
+	res, err := r.client.XPending(r.stream, r.group).Result()
+	if err != nil {
+		if isNoGroupErr(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return res.Count, nil
+}
+
+// Len returns the number of entries not yet delivered to the consumer group, or the length
+// of the stream when the group does not exist yet or Redis cannot tell.
+func (r *RedisStreamQueue) Len(_ context.Context) (int64, error) {
+	res, err := r.client.Do("XINFO", "GROUPS", r.stream).Result()
+	if err != nil {
+		if isNoSuchKeyErr(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	groups, _ := res.([]any)
+	for _, g := range groups {
+		fields, _ := g.([]any)
+		info := make(map[string]any, len(fields)/2)
+		for i := 0; i+1 < len(fields); i += 2 {
+			if key, ok := fields[i].(string); ok {
+				info[key] = fields[i+1]
+			}
+		}
+		if info["name"] != r.group {
+			continue
+		}
+		if lag, ok := info["lag"].(int64); ok {
+			return lag, nil
+		}
+	}
+	return r.client.XLen(r.stream).Result()
+}
+
+// PushDelayed adds a message to the stream once the delay has elapsed.
+func (r *RedisStreamQueue) PushDelayed(ctx context.Context, data []byte, delay time.Duration) error {
+	return r.PushAt(ctx, data, time.Now().Add(delay))
+}
+
+// PushAt adds a message to the stream at the given time.
+func (r *RedisStreamQueue) PushAt(_ context.Context, data []byte, at time.Time) error {
+	member, err := delayedMember(data)
+	if err != nil {
+		return err
+	}
+	return r.client.ZAdd(r.delayedKey(), &redis.Z{Score: float64(at.UnixMilli()), Member: member}).Err()
+}
+
+// PromoteDue moves due delayed messages to the stream and returns how many were moved.
+func (r *RedisStreamQueue) PromoteDue(_ context.Context) (int, error) {
+	keys := []string{r.stream, r.delayedKey()}
+	return streamPromoteScript.Run(r.client, keys, time.Now().UnixMilli(), promoteBatchSize, delayedIDLen, streamDataField, r.maxLen).Int()
+}
+
+// read reads up to count new entries for this consumer, blocking for at most block when it is
+// not negative. The consumer group is created if it does not exist.
+func (r *RedisStreamQueue) read(count int64, block time.Duration) ([]redis.XMessage, error) {
+	res, err := r.client.XReadGroup(&redis.XReadGroupArgs{
+		Group:    r.group,
+		Consumer: r.consumer,
+		Streams:  []string{r.stream, ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		if isNoGroupErr(err) {
+			return nil, r.createGroup()
+		}
+		return nil, err
+	}
+
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return res[0].Messages, nil
+}
+
+// createGroup creates the consumer group, ignoring the error when it already exists.
+func (r *RedisStreamQueue) createGroup() error {
+	err := r.client.XGroupCreateMkStream(r.stream, r.group, "0").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// nextClaimed returns up to n entries claimed by Recover.
+func (r *RedisStreamQueue) nextClaimed(n int) []redis.XMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n = min(n, len(r.claimed))
+	if n == 0 {
+		return nil
+	}
+	msgs := r.claimed[:n:n]
+	r.claimed = r.claimed[n:]
+	return msgs
+}
+
+// track records the entry ID of a delivered message and returns the message.
+func (r *RedisStreamQueue) track(msg redis.XMessage) []byte {
+	data, _ := msg.Values[streamDataField].(string)
+
+	r.mu.Lock()
+	r.inflight[data] = append(r.inflight[data], msg.ID)
+	r.mu.Unlock()
+
+	return []byte(data)
+}
+
+// untrack removes and returns the oldest in-flight entry ID of the message.
+func (r *RedisStreamQueue) untrack(data []byte) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := string(data)
+	ids := r.inflight[key]
+	if len(ids) == 0 {
+		return "", false
+	}
+	if len(ids) == 1 {
+		delete(r.inflight, key)
+	} else {
+		r.inflight[key] = ids[1:]
+	}
+	return ids[0], true
+}
+
+// delayedKey returns the key of the sorted set holding messages scheduled for later.
+func (r *RedisStreamQueue) delayedKey() string {
+	return r.stream + ":delayed"
+}
+
+// isNoSuchKeyErr reports whether err is returned because the stream does not exist.
+func isNoSuchKeyErr(err error) bool {
+	return strings.HasPrefix(err.Error(), "ERR no such key")
+}
+
+// isNoGroupErr reports whether the error is caused by a missing stream or consumer group.
+func isNoGroupErr(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOGROUP")
+}
+
+// parseAutoClaim parses the entries of an XAUTOCLAIM reply and the IDs of the claimed entries
+// that were deleted or trimmed from the stream, which Redis 6.2 returns with nil fields and
+// Redis 7 lists in a third element.
+func parseAutoClaim(res any) ([]redis.XMessage, []string) {
+	reply, ok := res.([]any)
+	if !ok || len(reply) < 2 {
+		return nil, nil
+	}
+	entries, _ := reply[1].([]any)
+
+	var deleted []string
+	if len(reply) > 2 {
+		ids, _ := reply[2].([]any)
+		for _, id := range ids {
+			if id, ok := id.(string); ok {
+				deleted = append(deleted, id)
+			}
+		}
+	}
+
+	msgs := make([]redis.XMessage, 0, len(entries))
+	for _, e := range entries {
+		entry, ok := e.([]any)
+		if !ok || len(entry) != 2 {
+			continue
+		}
+		id, _ := entry[0].(string)
+		fields, ok := entry[1].([]any)
+		if !ok {
+			deleted = append(deleted, id)
+			continue
+		}
+
+		values := make(map[string]any, len(fields)/2)
+		for i := 0; i+1 < len(fields); i += 2 {
+			if k, ok := fields[i].(string); ok {
+				values[k] = fields[i+1]
+			}
+		}
+		msgs = append(msgs, redis.XMessage{ID: id, Values: values})
+	}
+	return msgs, deleted
+}
+
+// JSONRedisStreamHandler handles JSON messages in a Redis stream queue.
+type JSONRedisStreamHandler[T any] struct {
+	HandlerFunc[T]
+	JSONCodec[T]
+	*RedisStreamQueue
+}
+
+// NewJSONRedisStreamHandler creates a new JSONRedisStreamHandler instance.
+func NewJSONRedisStreamHandler[T any](
+	client *redis.Client,
+	conf serverconf.QueueConfig,
+	handlerFn func(ctx context.Context, msg T) error,
+) *JSONRedisStreamHandler[T] {
+	return &JSONRedisStreamHandler[T]{
+		HandlerFunc:      handlerFn,
+		JSONCodec:        JSONCodec[T]{},
+		RedisStreamQueue: NewRedisStreamQueue(client, conf),
+	}
+}
+
+// ProtoRedisStreamHandler handles protobuf messages in a Redis stream queue.
+type ProtoRedisStreamHandler[T proto.Message] struct {
+	HandlerFunc[T]
+	ProtoCodec[T]
+	*RedisStreamQueue
+}
+
+// NewProtoRedisStreamHandler creates a new ProtoRedisStreamHandler instance.
+func NewProtoRedisStreamHandler[T proto.Message](
+	client *redis.Client,
+	conf serverconf.QueueConfig,
+	handlerFn func(ctx context.Context, msg T) error,
+	newMsgFn func() T,
+) *ProtoRedisStreamHandler[T] {
+	return &ProtoRedisStreamHandler[T]{
+		HandlerFunc:      handlerFn,
+		ProtoCodec:       ProtoCodec[T]{newMsg: newMsgFn},
+		RedisStreamQueue: NewRedisStreamQueue(client, conf),
+	}
+}
@@ -0,0 +1,293 @@
+package queuex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/server/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func popStream(t *testing.T, q *RedisStreamQueue) []byte {
+	t.Helper()
+	for i := 0; i < 3; i++ {
+		data, err := q.Pop(context.Background())
+		require.NoError(t, err)
+		if data != nil {
+			return data
+		}
+	}
+	return nil
+}
+
+func TestRedisStreamQueue_PopAck(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewRedisStreamQueue(client, serverconf.QueueConfig{QueueName: "sq", ConsumerName: "c1"})
+	ctx := context.Background()
+
+	require.NoError(t, q.Push(ctx, []byte("a")))
+	assert.Equal(t, []byte("a"), popStream(t, q))
+
+	pending, err := q.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pending)
+
+	attempts, err := q.Attempts(ctx, []byte("a"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts)
+
+	require.NoError(t, q.Ack(ctx, []byte("a")))
+	pending, err = q.Pending(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, pending)
+
+	assert.NoError(t, q.Ack(ctx, []byte("unknown")))
+	assert.NoError(t, q.Retry(ctx, []byte("unknown"), 0))
+	attempts, err = q.Attempts(ctx, []byte("unknown"))
+	require.NoError(t, err)
+	assert.Zero(t, attempts)
+}
+
+func TestRedisStreamQueue_ConsumerGroups(t *testing.T) {
+	_, client := newTestRedis(t)
+	g1 := NewRedisStreamQueue(client, serverconf.QueueConfig{QueueName: "sq", ConsumerGroup: "g1"})
+	g2 := NewRedisStreamQueue(client, serverconf.QueueConfig{QueueName: "sq", ConsumerGroup: "g2"})
+	ctx := context.Background()
+
+	// groups created before the first push start from the beginning of the stream.
+	require.NoError(t, g1.createGroup())
+	require.NoError(t, g1.createGroup())
+	require.NoError(t, g2.Push(ctx, []byte("a")))
+
+	assert.Equal(t, []byte("a"), popStream(t, g1))
+	assert.Equal(t, []byte("a"), popStream(t, g2))
+}
+
+func TestRedisStreamQueue_RecoverDeadConsumer(t *testing.T) {
+	_, client := newTestRedis(t)
+	conf := serverconf.QueueConfig{QueueName: "sq", VisibilityTimeout: 10 * time.Millisecond}
+	conf.ConsumerName = "dead"
+	dead := NewRedisStreamQueue(client, conf)
+	conf.ConsumerName = "alive"
+	alive := NewRedisStreamQueue(client, conf)
+	ctx := context.Background()
+
+	require.NoError(t, dead.Push(ctx, []byte("a")))
+	assert.Equal(t, []byte("a"), popStream(t, dead))
+
+	n, err := alive.Recover(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	time.Sleep(20 * time.Millisecond)
+	n, err = alive.Recover(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// claimed entries waiting to be popped are not claimed again.
+	time.Sleep(20 * time.Millisecond)
+	n, err = alive.Recover(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	data, err := alive.Pop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("a"), data)
+
+	attempts, err := alive.Attempts(ctx, data)
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestRedisStreamQueue_Retry(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewRedisStreamQueue(client, serverconf.QueueConfig{QueueName: "sq", VisibilityTimeout: time.Second})
+	ctx := context.Background()
+
+	require.NoError(t, q.Push(ctx, []byte("a")))
+	data := popStream(t, q)
+	require.NoError(t, q.Retry(ctx, data, 0))
+
+	n, err := q.Recover(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	data, err = q.Pop(ctx)
+	require.NoError(t, err)
+	attempts, err := q.Attempts(ctx, data)
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+
+	require.NoError(t, q.Retry(ctx, data, time.Hour))
+	n, err = q.Recover(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestRedisStreamQueue_Delayed(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewRedisStreamQueue(client, serverconf.QueueConfig{QueueName: "sq"})
+	ctx := context.Background()
+
+	require.NoError(t, q.PushDelayed(ctx, []byte("later"), 10*time.Millisecond))
+	n, err := q.PromoteDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	time.Sleep(20 * time.Millisecond)
+	n, err = q.Recover(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []byte("later"), popStream(t, q))
+}
+
+func TestRedisStreamQueue_MaxLen(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewRedisStreamQueue(client, serverconf.QueueConfig{QueueName: "sq", StreamMaxLen: 2})
+	ctx := context.Background()
+
+	for _, v := range []string{"a", "b", "c"} {
+		require.NoError(t, q.Push(ctx, []byte(v)))
+	}
+	assert.Equal(t, int64(2), client.XLen("sq").Val())
+
+	require.NoError(t, q.PushAt(ctx, []byte("d"), time.Now()))
+	n, err := q.PromoteDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, int64(2), client.XLen("sq").Val())
+	assert.Equal(t, []byte("c"), popStream(t, q))
+	assert.Equal(t, []byte("d"), popStream(t, q))
+
+	// unbounded when not configured.
+	unbounded := NewRedisStreamQueue(client, serverconf.QueueConfig{QueueName: "uq"})
+	for _, v := range []string{"a", "b", "c"} {
+		require.NoError(t, unbounded.Push(ctx, []byte(v)))
+	}
+	assert.Equal(t, int64(3), client.XLen("uq").Val())
+}
+
+func TestRedisStreamQueue_Errors(t *testing.T) {
+	mr, client := newTestRedis(t)
+	q := NewRedisStreamQueue(client, serverconf.QueueConfig{QueueName: "sq"})
+	ctx := context.Background()
+
+	pending, err := q.Pending(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, pending)
+
+	require.NoError(t, client.Set("sq", "not a stream", 0).Err())
+	_, err = q.Pop(ctx)
+	assert.Error(t, err)
+
+	mr.Close()
+	_, err = q.Pending(ctx)
+	assert.Error(t, err)
+	_, err = q.Recover(ctx)
+	assert.Error(t, err)
+	assert.Error(t, q.PushAt(ctx, []byte("a"), time.Now()))
+}
+
+func TestParseAutoClaim(t *testing.T) {
+	msgs, deleted := parseAutoClaim(nil)
+	assert.Nil(t, msgs)
+	assert.Nil(t, deleted)
+	msgs, _ = parseAutoClaim([]any{"0-0"})
+	assert.Nil(t, msgs)
+
+	// Redis 6.2 returns deleted entries with nil fields, Redis 7 lists them apart.
+	msgs, deleted = parseAutoClaim([]any{"0-0", []any{
+		nil,
+		[]any{"1-0", []any{"data", "a"}},
+		[]any{"2-0", nil},
+	}, []any{"3-0"}})
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "1-0", msgs[0].ID)
+	assert.Equal(t, "a", msgs[0].Values["data"])
+	assert.Equal(t, []string{"3-0", "2-0"}, deleted)
+}
+
+func TestRedisStreamQueue_ClaimDeleted(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewRedisStreamQueue(client, serverconf.QueueConfig{QueueName: "sq", ConsumerName: "c1", VisibilityTimeout: time.Millisecond})
+	ctx := context.Background()
+
+	require.NoError(t, q.Push(ctx, []byte("a")))
+	require.Equal(t, []byte("a"), popStream(t, q))
+	id := client.XRange("sq", "-", "+").Val()[0].ID
+	require.NoError(t, client.XDel("sq", id).Err())
+	time.Sleep(5 * time.Millisecond)
+
+	// the pending entry of a deleted message is acknowledged rather than claimed on every cycle.
+	n, err := q.claim()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	pending, err := q.Pending(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, pending)
+}
+
+func TestQueueServer_StreamRetry(t *testing.T) {
+	_, client := newTestRedis(t)
+	conf := serverconf.QueueConfig{
+		QueueName:          "sq",
+		PoolSize:           2,
+		EmptySleepInterval: time.Millisecond,
+		VisibilityTimeout:  2 * time.Second,
+		RetryBackoff:       time.Millisecond,
+		RecoverInterval:    5 * time.Millisecond,
+	}
+
+	var mu sync.Mutex
+	var calls int
+	handler := NewJSONRedisStreamHandler(client, conf, func(_ context.Context, _ MyTestMsg) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			return errors.New("first attempt fails")
+		}
+		return nil
+	})
+
+	qs, err := NewQueueServer(conf, handler)
+	require.NoError(t, err)
+
+	data, err := handler.Encode(MyTestMsg{ID: 1})
+	require.NoError(t, err)
+	require.NoError(t, handler.Push(context.Background(), data))
+
+	go qs.Start()
+	defer qs.Stop()
+
+	require.Eventually(t, func() bool {
+		pending, _ := handler.Pending(context.Background())
+		mu.Lock()
+		defer mu.Unlock()
+		return calls == 2 && pending == 0
+	}, 3*time.Second, 5*time.Millisecond)
+}
+
+func TestProtoRedisStreamHandler(t *testing.T) {
+	_, client := newTestRedis(t)
+	handler := NewProtoRedisStreamHandler(client, serverconf.QueueConfig{QueueName: "sq"}, nil, func() *test.TestMsg {
+		return &test.TestMsg{}
+	})
+	ctx := context.Background()
+
+	msg := &test.TestMsg{Id: 1, Name: "Alice"}
+	data, err := handler.Encode(msg)
+	require.NoError(t, err)
+	require.NoError(t, handler.Push(ctx, data))
+
+	decoded, err := handler.Decode(popStream(t, handler.RedisStreamQueue))
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(msg, decoded))
+	assert.NoError(t, handler.Handle(ctx, decoded))
+}
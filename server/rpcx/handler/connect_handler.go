@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/server/listener"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 )
@@ -21,6 +22,11 @@ type ConnectHandler struct {
 
 // NewConnectHandler builds a Connect handler.
 func NewConnectHandler(conf *serverconf.RPCConfig, registerMux func(mux *http.ServeMux)) (RPCHandler, error) {
+	return newConnectHandler(conf, nil, registerMux)
+}
+
+// newConnectHandler builds a Connect handler listening with the listener factory.
+func newConnectHandler(conf *serverconf.RPCConfig, listeners *listener.Factory, registerMux func(mux *http.ServeMux)) (RPCHandler, error) {
 	if conf == nil {
 		return nil, ErrEmptyRPCConf
 	}
@@ -44,7 +50,7 @@ func NewConnectHandler(conf *serverconf.RPCConfig, registerMux func(mux *http.Se
 		WriteTimeout: time.Duration(conf.Timeout) * time.Second,
 	}
 
-	ln, err := net.Listen("tcp", conf.Addr)
+	ln, err := listeners.Listen("tcp", conf.Addr)
 	if err != nil {
 		return nil, err
 	}
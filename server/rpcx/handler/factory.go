@@ -6,13 +6,19 @@ import (
 	"net/http"
 
 	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/health"
+	"github.com/hewen/mastiff-go/server/listener"
 	"google.golang.org/grpc"
 )
 
-// RPCBuildParams contains the parameters needed to build a RPC handler.
+// RPCBuildParams contains the parameters needed to build a RPC handler. Health, if set, is
+// served as the grpc.health.v1 service of gRPC handlers. Listeners, if set, is the listener
+// factory the handler listens with, so its listener is handed over on upgrade.
 type RPCBuildParams struct {
 	GrpcRegisterFunc      func(*grpc.Server)
 	ConnectRegisterMux    func(*http.ServeMux)
+	Health                *health.Registry
+	Listeners             *listener.Factory
 	ExtraGrpcInterceptors []grpc.UnaryServerInterceptor
 }
 
@@ -27,12 +33,19 @@ func NewHandler(conf *serverconf.RPCConfig, params RPCBuildParams) (RPCHandler,
 		if params.GrpcRegisterFunc == nil {
 			return nil, fmt.Errorf("grpc: register function is nil")
 		}
-		return NewGrpcHandler(conf, params.GrpcRegisterFunc, params.ExtraGrpcInterceptors...)
+		register := params.GrpcRegisterFunc
+		if params.Health != nil {
+			register = func(s *grpc.Server) {
+				params.GrpcRegisterFunc(s)
+				health.RegisterGRPC(s, params.Health)
+			}
+		}
+		return newGrpcHandler(conf, params.Listeners, register, params.ExtraGrpcInterceptors...)
 	case serverconf.FrameworkConnect:
 		if params.ConnectRegisterMux == nil {
 			return nil, fmt.Errorf("connect: register mux is nil")
 		}
-		return NewConnectHandler(conf, params.ConnectRegisterMux)
+		return newConnectHandler(conf, params.Listeners, params.ConnectRegisterMux)
 	default:
 		return nil, fmt.Errorf("unsupported rpc type: %s", conf.FrameworkType)
 	}
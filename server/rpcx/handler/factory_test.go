@@ -5,8 +5,11 @@ import (
 	"testing"
 
 	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/health"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func TestNewHandler_Connect(t *testing.T) {
@@ -41,6 +44,23 @@ func TestNewHandler_Grpc(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestNewHandler_GrpcHealth(t *testing.T) {
+	var registered []string
+	s, err := NewHandler(&serverconf.RPCConfig{
+		FrameworkType: serverconf.FrameworkGrpc,
+	}, RPCBuildParams{
+		GrpcRegisterFunc: func(*grpc.Server) {
+			registered = append(registered, "app")
+		},
+		Health: health.NewRegistry(),
+	})
+	require.NoError(t, err)
+	defer func() { _ = s.Stop() }()
+
+	assert.Equal(t, []string{"app"}, registered)
+	assert.Contains(t, s.(*GrpcHandler).s.GetServiceInfo(), healthpb.Health_ServiceDesc.ServiceName)
+}
+
 func TestNewHandler_ErrorType(t *testing.T) {
 	s, err := NewHandler(&serverconf.RPCConfig{}, RPCBuildParams{})
 	assert.Nil(t, s)
@@ -8,6 +8,7 @@ import (
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/hewen/mastiff-go/config/serverconf"
 	"github.com/hewen/mastiff-go/middleware"
+	"github.com/hewen/mastiff-go/server/listener"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 )
@@ -24,6 +25,16 @@ func NewGrpcHandler(
 	conf *serverconf.RPCConfig,
 	registerFunc func(*grpc.Server),
 	extraInterceptors ...grpc.UnaryServerInterceptor,
+) (RPCHandler, error) {
+	return newGrpcHandler(conf, nil, registerFunc, extraInterceptors...)
+}
+
+// newGrpcHandler builds a gRPC handler listening with the listener factory.
+func newGrpcHandler(
+	conf *serverconf.RPCConfig,
+	listeners *listener.Factory,
+	registerFunc func(*grpc.Server),
+	extraInterceptors ...grpc.UnaryServerInterceptor,
 ) (RPCHandler, error) {
 	if conf == nil {
 		return nil, ErrEmptyRPCConf
@@ -43,7 +54,7 @@ func NewGrpcHandler(
 		reflection.Register(s)
 	}
 
-	ln, err := net.Listen("tcp", conf.Addr)
+	ln, err := listeners.Listen("tcp", conf.Addr)
 	if err != nil {
 		return nil, err
 	}
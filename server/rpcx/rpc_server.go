@@ -2,11 +2,15 @@
 package rpcx
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
 	"sync"
 
 	"github.com/hewen/mastiff-go/config/serverconf"
 	"github.com/hewen/mastiff-go/logger"
 	"github.com/hewen/mastiff-go/server/rpcx/handler"
+	"google.golang.org/grpc"
 )
 
 // RPCServer is a server that provides a unified RPC abstraction over gRPC and Connect.
@@ -29,13 +33,22 @@ func NewRPCServer(conf *serverconf.RPCConfig, params handler.RPCBuildParams) (*R
 	}, nil
 }
 
-// Start starts the RPCServer.
+// Start starts the RPCServer, logging the error if it fails.
 func (s *RPCServer) Start() {
-	if err := s.handler.Start(); err != nil {
-		s.logger.Panicf("rpc server start failed: %v", err)
+	if err := s.Serve(); err != nil {
+		s.logger.Errorf("%v", err)
 	}
 }
 
+// Serve starts the RPCServer and blocks until it is stopped, returning the error if it fails.
+func (s *RPCServer) Serve() error {
+	err := s.handler.Start()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("rpc server start failed: %w", err)
+	}
+	return nil
+}
+
 // Stop stops the RPCServer.
 func (s *RPCServer) Stop() {
 	s.mu.Lock()
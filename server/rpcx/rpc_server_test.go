@@ -49,9 +49,10 @@ func TestRPCServerStart(t *testing.T) {
 		logger:  logger.NewLogger(),
 	}
 
-	assert.Panics(t, func() {
+	assert.NotPanics(t, func() {
 		s.Start()
 	})
+	assert.ErrorContains(t, s.Serve(), "start error")
 
 	s.Stop()
 	s.WithLogger(logger.NewLogger())
@@ -0,0 +1,305 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hewen/mastiff-go/server/listener"
+)
+
+// errNoListeners is returned when upgrading without a listener factory.
+var errNoListeners = errors.New("no listener factory, see WithListeners")
+
+const (
+	// DefaultShutdownTimeout is the default duration servers and shutdown hooks have to stop.
+	DefaultShutdownTimeout = 30 * time.Second
+)
+
+// WithShutdownTimeout sets the duration all servers and shutdown hooks have to stop.
+func (s *Servers) WithShutdownTimeout(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d > 0 {
+		s.shutdownTimeout = d
+	}
+}
+
+// WithListeners sets the listener factory shared by the servers, so Run reports readiness to
+// the process that started this one by an upgrade and hands its listeners over on upgrade.
+// The servers must be created with the same factory.
+func (s *Servers) WithListeners(f *listener.Factory) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = f
+}
+
+// OnShutdown adds a hook run once all servers stopped, such as closing a store. Hooks run
+// concurrently within the shutdown timeout.
+func (s *Servers) OnShutdown(fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shutdownHooks = append(s.shutdownHooks, fn)
+}
+
+// OnReload adds a hook run on SIGHUP while Run is running, such as reloading the configuration.
+func (s *Servers) OnReload(fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadHooks = append(s.reloadHooks, fn)
+}
+
+// Run starts all registered servers and blocks until ctx is done, SIGINT or SIGTERM is
+// received, Stop is called or the servers stop on their own, then shuts them down within the
+// shutdown timeout. SIGHUP runs the reload hooks. On unix, SIGUSR2 starts a new process of the
+// binary, hands over the listeners of the factory set by WithListeners and, once the new
+// process is ready, shuts this one down. It returns the start and shutdown errors.
+func (s *Servers) Run(ctx context.Context) error {
+	signals := []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+	if upgradeSignal != nil {
+		signals = append(signals, upgradeSignal)
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, signals...)
+	defer signal.Stop(sigs)
+
+	s.mu.Lock()
+	s.init()
+	ready, stopping, listeners := s.ready, s.stopping, s.listeners
+	s.mu.Unlock()
+
+	if listeners != nil {
+		listeners.EnableUpgrade()
+		go s.notifyParent(listeners, ready, stopping)
+	}
+
+	started := make(chan error, 1)
+	go func() {
+		started <- s.start()
+	}()
+
+	var startErr error
+	var stopped bool
+	running := true
+	for running {
+		select {
+		case <-ctx.Done():
+			s.logger.Infof("[server] %v, shutting down", context.Cause(ctx))
+			running = false
+		case sig := <-sigs:
+			switch sig {
+			case syscall.SIGHUP:
+				if err := s.Reload(ctx); err != nil {
+					s.logger.Errorf("[server] reload: %v", err)
+				}
+			case upgradeSignal:
+				if err := s.upgrade(ctx, listeners); err != nil {
+					s.logger.Errorf("[server] upgrade: %v", err)
+					continue
+				}
+				s.logger.Infof("[server] new process ready, shutting down")
+				running = false
+			default:
+				s.logger.Infof("[server] received %s, shutting down", sig)
+				running = false
+			}
+		case <-stopping:
+			stopped = true
+			running = false
+		case startErr = <-started:
+			started = nil
+			running = false
+		}
+	}
+
+	s.mu.Lock()
+	timeout := s.shutdownTimeout
+	s.mu.Unlock()
+
+	var shutdownErr error
+	if stopped {
+		// the shutdown in progress is bounded by the context of its caller.
+		shutdownErr = s.Shutdown(context.Background())
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if !stopped {
+		shutdownErr = s.Shutdown(shutdownCtx)
+	}
+
+	if started != nil {
+		select {
+		case startErr = <-started:
+		case <-shutdownCtx.Done():
+		}
+	}
+	return errors.Join(startErr, shutdownErr)
+}
+
+// upgrade starts a new process of the binary with the listeners of the factory, waiting for it
+// to become ready within the upgrade timeout.
+func (s *Servers) upgrade(ctx context.Context, listeners *listener.Factory) error {
+	if listeners == nil {
+		return errNoListeners
+	}
+	s.logger.Infof("[server] upgrading")
+	ctx, cancel := context.WithTimeout(ctx, listener.DefaultUpgradeTimeout)
+	defer cancel()
+	return listeners.Upgrade(ctx)
+}
+
+// notifyParent reports to the process that started this one by an upgrade that all servers are
+// ready, so it shuts down.
+func (s *Servers) notifyParent(listeners *listener.Factory, ready, stopping <-chan struct{}) {
+	select {
+	case <-ready:
+		if err := listeners.Ready(); err != nil {
+			s.logger.Errorf("[server] notify parent: %v", err)
+		}
+	case <-stopping:
+	}
+}
+
+// Reload runs the reload hooks in the order they were added, returning their errors.
+func (s *Servers) Reload(ctx context.Context) error {
+	s.mu.Lock()
+	hooks := append([]func(ctx context.Context) error(nil), s.reloadHooks...)
+	s.mu.Unlock()
+
+	s.logger.Infof("[server] reloading")
+	errs := make([]error, len(hooks))
+	for i, hook := range hooks {
+		errs[i] = hook(ctx)
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown stops all registered servers, each once the servers depending on it stopped, so
+// independent servers stop concurrently, then runs the shutdown hooks. It returns once done
+// or ctx is done, with the errors of servers that did not stop in time and of hooks. Later
+// calls wait for the first one and return its result.
+func (s *Servers) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.init()
+	select {
+	case <-s.stopping:
+		stopped := s.stopped
+		s.mu.Unlock()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.shutdownErr
+	default:
+		close(s.stopping)
+	}
+	if s.health != nil {
+		s.health.Shutdown()
+	}
+	order := s.order
+	if order == nil {
+		var err error
+		if order, err = resolve(s.s); err != nil {
+			// the servers cannot start, so they are stopped without order.
+			order = s.s
+			for _, e := range order {
+				e.depEntries = nil
+			}
+		}
+	}
+	hooks := append([]func(ctx context.Context) error(nil), s.shutdownHooks...)
+	s.mu.Unlock()
+
+	err := errors.Join(s.stopAll(ctx, order), s.runHooks(ctx, hooks))
+
+	s.mu.Lock()
+	s.shutdownErr = err
+	close(s.stopped)
+	s.mu.Unlock()
+	return err
+}
+
+// stopAll stops the entries concurrently, each once the entries depending on it stopped.
+func (s *Servers) stopAll(ctx context.Context, order []*entry) error {
+	done := make(map[*entry]chan struct{}, len(order))
+	dependents := make(map[*entry][]*entry, len(order))
+	for _, e := range order {
+		done[e] = make(chan struct{})
+		for _, dep := range e.depEntries {
+			dependents[dep] = append(dependents[dep], e)
+		}
+	}
+
+	errs := make([]error, len(order))
+	var group sync.WaitGroup
+	group.Add(len(order))
+	for i, e := range order {
+		go func() {
+			defer group.Done()
+			defer close(done[e])
+			for _, dependent := range dependents[e] {
+				if ch, ok := done[dependent]; ok {
+					<-ch
+				}
+			}
+			errs[i] = s.stop(ctx, e)
+		}()
+	}
+	group.Wait()
+	return errors.Join(errs...)
+}
+
+// stop stops the server of the entry, giving up waiting once its stop timeout elapses or
+// ctx is done.
+func (s *Servers) stop(ctx context.Context, e *entry) error {
+	ctx, cancel := context.WithTimeout(ctx, e.stopTimeout)
+	defer cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		e.server.Stop()
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("stop %s: %w", e.server.Name(), ctx.Err())
+	}
+}
+
+// runHooks runs the shutdown hooks concurrently, giving up waiting once ctx is done.
+func (s *Servers) runHooks(ctx context.Context, hooks []func(ctx context.Context) error) error {
+	errs := make([]error, len(hooks))
+	var group sync.WaitGroup
+	group.Add(len(hooks))
+	for i, hook := range hooks {
+		go func() {
+			defer group.Done()
+
+			done := make(chan error, 1)
+			go func() {
+				done <- hook(ctx)
+			}()
+			select {
+			case errs[i] = <-done:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+			}
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("shutdown hook: %w", errs[i])
+			}
+		}()
+	}
+	group.Wait()
+	return errors.Join(errs...)
+}
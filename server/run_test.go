@@ -0,0 +1,173 @@
+//go:build unix
+
+package server
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hewen/mastiff-go/server/listener"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServersRun(t *testing.T) {
+	rec := &recorder{}
+	db := newLifecycleServer(rec, "db")
+	api := newLifecycleServer(rec, "http")
+
+	servers := NewServers(nil)
+	servers.Add(db)
+	servers.Add(api, DependsOn(db))
+	servers.OnShutdown(func(_ context.Context) error {
+		rec.record("close store")
+		return nil
+	})
+	var reloads atomic.Int32
+	servers.OnReload(func(_ context.Context) error {
+		reloads.Add(1)
+		return errors.New("bad config")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan error, 1)
+	go func() {
+		ran <- servers.Run(ctx)
+	}()
+	<-servers.Ready()
+
+	// SIGHUP reloads without stopping, and a failed reload is only logged.
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+	require.Eventually(t, func() bool {
+		return reloads.Load() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-ran)
+	assert.Equal(t, []string{"start db", "start http", "stop http", "stop db", "close store"}, rec.Events())
+}
+
+func TestServersRunListeners(t *testing.T) {
+	servers := NewServers(nil)
+	assert.ErrorIs(t, servers.upgrade(context.Background(), nil), errNoListeners)
+
+	listeners, err := listener.New()
+	require.NoError(t, err)
+	servers.WithListeners(listeners)
+	servers.Add(newLifecycleServer(&recorder{}, "http"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan error, 1)
+	go func() {
+		ran <- servers.Run(ctx)
+	}()
+	<-servers.Ready()
+	// servers binding their own sockets allow for the upgrades of the factory.
+	assert.True(t, listeners.Upgradable())
+
+	cancel()
+	require.NoError(t, <-ran)
+}
+
+func TestServersRunStartError(t *testing.T) {
+	rec := &recorder{}
+	api := newLifecycleServer(rec, "http")
+	api.holdReady = true
+	api.serveErr = errors.New("listen failed")
+
+	var servers Servers
+	servers.Add(api)
+	err := servers.Run(context.Background())
+	assert.ErrorContains(t, err, "listen failed")
+	assert.Contains(t, rec.Events(), "stop http")
+}
+
+func TestServersShutdownParallel(t *testing.T) {
+	// both servers block in Stop until the other one is stopping too, so a sequential
+	// shutdown would time out.
+	rec := &recorder{}
+	a := newLifecycleServer(rec, "a")
+	b := newLifecycleServer(rec, "b")
+	block := make(chan struct{})
+	a.stopBlock, b.stopBlock = block, block
+	go func() {
+		for len(rec.Events()) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		close(block)
+	}()
+
+	var servers Servers
+	servers.Add(a)
+	servers.Add(b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, servers.Shutdown(ctx))
+	assert.ElementsMatch(t, []string{"stop a", "stop b"}, rec.Events())
+	// later calls return the result of the first one.
+	assert.NoError(t, servers.Shutdown(ctx))
+}
+
+func TestServersShutdownErrors(t *testing.T) {
+	rec := &recorder{}
+	api := newLifecycleServer(rec, "http")
+	api.stopBlock = make(chan struct{})
+	defer close(api.stopBlock)
+
+	var servers Servers
+	servers.Add(api, WithStopTimeout(10*time.Millisecond))
+	servers.WithShutdownTimeout(100 * time.Millisecond)
+	errHook := errors.New("close failed")
+	servers.OnShutdown(func(_ context.Context) error {
+		return errHook
+	})
+	servers.OnShutdown(func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+
+	ran := make(chan error, 1)
+	go func() {
+		ran <- servers.Run(context.Background())
+	}()
+	<-servers.Ready()
+	servers.Stop()
+
+	err := <-ran
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "stop http")
+	assert.ErrorIs(t, err, errHook)
+}
+
+func TestServersRunIndependent(t *testing.T) {
+	// several Servers run in one process without sharing state.
+	first, second := &Servers{}, &Servers{}
+	rec := &recorder{}
+	first.Add(newLifecycleServer(rec, "a"))
+	second.Add(newLifecycleServer(rec, "b"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan error, 2)
+	go func() {
+		ran <- first.Run(ctx)
+	}()
+	go func() {
+		ran <- second.Run(context.Background())
+	}()
+	<-first.Ready()
+	<-second.Ready()
+
+	cancel()
+	require.NoError(t, <-ran)
+	assert.Contains(t, rec.Events(), "stop a")
+	assert.NotContains(t, rec.Events(), "stop b")
+
+	second.Stop()
+	require.NoError(t, <-ran)
+	assert.Contains(t, rec.Events(), "stop b")
+}
@@ -2,13 +2,18 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"log"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
+	"github.com/hewen/mastiff-go/health"
 	"github.com/hewen/mastiff-go/logger"
+	"github.com/hewen/mastiff-go/server/listener"
 
 	// automatically sets GOMAXPROCS to match the Linux container CPU quota.
 	_ "go.uber.org/automaxprocs"
@@ -22,6 +27,9 @@ var (
 )
 
 // Server is an interface that defines methods for starting and stopping a server. It is used to provide a server implementation.
+//
+// Start reports neither errors nor readiness. Servers opt in to both with the Servable and
+// ReadyNotifier interfaces, so existing implementations keep working unchanged.
 type Server interface {
 	Name() string
 	Start()
@@ -29,10 +37,24 @@ type Server interface {
 	WithLogger(l logger.Logger)
 }
 
-// Servers is a collection of Server instances.
+// Servers is a collection of Server instances managing their lifecycle. Servers start once
+// their dependencies are ready and stop after the servers depending on them, within the
+// shutdown timeout. Servers holds no global state, so several can run in one process.
 type Servers struct {
-	logger logger.Logger
-	s      []Server
+	logger          logger.Logger
+	health          *health.Registry
+	listeners       *listener.Factory
+	ready           chan struct{}
+	stopping        chan struct{}
+	stopped         chan struct{}
+	shutdownErr     error
+	s               []*entry
+	order           []*entry
+	shutdownHooks   []func(ctx context.Context) error
+	reloadHooks     []func(ctx context.Context) error
+	shutdownTimeout time.Duration
+	started         bool
+	mu              sync.Mutex
 }
 
 // NewServers creates a new Servers instance.
@@ -41,51 +63,167 @@ func NewServers(l logger.Logger) *Servers {
 		l = logger.NewLogger()
 	}
 	return &Servers{
-		logger: l,
+		logger:          l,
+		shutdownTimeout: DefaultShutdownTimeout,
 	}
 }
 
 // Add adds a server to the list of servers.
-func (s *Servers) Add(server Server) {
-	if s.logger == nil {
-		s.logger = logger.NewLogger()
-	}
+func (s *Servers) Add(server Server, opts ...AddOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
 
-	if _, ok := server.(*LoggingServer); ok {
-		s.s = append(s.s, server)
-		return
+	ls, ok := server.(*LoggingServer)
+	if !ok {
+		ls = &LoggingServer{
+			Inner:  server,
+			Logger: s.logger,
+		}
 	}
 
-	s.s = append(s.s, &LoggingServer{
-		Inner:  server,
-		Logger: s.logger,
-	})
+	e := &entry{
+		server:       ls,
+		ready:        make(chan struct{}),
+		readyTimeout: DefaultReadyTimeout,
+		stopTimeout:  DefaultStopTimeout,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	s.s = append(s.s, e)
 }
 
-// Start starts all registered servers.
-func (s *Servers) Start() {
-	var group sync.WaitGroup
-	group.Add(len(s.s))
+// Start starts all registered servers, each once its dependencies are ready, and blocks
+// until they all stop. If a server fails to start or become ready, the others are stopped
+// and the errors are returned.
+//
+// Start used to return nothing: callers must now handle its error, which breaks code calling
+// it through an interface or a func() value. Start still installs the deprecated handler
+// global to the process that calls the functions added with AddGracefulStop on SIGINT and
+// SIGTERM, once per process. Run does not: it handles signals for its own servers only and
+// shuts them down within the shutdown timeout, so prefer it.
+func (s *Servers) Start() error {
 	gracefulStop()
+	return s.start()
+}
 
-	for i := range s.s {
-		go func(i int) {
+// start starts all registered servers and blocks until they all stop.
+func (s *Servers) start() error {
+	s.mu.Lock()
+	s.init()
+	if s.started {
+		s.mu.Unlock()
+		return ErrAlreadyStarted
+	}
+	order, err := resolve(s.s)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.started = true
+	s.order = order
+	stopping, stopped := s.stopping, s.stopped
+	s.mu.Unlock()
+
+	go s.notifyReady(order, stopping)
+
+	errs := make([]error, len(order))
+	var group sync.WaitGroup
+	group.Add(len(order))
+	for i, e := range order {
+		go func() {
 			defer group.Done()
-			s.s[i].Start()
-		}(i)
+			if errs[i] = s.run(e, stopping); errs[i] != nil {
+				s.logger.Errorf("[server] %v", errs[i])
+				go s.Stop()
+			}
+		}()
 	}
 
 	group.Wait()
+	if err := errors.Join(errs...); err != nil {
+		<-stopped
+		return err
+	}
+	return nil
 }
 
-// Stop stops all registered servers.
+// Stop stops all registered servers within the shutdown timeout, logging the servers that
+// did not stop in time and the errors of shutdown hooks. Servers not started yet are no
+// longer started.
 func (s *Servers) Stop() {
-	for i := range s.s {
-		s.s[i].Stop()
+	s.mu.Lock()
+	s.init()
+	timeout := s.shutdownTimeout
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		s.logger.Errorf("[server] shutdown: %v", err)
+	}
+}
+
+// WithHealth registers a readiness check of the registry passing once all servers are ready,
+// and makes the readiness of the registry fail as soon as the servers start shutting down.
+func (s *Servers) WithHealth(r *health.Registry) {
+	s.mu.Lock()
+	s.health = r
+	s.mu.Unlock()
+
+	r.Register("servers", health.CheckerFunc(func(_ context.Context) error {
+		select {
+		case <-s.Ready():
+			return nil
+		default:
+			return errors.New("servers not ready")
+		}
+	}))
+}
+
+// Ready returns a channel closed once all servers are ready.
+func (s *Servers) Ready() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+
+	return s.ready
+}
+
+// init initializes the fields of a zero Servers.
+func (s *Servers) init() {
+	if s.logger == nil {
+		s.logger = logger.NewLogger()
+	}
+	if s.shutdownTimeout <= 0 {
+		s.shutdownTimeout = DefaultShutdownTimeout
+	}
+	if s.ready == nil {
+		s.ready = make(chan struct{})
+	}
+	if s.stopping == nil {
+		s.stopping = make(chan struct{})
+		s.stopped = make(chan struct{})
+	}
+}
+
+// notifyReady closes the ready channel once all entries are ready.
+func (s *Servers) notifyReady(order []*entry, stopping <-chan struct{}) {
+	for _, e := range order {
+		select {
+		case <-e.ready:
+		case <-stopping:
+			return
+		}
 	}
+	close(s.ready)
 }
 
 // AddGracefulStop registers a function to be called during graceful shutdown.
+//
+// Deprecated: the functions are global to the process and stopped without a timeout. Use
+// Servers.OnShutdown and Servers.Run instead.
 func AddGracefulStop(fn func()) {
 	stopFuncMu.Lock()
 	defer stopFuncMu.Unlock()
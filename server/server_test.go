@@ -1,11 +1,16 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/hewen/mastiff-go/health"
 	"github.com/hewen/mastiff-go/logger"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type MockServers struct{}
@@ -21,12 +26,15 @@ func (*MockServers) Name() string {
 func (s *MockServers) WithLogger(_ logger.Logger) {
 }
 
-func TestServersStart(_ *testing.T) {
+func TestServersStart(t *testing.T) {
 	var servers Servers
 	ms := &MockServers{}
 	servers.Add(ms)
-	servers.Start()
-	servers.Stop()
+	go func() {
+		<-servers.Ready()
+		servers.Stop()
+	}()
+	assert.NoError(t, servers.Start())
 }
 
 func TestServersStop(_ *testing.T) {
@@ -53,3 +61,231 @@ func TestGracefulStop(t *testing.T) {
 	assert.True(t, called)
 	mu.Unlock()
 }
+
+// recorder records lifecycle events of test servers.
+type recorder struct {
+	events []string
+	mu     sync.Mutex
+}
+
+func (r *recorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) Events() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+// lifecycleServer is a Server implementing Servable and ReadyNotifier for lifecycle tests.
+type lifecycleServer struct {
+	rec       *recorder
+	ready     chan struct{}
+	serveErr  error
+	stopped   chan struct{}
+	stopBlock chan struct{}
+	name      string
+	holdReady bool
+	readyOnce sync.Once
+	stopOnce  sync.Once
+}
+
+func newLifecycleServer(rec *recorder, name string) *lifecycleServer {
+	return &lifecycleServer{
+		rec:     rec,
+		name:    name,
+		ready:   make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (s *lifecycleServer) markReady() {
+	s.readyOnce.Do(func() {
+		close(s.ready)
+	})
+}
+
+func (s *lifecycleServer) Name() string { return s.name }
+
+func (s *lifecycleServer) Start() { _ = s.Serve() }
+
+func (s *lifecycleServer) Serve() error {
+	s.rec.record("start " + s.name)
+	if !s.holdReady {
+		s.markReady()
+	}
+	if s.serveErr != nil {
+		return s.serveErr
+	}
+	<-s.stopped
+	return nil
+}
+
+func (s *lifecycleServer) Ready() <-chan struct{} { return s.ready }
+
+func (s *lifecycleServer) Stop() {
+	s.rec.record("stop " + s.name)
+	if s.stopBlock != nil {
+		<-s.stopBlock
+	}
+	s.stopOnce.Do(func() {
+		close(s.stopped)
+	})
+}
+
+func (*lifecycleServer) WithLogger(_ logger.Logger) {}
+
+func TestServersLifecycle(t *testing.T) {
+	rec := &recorder{}
+	db := newLifecycleServer(rec, "db")
+	api := newLifecycleServer(rec, "http")
+	api.holdReady = true
+	queue := newLifecycleServer(rec, "queue")
+
+	servers := NewServers(nil)
+	var checks int
+	servers.Add(queue, DependsOn(db, api), WaitFor(func(_ context.Context) error {
+		checks++
+		if checks == 1 {
+			return errors.New("not yet")
+		}
+		return nil
+	}))
+	servers.Add(api, DependsOn(db))
+	servers.Add(db)
+
+	started := make(chan error, 1)
+	go func() {
+		started <- servers.Start()
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(rec.Events()) == 2
+	}, time.Second, 5*time.Millisecond)
+	// queue waits until http is ready.
+	assert.Equal(t, []string{"start db", "start http"}, rec.Events())
+	api.markReady()
+
+	select {
+	case <-servers.Ready():
+	case <-time.After(3 * time.Second):
+		t.Fatal("servers are not ready")
+	}
+	assert.Equal(t, []string{"start db", "start http", "start queue"}, rec.Events())
+	assert.Equal(t, 2, checks)
+	assert.ErrorIs(t, servers.Start(), ErrAlreadyStarted)
+
+	servers.Stop()
+	servers.Stop()
+	require.NoError(t, <-started)
+	assert.Equal(t, []string{"stop queue", "stop http", "stop db"}, rec.Events()[3:])
+}
+
+func TestServersStartError(t *testing.T) {
+	rec := &recorder{}
+	db := newLifecycleServer(rec, "db")
+	api := newLifecycleServer(rec, "http")
+	api.holdReady = true
+	api.serveErr = errors.New("listen failed")
+	queue := newLifecycleServer(rec, "queue")
+
+	var servers Servers
+	servers.Add(db)
+	servers.Add(api)
+	servers.Add(queue, DependsOn(api))
+
+	err := servers.Start()
+	assert.ErrorContains(t, err, "start http: listen failed")
+	// queue is never started, and the started servers are stopped.
+	assert.NotContains(t, rec.Events(), "start queue")
+	assert.Contains(t, rec.Events(), "stop db")
+}
+
+func TestServersReadyTimeout(t *testing.T) {
+	rec := &recorder{}
+	api := newLifecycleServer(rec, "http")
+	api.holdReady = true
+
+	var servers Servers
+	servers.Add(api, WithReadyTimeout(20*time.Millisecond))
+	err := servers.Start()
+	assert.ErrorIs(t, err, ErrNotReady)
+	assert.Equal(t, []string{"start http", "stop http"}, rec.Events())
+
+	db := newLifecycleServer(rec, "db")
+	var checked Servers
+	checked.Add(db, WaitFor(func(_ context.Context) error {
+		return errors.New("ping failed")
+	}), WithReadyTimeout(20*time.Millisecond))
+	err = checked.Start()
+	assert.ErrorIs(t, err, ErrNotReady)
+	assert.ErrorContains(t, err, "ping failed")
+	assert.NotContains(t, rec.Events(), "start db")
+}
+
+func TestServersStopTimeout(t *testing.T) {
+	rec := &recorder{}
+	api := newLifecycleServer(rec, "http")
+	api.stopBlock = make(chan struct{})
+	defer close(api.stopBlock)
+	db := newLifecycleServer(rec, "db")
+
+	var servers Servers
+	servers.Add(db)
+	servers.Add(api, DependsOn(db), WithStopTimeout(20*time.Millisecond))
+
+	// a server hanging on stop does not keep its dependencies running.
+	servers.Stop()
+	assert.Equal(t, []string{"stop http", "stop db"}, rec.Events())
+
+	// nothing is started once stopped.
+	require.NoError(t, servers.Start())
+	assert.Equal(t, []string{"stop http", "stop db"}, rec.Events())
+}
+
+func TestServersDependencyErrors(t *testing.T) {
+	rec := &recorder{}
+	a := newLifecycleServer(rec, "a")
+	b := newLifecycleServer(rec, "b")
+
+	var servers Servers
+	servers.Add(a, DependsOn(newLifecycleServer(rec, "c")))
+	assert.ErrorIs(t, servers.Start(), ErrUnknownDependency)
+
+	var cyclic Servers
+	cyclic.Add(a, DependsOn(b))
+	cyclic.Add(&LoggingServer{Inner: b, Logger: logger.NewLogger()}, DependsOn(a))
+	assert.ErrorIs(t, cyclic.Start(), ErrDependencyCycle)
+	assert.Empty(t, rec.Events())
+}
+
+func TestServersWithHealth(t *testing.T) {
+	rec := &recorder{}
+	api := newLifecycleServer(rec, "http")
+	api.holdReady = true
+
+	r := health.NewRegistry()
+	var servers Servers
+	servers.Add(api)
+	servers.WithHealth(r)
+
+	ctx := context.Background()
+	assert.False(t, r.Ready(ctx).Up())
+
+	started := make(chan error, 1)
+	go func() {
+		started <- servers.Start()
+	}()
+	api.markReady()
+	<-servers.Ready()
+	assert.True(t, r.Ready(ctx).Up())
+
+	servers.Stop()
+	require.NoError(t, <-started)
+	report := r.Ready(ctx)
+	assert.False(t, report.Up())
+	assert.Equal(t, health.ErrShuttingDown.Error(), report.Checks[0].Error)
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package server
+
+import "os"
+
+// upgradeSignal is nil, as only unix processes can be upgraded.
+var upgradeSignal os.Signal
@@ -0,0 +1,11 @@
+//go:build unix
+
+package server
+
+import (
+	"os"
+	"syscall"
+)
+
+// upgradeSignal is the signal Run upgrades the process on.
+var upgradeSignal os.Signal = syscall.SIGUSR2
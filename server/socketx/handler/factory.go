@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/hewen/mastiff-go/config/serverconf"
+	"github.com/hewen/mastiff-go/server/listener"
 	"github.com/panjf2000/gnet/v2"
 )
 
@@ -13,9 +14,11 @@ var (
 	ErrEmptySocketConf = fmt.Errorf("empty socket conf")
 )
 
-// BuildParams contains the parameters needed to build a socket handler.
+// BuildParams contains the parameters needed to build a socket handler. Listeners, if set, is
+// the listener factory of the process, whose upgrades the handler allows for.
 type BuildParams struct {
 	GnetHandler gnet.EventHandler
+	Listeners   *listener.Factory
 }
 
 // NewHandler creates a handler from registered builders for different socket frameworks.
@@ -30,7 +33,12 @@ func NewHandler(conf *serverconf.SocketConfig, params BuildParams) (SocketHandle
 			return nil, fmt.Errorf("gnet: handler is nil")
 		}
 
-		return NewGnetHandler(conf, params.GnetHandler)
+		h, err := NewGnetHandler(conf, params.GnetHandler)
+		if err != nil {
+			return nil, err
+		}
+		h.listeners = params.Listeners
+		return h, nil
 	default:
 		return nil, fmt.Errorf("unsupported socket type: %s", conf.FrameworkType)
 	}
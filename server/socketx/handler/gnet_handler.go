@@ -8,17 +8,19 @@ import (
 
 	"github.com/hewen/mastiff-go/config/serverconf"
 	"github.com/hewen/mastiff-go/logger"
+	"github.com/hewen/mastiff-go/server/listener"
 	"github.com/panjf2000/gnet/v2"
 )
 
 // GnetHandler is a handler that provides a unified socket abstraction over gnet.
 type GnetHandler struct {
-	logger logger.Logger
-	conf   *serverconf.SocketConfig
-	event  gnet.EventHandler
-	engine gnet.Engine
-	name   string
-	addr   string
+	logger    logger.Logger
+	conf      *serverconf.SocketConfig
+	listeners *listener.Factory
+	event     gnet.EventHandler
+	engine    gnet.Engine
+	name      string
+	addr      string
 }
 
 // OnBoot is called once when the engine starts.
@@ -80,9 +82,15 @@ func (h *GnetHandler) Name() string {
 	return fmt.Sprintf("socket %s server(%s)", h.name, h.addr)
 }
 
-// Start starts the GnetHandler.
+// Start starts the GnetHandler. gnet binds its own sockets, which cannot be handed over to a
+// new process, so SO_REUSEPORT is set when the listener factory allows upgrades, letting
+// the new process bind the address while this one drains.
 func (h *GnetHandler) Start() error {
-	return gnet.Run(h, h.conf.Addr, gnet.WithOptions(h.conf.GnetOptions))
+	opts := h.conf.GnetOptions
+	if h.listeners.Upgradable() {
+		opts.ReusePort = true
+	}
+	return gnet.Run(h, h.conf.Addr, gnet.WithOptions(opts))
 }
 
 // Stop stops the GnetHandler.
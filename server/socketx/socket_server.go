@@ -2,6 +2,7 @@
 package socketx
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/hewen/mastiff-go/config/serverconf"
@@ -28,11 +29,19 @@ func NewSocketServer(conf *serverconf.SocketConfig, params handler.BuildParams)
 	}, nil
 }
 
-// Start starts the SocketServer.
+// Start starts the SocketServer, logging the error if it fails.
 func (s *SocketServer) Start() {
+	if err := s.Serve(); err != nil {
+		s.logger.Errorf("%v", err)
+	}
+}
+
+// Serve starts the SocketServer and blocks until it is stopped, returning the error if it fails.
+func (s *SocketServer) Serve() error {
 	if err := s.handler.Start(); err != nil {
-		s.logger.Panicf("socket server start failed: %v", err)
+		return fmt.Errorf("socket server start failed: %w", err)
 	}
+	return nil
 }
 
 // Stop stops the SocketServer.
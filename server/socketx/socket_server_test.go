@@ -114,9 +114,10 @@ func TestSocketServer_Start_Error(t *testing.T) {
 	mockHandler.On("Start").Return(expectedError)
 
 	// Test Start - should not panic even when handler.Start() returns error
-	assert.Panics(t, func() {
+	assert.NotPanics(t, func() {
 		server.Start()
 	})
+	assert.ErrorIs(t, server.Serve(), expectedError)
 
 	// Verify that Start was called
 	mockHandler.AssertExpectations(t)